@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"gossh/internal/controller"
+	"gossh/internal/view"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchCommand    string
+	benchForksSweep []int
+	benchLogDir     string
+	benchLimit      int
+	benchOffset     int
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "测试不同并发数下的执行耗时，帮助选择 --forks",
+	Long: `依次以 --forks-sweep 中的每个并发数对所有主机执行同一条命令（默认: true），
+输出各并发级别的总耗时、成功率和单台主机平均耗时的对比表格，并推荐成功率最高且耗时最短的并发数。
+
+示例:
+  # 测试默认的 5、10、20、50 四个并发级别
+  gossh bench -i hosts.ini -g web_servers -u root
+
+  # 指定测试的并发数和命令
+  gossh bench -i hosts.ini -g all -u root -c "uptime" --forks-sweep 10,30,100
+
+  # 只用前 50 台主机测试
+  gossh bench -i hosts.ini -g all -u root --forks-sweep 5,10,20 --limit 50`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 创建 controller
+		ctrl := controller.NewBenchController()
+
+		// 构建请求
+		req := &controller.BenchCommandRequest{
+			ConfigFile: configFile,
+			Inventory:  inventory,
+			Group:      group,
+			User:       user,
+			KeyPath:    keyPath,
+			Password:   password,
+			Port:       port,
+			TOTP:       totpCode,
+			TOTPSecret: totpSecret,
+			TOTPPrompt: totpPrompt,
+			Command:    benchCommand,
+			ForksSweep: benchForksSweep,
+			LogDir:     benchLogDir,
+			Limit:      benchLimit,
+			Offset:     benchOffset,
+			Shuffle:    shuffle,
+			Seed:       shuffleSeed,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+		}
+
+		// 执行命令
+		resp, err := ctrl.Execute(req)
+		if err != nil {
+			return err
+		}
+
+		// 输出结果
+		view.PrintBenchResults(resp.Levels, resp.TotalDuration, resp.Group, resp.HostCount)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	// bench 相关参数
+	benchCmd.Flags().StringVarP(&benchCommand, "command", "c", "true", "每个并发级别执行的命令（默认: true，只测试连接和会话开销）")
+	benchCmd.Flags().IntSliceVar(&benchForksSweep, "forks-sweep", []int{5, 10, 20, 50}, "依次测试的并发数，逗号分隔（默认: 5,10,20,50）")
+	benchCmd.Flags().StringVar(&benchLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：bench-时间戳.log")
+	benchCmd.Flags().IntVar(&benchLimit, "limit", 0, "限制测试的主机数量（0 表示不限制）")
+	benchCmd.Flags().IntVar(&benchOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(benchCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"gossh/internal/controller"
+	"gossh/internal/view"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	checksumRemotePath string
+	checksumAlgorithm  string
+	checksumLogDir     string
+	checksumLimit      int
+	checksumOffset     int
+)
+
+// checksumCmd represents the checksum command
+var checksumCmd = &cobra.Command{
+	Use:   "checksum",
+	Short: "批量计算远程文件的校验和",
+	Long: `批量 SSH 连接到多台服务器，计算远程文件的校验和（sha256sum 或 md5sum）。
+校验和相同的主机会归为一组，便于审计时发现配置漂移。
+远程文件不存在和没有读取权限的主机会分别列出。
+
+示例:
+  # 检查各主机上的配置文件是否一致
+  gossh checksum -i hosts.ini -g web_servers -u root -r /etc/app.conf
+
+  # 使用 -g all 选择所有分组的主机
+  gossh checksum -i hosts.txt -g all -u root -k ~/.ssh/id_rsa -r /usr/local/bin/app
+
+  # 使用 md5 算法
+  gossh checksum -i hosts.txt -g all -u root -r /etc/app.conf --algorithm md5
+
+  # 只检查前 5 台主机
+  gossh checksum -i hosts.txt -g all -u root -r /etc/app.conf --limit 5`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 创建 controller
+		ctrl := controller.NewChecksumController()
+
+		// 构建请求
+		req := &controller.ChecksumCommandRequest{
+			ConfigFile:  configFile,
+			Inventory:   inventory,
+			Group:       group,
+			User:        user,
+			KeyPath:     keyPath,
+			Password:    password,
+			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
+			RemotePath:  checksumRemotePath,
+			Algorithm:   checksumAlgorithm,
+			Concurrency: forks,
+			LogDir:      checksumLogDir,
+			Limit:       checksumLimit,
+			Offset:      checksumOffset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+		}
+
+		// 执行命令
+		resp, err := ctrl.Execute(req)
+		if err != nil {
+			return err
+		}
+
+		// 输出结果
+		view.PrintChecksumResults(resp.Results, resp.TotalDuration, resp.Group, resp.Hosts)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checksumCmd)
+
+	// 校验和相关参数
+	checksumCmd.Flags().StringVarP(&checksumRemotePath, "remote", "r", "", "远程文件路径（必需）")
+	checksumCmd.MarkFlagRequired("remote")
+	checksumCmd.Flags().StringVar(&checksumAlgorithm, "algorithm", "sha256", "校验和算法: sha256 或 md5（默认: sha256）")
+	checksumCmd.Flags().StringVar(&checksumLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：checksum-时间戳.log")
+	checksumCmd.Flags().IntVar(&checksumLimit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
+	checksumCmd.Flags().IntVar(&checksumOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(checksumCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"gossh/internal/controller"
+	"gossh/internal/view"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "显示实际生效的配置（不连接主机）",
+	Long: `按其他命令相同的优先级（命令行参数 > ~/.gossh.yaml > ansible.cfg > 默认值）合并配置，
+显示实际使用的 ansible.cfg 路径、主机列表、用户名、私钥、并发数和超时，以及每项的来源。
+只读取本地文件，不连接任何主机，用于排查"用错了 inventory"等问题。
+
+示例:
+  # 查看当前目录下会使用哪个 ansible.cfg 和 inventory
+  gossh config
+
+  # 与其他命令使用相同的参数，查看合并后的结果和匹配的主机数
+  gossh config -i hosts.ini -g web -u deploy -f 20`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 创建 controller
+		ctrl := controller.NewConfigController()
+
+		// 构建请求
+		req := &controller.ConfigRequest{
+			ConfigFile:     configFile,
+			Inventory:      inventory,
+			Group:          group,
+			User:           user,
+			KeyPath:        keyPath,
+			Password:       password,
+			Port:           port,
+			Concurrency:    forks,
+			Timeout:        timeout,
+			ConnectTimeout: connectTimeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+
+			GosshConfig: gosshConfigPath,
+			FlagSources: flagSources(cmd),
+		}
+
+		// 执行 config 命令
+		resp, err := ctrl.Execute(req)
+		if err != nil {
+			return err
+		}
+
+		// 输出结果
+		view.PrintResolvedConfig(resp.Items)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
+
+// flagSources 返回显式设置的参数（key 为参数长名称）的来源：命令行参数或 gossh 配置文件
+// gossh 配置文件设置的参数不算作 Changed，只记录在 gosshConfigFlags 中
+func flagSources(cmd *cobra.Command) map[string]string {
+	sources := make(map[string]string)
+	for name := range gosshConfigFlags {
+		sources[name] = "gossh 配置文件"
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		sources[f.Name] = "命令行参数"
+	})
+	return sources
+}
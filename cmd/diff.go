@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"gossh/internal/controller"
+	"gossh/internal/view"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffLocalPath  string
+	diffRemotePath string
+	diffLogDir     string
+	diffLimit      int
+	diffOffset     int
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "对比本地文件与远程文件的差异",
+	Long: `批量 SSH 连接到多台服务器，对比本地文件与远程文件的差异。
+本地文件内容会发送到远程主机，由远程的 diff -u 计算统一格式差异，差异相同的主机会合并显示。
+远程文件不存在的主机会单独列出。
+
+示例:
+  # 覆盖配置前，对比本地配置与各主机上的配置文件
+  gossh diff -i hosts.ini -g web_servers -u root -l app.conf -r /etc/app.conf
+
+  # 使用 -g all 选择所有分组的主机
+  gossh diff -i hosts.txt -g all -u root -k ~/.ssh/id_rsa -l app.conf -r /etc/app.conf
+
+  # 从命令行参数指定主机（逗号分隔，也需要指定 -g）
+  gossh diff -i "192.168.1.10,192.168.1.11" -g all -u root -l app.conf -r /etc/app.conf
+
+  # 只对比前 5 台主机
+  gossh diff -i hosts.txt -g all -u root -l app.conf -r /etc/app.conf --limit 5`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 创建 controller
+		ctrl := controller.NewDiffController()
+
+		// 构建请求
+		req := &controller.DiffCommandRequest{
+			ConfigFile:  configFile,
+			Inventory:   inventory,
+			Group:       group,
+			User:        user,
+			KeyPath:     keyPath,
+			Password:    password,
+			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
+			LocalPath:   diffLocalPath,
+			RemotePath:  diffRemotePath,
+			Concurrency: forks,
+			LogDir:      diffLogDir,
+			Limit:       diffLimit,
+			Offset:      diffOffset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+		}
+
+		// 执行命令
+		resp, err := ctrl.Execute(req)
+		if err != nil {
+			return err
+		}
+
+		// 输出结果
+		view.PrintDiffResults(resp.Results, resp.TotalDuration, resp.Group, resp.Hosts)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	// 对比相关参数
+	diffCmd.Flags().StringVarP(&diffLocalPath, "local", "l", "", "本地文件路径（必需）")
+	diffCmd.MarkFlagRequired("local")
+	diffCmd.Flags().StringVarP(&diffRemotePath, "remote", "r", "", "远程文件路径（必需）")
+	diffCmd.MarkFlagRequired("remote")
+	diffCmd.Flags().StringVar(&diffLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：diff-时间戳.log")
+	diffCmd.Flags().IntVar(&diffLimit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
+	diffCmd.Flags().IntVar(&diffOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(diffCmd)
+}
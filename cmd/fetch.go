@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"gossh/internal/controller"
+	"gossh/internal/view"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fetchRemotePath string
+	fetchDestDir    string
+	fetchArchive    string
+	fetchBecome     bool
+	fetchBecomeUser string
+	fetchSudoFlags  string
+	fetchLogDir     string
+	fetchLimit      int
+	fetchOffset     int
+)
+
+// fetchCmd represents the fetch command
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "批量下载远程文件",
+	Long: `批量 SSH 连接到多台服务器，并发下载同一个远程文件到本地。
+本地文件名为 <主机>-<文件名>-<时间戳>（如 192.168.1.10-app.log-20260101-120000），
+同一次执行的所有文件使用相同的时间戳；非 22 端口的主机名为 <主机>_<端口>。
+使用 --archive 可以将下载的文件打包为一个 tar.gz。
+
+示例:
+  # 将各主机的应用日志下载到 ./logs 目录
+  gossh fetch -i hosts.ini -g web_servers -u root -r /var/log/app.log -d ./logs
+
+  # 下载并打包为一个文件（未指定 -d 时不保留单独的文件）
+  gossh fetch -i hosts.txt -g all -u root -r /var/log/app.log --archive app-logs.tar.gz
+
+  # 登录用户无权限读取时，使用 sudo 读取
+  gossh fetch -i hosts.txt -g all -u deploy -r /var/log/messages --become -d ./logs`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 创建 controller
+		ctrl := controller.NewFetchController()
+
+		// 构建请求
+		req := &controller.FetchCommandRequest{
+			ConfigFile:  configFile,
+			Inventory:   inventory,
+			Group:       group,
+			User:        user,
+			KeyPath:     keyPath,
+			Password:    password,
+			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
+			RemotePath:  fetchRemotePath,
+			DestDir:     fetchDestDir,
+			Archive:     fetchArchive,
+			Become:      fetchBecome,
+			BecomeUser:  fetchBecomeUser,
+			SudoFlags:   fetchSudoFlags,
+			Concurrency: forks,
+			LogDir:      fetchLogDir,
+			Limit:       fetchLimit,
+			Offset:      fetchOffset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+		}
+
+		// 执行命令
+		resp, err := ctrl.Execute(req)
+		if err != nil {
+			return err
+		}
+
+		// 输出结果
+		view.PrintFetchResults(resp.Results, resp.TotalDuration, resp.Group, resp.Hosts, resp.DestDir, resp.Archive)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+
+	// 下载相关参数
+	fetchCmd.Flags().StringVarP(&fetchRemotePath, "remote", "r", "", "远程文件路径（必需）")
+	fetchCmd.MarkFlagRequired("remote")
+	fetchCmd.Flags().StringVarP(&fetchDestDir, "dest", "d", "", "本地保存目录，不存在时自动创建（默认: 当前目录；只指定 --archive 时不保留单独的文件）")
+	fetchCmd.Flags().StringVar(&fetchArchive, "archive", "", "将下载的文件打包为 tar.gz（如 out.tar.gz）")
+	fetchCmd.Flags().BoolVar(&fetchBecome, "become", false, "使用 sudo 读取远程文件（用于登录用户无权限读取的文件）")
+	fetchCmd.Flags().StringVar(&fetchBecomeUser, "become-user", "", "使用 sudo 切换到指定用户读取远程文件（默认: root）")
+	fetchCmd.Flags().StringVar(&fetchSudoFlags, "sudo-flags", "", "追加到 sudo 的额外参数（需配合 --become），例如: \"-H\"。目标用户只能通过 --become-user 指定")
+	fetchCmd.Flags().StringVar(&fetchLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：fetch-时间戳.log")
+	fetchCmd.Flags().IntVar(&fetchLimit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
+	fetchCmd.Flags().IntVar(&fetchOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(fetchCmd)
+}
@@ -8,7 +8,8 @@ import (
 )
 
 var (
-	listGroupOneLine bool // 是否一行输出（逗号分隔）
+	listGroupOneLine   bool // 是否一行输出（逗号分隔）
+	listGroupCountOnly bool // 只输出组的数量
 )
 
 // listGroupCmd represents the list-group command
@@ -26,7 +27,10 @@ var listGroupCmd = &cobra.Command{
   gossh list-group -i hosts_dir/
 
   # 从 ansible.cfg 配置的 inventory 列出所有组
-  gossh list-group`,
+  gossh list-group
+
+  # 只输出组的数量
+  gossh list-group -i hosts.ini --count-only`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 创建 controller
 		ctrl := controller.NewListGroupController()
@@ -44,7 +48,7 @@ var listGroupCmd = &cobra.Command{
 		}
 
 		// 输出结果
-		view.PrintGroupResults(resp.Groups, listGroupOneLine)
+		view.PrintGroupResults(resp.Groups, listGroupOneLine, listGroupCountOnly)
 
 		return nil
 	},
@@ -55,5 +59,8 @@ func init() {
 
 	// 一行输出参数
 	listGroupCmd.Flags().BoolVar(&listGroupOneLine, "one-line", false, "一行输出（逗号分隔）")
+
+	// 只输出数量参数
+	listGroupCmd.Flags().BoolVar(&listGroupCountOnly, "count-only", false, "只输出组的数量（一个整数），忽略 --one-line")
 }
 
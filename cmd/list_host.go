@@ -8,8 +8,10 @@ import (
 )
 
 var (
-	listHostFormat string // 输出格式: ip, full, json
+	listHostFormat string // 输出格式: ip, full, wide, json
 	listHostOneLine bool   // 是否一行输出（逗号分隔）
+
+	listHostCountOnly bool // 只输出主机数量
 )
 
 // listHostCmd represents the list-host command
@@ -32,8 +34,11 @@ var listHostCmd = &cobra.Command{
   # 从命令行参数列出主机（逗号分隔，也需要指定 -g）
   gossh list-host -i "192.168.1.10,192.168.1.11" -g all
 
-  # 指定输出格式（ip/full/json）
-  gossh list-host -i ansible_hosts -g test --format full`,
+  # 指定输出格式（ip/full/wide/json）
+  gossh list-host -i ansible_hosts -g test --format full
+
+  # 只输出主机数量（便于脚本使用）
+  gossh list-host -i ansible_hosts -g web_servers --count-only`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 创建 controller
 		ctrl := controller.NewListController()
@@ -44,6 +49,12 @@ var listHostCmd = &cobra.Command{
 			Inventory:  inventory,
 			Group:      group,
 			Format:     listHostFormat,
+			CountOnly:  listHostCountOnly,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
 		}
 
 		// 执行 list-host 命令
@@ -53,7 +64,7 @@ var listHostCmd = &cobra.Command{
 		}
 
 		// 输出结果
-		view.PrintListResults(resp.Hosts, listHostFormat, listHostOneLine)
+		view.PrintListResults(resp.Hosts, listHostFormat, listHostOneLine, listHostCountOnly)
 
 		return nil
 	},
@@ -63,8 +74,11 @@ func init() {
 	rootCmd.AddCommand(listHostCmd)
 
 	// 输出格式参数
-	listHostCmd.Flags().StringVar(&listHostFormat, "format", "ip", "输出格式: ip（仅IP地址）、full（完整信息）、json（JSON格式）")
+	listHostCmd.Flags().StringVar(&listHostFormat, "format", "ip", "输出格式: ip（仅IP地址）、full（完整信息，包括所属分组）、wide（full 加上主机级的连接超时、解释器和 become）、json（JSON格式）")
 	// 一行输出参数
 	listHostCmd.Flags().BoolVar(&listHostOneLine, "one-line", false, "一行输出（逗号分隔）")
+
+	// 只输出数量参数
+	listHostCmd.Flags().BoolVar(&listHostCountOnly, "count-only", false, "只输出匹配的主机数量（一个整数，按 -g 分组筛选后计数），忽略 --format 和 --one-line")
 }
 
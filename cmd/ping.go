@@ -7,7 +7,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// ping 命令没有额外的局部变量，全部使用全局变量
+var (
+	pingShowPhases bool
+	pingOutput     string
+
+	pingCheckBecome    bool
+	pingBecomeUser     string
+	pingSudoFlags      string
+	pingBecomePassword string
+	pingBecomePrompt   string
+)
 
 // pingCmd represents the ping command
 var pingCmd = &cobra.Command{
@@ -30,7 +39,17 @@ var pingCmd = &cobra.Command{
   gossh ping -i "192.168.1.10,192.168.1.11" -g all -u root
 
   # 指定并发数
-  gossh ping -i hosts.txt -g all -u root -f 10`,
+  gossh ping -i hosts.txt -g all -u root -f 10
+
+  # 显示 TCP 连接、SSH 握手、创建会话各阶段的耗时，定位慢主机的原因
+  gossh ping -i hosts.txt -g all -u root --phases
+
+  # 正式执行 --become 之前，检查各主机上的 sudo 是否可用（免密 sudo，或使用提供的 sudo 密码）
+  gossh ping -i hosts.txt -g all -u deploy --check-become
+  gossh ping -i hosts.txt -g all -u deploy --check-become --become-user appuser --become-password "$SUDO_PASS"
+
+  # 以 JSON 输出结果，便于接入监控系统
+  gossh ping -i hosts.txt -g all -u root --output json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 创建 controller
 		ctrl := controller.NewPingController()
@@ -44,8 +63,30 @@ var pingCmd = &cobra.Command{
 			KeyPath:     keyPath,
 			Password:    password,
 			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
 			Concurrency: forks,
 			Timeout:     timeout,
+
+			ConnectTimeout: connectTimeout,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			CheckBecome:    pingCheckBecome,
+			BecomeUser:     pingBecomeUser,
+			SudoFlags:      pingSudoFlags,
+			BecomePassword: pingBecomePassword,
+			BecomePrompt:   pingBecomePrompt,
+
+			Output: pingOutput,
 		}
 
 		// 执行 ping 测试
@@ -55,7 +96,7 @@ var pingCmd = &cobra.Command{
 		}
 
 		// 输出结果
-		view.PrintPingResults(resp.Results, resp.TotalDuration, resp.Group, resp.Hosts)
+		view.PrintPingResults(resp.Results, resp.TotalDuration, resp.Group, resp.Hosts, pingShowPhases, pingCheckBecome, pingOutput)
 
 		return nil
 	},
@@ -63,5 +104,12 @@ var pingCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(pingCmd)
-	// ping 命令使用全局参数，无需额外定义
+
+	pingCmd.Flags().BoolVar(&pingShowPhases, "phases", false, "显示各阶段耗时（TCP 连接、SSH 握手、创建会话），用于区分网络、加密握手和服务器负载造成的慢")
+	pingCmd.Flags().BoolVar(&pingCheckBecome, "check-become", false, "连接成功后检查 sudo 是否可用：以 sudo 执行 id -u 并确认 UID 与目标用户一致，没有 --become-password 时加 -n（需要密码的主机立即失败）。结果显示在 sudo 列中，sudo 不可用不计入连接失败")
+	pingCmd.Flags().StringVar(&pingBecomeUser, "become-user", "", "检查 sudo 时的目标用户（需配合 --check-become，默认: root）")
+	pingCmd.Flags().StringVar(&pingSudoFlags, "sudo-flags", "", "检查 sudo 时追加到 sudo 的额外参数（需配合 --check-become），例如: \"-H\"")
+	pingCmd.Flags().StringVar(&pingBecomePassword, "become-password", "", "sudo 密码（需配合 --check-become），只有在标准错误中检测到密码提示符时才发送")
+	pingCmd.Flags().StringVar(&pingBecomePrompt, "become-prompt", "", "检测 sudo 密码提示符的正则表达式（需配合 --become-password，默认: (?i)password）")
+	pingCmd.Flags().StringVar(&pingOutput, "output", "table", "输出格式: table（表格）、json（JSON 数组，每台主机一个 {host, success, duration_ms, error} 对象，不输出配置参数和进度条）")
 }
@@ -5,23 +5,91 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
+	"gossh/internal/config"
+	"gossh/internal/controller"
+	"gossh/internal/logger"
+	"gossh/internal/view"
+
 	"github.com/spf13/cobra"
 )
 
 // 全局参数（所有子命令都可以访问）
 var (
-	configFile string        // 配置文件路径
-	inventory  string        // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	group      string        // Ansible INI 格式的分组名称
-	user       string        // SSH 用户名
-	keyPath    string        // SSH 私钥路径
-	password   string        // SSH 密码
-	port       string        // SSH 端口
-	forks      int           // 并发数（类似 ansible 的 -f --forks）
-	timeout    time.Duration // 连接超时时间（类似 ansible 的 -T --timeout）
+	configFile   string        // 配置文件路径
+	inventory    []string      // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	group        string        // Ansible INI 格式的分组名称
+	user         string        // SSH 用户名
+	keyPath      string        // SSH 私钥路径
+	password     string        // SSH 密码
+	port         string        // SSH 端口
+	forks        int           // 并发数（类似 ansible 的 -f --forks）
+	timeout      time.Duration // 单台主机的操作总时间（类似 ansible 的 -T --timeout；ping 默认 30s，其他命令默认不限制）
+	totpCode     string        // 二次验证码（TOTP）
+	totpSecret   string        // TOTP 密钥（Base32）
+	totpPrompt   bool          // 交互式输入二次验证码
+	tableWidth   int           // 表格宽度（0 表示自动检测终端宽度）
+	progress     string        // 进度显示方式: auto、bar、plain、none
+	progressFD   int           // 输出进度事件（NDJSON）的文件描述符（0 表示不输出）
+	configOutput string        // 执行前打印配置参数的格式: table、json
+	verbose      int           // 调试日志级别（-v: 调试信息，-vv: 额外输出每台主机的连接过程）
+
+	connectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手）
+	mergeStrategy  string        // 同一主机在多个 inventory 来源中重复定义时的合并策略
+	preResolve     bool          // 执行前预先解析所有主机名
+	varsDir        string        // Ansible 风格的 vars 目录（host_vars、group_vars）
+	proxyCommand   string        // ProxyCommand 模板，通过该命令的标准输入输出连接主机
+	hostKeyCheck   string        // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking
+	identityAgent  string        // ssh-agent 的 socket 路径，为空时不使用 ssh-agent
+	noDefaultKey   bool          // 未指定认证方式时不尝试 ~/.ssh 下的默认私钥
+
+	reuseConnections bool // 复用同一主机的空闲连接
+
+	exclude     []string // 要排除的主机（address 或 address:port）
+	excludeFile string   // 要排除的主机列表文件
+)
+
+// 钩子参数（run、script、upload 命令共用）
+var (
+	preHook          string // 执行前调用的本地可执行文件
+	postHook         string // 执行后调用的本地可执行文件
+	ignoreHookErrors bool   // 忽略 pre-hook 的失败
+	onSuccess        string // 每台主机执行成功后执行的本地命令模板
+	onFailure        string // 每台主机执行失败后执行的本地命令模板
+)
+
+// 确认参数（run、script、upload 命令共用）
+var (
+	confirmOver int  // 主机数超过该值时要求确认（0 表示不确认）
+	assumeYes   bool // 跳过确认
+)
+
+// 执行记录参数（run、script、upload 命令共用）
+var historyDB string // 执行结果追加写入的 SQLite 数据库文件
+
+// 只列出目标主机参数（run、script、upload 命令共用）
+var (
+	listOnly        bool   // 只输出将要操作的主机，不执行
+	listOnlyFormat  string // 输出格式: ip、full、wide、json（同 list-host 的 --format）
+	listOnlyOneLine bool   // 一行输出（逗号分隔）
+)
+
+// 整批执行的总时间上限（run、script、upload 命令共用）
+var deadline time.Duration
+
+// 打乱主机顺序参数（run、script、upload、diff、checksum、fetch、bench 命令共用）
+var (
+	shuffle     bool  // 执行前随机打乱主机顺序
+	shuffleSeed int64 // 打乱主机顺序的随机种子（0 表示每次随机）
+)
+
+// gossh 配置文件（config 命令用于说明参数的来源）
+var (
+	gosshConfigPath  string          // 使用的 gossh 配置文件路径，没有配置文件时为空
+	gosshConfigFlags map[string]bool // 从 gossh 配置文件设置了值的参数
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -41,13 +109,42 @@ var rootCmd = &cobra.Command{
   gossh run -i hosts.txt -g all -u root -k ~/.ssh/id_rsa -c "uptime"
   gossh run -i "192.168.1.10,192.168.1.11" -g all -u root -c "df -h"`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// list-group 命令不需要 group 参数，跳过验证
-		if cmd.Name() == "list-group" {
+		// 读取 gossh 配置文件中的默认值（优先级：命令行参数 > ~/.gossh.yaml > ansible.cfg > 默认值）
+		gosshCfgPath, err := applyGosshConfig(cmd)
+		if err != nil {
+			return err
+		}
+		gosshConfigPath = gosshCfgPath
+
+		// 设置调试日志（-v / -vv，输出到 stderr）
+		logger.SetupVerbose(verbose)
+		if gosshCfgPath != "" {
+			slog.Debug("使用 gossh 配置文件", "path", gosshCfgPath)
+		}
+
+		// 设置表格宽度（未指定时根据终端宽度自动调整）
+		view.SetTableWidth(tableWidth)
+
+		// 设置进度显示方式（默认标准输出不是终端时逐行输出）
+		if err := view.SetProgressMode(progress); err != nil {
+			return err
+		}
+		// 设置进度事件输出（供 GUI 等外部程序读取，与进度显示方式无关）
+		if err := view.SetProgressFD(progressFD); err != nil {
+			return err
+		}
+		// 设置执行前打印配置参数的格式
+		if err := view.SetConfigOutput(configOutput); err != nil {
+			return err
+		}
+
+		// list-group 和 config 命令不需要 group 参数，跳过验证
+		if cmd.Name() == "list-group" || cmd.Name() == "config" {
 			return nil
 		}
 		// 如果 inventory 是文件或目录路径，则需要 group 参数
 		// 如果 inventory 是IP地址（直接指定），则不需要 group 参数
-		if isInventoryFileOrDir(inventory) && group == "" {
+		if hasInventoryFileOrDir(inventory) && group == "" {
 			return fmt.Errorf("必须指定分组名称 (-g)。使用 -g all 表示选择所有分组，支持逗号分隔的多个组，例如: -g test,web_servers")
 		}
 		return nil
@@ -68,28 +165,161 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&configFile, "config-file", "", "指定 ansible.cfg 配置文件路径。如果未指定，将按以下顺序查找：1) 环境变量 ANSIBLE_CONFIG 2) 当前目录及父目录的 ansible.cfg 3) ~/.ansible.cfg")
 
 	// 主机列表相关参数
-	rootCmd.PersistentFlags().StringVarP(&inventory, "inventory", "i", "", "主机列表（文件路径、目录路径或逗号分隔的主机列表）。如果指定目录，会递归读取目录下所有子文件并聚合，例如: -i hosts.ini 或 -i hosts_dir/ 或 -i 192.168.1.10,192.168.1.11")
+	rootCmd.PersistentFlags().StringArrayVarP(&inventory, "inventory", "i", nil, "主机列表（文件路径、目录路径或逗号分隔的主机列表）。如果指定目录，会递归读取目录下所有子文件并聚合，例如: -i hosts.ini 或 -i hosts_dir/ 或 -i 192.168.1.10,192.168.1.11。可以多次指定以聚合多个文件或目录中的主机（此时每项都必须是文件或目录路径），例如: -i prod.ini -i staging.ini")
 	rootCmd.PersistentFlags().StringVarP(&group, "group", "g", "", "Ansible INI 格式的分组名称（必需）。使用 -g all 表示选择所有分组，支持逗号分隔的多个组，例如: -g test 或 -g web_servers 或 -g all 或 -g test,web_servers")
+	rootCmd.PersistentFlags().StringVar(&mergeStrategy, "merge-strategy", "first", "同一主机（地址:端口）在多个 inventory 来源（目录中的多个文件、多次指定的 -i、ansible.cfg 中逗号分隔的多个 inventory）中重复定义时的合并策略: first（使用最先出现的定义）、last（使用最后出现的定义）、merge（合并非空字段，如用户名取自提供了用户名的定义）")
+	rootCmd.PersistentFlags().StringVar(&varsDir, "vars-dir", "", "Ansible 风格的 vars 目录，加载其中的 host_vars/<主机> 和 group_vars/<分组>（YAML 或 INI，group_vars/all 对所有主机生效），应用 ansible_user、ansible_port、ansible_ssh_private_key_file、ansible_become、ansible_become_user 等变量。优先级: host_vars > group_vars > inventory 中的值")
+	rootCmd.PersistentFlags().StringSliceVar(&exclude, "exclude", nil, "从主机列表中排除的主机，逗号分隔，每项为 address（匹配所有端口）或 address:port，例如: --exclude 10.0.0.5,10.0.0.6:2222。在 --limit/--offset 之前应用")
+	rootCmd.PersistentFlags().StringVar(&excludeFile, "exclude-file", "", "从主机列表中排除文件中列出的主机（每行一个 address 或 address:port，忽略空行和注释，--write-failures 写入的文件也可以直接使用）")
 
 	// 认证相关参数
 	rootCmd.PersistentFlags().StringVarP(&user, "user", "u", "", "SSH 用户名（可从 ansible.cfg 的 remote_user 读取）")
-	rootCmd.PersistentFlags().StringVarP(&keyPath, "key", "k", "", "SSH 私钥路径（优先使用，可从 ansible.cfg 的 private_key_file 读取）")
+	rootCmd.PersistentFlags().StringVarP(&keyPath, "key", "k", "", "SSH 私钥路径（优先使用，可从 ansible.cfg 的 private_key_file 读取）。支持逗号分隔的多个私钥，依次尝试，例如: -k ~/.ssh/id_ed25519,~/.ssh/id_rsa")
 	rootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "SSH 密码（如果未提供 key）")
 	rootCmd.PersistentFlags().StringVarP(&port, "port", "P", "22", "SSH 端口（默认: 22）")
+	rootCmd.PersistentFlags().StringVar(&totpCode, "totp", "", "二次验证码（用于堡垒机 keyboard-interactive 认证，所有主机共用）")
+	rootCmd.PersistentFlags().StringVar(&totpSecret, "totp-secret", "", "TOTP 密钥（Base32），每次连接自动生成验证码（也可通过环境变量 GOSSH_TOTP_SECRET 指定）")
+	rootCmd.PersistentFlags().BoolVar(&totpPrompt, "totp-prompt", false, "连接主机前交互式输入二次验证码（在当前 30 秒周期结束前被所有主机复用）")
+	rootCmd.PersistentFlags().StringVar(&proxyCommand, "proxy-command", "", "通过指定命令连接主机（类似 OpenSSH 的 ProxyCommand），以命令的标准输入输出作为 SSH 传输通道，支持占位符 %h（主机）、%p（端口）、%r（用户名）、%%，例如: --proxy-command \"cloudflared access ssh --hostname %h\" 或 --proxy-command \"nc -U /run/ssh-%h.sock\"")
+	rootCmd.PersistentFlags().StringVar(&hostKeyCheck, "host-key-check", "", "是否根据 ~/.ssh/known_hosts 校验主机密钥: yes（不在 known_hosts 中或密钥不一致的主机连接失败）或 no。未指定时使用 ansible.cfg 的 host_key_checking，都未设置时不校验")
+	rootCmd.PersistentFlags().StringVar(&identityAgent, "identity-agent", "", "使用指定的 ssh-agent（Unix socket 路径）中的私钥认证，例如: --identity-agent /tmp/ci-agent.sock。SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取路径。与 -k 同时指定时先尝试私钥文件；未指定 -k 时 ssh-agent 认证失败后再尝试 -p 密码。未指定时不使用 ssh-agent")
+	rootCmd.PersistentFlags().BoolVar(&noDefaultKey, "no-default-key", false, "未指定 -k、-p 和 --identity-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa），直接报告未提供认证方式，使 CI 等环境的执行结果不依赖运行用户的主目录")
 
 	// 执行相关参数
 	rootCmd.PersistentFlags().IntVarP(&forks, "forks", "f", 0, "并发执行数量（默认: 5，可从 ansible.cfg 的 forks 读取）")
-	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "T", 0, "连接超时时间（默认: 30s，可从 ansible.cfg 的 timeout 读取），例如: 30s, 1m, 2m30s")
+	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "T", 0, "单台主机的操作总时间（从建立连接到执行完成），超过后断开连接并记为执行超时。ping 默认 30s（可从 ansible.cfg 的 timeout 读取），其他命令默认不限制，例如: 30s, 1m, 2m30s")
+	rootCmd.PersistentFlags().BoolVar(&preResolve, "pre-resolve", false, "执行前并发解析所有主机名（每个主机名只解析一次），之后的连接复用解析结果；无法解析的主机提前报告并直接记为失败（run、script、upload、diff、checksum、fetch、bench 有效）")
+	rootCmd.PersistentFlags().BoolVar(&reuseConnections, "reuse-connections", false, "复用 SSH 连接：同一主机的多个步骤（如 script 的检查解释器、上传、执行和清理，bench 的各个并发级别）共用空闲连接，不再每次重新连接和认证。空闲连接保留 60s，执行结束时关闭（run、script、upload、diff、checksum、fetch、bench 有效）")
+	rootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", 0, "连接超时（TCP 连接和 SSH 握手，默认: 10s，不超过 --timeout），主机变量 gossh_timeout 优先")
+
+	// 输出相关参数
+	rootCmd.PersistentFlags().IntVar(&tableWidth, "table-width", 0, "表格宽度（默认: 0，表示根据终端宽度自动调整）")
+	rootCmd.PersistentFlags().StringVar(&progress, "progress", view.ProgressAuto, "进度显示方式: auto（标准输出是终端时显示进度条，否则逐行输出）、bar（进度条）、plain（每台主机完成时向 stderr 输出一行，不含控制字符，适用于 CI）、none（不显示）")
+	rootCmd.PersistentFlags().StringVar(&configOutput, "config-output", view.ConfigOutputTable, "执行前打印配置参数的格式: table（表格）或 json（一行 JSON 对象，密码只输出 password_set，便于包装 gossh 的程序记录实际使用的参数）；--output json 等不打印配置参数时不输出")
+	rootCmd.PersistentFlags().IntVar(&progressFD, "progress-fd", 0, "将进度事件以 NDJSON 格式（每行一个 JSON 对象）写入指定的文件描述符，例如 --progress-fd 3 3>events.ndjson，供 GUI 等外部程序读取（与 --progress 同时生效）")
+	rootCmd.PersistentFlags().CountVarP(&verbose, "verbose", "v", "输出调试日志到 stderr（配置文件、分组匹配、认证方式、调度），-vv 额外输出每台主机的连接过程")
+}
+
+// addHookFlags 为批量执行类命令注册 --pre-hook、--post-hook、--ignore-hook-errors、--on-success 和 --on-failure 参数
+func addHookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&preHook, "pre-hook", "", "执行前调用的本地可执行文件，通过 GOSSH_* 环境变量获取主机数、分组、命令等信息；退出码非零时终止执行")
+	cmd.Flags().StringVar(&postHook, "post-hook", "", "执行后调用的本地可执行文件，额外通过 GOSSH_SUCCESS_COUNT、GOSSH_FAIL_COUNT 等环境变量获取执行结果")
+	cmd.Flags().BoolVar(&ignoreHookErrors, "ignore-hook-errors", false, "pre-hook 退出码非零时只打印警告并继续执行")
+	cmd.Flags().StringVar(&onSuccess, "on-success", "", "每台主机执行成功后执行的本地命令（通过 sh -c 执行），支持 {{.Host}}、{{.ExitCode}} 等模板变量，输出写入日志")
+	cmd.Flags().StringVar(&onFailure, "on-failure", "", "每台主机执行失败（包括连接失败）后执行的本地命令，模板变量同 --on-success，例如从负载均衡中摘除该主机")
 }
 
-// isInventoryFileOrDir 判断 inventory 是否是文件或目录路径
-// 如果 inventory 是文件或目录路径，返回 true
-// 如果 inventory 是IP地址或逗号分隔的主机列表，返回 false
-func isInventoryFileOrDir(inventory string) bool {
-	if inventory == "" {
-		return false
+// addConfirmFlags 为批量执行类命令注册 --confirm-over 和 --yes 参数
+func addConfirmFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&confirmOver, "confirm-over", 0, "主机数超过该值时，打印配置参数后要求在终端输入 yes 确认再执行（0 表示不确认），防止分组选错时误操作整个集群；可以写在 gossh 配置文件中")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "跳过 --confirm-over 的确认（非交互环境中超过阈值时必须指定）")
+}
+
+// addHistoryFlags 为批量执行类命令注册 --db 参数
+func addHistoryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&historyDB, "db", "", "执行结束后将本次执行和每台主机的结果追加到 SQLite 数据库（runs、host_results 两张表，文件不存在时自动创建），用于查询各主机的历史成功率（内置纯 Go 的 SQLite 驱动，不需要 sqlite3 命令行工具）；写入失败只打印警告")
+}
+
+// addListOnlyFlags 为批量执行类命令注册 --list-only、--list-format 和 --list-one-line 参数
+func addListOnlyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&listOnly, "list-only", false, "只输出将要操作的主机（应用 -g、--exclude、--shuffle、--offset 和 --limit 之后），格式同 list-host，然后退出，不连接主机也不打印配置参数；便于把目标主机传给其他工具")
+	cmd.Flags().StringVar(&listOnlyFormat, "list-format", "ip", "--list-only 的输出格式: ip（非 22 端口的主机输出 地址:端口）、full、wide、json，同 list-host 的 --format")
+	cmd.Flags().BoolVar(&listOnlyOneLine, "list-one-line", false, "--list-only 时一行输出（逗号分隔），同 list-host 的 --one-line")
+}
+
+// validateListOnlyFlags 检查 --list-format 和 --list-one-line 是否与 --list-only 一起使用，以及输出格式是否有效
+func validateListOnlyFlags(cmd *cobra.Command) error {
+	if !listOnly && (cmd.Flags().Changed("list-format") || cmd.Flags().Changed("list-one-line")) {
+		return fmt.Errorf("--list-format 和 --list-one-line 需要与 --list-only 一起使用")
+	}
+	switch listOnlyFormat {
+	case "ip", "full", "wide", "json":
+	default:
+		return fmt.Errorf("不支持的 --list-format %q，可选值: ip、full、wide、json", listOnlyFormat)
+	}
+	return nil
+}
+
+// addDeadlineFlags 为批量执行类命令注册 --deadline 参数
+func addDeadlineFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&deadline, "deadline", 0, "整批执行的总时间上限（从开始连接第一台主机时计时），超过后不再开始新的主机，执行中的主机断开连接，这些主机都记为\"超过时限\"；适用于定时任务限制总运行时间（默认: 0，不限制），例如: 5m")
+}
+
+// addShuffleFlags 为支持 --limit/--offset 的命令注册 --shuffle 和 --seed 参数
+func addShuffleFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&shuffle, "shuffle", false, "执行前随机打乱主机顺序（在 --limit/--offset 之前应用），避免每次都先在相同的主机上执行，配合 --limit 可以随机选取部分主机做金丝雀验证")
+	cmd.Flags().Int64Var(&shuffleSeed, "seed", 0, "--shuffle 的随机种子，主机列表不变时相同的种子得到相同的顺序，便于重现（默认: 0，表示每次随机）")
+}
+
+// hookConfig 根据命令行参数构建钩子配置
+func hookConfig() controller.HookConfig {
+	return controller.HookConfig{
+		PreHook:          preHook,
+		PostHook:         postHook,
+		IgnoreHookErrors: ignoreHookErrors,
+		OnSuccess:        onSuccess,
+		OnFailure:        onFailure,
+	}
+}
+
+// applyGosshConfig 读取 gossh 配置文件（GOSSH_CONFIG 或 ~/.gossh.yaml），为命令行未显式指定的参数设置值
+// 配置项名称与参数长名称一致（如 forks、timeout、inventory）；配置文件在所有子命令间共用，
+// 当前命令没有的参数会被忽略，任何命令都没有的参数只打印警告。
+// 通过 flag.Value.Set 设置值，参数的 Changed 仍为 false（Changed 只表示在命令行中指定），来源记录在 gosshConfigFlags 中
+// 返回使用的配置文件路径，没有配置文件时为空
+func applyGosshConfig(cmd *cobra.Command) (string, error) {
+	cfgPath, entries, err := config.LoadGosshConfig()
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.Key == "help" {
+			continue
+		}
+		flag := cmd.Flags().Lookup(entry.Key)
+		if flag == nil {
+			if !isKnownFlag(cmd.Root(), entry.Key) {
+				fmt.Fprintf(os.Stderr, "警告: %s 第 %d 行: 未知的配置项 %s（已忽略）\n", cfgPath, entry.Line, entry.Key)
+			}
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := flag.Value.Set(entry.Value); err != nil {
+			return "", fmt.Errorf("%s 第 %d 行: 配置项 %s 的值无效: %w", cfgPath, entry.Line, entry.Key, err)
+		}
+		if gosshConfigFlags == nil {
+			gosshConfigFlags = make(map[string]bool)
+		}
+		gosshConfigFlags[entry.Key] = true
+	}
+	return cfgPath, nil
+}
+
+// isKnownFlag 判断 cmd 及其子命令中是否存在指定名称的参数
+func isKnownFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range cmd.Commands() {
+		if isKnownFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInventoryFileOrDir 判断 inventory 中是否有文件或目录路径
+// 如果任何一项是文件或目录路径，返回 true
+// 如果 inventory 为空或是IP地址、逗号分隔的主机列表，返回 false
+func hasInventoryFileOrDir(inventory []string) bool {
+	for _, item := range inventory {
+		// 检查是否是文件或目录路径
+		if _, err := os.Stat(item); err == nil {
+			return true
+		}
 	}
-	// 检查是否是文件或目录路径
-	_, err := os.Stat(inventory)
-	return err == nil
+	return false
 }
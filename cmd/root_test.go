@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gossh/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+func TestApplyGosshConfigNotChanged(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "gossh.yaml")
+	if err := os.WriteFile(cfgPath, []byte("list-format: json\nlist-one-line: true\nforks: 7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.GosshConfigEnv, cfgPath)
+	t.Cleanup(func() { gosshConfigFlags = nil })
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().IntVarP(&forks, "forks", "f", 0, "")
+	addListOnlyFlags(cmd)
+	if err := cmd.ParseFlags(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := applyGosshConfig(cmd); err != nil {
+		t.Fatalf("applyGosshConfig() 失败: %v", err)
+	}
+
+	if forks != 7 || listOnlyFormat != "json" || !listOnlyOneLine {
+		t.Errorf("配置文件中的值没有生效: forks=%d list-format=%q list-one-line=%v", forks, listOnlyFormat, listOnlyOneLine)
+	}
+	for _, name := range []string{"forks", "list-format", "list-one-line"} {
+		if cmd.Flags().Changed(name) {
+			t.Errorf("配置文件设置的参数 %s 不应被视为在命令行中指定（Changed）", name)
+		}
+		if !gosshConfigFlags[name] {
+			t.Errorf("gosshConfigFlags 中没有记录参数 %s", name)
+		}
+	}
+	if sources := flagSources(cmd); sources["forks"] != "gossh 配置文件" {
+		t.Errorf("forks 的来源为 %q，期望 gossh 配置文件", sources["forks"])
+	}
+
+	// 配置文件中的 list-format 不要求同时指定 --list-only
+	if err := validateListOnlyFlags(cmd); err != nil {
+		t.Errorf("validateListOnlyFlags() = %v，期望 nil", err)
+	}
+}
+
+func TestApplyGosshConfigCommandLineWins(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "gossh.yaml")
+	if err := os.WriteFile(cfgPath, []byte("forks: 7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.GosshConfigEnv, cfgPath)
+	t.Cleanup(func() { gosshConfigFlags = nil })
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().IntVarP(&forks, "forks", "f", 0, "")
+	if err := cmd.ParseFlags([]string{"--forks", "3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := applyGosshConfig(cmd); err != nil {
+		t.Fatalf("applyGosshConfig() 失败: %v", err)
+	}
+	if forks != 3 || !cmd.Flags().Changed("forks") || gosshConfigFlags["forks"] {
+		t.Errorf("命令行参数应优先于配置文件: forks=%d changed=%v", forks, cmd.Flags().Changed("forks"))
+	}
+	if sources := flagSources(cmd); sources["forks"] != "命令行参数" {
+		t.Errorf("forks 的来源为 %q，期望 命令行参数", sources["forks"])
+	}
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"gossh/internal/controller"
 	"gossh/internal/view"
 
@@ -9,23 +13,86 @@ import (
 
 var (
 	command    string
+	commandMap string
+	mapStrict  bool
 	become     bool
 	becomeUser string
+	sudoFlags  string
+	loginShell bool
 	showOutput bool
+	showTiming bool // 分别显示连接耗时和执行耗时
+	topSlow    int  // 执行结束后显示最慢的 N 台主机
 	logDir     string
 	limit      int
 	offset     int
+
+	sequentialOutput bool // 逐台执行，每台主机完成后立即打印输出
+
+	retryFailed   string
+	writeFailures string
+
+	safeMode         bool
+	dangerPatterns   []string
+	iKnowWhatImDoing bool
+
+	webhookURL      string
+	webhookHeaders  []string
+	webhookRequired bool
+
+	retries      int
+	retryBackoff time.Duration
+	retryJitter  float64
+
+	retryExitCodes []int
+
+	reconnectOnDrop bool // 命令执行过程中连接断开时重新连接并重新执行命令
+
+	printCommand bool
+	verifyBecome bool
+
+	becomePassword string
+	becomePrompt   string
+
+	commandFile      string
+	parallelCommands bool
+	aggregateExit    string // 命令文件中多条命令合并后的退出码计算方式
+	ignoreErrors     bool   // 命令文件中某条命令失败后继续执行
+
+	groupCommands   []string // 按分组指定命令（"分组=命令"）
+	groupPrecedence []string // 主机属于多个映射分组时的分组优先级
+
+	stdinContent string
+	stdinFile    string
+
+	runEnv       []string // 远程命令的环境变量（KEY=VALUE）
+	runExtraVars []string // 命令模板变量（JSON 对象或 key=value）
+
+	expectPattern string // 输出合规检查的正则表达式
+	expectFile    string // 包含预期输出的文件
+
+	runOutput  string // 输出格式: table、json、json-grouped、html
+	parseJSON  bool   // 把每台主机的标准输出解析为 JSON
+	trimOutput bool   // 去掉输出首尾的空白
+	outputHead int    // 详细输出中每台主机只显示前 N 行
+	outputTail int    // 详细输出中每台主机只显示后 N 行
+
+	outputStream string // 显示的输出流: stdout、stderr、both
 )
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
-	Use:   "run",
+	Use:   "run [-- 命令...]",
 	Short: "批量执行命令",
 	Long: `批量 SSH 连接到多台服务器并执行命令。
 
+命令可以通过 -c 指定，也可以写在 -- 之后（多个参数以空格连接后交给远程 shell 执行，与 ssh 相同）。
+
 示例:
   # 使用 -g 指定组名，只对指定分组的主机执行命令
   gossh run -i ansible_hosts -g test -u root -c "df -h"
+
+  # 命令写在 -- 之后，等同于 -c "df -h"
+  gossh run -i ansible_hosts -g test -u root -- df -h
   gossh run -i hosts.ini -g web_servers -u root -k ~/.ssh/id_rsa -c "uptime"
 
   # 使用 -g all 选择所有分组的主机
@@ -41,6 +108,9 @@ var runCmd = &cobra.Command{
   gossh run -i hosts.txt -g all -u root -c "systemctl restart nginx" --become
   gossh run -i hosts.txt -g all -u root -c "whoami" --become --become-user appuser
 
+  # 以登录 shell 执行命令（加载 /etc/profile 和用户配置文件）
+  gossh run -i hosts.txt -g all -u root -c "command -v java" --login-shell
+
   # 指定并发数
   gossh run -i hosts.txt -g all -u root -c "ls -la" -f 10
 
@@ -48,8 +118,72 @@ var runCmd = &cobra.Command{
   gossh run -i hosts.txt -g all -u root -c "uptime" --limit 5
 
   # 跳过前 3 台主机，然后执行接下来的 5 台
-  gossh run -i hosts.txt -g all -u root -c "df -h" --offset 3 --limit 5`,
+  gossh run -i hosts.txt -g all -u root -c "df -h" --offset 3 --limit 5
+
+  # 按主机执行不同的命令（映射文件: {"192.168.1.10": "uptime", "192.168.1.11:2222": "df -h"}）
+  gossh run -i hosts.txt -g all -u root --command-map commands.json
+
+  # 不在映射中的主机记为失败而不是跳过
+  gossh run -i hosts.txt -g all -u root --command-map commands.jsonl --map-strict
+
+  # 按主机所属分组执行不同的命令（同时属于 web 和 db 的主机执行 web 的命令）
+  gossh run -i hosts.ini -u root --group-command web="systemctl restart nginx" --group-command db="systemctl restart postgresql" --group-precedence web,db
+
+  # 依次执行命令文件中的命令（每行一条，共用一个连接）
+  gossh run -i hosts.txt -g all -u root --command-file checks.txt
+
+  # 同一主机上并发执行命令文件中相互独立的检查
+  gossh run -i hosts.txt -g all -u root --command-file checks.txt --parallel-commands
+
+  # 某条命令失败后继续执行，退出码取所有命令中最大的一个
+  gossh run -i hosts.txt -g all -u root --command-file checks.txt --aggregate-exit max
+
+  # 将本地文件的内容通过标准输入写入远程文件
+  gossh run -i hosts.txt -g all -u root -c "tee /etc/app.conf >/dev/null" --stdin-file app.conf
+
+  # 设置远程命令的环境变量，个别主机在 inventory 中用 gossh_env_<名称> 覆盖（如 10.0.0.5 gossh_env_REGION=eu-west-1）
+  gossh run -i hosts.ini -g all -u root -c 'echo $REGION $APP_ENV' --env REGION=us-east-1 --env APP_ENV=prod
+
+  # 指定 --extra-vars 后 -c 按主机渲染为模板，可以使用其中的变量和 {{.Host}}、{{.Port}}、{{.User}}
+  gossh run -i hosts.txt -g all -u root -c "install-app {{.version}} --node {{.Host}}" -e '{"version":"1.2.3"}'
+  gossh run -i hosts.txt -g all -u root -c "install-app {{.version}}" -e version=1.2.3
+
+  # 合规检查：输出符合预期的主机为合规，其他为不合规
+  gossh run -i hosts.txt -g all -u root -c "sshd -T | grep -i '^permitrootlogin'" --expect "(?i)permitrootlogin no"
+  gossh run -i hosts.txt -g all -u root -c "cat /etc/resolv.conf" --expect-file resolv.conf
+
+  # 将失败的主机写入文件，修复后只对这些主机重新执行
+  gossh run -i hosts.txt -g all -u root -c "yum -y update" --write-failures failures.txt
+  gossh run -u root -c "yum -y update" --retry-failed failures.txt --write-failures failures.txt
+
+  # 安全模式：拒绝 rm -rf /、mkfs、dd of=/dev/ 等危险命令，可追加自定义规则
+  gossh run -i hosts.txt -g all -u root -c "rm -rf /data/tmp" --safe-mode --danger-pattern '\brm\s+-rf\s+/data\b'
+
+  # 执行完成后将结果发送到 webhook（CI/ChatOps）
+  gossh run -i hosts.txt -g all -u root -c "uptime" --webhook https://hooks.example.com/gossh --webhook-header "Authorization: Bearer $TOKEN"
+
+  # 逐台滚动执行，每台主机完成后立即显示其输出
+  gossh run -i hosts.txt -g web -u root -c "systemctl restart app && systemctl is-active app" --forks 1 --sequential-output
+
+  # 生成 HTML 报告，便于分享给不使用命令行的同事或归档
+  gossh run -i hosts.txt -g all -u root -c "systemctl status nginx" --output html > report.html
+
+  # 以 JSON 输出结果，并把每台主机输出的 JSON 解析为结构化数据
+  gossh run -i hosts.txt -g all -u root -c "docker inspect nginx" --output json --parse-json | jq '.[].stdout_json[0].State.Status'`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// 位置参数作为要执行的命令
+		runCommand := command
+		if len(args) > 0 {
+			if command != "" {
+				return fmt.Errorf("-c 与 -- 之后的命令不能同时使用")
+			}
+			runCommand = strings.Join(args, " ")
+		}
+
+		if err := validateListOnlyFlags(cmd); err != nil {
+			return err
+		}
+
 		// 创建 controller
 		ctrl := controller.NewRunController()
 
@@ -62,14 +196,104 @@ var runCmd = &cobra.Command{
 			KeyPath:     keyPath,
 			Password:    password,
 			Port:        port,
-			Command:     command,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
+			Command:     runCommand,
+			CommandMap:  commandMap,
+			MapStrict:   mapStrict,
 			Become:      become,
 			BecomeUser:  becomeUser,
+			SudoFlags:   sudoFlags,
+			LoginShell:  loginShell,
 			Concurrency: forks,
 			ShowOutput:  showOutput,
 			LogDir:      logDir,
 			Limit:       limit,
 			Offset:      offset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+			ListOnly:    listOnly,
+			Hooks:       hookConfig(),
+
+			RetryFailed:   retryFailed,
+			WriteFailures: writeFailures,
+
+			SafeMode:         safeMode,
+			DangerPatterns:   dangerPatterns,
+			IKnowWhatImDoing: iKnowWhatImDoing,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+			Deadline:       deadline,
+
+			Webhook:         webhookURL,
+			WebhookHeaders:  webhookHeaders,
+			WebhookRequired: webhookRequired,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+
+			Retries:      retries,
+			RetryBackoff: retryBackoff,
+			RetryJitter:  retryJitter,
+
+			RetryExitCodes: retryExitCodes,
+
+			ReconnectOnDrop: reconnectOnDrop,
+
+			PrintCommand: printCommand,
+			VerifyBecome: verifyBecome,
+
+			BecomePassword: becomePassword,
+			BecomePrompt:   becomePrompt,
+
+			CommandFile:      commandFile,
+			ParallelCommands: parallelCommands,
+			AggregateExit:    aggregateExit,
+			IgnoreErrors:     ignoreErrors,
+
+			GroupCommands:   groupCommands,
+			GroupPrecedence: groupPrecedence,
+
+			Stdin:     stdinContent,
+			StdinFile: stdinFile,
+
+			Env: runEnv,
+
+			ExtraVars: runExtraVars,
+
+			Expect:     expectPattern,
+			ExpectFile: expectFile,
+
+			ConfirmOver: confirmOver,
+			DB:          historyDB,
+			AssumeYes:   assumeYes,
+
+			Output:    runOutput,
+			ParseJSON: parseJSON,
+
+			SequentialOutput: sequentialOutput,
+			TrimOutput:       trimOutput,
+		}
+
+		if err := view.SetDetailedOutputLines(outputHead, outputTail); err != nil {
+			return err
+		}
+		if topSlow < 0 {
+			return fmt.Errorf("--top-slow 不能为负数")
+		}
+		if err := view.SetOutputStream(outputStream); err != nil {
+			return err
 		}
 
 		// 执行命令
@@ -78,8 +302,30 @@ var runCmd = &cobra.Command{
 			return err
 		}
 
+		// 只列出目标主机
+		if listOnly {
+			view.PrintListResults(resp.Hosts, listOnlyFormat, listOnlyOneLine, false)
+			return nil
+		}
+
 		// 输出结果
-		view.PrintRunResults(resp.Results, resp.TotalDuration, showOutput, resp.Group, resp.Hosts)
+		if trimOutput {
+			view.TrimResultsOutput(resp.Results)
+		}
+		switch runOutput {
+		case "json":
+			view.PrintRunResultsJSON(resp.Results, parseJSON)
+			return nil
+		case "json-grouped":
+			view.PrintRunResultsGroupedJSON(resp.Results, parseJSON)
+			return nil
+		case "html":
+			view.PrintRunResultsHTML(resp.Results, resp.TotalDuration, resp.Group)
+			return nil
+		}
+		// 逐台输出时每台主机的输出已经在执行过程中打印，最后只打印结果表格和汇总
+		view.PrintRunResultsWithTiming(resp.Results, resp.TotalDuration, showOutput && !sequentialOutput, resp.Group, resp.Hosts, showTiming)
+		view.PrintSlowestHosts(resp.Results, topSlow)
 
 		return nil
 	},
@@ -89,12 +335,60 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 
 	// 执行相关参数
-	runCmd.Flags().StringVarP(&command, "command", "c", "", "要执行的命令（必需）")
-	runCmd.MarkFlagRequired("command")
+	runCmd.Flags().StringVarP(&command, "command", "c", "", "要执行的命令（与 --command-map、--command-file、--group-command 四选一），也可以写在 -- 之后")
+	runCmd.Flags().StringVar(&commandMap, "command-map", "", "按主机指定命令的映射文件（JSON 对象或 JSON Lines），每台主机执行各自的命令")
+	runCmd.Flags().BoolVar(&mapStrict, "map-strict", false, "不在命令映射（--command-map 或 --group-command）中的主机记为失败（默认跳过）")
+	runCmd.Flags().StringArrayVar(&groupCommands, "group-command", nil, "按主机所属分组指定命令（格式: 分组=命令），可以多次指定，例如: --group-command web=\"systemctl restart nginx\" --group-command db=\"systemctl restart postgresql\"；不属于任何指定分组的主机跳过")
+	runCmd.Flags().StringSliceVar(&groupPrecedence, "group-precedence", nil, "主机同时属于多个 --group-command 分组且命令不同时按该顺序选择（逗号分隔，靠前的优先），未指定时这样的主机会报错")
+	runCmd.Flags().StringVar(&commandFile, "command-file", "", "命令文件（每行一条命令，忽略空行和 # 注释），每台主机在一个连接上依次执行，某条命令失败后不再执行后续命令")
+	runCmd.Flags().BoolVar(&parallelCommands, "parallel-commands", false, "同一主机上并发执行 --command-file 中的命令（每条命令一个会话，共用一个连接，同时最多 10 个会话），全部执行完成后汇总，输出仍按文件中的顺序排列")
+	runCmd.Flags().StringVar(&aggregateExit, "aggregate-exit", "", "--command-file 中多条命令合并后的退出码: first-nonzero（第一条失败命令的退出码，顺序执行时遇到失败即停止，默认）、last（最后一条命令的退出码）、max（最大的退出码）；last 和 max 时某条命令失败后继续执行后续命令")
+	runCmd.Flags().BoolVar(&ignoreErrors, "ignore-errors", false, "--command-file 中某条命令失败后继续执行后续命令（退出码仍按 --aggregate-exit 计算）")
+	runCmd.Flags().StringVar(&stdinContent, "stdin", "", "写入远程命令标准输入的内容（所有主机相同，原样写入，不追加换行），例如: --stdin \"$(cat token)\"")
+	runCmd.Flags().StringVar(&stdinFile, "stdin-file", "", "将本地文件的内容写入远程命令的标准输入（所有主机相同），\"-\" 表示读取 gossh 自身的标准输入，例如: -c \"tee /etc/app.conf\" --stdin-file app.conf")
+	runCmd.Flags().StringArrayVarP(&runExtraVars, "extra-vars", "e", nil, "命令模板变量，JSON 对象（'{\"version\":\"1.2.3\"}'）或空格分隔的 key=value，可以多次指定；指定后 -c 按主机渲染为 Go 模板，可以使用 {{.version}} 等变量和主机字段 {{.Host}}、{{.Port}}、{{.User}}、{{.Groups}}，引用不存在的变量时报错")
+	runCmd.Flags().StringVar(&expectPattern, "expect", "", "输出合规检查: 在每台主机的标准输出中查找该正则表达式，找到且命令执行成功的主机为合规，否则为不合规，汇总中分别统计，例如: --expect \"(?m)^PermitRootLogin no$\"")
+	runCmd.Flags().StringVar(&expectFile, "expect-file", "", "输出合规检查: 每台主机的标准输出与该文件的内容完全一致（都去掉首尾空白后比较）且命令执行成功时为合规，否则为不合规，与 --expect 二选一")
+	runCmd.Flags().StringArrayVar(&runEnv, "env", nil, "设置远程命令的环境变量（格式: KEY=VALUE），可以多次指定；主机变量 gossh_env_<名称> 优先于该参数，become 时在 sudo 之后设置")
 	runCmd.Flags().BoolVar(&become, "become", false, "使用 sudo 执行命令（类似 ansible 的 become）")
 	runCmd.Flags().StringVar(&becomeUser, "become-user", "", "使用 sudo 切换到指定用户执行命令（默认: root）")
+	runCmd.Flags().StringVar(&sudoFlags, "sudo-flags", "", "追加到 sudo 与命令之间的额外参数（需配合 --become），例如: \"-H\" 或 \"-i --preserve-env\"。目标用户只能通过 --become-user 指定")
+	runCmd.Flags().BoolVar(&loginShell, "login-shell", false, "以登录 shell 执行命令（bash -lc），加载 /etc/profile 和用户配置文件中的环境变量")
+	runCmd.Flags().BoolVar(&printCommand, "print-command", false, "在详细输出中显示每台主机实际执行的命令（经过 sudo、登录 shell 等包装后的完整命令）")
+	runCmd.Flags().BoolVar(&verifyBecome, "verify-become", false, "become 时先以 sudo 执行 id -u，确认实际 UID 与目标用户一致后再执行命令；提权未生效（sudo 失败或切换到了其他用户）的主机不执行命令，记为提权失败")
+	runCmd.Flags().StringVar(&becomePassword, "become-password", "", "sudo 密码（需配合 --become）。以 sudo -S 执行，只有在标准错误中检测到密码提示符时才发送密码，sudoers 配置为 NOPASSWD 的主机不会收到密码")
+	runCmd.Flags().StringVar(&becomePrompt, "become-prompt", "", "检测 sudo 密码提示符的正则表达式（需配合 --become-password，默认: (?i)password），例如非英文系统的提示符: \"(?i)password|密码\"")
 	runCmd.Flags().BoolVar(&showOutput, "show-output", true, "显示命令输出（默认: true）")
+	runCmd.Flags().BoolVar(&sequentialOutput, "sequential-output", false, "逐台执行主机（并发数为 1，不能与大于 1 的 --forks 一起使用），每台主机完成后立即打印其完整输出，下一台主机在此之后才开始执行，适合需要逐台观察的滚动发布；不显示进度条，最后只打印结果表格和汇总")
+	runCmd.Flags().IntVar(&outputHead, "head", 0, "详细输出中每台主机的标准输出和标准错误只显示前 N 行（类似 head），并提示省略的行数；JSON 输出、日志和 webhook 中保留完整输出（默认: 0，不限制）")
+	runCmd.Flags().IntVar(&outputTail, "tail", 0, "详细输出中每台主机的标准输出和标准错误只显示后 N 行（类似 tail），与 --head 同时指定时显示前 N 行和后 N 行（默认: 0，不限制）")
+	runCmd.Flags().StringVar(&outputStream, "output-stream", view.OutputStreamBoth, "详细输出、JSON 和 HTML 输出中显示的输出流: stdout（只显示标准输出）、stderr（只显示标准错误，json-grouped 按标准错误分组）、both（默认）；日志和 webhook 中保留两个输出流")
+	runCmd.Flags().BoolVar(&trimOutput, "trim-output", false, "显示结果前去掉每台主机标准输出和标准错误首尾的空白（包括末尾的换行），适用于 hostname 等输出一行的命令；日志和 webhook 中保留原始输出")
+	runCmd.Flags().StringVar(&runOutput, "output", "table", "输出格式: table（表格）、json（JSON 数组，每台主机一个 {host, status, exit_code, duration_ms, stdout, stderr, error} 对象，不输出配置参数和进度条）、json-grouped（退出码和标准输出相同的主机合并为一组，每组一个 {exit_code, output, count, hosts} 对象）、html（自包含的 HTML 报告，主机表格可按列排序，每台主机的输出可折叠，例如: --output html > report.html）")
+	runCmd.Flags().BoolVar(&parseJSON, "parse-json", false, "把每台主机的标准输出解析为 JSON，放在结果的 stdout_json 字段中（解析失败时在 stdout_json_error 中说明原因），需配合 --output json（json-grouped 时为每组的 output_json），例如: -c \"docker inspect nginx\" --output json --parse-json")
+	runCmd.Flags().IntVar(&topSlow, "top-slow", 0, "执行结束后在汇总之后按耗时从高到低显示最慢的 N 台主机（含连接耗时和执行耗时），用于找出拖慢整批执行的主机（默认: 0，不显示；JSON 和 HTML 输出时不显示）")
+	runCmd.Flags().BoolVar(&showTiming, "timing", false, "在结果表格中分别显示连接耗时（TCP 连接、SSH 握手、认证和创建会话）和执行耗时，并显示平均值，用于区分连接建立慢还是命令本身慢")
 	runCmd.Flags().StringVar(&logDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：run-时间戳.log")
 	runCmd.Flags().IntVar(&limit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
 	runCmd.Flags().IntVar(&offset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(runCmd)
+	runCmd.Flags().StringVar(&retryFailed, "retry-failed", "", "从 --write-failures 写入的失败主机文件加载主机列表（替代 -i 和 -g）")
+	runCmd.Flags().StringVar(&writeFailures, "write-failures", "", "执行结束后将失败的主机写入该文件（每行 address:port），可配合 --retry-failed 重试")
+	runCmd.Flags().BoolVar(&safeMode, "safe-mode", false, "拒绝执行匹配危险命令规则的命令（如 rm -rf /、mkfs、dd of=/dev/）")
+	runCmd.Flags().StringArrayVar(&dangerPatterns, "danger-pattern", nil, "追加危险命令规则（Go 正则表达式，可多次指定，需配合 --safe-mode）")
+	runCmd.Flags().BoolVar(&iKnowWhatImDoing, "i-know-what-im-doing", false, "跳过 --safe-mode 的危险命令检查")
+	runCmd.Flags().StringVar(&webhookURL, "webhook", "", "执行完成后以 POST 发送 JSON 结果（汇总和每台主机的结果）的地址，发送失败只打印警告")
+	runCmd.Flags().StringArrayVar(&webhookHeaders, "webhook-header", nil, "发送 webhook 时附加的请求头，格式 \"Name: Value\"（可多次指定），例如: \"Authorization: Bearer xxx\"")
+	runCmd.Flags().BoolVar(&webhookRequired, "webhook-required", false, "webhook 发送失败时命令返回错误")
+	runCmd.Flags().IntVar(&retries, "retries", 0, "连接失败或连接超时时的最大重试次数（0 表示不重试），认证失败和命令执行失败不重试")
+	runCmd.Flags().DurationVar(&retryBackoff, "retry-backoff", time.Second, "第一次重试前的基础等待时间，之后每次翻倍（最长 30s）")
+	runCmd.Flags().Float64Var(&retryJitter, "retry-jitter", 0.5, "重试等待时间的随机抖动系数（0-1），例如 0.5 表示在基础时间的 50%-150% 之间随机，避免所有主机同时重连")
+	runCmd.Flags().IntSliceVar(&retryExitCodes, "retry-exit-codes", nil, "命令以这些退出码结束时也重试（逗号分隔，需要配合 --retries），例如: --retry-exit-codes 75,111。命令会被重新执行，只适用于可以重复执行的命令")
+	runCmd.Flags().BoolVar(&reconnectOnDrop, "reconnect-on-drop", false, "命令执行过程中连接断开（会话没有返回退出码就结束）时，保留断开前的输出，重新连接后重新执行命令（最多 3 次），结果中附带连接断开的警告。命令会被重新执行，只适用于幂等的只读命令（如监控、查看日志），不能与 --command-file 一起使用")
+
+	addHookFlags(runCmd)
+	addListOnlyFlags(runCmd)
+	addDeadlineFlags(runCmd)
+	addConfirmFlags(runCmd)
+	addHistoryFlags(runCmd)
 }
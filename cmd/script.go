@@ -11,11 +11,26 @@ var (
 	scriptPath       string
 	scriptBecome     bool
 	scriptBecomeUser string
+	scriptSudoFlags  string
 	scriptShowOutput bool
 	scriptLogDir     string
 	scriptLimit      int
 	scriptOffset     int
 	scriptExecutor   string
+	scriptKeepScript bool
+	scriptRemoteTmp  string
+	scriptEnv        []string
+
+	scriptInterpreter  string
+	scriptPrintCommand bool
+	scriptVerifyBecome bool
+	scriptTrimOutput   bool
+	scriptOutputHead   int
+	scriptOutputTail   int
+	scriptOutputStream string
+
+	scriptBecomePassword string
+	scriptBecomePrompt   string
 )
 
 // scriptCmd represents the script command
@@ -56,8 +71,26 @@ var scriptCmd = &cobra.Command{
   gossh script -i hosts.txt -g all -u root -s deploy.sh --executor bash
   gossh script -i hosts.txt -g all -u root -s deploy.sh --executor sh
   gossh script -i hosts.txt -g all -u root -s deploy.py --executor python
-  gossh script -i hosts.txt -g all -u root -s deploy.py --executor python3`,
+  gossh script -i hosts.txt -g all -u root -s deploy.py --executor python3
+
+  # 各主机的 Python 路径不同时，用 --interpreter 指定默认解释器，
+  # 个别主机在 inventory 中用 gossh_interpreter 覆盖（如 10.0.0.5 gossh_interpreter=/usr/bin/python3.11）
+  # 执行前会检查解释器是否存在，不存在的主机直接报告"解释器不存在"
+  gossh script -i hosts.ini -g all -u root -s report.py --interpreter python3
+
+  # 设置脚本的环境变量，个别主机在 inventory 中用 gossh_env_<名称> 覆盖
+  gossh script -i hosts.ini -g all -u root -s deploy.sh --env APP_ENV=prod
+
+  # 调试时保留远程临时脚本（输出中会给出脚本在远程主机上的路径）
+  gossh script -i hosts.txt -g all -u root -s deploy.sh --keep-script
+
+  # 指定远程临时目录（例如 /tmp 挂载为 noexec 时）
+  gossh script -i hosts.txt -g all -u root -s deploy.sh --remote-tmp /var/tmp`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateListOnlyFlags(cmd); err != nil {
+			return err
+		}
+
 		// 创建 controller
 		ctrl := controller.NewScriptController()
 
@@ -70,15 +103,60 @@ var scriptCmd = &cobra.Command{
 			KeyPath:     keyPath,
 			Password:    password,
 			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
 			ScriptPath:  scriptPath,
 			Become:      scriptBecome,
 			BecomeUser:  scriptBecomeUser,
+			SudoFlags:   scriptSudoFlags,
 			Concurrency: forks,
 			ShowOutput:  scriptShowOutput,
 			LogDir:      scriptLogDir,
 			Limit:       scriptLimit,
 			Offset:      scriptOffset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+			ListOnly:    listOnly,
+			Hooks:       hookConfig(),
 			Executor:    scriptExecutor,
+			Interpreter: scriptInterpreter,
+			KeepScript:  scriptKeepScript,
+			RemoteTmp:   scriptRemoteTmp,
+			Env:         scriptEnv,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+			Deadline:       deadline,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+
+			PrintCommand: scriptPrintCommand,
+			VerifyBecome: scriptVerifyBecome,
+
+			BecomePassword: scriptBecomePassword,
+			BecomePrompt:   scriptBecomePrompt,
+
+			ConfirmOver: confirmOver,
+			DB:          historyDB,
+			AssumeYes:   assumeYes,
+		}
+
+		if err := view.SetDetailedOutputLines(scriptOutputHead, scriptOutputTail); err != nil {
+			return err
+		}
+		if err := view.SetOutputStream(scriptOutputStream); err != nil {
+			return err
 		}
 
 		// 执行命令
@@ -87,7 +165,16 @@ var scriptCmd = &cobra.Command{
 			return err
 		}
 
+		// 只列出目标主机
+		if listOnly {
+			view.PrintListResults(resp.Hosts, listOnlyFormat, listOnlyOneLine, false)
+			return nil
+		}
+
 		// 输出结果
+		if scriptTrimOutput {
+			view.TrimResultsOutput(resp.Results)
+		}
 		view.PrintRunResults(resp.Results, resp.TotalDuration, scriptShowOutput, resp.Group, resp.Hosts)
 
 		return nil
@@ -102,9 +189,29 @@ func init() {
 	scriptCmd.MarkFlagRequired("script")
 	scriptCmd.Flags().BoolVar(&scriptBecome, "become", false, "使用 sudo 执行脚本（类似 ansible 的 become）")
 	scriptCmd.Flags().StringVar(&scriptBecomeUser, "become-user", "", "使用 sudo 切换到指定用户执行脚本（默认: root）")
+	scriptCmd.Flags().StringVar(&scriptSudoFlags, "sudo-flags", "", "追加到 sudo 与命令之间的额外参数（需配合 --become），例如: \"-H\" 或 \"-i --preserve-env\"。目标用户只能通过 --become-user 指定")
 	scriptCmd.Flags().BoolVar(&scriptShowOutput, "show-output", true, "显示命令输出（默认: true）")
+	scriptCmd.Flags().IntVar(&scriptOutputHead, "head", 0, "详细输出中每台主机的标准输出和标准错误只显示前 N 行（类似 head），并提示省略的行数；日志和 webhook 中保留完整输出（默认: 0，不限制）")
+	scriptCmd.Flags().IntVar(&scriptOutputTail, "tail", 0, "详细输出中每台主机的标准输出和标准错误只显示后 N 行（类似 tail），与 --head 同时指定时显示前 N 行和后 N 行（默认: 0，不限制）")
+	scriptCmd.Flags().StringVar(&scriptOutputStream, "output-stream", view.OutputStreamBoth, "详细输出中显示的输出流: stdout（只显示标准输出）、stderr（只显示标准错误）、both（默认）；日志和 webhook 中保留两个输出流")
+	scriptCmd.Flags().BoolVar(&scriptTrimOutput, "trim-output", false, "显示结果前去掉每台主机标准输出和标准错误首尾的空白（包括末尾的换行）；日志和 webhook 中保留原始输出")
 	scriptCmd.Flags().StringVar(&scriptLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：script-时间戳.log")
 	scriptCmd.Flags().IntVar(&scriptLimit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
 	scriptCmd.Flags().IntVar(&scriptOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(scriptCmd)
 	scriptCmd.Flags().StringVar(&scriptExecutor, "executor", "bash", "脚本执行器（默认: bash，可选: sh, python, python3 等）")
+	scriptCmd.Flags().StringVar(&scriptInterpreter, "interpreter", "", "脚本解释器，优先于 --executor（例如: python3、/usr/bin/python3.11）。主机变量 gossh_interpreter 优先于该参数，执行前会通过 command -v 检查解释器是否存在")
+	scriptCmd.Flags().BoolVar(&scriptKeepScript, "keep-script", false, "执行后保留远程临时脚本不删除（调试用），脚本路径会附加在输出末尾")
+	scriptCmd.Flags().StringArrayVar(&scriptEnv, "env", nil, "设置脚本的环境变量（格式: KEY=VALUE），可以多次指定；主机变量 gossh_env_<名称> 优先于该参数，become 时在 sudo 之后设置")
+	scriptCmd.Flags().StringVar(&scriptRemoteTmp, "remote-tmp", "", "远程临时目录，脚本会上传到该目录（默认: /tmp）")
+	scriptCmd.Flags().BoolVar(&scriptPrintCommand, "print-command", false, "在详细输出中显示每台主机实际执行的命令（经过 sudo 等包装后的完整命令）")
+	scriptCmd.Flags().BoolVar(&scriptVerifyBecome, "verify-become", false, "become 时先以 sudo 执行 id -u，确认实际 UID 与目标用户一致后再上传和执行脚本；提权未生效的主机记为提权失败")
+	scriptCmd.Flags().StringVar(&scriptBecomePassword, "become-password", "", "sudo 密码（需配合 --become）。以 sudo -S 执行，只有在标准错误中检测到密码提示符时才发送密码，sudoers 配置为 NOPASSWD 的主机不会收到密码")
+	scriptCmd.Flags().StringVar(&scriptBecomePrompt, "become-prompt", "", "检测 sudo 密码提示符的正则表达式（需配合 --become-password，默认: (?i)password），例如非英文系统的提示符: \"(?i)password|密码\"")
+
+	addHookFlags(scriptCmd)
+	addListOnlyFlags(scriptCmd)
+	addDeadlineFlags(scriptCmd)
+	addConfirmFlags(scriptCmd)
+	addHistoryFlags(scriptCmd)
 }
@@ -8,15 +8,22 @@ import (
 )
 
 var (
-	uploadLocalPath  string
-	uploadRemotePath string
-	uploadMode       string
-	uploadShowOutput bool
-	uploadLogDir     string
-	uploadLimit      int
-	uploadOffset     int
-	uploadBackup     bool
-	uploadForce      bool
+	uploadLocalPath   string
+	uploadRemotePaths []string
+	uploadMode        string
+	uploadShowOutput  bool
+	uploadLogDir      string
+	uploadLimit       int
+	uploadOffset      int
+	uploadBackup      bool
+	uploadForce       bool
+	uploadBecome      bool
+	uploadBecomeUser  string
+	uploadSudoFlags   string
+
+	uploadBackupDir        string
+	uploadBackupSuffix     string
+	uploadBackupTimeFormat string
 )
 
 // uploadCmd represents the upload command
@@ -39,6 +46,9 @@ var uploadCmd = &cobra.Command{
   # 从命令行参数指定主机上传文件（逗号分隔，也需要指定 -g）
   gossh upload -i "192.168.1.10,192.168.1.11" -g all -u root -l app.tar.gz -r /tmp/app.tar.gz
 
+  # 同时上传到多个远程路径（可重复 -r 或逗号分隔）
+  gossh upload -i hosts.txt -g all -u root -l app -r /usr/local/bin/app,/opt/app/bin/app --mode 0755
+
   # 指定文件权限
   gossh upload -i hosts.txt -g all -u root -l script.sh -r /tmp/script.sh --mode 0755
 
@@ -54,12 +64,25 @@ var uploadCmd = &cobra.Command{
   # 如果文件已存在，先备份再上传（备份文件名格式: 原文件名.backup.YYYYMMDD-HHMMSS）
   gossh upload -i hosts.txt -g all -u root -l config.conf -r /etc/config.conf --backup --force
 
+  # 备份到独立的目录（/etc 所在分区只读或空间不足时），备份文件为 /var/backups/gossh/etc/config.conf.bak-<时间戳>
+  gossh upload -i hosts.txt -g all -u root -l config.conf -r /etc/config.conf --backup --backup-dir /var/backups/gossh --backup-suffix ".bak-{timestamp}"
+
   # 强制覆盖已存在的文件（不备份）
   gossh upload -i hosts.txt -g all -u root -l app.tar.gz -r /tmp/app.tar.gz --force
 
   # 默认行为：如果文件已存在则跳过（不覆盖）
-  gossh upload -i hosts.txt -g all -u root -l app.tar.gz -r /tmp/app.tar.gz`,
+  gossh upload -i hosts.txt -g all -u root -l app.tar.gz -r /tmp/app.tar.gz
+
+  # 以普通用户登录，通过 sudo 上传到 root 所有的目录
+  gossh upload -i hosts.txt -g all -u deploy -l nginx.conf -r /etc/nginx/nginx.conf --become --force
+
+  # 通过 sudo 上传，目标文件属主为 appuser
+  gossh upload -i hosts.txt -g all -u deploy -l app.conf -r /opt/app/app.conf --become --become-user appuser`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateListOnlyFlags(cmd); err != nil {
+			return err
+		}
+
 		// 创建 controller
 		ctrl := controller.NewUploadController()
 
@@ -72,16 +95,50 @@ var uploadCmd = &cobra.Command{
 			KeyPath:     keyPath,
 			Password:    password,
 			Port:        port,
+			TOTP:        totpCode,
+			TOTPSecret:  totpSecret,
+			TOTPPrompt:  totpPrompt,
 			LocalPath:   uploadLocalPath,
-			RemotePath:  uploadRemotePath,
+			RemotePaths: uploadRemotePaths,
 			Mode:        uploadMode,
 			Concurrency: forks,
 			ShowOutput:  uploadShowOutput,
 			LogDir:      uploadLogDir,
 			Limit:       uploadLimit,
 			Offset:      uploadOffset,
+			Shuffle:     shuffle,
+			Seed:        shuffleSeed,
+			ListOnly:    listOnly,
+			Hooks:       hookConfig(),
 			Backup:      uploadBackup,
 			Force:       uploadForce,
+			Become:      uploadBecome,
+			BecomeUser:  uploadBecomeUser,
+			SudoFlags:   uploadSudoFlags,
+
+			BackupDir:        uploadBackupDir,
+			BackupSuffix:     uploadBackupSuffix,
+			BackupTimeFormat: uploadBackupTimeFormat,
+
+			ConnectTimeout: connectTimeout,
+			Timeout:        timeout,
+			Deadline:       deadline,
+
+			MergeStrategy: mergeStrategy,
+			VarsDir:       varsDir,
+			Exclude:       exclude,
+			ExcludeFile:   excludeFile,
+			PreResolve:    preResolve,
+			ProxyCommand:  proxyCommand,
+			HostKeyCheck:  hostKeyCheck,
+			IdentityAgent: identityAgent,
+			NoDefaultKey:  noDefaultKey,
+
+			ReuseConnections: reuseConnections,
+
+			ConfirmOver: confirmOver,
+			DB:          historyDB,
+			AssumeYes:   assumeYes,
 		}
 
 		// 执行命令
@@ -90,8 +147,14 @@ var uploadCmd = &cobra.Command{
 			return err
 		}
 
+		// 只列出目标主机
+		if listOnly {
+			view.PrintListResults(resp.Hosts, listOnlyFormat, listOnlyOneLine, false)
+			return nil
+		}
+
 		// 输出结果
-		view.PrintRunResults(resp.Results, resp.TotalDuration, uploadShowOutput, resp.Group, resp.Hosts)
+		view.PrintUploadResults(resp.Results, resp.TotalDuration, uploadShowOutput, resp.Group, resp.Hosts)
 
 		return nil
 	},
@@ -103,13 +166,26 @@ func init() {
 	// 上传相关参数
 	uploadCmd.Flags().StringVarP(&uploadLocalPath, "local", "l", "", "本地文件路径（必需）")
 	uploadCmd.MarkFlagRequired("local")
-	uploadCmd.Flags().StringVarP(&uploadRemotePath, "remote", "r", "", "远程文件路径（必需）")
+	uploadCmd.Flags().StringSliceVarP(&uploadRemotePaths, "remote", "r", nil, "远程文件路径（必需）。可重复指定或逗号分隔，每台主机依次上传到所有路径，例如: -r /usr/local/bin/app -r /opt/app/bin/app")
 	uploadCmd.MarkFlagRequired("remote")
 	uploadCmd.Flags().StringVar(&uploadMode, "mode", "0644", "文件权限（默认: 0644）")
 	uploadCmd.Flags().BoolVar(&uploadShowOutput, "show-output", true, "显示命令输出（默认: true）")
 	uploadCmd.Flags().StringVar(&uploadLogDir, "log-dir", "", "日志目录路径（可选，JSON 格式）。会自动生成文件名：upload-时间戳.log")
 	uploadCmd.Flags().IntVar(&uploadLimit, "limit", 0, "限制执行的主机数量（0 表示不限制）")
 	uploadCmd.Flags().IntVar(&uploadOffset, "offset", 0, "跳过前 N 台主机（默认: 0）")
+	addShuffleFlags(uploadCmd)
 	uploadCmd.Flags().BoolVar(&uploadBackup, "backup", false, "如果文件已存在，先备份再上传（备份文件名格式: 原文件名.backup.YYYYMMDD-HHMMSS）")
+	uploadCmd.Flags().StringVar(&uploadBackupDir, "backup-dir", "", "备份文件存放的远程目录（需配合 --backup），备份文件在其中保留原文件的完整路径，例如 /var/backups/gossh/etc/app.conf.backup.<时间戳>，目录不存在时自动创建（默认: 原文件所在目录）")
+	uploadCmd.Flags().StringVar(&uploadBackupSuffix, "backup-suffix", "", "备份文件名的后缀（需配合 --backup），{timestamp} 替换为时间戳，不含 {timestamp} 时每次备份覆盖同一个文件（默认: .backup.{timestamp}）")
+	uploadCmd.Flags().StringVar(&uploadBackupTimeFormat, "backup-time-format", "", "备份文件名中时间戳的格式（需配合 --backup），使用 Go 的时间格式，例如 2006-01-02T150405（默认: 20060102-150405）")
 	uploadCmd.Flags().BoolVar(&uploadForce, "force", false, "强制覆盖已存在的文件（默认: false，遇到已存在的文件会跳过）")
+	uploadCmd.Flags().BoolVar(&uploadBecome, "become", false, "先上传到临时文件，再通过 sudo 移动到目标路径（用于写入登录用户无权限的目录）")
+	uploadCmd.Flags().StringVar(&uploadBecomeUser, "become-user", "", "使用 sudo 切换到指定用户写入目标文件，该用户即为文件属主（默认: root）")
+	uploadCmd.Flags().StringVar(&uploadSudoFlags, "sudo-flags", "", "追加到 sudo 的额外参数（需配合 --become），例如: \"-H\"。目标用户只能通过 --become-user 指定")
+
+	addHookFlags(uploadCmd)
+	addListOnlyFlags(uploadCmd)
+	addDeadlineFlags(uploadCmd)
+	addConfirmFlags(uploadCmd)
+	addHistoryFlags(uploadCmd)
 }
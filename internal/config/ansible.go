@@ -3,12 +3,14 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"gossh/internal/executor"
+	"gossh/internal/ssh"
 )
 
 // AnsibleConfig Ansible 配置文件结构
@@ -18,6 +20,11 @@ type AnsibleConfig struct {
 	RemoteUser     string // remote_user
 	Forks          int    // forks
 	Timeout        int    // timeout
+	ForksSet       bool   // 配置文件中是否设置了 forks（未设置时 Forks 为默认值 5）
+	TimeoutSet     bool   // 配置文件中是否设置了 timeout（未设置时 Timeout 为默认值 30）
+
+	HostKeyChecking    bool // host_key_checking
+	HostKeyCheckingSet bool // 配置文件中是否设置了 host_key_checking
 }
 
 // LoadAnsibleConfig 加载 ansible.cfg 配置文件
@@ -26,34 +33,48 @@ type AnsibleConfig struct {
 // 2. 当前目录和父目录中的 ansible.cfg
 // 3. 用户主目录下的 .ansible.cfg
 func LoadAnsibleConfig(configPath string) (*AnsibleConfig, error) {
-	var cfgPath string
-	var err error
+	cfgPath, _, err := ResolveAnsibleConfigPath(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfgPath == "" {
+		// 如果找不到配置文件，返回默认配置
+		slog.Debug("未找到 ansible.cfg，使用默认配置")
+		return &AnsibleConfig{}, nil
+	}
 
+	slog.Debug("使用 ansible.cfg", "path", cfgPath)
+	return parseAnsibleConfig(cfgPath)
+}
+
+// ResolveAnsibleConfigPath 按 LoadAnsibleConfig 的查找顺序确定使用的 ansible.cfg 路径
+// 返回路径和来源说明（如 "--config-file"、"环境变量 ANSIBLE_CONFIG"）；找不到配置文件时路径为空，
+// 指定的 configPath 不存在时返回错误
+func ResolveAnsibleConfigPath(configPath string) (string, string, error) {
 	// 如果指定了配置文件路径，直接使用
 	if configPath != "" {
 		if _, err := os.Stat(configPath); err != nil {
-			return nil, fmt.Errorf("配置文件不存在: %s", configPath)
-		}
-		cfgPath = configPath
-	} else {
-		// 首先检查环境变量 ANSIBLE_CONFIG
-		if envCfg := os.Getenv("ANSIBLE_CONFIG"); envCfg != "" {
-			if _, err := os.Stat(envCfg); err == nil {
-				cfgPath = envCfg
-			}
+			return "", "", fmt.Errorf("配置文件不存在: %s", configPath)
 		}
+		return configPath, "--config-file", nil
+	}
 
-		// 如果环境变量未设置或文件不存在，查找默认位置
-		if cfgPath == "" {
-			cfgPath, err = findAnsibleConfig()
-			if err != nil {
-				// 如果找不到配置文件，返回默认配置
-				return &AnsibleConfig{}, nil
-			}
+	// 首先检查环境变量 ANSIBLE_CONFIG
+	if envCfg := os.Getenv("ANSIBLE_CONFIG"); envCfg != "" {
+		if _, err := os.Stat(envCfg); err == nil {
+			return envCfg, "环境变量 ANSIBLE_CONFIG", nil
 		}
 	}
 
-	return parseAnsibleConfig(cfgPath)
+	// 如果环境变量未设置或文件不存在，查找默认位置
+	cfgPath, err := findAnsibleConfig()
+	if err != nil {
+		return "", "", nil
+	}
+	if filepath.Base(cfgPath) == ".ansible.cfg" {
+		return cfgPath, "用户主目录", nil
+	}
+	return cfgPath, "当前目录或父目录", nil
 }
 
 // findAnsibleConfig 查找 ansible.cfg 文件
@@ -180,23 +201,55 @@ func applyConfigValue(config *AnsibleConfig, key, value string) {
 	case "inventory":
 		config.Inventory = value
 	case "private_key_file":
-		config.PrivateKeyFile = value
+		// 支持逗号分隔的多个私钥，分别展开路径
+		keyPaths := ssh.SplitKeyPaths(value)
+		for i, p := range keyPaths {
+			keyPaths[i] = ssh.ExpandPath(p)
+		}
+		config.PrivateKeyFile = strings.Join(keyPaths, ",")
 	case "remote_user":
 		config.RemoteUser = value
 	case "forks":
 		if forks, err := strconv.Atoi(value); err == nil {
 			config.Forks = forks
+			config.ForksSet = true
 		}
 	case "timeout":
 		if timeout, err := strconv.Atoi(value); err == nil {
 			config.Timeout = timeout
+			config.TimeoutSet = true
+		}
+	case "host_key_checking":
+		if enabled, err := ParseAnsibleBool(value); err == nil {
+			config.HostKeyChecking = enabled
+			config.HostKeyCheckingSet = true
+		} else {
+			slog.Debug("忽略无法识别的 host_key_checking", "value", value)
 		}
 	}
 }
 
+// ParseAnsibleBool 解析 Ansible 风格的布尔值（不区分大小写）：
+// true、yes、on、1 为真，false、no、off、0 为假
+func ParseAnsibleBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("无法识别的布尔值: %s", value)
+}
+
 // LoadHostsFromInventory 从 inventory 配置加载主机列表
 // 支持多个文件（逗号分隔），会聚合所有主机
 func LoadHostsFromInventory(inventory string, group string) ([]executor.Host, error) {
+	return LoadHostsFromInventoryWithStrategy(inventory, group, MergeFirst)
+}
+
+// LoadHostsFromInventoryWithStrategy 从 inventory 配置加载主机列表
+// 同一主机在多个 inventory 中重复定义时，按 strategy 决定使用哪个定义的用户、密钥等属性
+func LoadHostsFromInventoryWithStrategy(inventory string, group string, strategy MergeStrategy) ([]executor.Host, error) {
 	if inventory == "" {
 		return nil, fmt.Errorf("inventory 配置为空")
 	}
@@ -204,7 +257,7 @@ func LoadHostsFromInventory(inventory string, group string) ([]executor.Host, er
 	// 分割多个文件路径
 	files := strings.Split(inventory, ",")
 	var allHosts []executor.Host
-	hostMap := make(map[string]bool) // 用于去重
+	hostIndex := make(map[string]int) // 用于去重，记录主机在 allHosts 中的位置
 
 	for _, filePath := range files {
 		filePath = strings.TrimSpace(filePath)
@@ -212,7 +265,7 @@ func LoadHostsFromInventory(inventory string, group string) ([]executor.Host, er
 			continue
 		}
 
-		hosts, err := loadHostsFromInventoryPath(filePath, group)
+		hosts, err := loadHostsFromInventoryPath(filePath, group, strategy)
 		if err != nil {
 			// 如果某个路径加载失败，记录错误但继续处理其他文件
 			fmt.Fprintf(os.Stderr, "警告: 从路径 %s 加载主机失败: %v\n", filePath, err)
@@ -220,7 +273,7 @@ func LoadHostsFromInventory(inventory string, group string) ([]executor.Host, er
 		}
 
 		// 聚合主机并去重
-		allHosts = mergeHostsWithDedup(allHosts, hosts, hostMap)
+		allHosts = mergeHostsWithDedup(allHosts, hosts, hostIndex, strategy)
 	}
 
 	if len(allHosts) == 0 {
@@ -230,16 +283,33 @@ func LoadHostsFromInventory(inventory string, group string) ([]executor.Host, er
 	return allHosts, nil
 }
 
+// LoadHostsFromPathsWithStrategy 从多个 inventory 路径（文件或目录，如多次指定的 -i）加载主机列表并聚合
+// 与 ansible.cfg 中的 inventory 不同，这些路径由用户显式指定，任何一个加载失败都返回错误
+func LoadHostsFromPathsWithStrategy(paths []string, group string, strategy MergeStrategy) ([]executor.Host, error) {
+	var allHosts []executor.Host
+	hostIndex := make(map[string]int) // 用于去重，记录主机在 allHosts 中的位置
+
+	for _, path := range paths {
+		hosts, err := loadHostsFromInventoryPath(path, group, strategy)
+		if err != nil {
+			return nil, fmt.Errorf("从路径 %s 加载主机列表失败: %w", path, err)
+		}
+		allHosts = mergeHostsWithDedup(allHosts, hosts, hostIndex, strategy)
+	}
+
+	return allHosts, nil
+}
+
 // loadHostsFromInventoryPath 从单个 inventory 路径加载主机列表
 // 支持文件和目录两种类型
-func loadHostsFromInventoryPath(filePath, group string) ([]executor.Host, error) {
+func loadHostsFromInventoryPath(filePath, group string, strategy MergeStrategy) ([]executor.Host, error) {
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("路径不存在: %w", err)
 	}
 
 	if info.IsDir() {
-		return LoadHostsFromDirectory(filePath, group)
+		return LoadHostsFromDirectoryWithStrategy(filePath, group, strategy)
 	}
 
 	return loadHostsFromInventoryFile(filePath, group)
@@ -300,14 +370,16 @@ func loadHostsFromInventoryFile(filePath, group string) ([]executor.Host, error)
 	return hosts, nil
 }
 
-// mergeHostsWithDedup 合并主机列表并去重
-func mergeHostsWithDedup(allHosts, newHosts []executor.Host, hostMap map[string]bool) []executor.Host {
+// mergeHostsWithDedup 合并主机列表并去重，重复的主机按 strategy 合并，保持首次出现的顺序
+func mergeHostsWithDedup(allHosts, newHosts []executor.Host, hostIndex map[string]int, strategy MergeStrategy) []executor.Host {
 	for _, host := range newHosts {
 		key := fmt.Sprintf("%s:%s", host.Address, host.Port)
-		if !hostMap[key] {
-			hostMap[key] = true
-			allHosts = append(allHosts, host)
+		if i, ok := hostIndex[key]; ok {
+			allHosts[i] = mergeHost(allHosts[i], host, strategy)
+			continue
 		}
+		hostIndex[key] = len(allHosts)
+		allHosts = append(allHosts, host)
 	}
 	return allHosts
 }
@@ -349,6 +421,27 @@ func LoadGroupsFromInventory(inventory string) ([]string, error) {
 	return allGroups, nil
 }
 
+// LoadGroupsFromPaths 从多个 inventory 路径（文件或目录，如多次指定的 -i）加载组列表，按首次出现的顺序去重
+func LoadGroupsFromPaths(paths []string) ([]string, error) {
+	var allGroups []string
+	groupSet := make(map[string]bool) // 用于去重
+
+	for _, path := range paths {
+		groups, err := loadGroupsFromInventoryPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("从路径 %s 加载组列表失败: %w", path, err)
+		}
+		for _, group := range groups {
+			if !groupSet[group] {
+				groupSet[group] = true
+				allGroups = append(allGroups, group)
+			}
+		}
+	}
+
+	return allGroups, nil
+}
+
 // loadGroupsFromInventoryPath 从单个 inventory 路径加载组列表
 // 支持文件和目录两种类型
 func loadGroupsFromInventoryPath(filePath string) ([]string, error) {
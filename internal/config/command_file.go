@@ -0,0 +1,38 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadCommandFile 从文件加载要依次执行的命令列表
+// 每行一条命令，空行和 # 开头的行会被忽略，行首尾的空白会被去掉
+func LoadCommandFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取命令文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var commands []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commands = append(commands, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取命令文件失败: %w", err)
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("命令文件为空: %s", filePath)
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// commandMapLine JSON Lines 格式中的一行
+type commandMapLine struct {
+	Host    string `json:"host"`
+	Command string `json:"command"`
+}
+
+// LoadCommandMap 从文件加载主机到命令的映射关系
+// 返回 map[string]string，key 是主机地址（"address" 或 "address:port"），value 是要在该主机上执行的命令
+// 支持格式：
+//  1. JSON 对象：
+//     {"192.168.1.10": "systemctl restart nginx", "192.168.1.11:2222": "uptime"}
+//  2. JSON Lines（每行一个对象，空行和 # 开头的行会被忽略）：
+//     {"host": "192.168.1.10", "command": "systemctl restart nginx"}
+//     {"host": "192.168.1.11:2222", "command": "uptime"}
+func LoadCommandMap(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取命令映射文件失败: %w", err)
+	}
+
+	var commands map[string]string
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) && json.Unmarshal(trimmed, &commands) == nil {
+		return validateCommandMap(commands)
+	}
+
+	commands, err = parseCommandMapLines(data)
+	if err != nil {
+		return nil, err
+	}
+	return validateCommandMap(commands)
+}
+
+// parseCommandMapLines 按 JSON Lines 格式解析命令映射
+func parseCommandMapLines(data []byte) (map[string]string, error) {
+	commands := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var entry commandMapLine
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("命令映射文件第 %d 行格式错误: %w", lineNum, err)
+		}
+		if entry.Host == "" {
+			return nil, fmt.Errorf("命令映射文件第 %d 行缺少 host 字段", lineNum)
+		}
+		if _, exists := commands[entry.Host]; exists {
+			return nil, fmt.Errorf("命令映射文件第 %d 行主机重复: %s", lineNum, entry.Host)
+		}
+		commands[entry.Host] = entry.Command
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取命令映射文件失败: %w", err)
+	}
+
+	return commands, nil
+}
+
+// validateCommandMap 校验命令映射：不能为空，且每个主机的命令不能为空
+func validateCommandMap(commands map[string]string) (map[string]string, error) {
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("命令映射文件为空")
+	}
+
+	for host, command := range commands {
+		if strings.TrimSpace(host) == "" {
+			return nil, fmt.Errorf("命令映射中存在空的主机地址")
+		}
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("命令映射中主机 %s 的命令为空", host)
+		}
+	}
+
+	return commands, nil
+}
+
+// ParseGroupCommands 解析按分组指定的命令（--group-command），每项格式为 "分组=命令"
+// 返回 map[string]string，key 是分组名，value 是该分组主机上执行的命令
+func ParseGroupCommands(entries []string) (map[string]string, error) {
+	commands := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		group, command, ok := strings.Cut(entry, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("--group-command 格式错误: %q（应为 分组=命令）", entry)
+		}
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("--group-command 中分组 %s 的命令为空", group)
+		}
+		if _, exists := commands[group]; exists {
+			return nil, fmt.Errorf("--group-command 中分组重复: %s", group)
+		}
+		commands[group] = command
+	}
+	return commands, nil
+}
@@ -2,13 +2,19 @@ package config
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"gossh/internal/executor"
+	"gossh/internal/ssh"
 )
 
 // LoadHostsFromFile 从文件加载主机列表
@@ -23,6 +29,8 @@ import (
 //     host1
 //     host2
 //     如果指定了 group，只加载该分组的主机；如果未指定，加载所有分组的主机
+//  3. JSON 格式（与 list-host --format json 的输出相同）：
+//     [{"address": "host1", "port": "22", "user": "root", "key_path": "", "groups": ["web"]}]
 func LoadHostsFromFile(filePath string) ([]executor.Host, error) {
 	return LoadHostsFromFileWithGroup(filePath, "")
 }
@@ -83,6 +91,7 @@ func LoadHostsFromFileWithGroup(filePath, group string) ([]executor.Host, error)
 	if err != nil {
 		return nil, err
 	}
+	logGroupMatches(filePath, hostsWithGroups, targetGroups)
 
 	// 构建主机到分组的映射（一个主机可能属于多个分组）
 	hostGroupsMap := make(map[string][]string)
@@ -140,11 +149,12 @@ func LoadHostsFromFileWithGroup(filePath, group string) ([]executor.Host, error)
 // detectINIFormat 检测文件是否是 INI 格式
 func detectINIFormat(file *os.File) (bool, error) {
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 	sectionPattern := regexp.MustCompile(`^\s*\[.+\]\s*$`)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+		line := inventoryLine(scanner.Text())
+		if line == "" {
 			continue
 		}
 		// 如果找到 [section] 格式，认为是 INI 格式
@@ -165,15 +175,16 @@ func detectINIFormat(file *os.File) (bool, error) {
 func loadHostsFromINI(file *os.File, targetGroups []string) ([]executor.Host, error) {
 	var hosts []executor.Host
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 	sectionPattern := regexp.MustCompile(`^\s*\[(.+)\]\s*$`)
 	currentGroup := ""
 	loadAllGroups := len(targetGroups) == 0
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		line := inventoryLine(scanner.Text())
 
 		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, "#") {
+		if line == "" {
 			continue
 		}
 
@@ -188,9 +199,8 @@ func loadHostsFromINI(file *os.File, targetGroups []string) ([]executor.Host, er
 			continue
 		}
 
-		// 解析主机行
-		host := parseHostLine(line)
-		hosts = append(hosts, host)
+		// 解析主机行（一行可以包含多个主机）
+		hosts = append(hosts, parseInventoryLine(line)...)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -210,15 +220,15 @@ func loadHostsFromINI(file *os.File, targetGroups []string) ([]executor.Host, er
 func loadHostsFromPlain(file *os.File) ([]executor.Host, error) {
 	var hosts []executor.Host
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+		line := inventoryLine(scanner.Text())
+		if line == "" {
 			continue // 跳过空行和注释
 		}
 
-		host := parseHostLine(line)
-		hosts = append(hosts, host)
+		hosts = append(hosts, parseInventoryLine(line)...)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -230,6 +240,13 @@ func loadHostsFromPlain(file *os.File) ([]executor.Host, error) {
 
 // LoadHostsFromString 从字符串加载主机列表（逗号分隔）
 func LoadHostsFromString(hostsStr string) ([]executor.Host, error) {
+	return LoadHostsFromStringWithInventory(hostsStr, nil)
+}
+
+// LoadHostsFromStringWithInventory 从字符串加载主机列表（逗号分隔），地址已在 inventory 中定义的主机沿用 inventory 中的配置
+// （用户、私钥、分组和主机变量），命令行中显式写出的 user@、:port 和主机变量覆盖 inventory 中的值，
+// 例如 inventory 中的 web1 端口为 22 时，-i web1:2222 会用 web1 的用户和私钥连接 2222 端口
+func LoadHostsFromStringWithInventory(hostsStr string, inventory []executor.Host) ([]executor.Host, error) {
 	if hostsStr == "" {
 		return nil, fmt.Errorf("主机列表为空")
 	}
@@ -243,24 +260,198 @@ func LoadHostsFromString(hostsStr string) ([]executor.Host, error) {
 			continue
 		}
 
-		host := parseHostLine(part)
-		hosts = append(hosts, host)
+		if len(inventory) == 0 {
+			hosts = append(hosts, parseInventoryLine(part)...)
+			continue
+		}
+
+		var hostFields, hostVars []string
+		for _, field := range strings.Fields(part) {
+			if strings.Contains(field, "=") {
+				hostVars = append(hostVars, field)
+			} else {
+				hostFields = append(hostFields, field)
+			}
+		}
+		for _, field := range hostFields {
+			host := overrideInventoryHost(field, inventory)
+			for _, hostVar := range hostVars {
+				applyHostVar(&host, hostVar)
+			}
+			hosts = append(hosts, host)
+		}
 	}
 
 	return hosts, nil
 }
 
+// overrideInventoryHost 解析命令行中的单个主机，地址在 inventory 中时以 inventory 中的定义为基础，
+// 只覆盖显式写出的用户名和端口。显式指定端口时优先匹配地址和端口都相同的定义，否则使用该地址的第一个定义
+func overrideInventoryHost(field string, inventory []executor.Host) executor.Host {
+	host := parseHostLine(field)
+	hostPart := field
+	if idx := strings.Index(hostPart, "@"); idx != -1 {
+		hostPart = hostPart[idx+1:]
+	}
+	explicitPort := strings.Contains(hostPart, ":")
+
+	matched := -1
+	for i, h := range inventory {
+		if h.Address != host.Address {
+			continue
+		}
+		if explicitPort && h.Port == host.Port {
+			matched = i
+			break
+		}
+		if matched == -1 {
+			matched = i
+		}
+	}
+	if matched == -1 {
+		return host
+	}
+
+	merged := inventory[matched]
+	merged.Groups = append([]string(nil), merged.Groups...)
+	if explicitPort {
+		merged.Port = host.Port
+	}
+	if host.User != "" {
+		merged.User = host.User
+	}
+	return merged
+}
+
+// inventoryLine 去掉 inventory 行的注释和首尾空白，整行都是注释时返回空字符串
+// 注释以 # 或 ;（INI 风格）开头：位于行首，或前面是空白（行内注释，如 "web1 # 前端"、"[web] ; 前端"）。
+// 紧跟在其他字符后的 # 和 ; 属于主机名或变量值的一部分（如 gossh_interpreter=/opt/py#3），不会被去掉；
+// 与 splitInventoryFields 相同，"..." 和 '...' 中的内容不会被当作注释（如 gossh_env_MSG="build #1"）
+func inventoryLine(raw string) string {
+	line := strings.TrimSpace(raw)
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case (c == '#' || c == ';') && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
+
+// scanInventoryLines 按行拆分 inventory 文件（bufio.SplitFunc），以 \ 结尾的行与下一行合并（中间用空格分隔），
+// 用于把一行很长的主机列表拆成多行书写
+func scanInventoryLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	var joined []byte
+	for {
+		n, line, err := bufio.ScanLines(data[advance:], atEOF)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n == 0 {
+			if atEOF && advance > 0 {
+				// 最后一行以 \ 结尾，没有下一行可以合并
+				return advance, joined, nil
+			}
+			return 0, nil, nil // 需要更多数据
+		}
+		advance += n
+
+		// 去掉注释后再判断，注释行末尾的 \ 不会合并下一行
+		content := inventoryLine(string(line))
+		if !strings.HasSuffix(content, `\`) {
+			return advance, append(joined, line...), nil
+		}
+		joined = append(joined, content[:len(content)-1]...)
+		joined = append(joined, ' ')
+	}
+}
+
+// parseInventoryLine 解析 inventory 中的一行，返回该行的所有主机
+// 一行可以包含多个空格分隔的主机，例如: web1 web2 web3
+// 含有 = 的字段是主机变量（key=value），对该行的所有主机生效，例如: web1 web2:2222 gossh_timeout=60s；
+// 变量的值可以用引号包含空格，例如: web1 desc="front end"
+func parseInventoryLine(line string) []executor.Host {
+	var hostFields, hostVars []string
+	for _, field := range splitInventoryFields(line) {
+		if strings.Contains(field, "=") {
+			hostVars = append(hostVars, field)
+		} else {
+			hostFields = append(hostFields, field)
+		}
+	}
+
+	hosts := make([]executor.Host, 0, len(hostFields))
+	for _, field := range hostFields {
+		host := parseHostLine(field)
+		for _, hostVar := range hostVars {
+			applyHostVar(&host, hostVar)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// splitInventoryFields 按空白拆分 inventory 行，"..." 和 '...' 中的空白不拆分，并去掉引号，
+// 例如 web1 desc="front end" 拆分为 web1 和 desc=front end；引号没有闭合时到行尾为止
+func splitInventoryFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
 // parseHostLine 解析主机行
 // 支持格式：
 // - host:port
 // - host
 // - user@host:port
 // - user@host
+// 主机后面可以跟空格分隔的 key=value 主机变量，例如: host:port gossh_timeout=60s
 func parseHostLine(line string) executor.Host {
 	host := executor.Host{
 		Port: "22", // 默认 SSH 端口
 	}
 
+	// 拆分主机和主机变量
+	var hostVars []string
+	if fields := splitInventoryFields(line); len(fields) > 0 {
+		line = fields[0]
+		hostVars = fields[1:]
+	}
+
 	// 检查是否有用户信息
 	if idx := strings.Index(line, "@"); idx != -1 {
 		host.User = line[:idx]
@@ -275,9 +466,93 @@ func parseHostLine(line string) executor.Host {
 		host.Address = line
 	}
 
+	for _, field := range hostVars {
+		applyHostVar(&host, field)
+	}
+
 	return host
 }
 
+// applyHostVar 应用单个主机变量（key=value），不认识的变量直接忽略
+// 支持的变量：
+// - gossh_timeout: 主机级连接超时，例如 60s、2m，纯数字按秒计算
+// - gossh_interpreter: 主机级脚本解释器（script 命令），例如 python3、/usr/bin/python3.11
+// - gossh_env_<名称>: 主机级环境变量（run、script 命令），例如 gossh_env_REGION=us-east-1
+func applyHostVar(host *executor.Host, field string) {
+	key, value, ok := strings.Cut(field, "=")
+	if !ok {
+		return
+	}
+
+	if name, ok := strings.CutPrefix(key, "gossh_env_"); ok {
+		if !ssh.ValidEnvName(name) {
+			fmt.Fprintf(os.Stderr, "警告: 主机 %s 的 %s 不是有效的环境变量名称，已忽略\n", host.Address, key)
+			return
+		}
+		// 复制后再修改：同一个主机定义可能被复制给多个主机（如命令行中覆盖 inventory 中的主机）
+		env := maps.Clone(host.Env)
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[name] = value
+		host.Env = env
+		return
+	}
+
+	switch key {
+	case "gossh_timeout":
+		timeout, err := parseHostTimeout(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 主机 %s 的 gossh_timeout 无效（%s），使用全局超时\n", host.Address, value)
+			return
+		}
+		host.Timeout = timeout
+	case "gossh_interpreter":
+		host.Interpreter = value
+	}
+}
+
+// parseHostTimeout 解析超时时间，支持 Go duration 格式（如 60s、2m30s）和纯数字秒数
+func parseHostTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("超时时间必须大于 0")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("超时时间必须大于 0")
+	}
+	return timeout, nil
+}
+
+// logGroupMatches 输出每个分组的主机数以及是否匹配 -g 指定的分组（-v 时可见），用于排查选不到主机的问题
+func logGroupMatches(source string, hostsWithGroups []hostWithGroup, targetGroups []string) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for _, hwg := range hostsWithGroups {
+		if _, exists := counts[hwg.group]; !exists {
+			order = append(order, hwg.group)
+		}
+		counts[hwg.group]++
+	}
+
+	slog.Debug("解析主机列表", "source", source, "hosts", len(hostsWithGroups), "groups", len(order), "target_groups", strings.Join(targetGroups, ","))
+	for _, g := range order {
+		matched := len(targetGroups) == 0 || isGroupMatch(g, targetGroups)
+		slog.Debug("分组匹配", "source", source, "group", g, "hosts", counts[g], "matched", matched)
+	}
+}
+
 // hostWithGroup 用于存储主机和分组的映射关系
 type hostWithGroup struct {
 	host  executor.Host
@@ -288,6 +563,12 @@ type hostWithGroup struct {
 // 会递归读取目录下所有子文件（支持普通格式和 INI 格式），先聚合所有主机，然后根据分组筛选
 // group 为空字符串或 "all" 时加载所有分组的主机，支持逗号分隔的多个组
 func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
+	return LoadHostsFromDirectoryWithStrategy(dirPath, group, MergeFirst)
+}
+
+// LoadHostsFromDirectoryWithStrategy 从目录加载所有文件的主机列表并聚合
+// 同一主机在多个文件（或多个分组）中重复定义时，按 strategy 决定使用哪个定义的用户、密钥等属性
+func LoadHostsFromDirectoryWithStrategy(dirPath, group string, strategy MergeStrategy) ([]executor.Host, error) {
 	// 检查目录是否存在
 	info, err := os.Stat(dirPath)
 	if err != nil {
@@ -302,7 +583,8 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 
 	// 存储所有主机和分组的映射关系
 	var allHostsWithGroup []hostWithGroup
-	hostMap := make(map[string]bool) // 用于去重，key 格式: "address:port"
+	hostMap := make(map[string]bool)                // 用于去重，key 格式: "address:port|group"
+	resolvedHosts := make(map[string]executor.Host) // 按合并策略合并后的主机定义，key 格式: "address:port"
 
 	// 支持的文件扩展名列表（空字符串表示无扩展名的文件也支持）
 	supportedExts := map[string]bool{
@@ -310,6 +592,7 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 		".txt":  true,
 		".conf": true,
 		".hosts": true,
+		".json": true,
 		"":      true, // 无扩展名的文件也支持
 	}
 
@@ -326,12 +609,14 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 
 		// 跳过隐藏文件（以 . 开头的文件）
 		if strings.HasPrefix(info.Name(), ".") {
+			slog.Debug("跳过隐藏文件", "file", path)
 			return nil
 		}
 
 		// 检查文件扩展名
 		ext := strings.ToLower(filepath.Ext(info.Name()))
 		if !supportedExts[ext] {
+			slog.Debug("跳过不支持的文件扩展名", "file", path, "ext", ext)
 			return nil
 		}
 
@@ -343,11 +628,20 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 			return nil
 		}
 
-		// 聚合主机并去重
+		slog.Debug("读取主机文件", "file", path, "hosts", len(hostsWithGroups))
+
+		// 聚合主机并去重（同一主机属于多个分组时保留每个分组的记录）
 		for _, hwg := range hostsWithGroups {
 			key := fmt.Sprintf("%s:%s", hwg.host.Address, hwg.host.Port)
-			if !hostMap[key] {
-				hostMap[key] = true
+			if existing, ok := resolvedHosts[key]; ok {
+				resolvedHosts[key] = mergeHost(existing, hwg.host, strategy)
+			} else {
+				resolvedHosts[key] = hwg.host
+			}
+
+			groupKey := fmt.Sprintf("%s|%s", key, hwg.group)
+			if !hostMap[groupKey] {
+				hostMap[groupKey] = true
 				allHostsWithGroup = append(allHostsWithGroup, hwg)
 			}
 		}
@@ -358,6 +652,7 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 	if err != nil {
 		return nil, fmt.Errorf("遍历目录失败: %w", err)
 	}
+	logGroupMatches(dirPath, allHostsWithGroup, targetGroups)
 
 	// 构建主机到分组的映射（一个主机可能属于多个分组）
 	hostGroupsMap := make(map[string][]string)
@@ -394,7 +689,7 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 			key := fmt.Sprintf("%s:%s", hwg.host.Address, hwg.host.Port)
 			if !resultHostMap[key] {
 				resultHostMap[key] = true
-				host := hwg.host
+				host := resolvedHosts[key]
 				host.Groups = hostGroupsMap[key]
 				resultHosts = append(resultHosts, host)
 			}
@@ -406,7 +701,7 @@ func LoadHostsFromDirectory(dirPath, group string) ([]executor.Host, error) {
 				key := fmt.Sprintf("%s:%s", hwg.host.Address, hwg.host.Port)
 				if !resultHostMap[key] {
 					resultHostMap[key] = true
-					host := hwg.host
+					host := resolvedHosts[key]
 					host.Groups = hostGroupsMap[key]
 					resultHosts = append(resultHosts, host)
 				}
@@ -433,6 +728,16 @@ func loadHostsFromFileWithGroups(filePath string) ([]hostWithGroup, error) {
 	}
 	defer file.Close()
 
+	// 检测是否是 JSON 格式（list-host --format json 的输出）
+	isJSON, err := detectJSONFormat(file)
+	if err != nil {
+		return nil, fmt.Errorf("检测文件格式失败: %w", err)
+	}
+	file.Seek(0, 0)
+	if isJSON {
+		return loadHostsFromJSONWithGroups(file)
+	}
+
 	// 检测是否是 INI 格式
 	isINI, err := detectINIFormat(file)
 	if err != nil {
@@ -454,14 +759,15 @@ func loadHostsFromFileWithGroups(filePath string) ([]hostWithGroup, error) {
 func loadHostsFromINIWithGroups(file *os.File) ([]hostWithGroup, error) {
 	var hostsWithGroups []hostWithGroup
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 	sectionPattern := regexp.MustCompile(`^\s*\[(.+)\]\s*$`)
 	currentGroup := ""
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		line := inventoryLine(scanner.Text())
 
 		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, "#") {
+		if line == "" {
 			continue
 		}
 
@@ -471,12 +777,13 @@ func loadHostsFromINIWithGroups(file *os.File) ([]hostWithGroup, error) {
 			continue
 		}
 
-		// 解析主机行
-		host := parseHostLine(line)
-		hostsWithGroups = append(hostsWithGroups, hostWithGroup{
-			host:  host,
-			group: currentGroup,
-		})
+		// 解析主机行（一行可以包含多个主机）
+		for _, host := range parseInventoryLine(line) {
+			hostsWithGroups = append(hostsWithGroups, hostWithGroup{
+				host:  host,
+				group: currentGroup,
+			})
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -490,18 +797,20 @@ func loadHostsFromINIWithGroups(file *os.File) ([]hostWithGroup, error) {
 func loadHostsFromPlainWithGroups(file *os.File) ([]hostWithGroup, error) {
 	var hostsWithGroups []hostWithGroup
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+		line := inventoryLine(scanner.Text())
+		if line == "" {
 			continue // 跳过空行和注释
 		}
 
-		host := parseHostLine(line)
-		hostsWithGroups = append(hostsWithGroups, hostWithGroup{
-			host:  host,
-			group: "", // 普通格式没有分组
-		})
+		for _, host := range parseInventoryLine(line) {
+			hostsWithGroups = append(hostsWithGroups, hostWithGroup{
+				host:  host,
+				group: "", // 普通格式没有分组
+			})
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -519,6 +828,16 @@ func LoadGroupsFromFile(filePath string) ([]string, error) {
 	}
 	defer file.Close()
 
+	// 检测是否是 JSON 格式
+	isJSON, err := detectJSONFormat(file)
+	if err != nil {
+		return nil, fmt.Errorf("检测文件格式失败: %w", err)
+	}
+	file.Seek(0, 0)
+	if isJSON {
+		return loadGroupsFromJSON(file)
+	}
+
 	// 检测是否是 INI 格式
 	isINI, err := detectINIFormat(file)
 	if err != nil {
@@ -541,13 +860,14 @@ func loadGroupsFromINI(file *os.File) ([]string, error) {
 	var groups []string
 	groupSet := make(map[string]bool)
 	scanner := bufio.NewScanner(file)
+	scanner.Split(scanInventoryLines)
 	sectionPattern := regexp.MustCompile(`^\s*\[(.+)\]\s*$`)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		line := inventoryLine(scanner.Text())
 
 		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, "#") {
+		if line == "" {
 			continue
 		}
 
@@ -589,6 +909,7 @@ func LoadGroupsFromDirectory(dirPath string) ([]string, error) {
 		".txt":   true,
 		".conf":  true,
 		".hosts": true,
+		".json":  true,
 		"":       true, // 无扩展名的文件也支持
 	}
 
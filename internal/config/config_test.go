@@ -0,0 +1,121 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInventoryLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string // address:port
+		timeout time.Duration
+		env     map[string]string
+	}{
+		{
+			name: "多个主机",
+			line: "web1 web2 web3",
+			want: []string{"web1:22", "web2:22", "web3:22"},
+		},
+		{
+			name:    "主机和变量混合",
+			line:    "web1 web2:2222 gossh_timeout=60s",
+			want:    []string{"web1:22", "web2:2222"},
+			timeout: 60 * time.Second,
+		},
+		{
+			name: "双引号中的值包含空格",
+			line: `web1 gossh_env_DESC="front end" web2`,
+			want: []string{"web1:22", "web2:22"},
+			env:  map[string]string{"DESC": "front end"},
+		},
+		{
+			name: "单引号中的值包含空格",
+			line: `web1 gossh_env_DESC='front end'`,
+			want: []string{"web1:22"},
+			env:  map[string]string{"DESC": "front end"},
+		},
+		{
+			name: "引号中的值包含 # 且有行内注释",
+			line: `web1 gossh_env_MSG="build #1" web2 # 前端`,
+			want: []string{"web1:22", "web2:22"},
+			env:  map[string]string{"MSG": "build #1"},
+		},
+		{
+			name: "引号中的值包含 ;",
+			line: `web1 gossh_env_MSG='a ; b' ; 前端`,
+			want: []string{"web1:22"},
+			env:  map[string]string{"MSG": "a ; b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 与读取 inventory 文件时相同，先去掉注释再解析
+			hosts := parseInventoryLine(inventoryLine(tt.line))
+			got := make([]string, 0, len(hosts))
+			for _, h := range hosts {
+				got = append(got, h.Address+":"+h.Port)
+				if h.Timeout != tt.timeout {
+					t.Errorf("主机 %s 的超时为 %v，期望 %v", h.Address, h.Timeout, tt.timeout)
+				}
+				if len(h.Env) != 0 || len(tt.env) != 0 {
+					if !reflect.DeepEqual(h.Env, tt.env) {
+						t.Errorf("主机 %s 的环境变量为 %v，期望 %v", h.Address, h.Env, tt.env)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInventoryLine(inventoryLine(%q)) = %v，期望 %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitInventoryFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"web1  web2\tweb3", []string{"web1", "web2", "web3"}},
+		{`web1 desc="front end"`, []string{"web1", "desc=front end"}},
+		{`web1 desc='a "b" c'`, []string{"web1", `desc=a "b" c`}},
+		{`web1 desc=""`, []string{"web1", "desc="}},
+		{`web1 desc="unterminated value`, []string{"web1", "desc=unterminated value"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitInventoryFields(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitInventoryFields(%q) = %q，期望 %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestInventoryLine(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"web1 # 注释", "web1"},
+		{"web1\t; 注释", "web1"},
+		{"  web1 web2  ", "web1 web2"},
+		{"[web] # 注释", "[web]"},
+		{"[web];注释", "[web];注释"},
+		{"[web]\t;注释", "[web]"},
+		{"# 整行注释", ""},
+		{"; 整行注释", ""},
+		{"web1 gossh_env_TAG=a#b", "web1 gossh_env_TAG=a#b"},
+		{"web1 gossh_env_TAG=a;b # 注释", "web1 gossh_env_TAG=a;b"},
+		{`web1 gossh_env_MSG="build #1"`, `web1 gossh_env_MSG="build #1"`},
+		{`web1 gossh_env_MSG='a ; b' # 注释`, `web1 gossh_env_MSG='a ; b'`},
+		{`web1 gossh_env_MSG="x" # "注释"`, `web1 gossh_env_MSG="x"`},
+	}
+
+	for _, tt := range tests {
+		if got := inventoryLine(tt.raw); got != tt.want {
+			t.Errorf("inventoryLine(%q) = %q，期望 %q", tt.raw, got, tt.want)
+		}
+	}
+}
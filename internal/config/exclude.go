@@ -0,0 +1,96 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gossh/internal/executor"
+)
+
+// ExcludeRule 排除主机的规则，Port 为空时匹配该地址的所有端口
+type ExcludeRule struct {
+	Address string
+	Port    string
+}
+
+// ParseExcludeRules 解析排除规则，每项为 "address" 或 "address:port"（可以带 user@ 前缀，会被忽略）
+// 与主机列表相同，最后一个冒号之后的部分视为端口
+func ParseExcludeRules(entries []string) []ExcludeRule {
+	var rules []ExcludeRule
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		value := fields[0]
+		if idx := strings.Index(value, "@"); idx != -1 {
+			value = value[idx+1:]
+		}
+
+		var rule ExcludeRule
+		if idx := strings.LastIndex(value, ":"); idx != -1 {
+			rule.Address = value[:idx]
+			rule.Port = value[idx+1:]
+		} else {
+			rule.Address = value
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// LoadExcludeFile 从文件加载要排除的主机，每行一个 "address" 或 "address:port"
+// 空行和注释会被忽略，格式与普通主机列表相同（--write-failures 写入的失败主机文件也可以直接使用）
+func LoadExcludeFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取排除主机文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := inventoryLine(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entries = append(entries, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取排除主机文件失败: %w", err)
+	}
+	return entries, nil
+}
+
+// ExcludeHosts 从主机列表中去掉匹配排除规则的主机，返回剩余的主机和被排除的主机数
+func ExcludeHosts(hosts []executor.Host, rules []ExcludeRule) ([]executor.Host, int) {
+	if len(rules) == 0 {
+		return hosts, 0
+	}
+
+	kept := make([]executor.Host, 0, len(hosts))
+	for _, h := range hosts {
+		if !matchExcludeRules(h, rules) {
+			kept = append(kept, h)
+		}
+	}
+	return kept, len(hosts) - len(kept)
+}
+
+// matchExcludeRules 判断主机是否匹配任意一条排除规则
+func matchExcludeRules(h executor.Host, rules []ExcludeRule) bool {
+	port := h.Port
+	if port == "" {
+		port = "22"
+	}
+	for _, rule := range rules {
+		if rule.Address == h.Address && (rule.Port == "" || rule.Port == port) {
+			return true
+		}
+	}
+	return false
+}
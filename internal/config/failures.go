@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gossh/internal/executor"
+)
+
+// WriteFailuresFile 将失败的主机写入文件，每行一个 "[user@]address:port [主机变量...]"
+// 文件格式与普通主机列表相同，可以直接作为 --retry-failed 或 -i 的主机来源；
+// 没有失败主机时也会写入（只包含注释），避免下次误用旧的失败列表
+func WriteFailuresFile(path string, hosts []executor.Host, command string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# gossh 失败主机列表（%s）\n", time.Now().Format("2006-01-02 15:04:05"))
+	if command != "" {
+		fmt.Fprintf(&sb, "# 命令: %s\n", strings.ReplaceAll(command, "\n", " "))
+	}
+	fmt.Fprintf(&sb, "# 失败主机数: %d\n", len(hosts))
+	for _, h := range hosts {
+		sb.WriteString(failureLine(h))
+		sb.WriteByte('\n')
+	}
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("写入失败主机文件失败: %w", err)
+	}
+	return nil
+}
+
+// failureLine 返回主机在失败主机文件中的一行，用户和 inventory 主机变量（gossh_timeout、gossh_interpreter、
+// gossh_env_<名称>）按 parseInventoryLine 支持的格式写入，重试时与上次使用相同的配置；
+// 私钥、分组和 vars 目录中的 become 配置无法用主机变量表示，不会写入
+func failureLine(h executor.Host) string {
+	var sb strings.Builder
+	if h.User != "" {
+		sb.WriteString(h.User + "@")
+	}
+	port := h.Port
+	if port == "" {
+		port = "22"
+	}
+	fmt.Fprintf(&sb, "%s:%s", h.Address, port)
+
+	if h.Timeout > 0 {
+		sb.WriteString(" gossh_timeout=" + h.Timeout.String())
+	}
+	if h.Interpreter != "" {
+		sb.WriteString(" gossh_interpreter=" + quoteInventoryValue(h.Interpreter))
+	}
+	names := make([]string, 0, len(h.Env))
+	for name := range h.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(" gossh_env_" + name + "=" + quoteInventoryValue(h.Env[name]))
+	}
+	return sb.String()
+}
+
+// quoteInventoryValue 为包含空白、引号、# 或 ; 的主机变量值加上引号，使 splitInventoryFields 能还原原值；
+// 值中有双引号时使用单引号（同时包含两种引号的值无法表示）
+func quoteInventoryValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'#;") {
+		return value
+	}
+	if strings.Contains(value, `"`) {
+		return "'" + value + "'"
+	}
+	return `"` + value + `"`
+}
+
+// LoadFailuresFile 从 WriteFailuresFile 写入的文件加载主机列表
+func LoadFailuresFile(path string) ([]executor.Host, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("失败主机文件不存在: %s", path)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("失败主机文件是目录: %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开失败主机文件失败: %w", err)
+	}
+	defer file.Close()
+
+	hosts, err := loadHostsFromPlain(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("失败主机文件中没有主机（上次执行可能全部成功）: %s", path)
+	}
+	return hosts, nil
+}
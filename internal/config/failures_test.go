@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gossh/internal/executor"
+)
+
+func TestFailuresFileRoundTrip(t *testing.T) {
+	hosts := []executor.Host{
+		{Address: "web1", Port: "22"},
+		{Address: "web2", Port: "2222", User: "deploy", Timeout: 90 * time.Second},
+		{
+			Address:     "10.0.0.3",
+			Port:        "22",
+			User:        "app",
+			Interpreter: "python3 -u",
+			Env:         map[string]string{"DESC": "front end", "QUOTE": `say "hi"`, "TAG": "v1", "MSG": "build #1 ; done"},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "failures.txt")
+	if err := WriteFailuresFile(path, hosts, "uptime"); err != nil {
+		t.Fatalf("WriteFailuresFile() 失败: %v", err)
+	}
+	got, err := LoadFailuresFile(path)
+	if err != nil {
+		t.Fatalf("LoadFailuresFile() 失败: %v", err)
+	}
+	if !reflect.DeepEqual(got, hosts) {
+		t.Errorf("LoadFailuresFile() = %+v，期望 %+v", got, hosts)
+	}
+}
+
+func TestFailureLine(t *testing.T) {
+	tests := []struct {
+		host executor.Host
+		want string
+	}{
+		{executor.Host{Address: "web1", Port: "22"}, "web1:22"},
+		{executor.Host{Address: "web1"}, "web1:22"},
+		{executor.Host{Address: "web1", Port: "2222", User: "deploy"}, "deploy@web1:2222"},
+		{
+			executor.Host{Address: "web1", Port: "22", Timeout: time.Minute, Env: map[string]string{"B": "x y", "A": ""}},
+			`web1:22 gossh_timeout=1m0s gossh_env_A="" gossh_env_B="x y"`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := failureLine(tt.host); got != tt.want {
+			t.Errorf("failureLine(%+v) = %q，期望 %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GosshConfigEnv 指定 gossh 配置文件路径的环境变量
+const GosshConfigEnv = "GOSSH_CONFIG"
+
+// GosshConfigEntry gossh 配置文件中的一项配置
+type GosshConfigEntry struct {
+	Key   string // 配置项名称，与命令行参数的长名称一致（如 forks、timeout、inventory）
+	Value string
+	Line  int // 所在行号，用于错误提示
+}
+
+// LoadGosshConfig 加载 gossh 自身的配置文件，返回配置文件路径和按出现顺序排列的配置项
+// 查找顺序：
+// 1. 环境变量 GOSSH_CONFIG
+// 2. 用户主目录下的 .gossh.yaml
+// 找不到配置文件时返回空路径和空配置，不视为错误
+func LoadGosshConfig() (string, []GosshConfigEntry, error) {
+	cfgPath := os.Getenv(GosshConfigEnv)
+	if cfgPath != "" {
+		if _, err := os.Stat(cfgPath); err != nil {
+			return "", nil, fmt.Errorf("%s 指定的配置文件不存在: %s", GosshConfigEnv, cfgPath)
+		}
+	} else {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil, nil
+		}
+		cfgPath = filepath.Join(homeDir, ".gossh.yaml")
+		if _, err := os.Stat(cfgPath); err != nil {
+			return "", nil, nil
+		}
+	}
+
+	entries, err := parseGosshConfig(cfgPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return cfgPath, entries, nil
+}
+
+// parseGosshConfig 解析 gossh 配置文件
+// 只支持 YAML 的扁平 "key: value" 子集：忽略空行和 # 注释，值两端的引号会被去掉，不支持嵌套结构
+func parseGosshConfig(cfgPath string) ([]GosshConfigEntry, error) {
+	file, err := os.Open(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开配置文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var entries []GosshConfigEntry
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		rawLine := scanner.Text()
+		line := strings.TrimSpace(rawLine)
+
+		// 跳过空行、注释和 YAML 文档分隔符
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		if rawLine[0] == ' ' || rawLine[0] == '\t' || strings.HasPrefix(line, "- ") {
+			return nil, fmt.Errorf("%s 第 %d 行: 不支持嵌套结构或列表，只支持 \"key: value\" 格式", cfgPath, lineNum)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s 第 %d 行: 格式错误，应为 \"key: value\"", cfgPath, lineNum)
+		}
+		key = strings.TrimSpace(key)
+		value = parseGosshConfigValue(value)
+		if key == "" {
+			return nil, fmt.Errorf("%s 第 %d 行: 配置项名称为空", cfgPath, lineNum)
+		}
+		if prev, exists := seen[key]; exists {
+			return nil, fmt.Errorf("%s 第 %d 行: 配置项 %s 重复（第 %d 行已定义）", cfgPath, lineNum, key, prev)
+		}
+		seen[key] = lineNum
+
+		entries = append(entries, GosshConfigEntry{Key: key, Value: value, Line: lineNum})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	return entries, nil
+}
+
+// parseGosshConfigValue 处理配置值：去掉行尾注释和两端的引号
+func parseGosshConfigValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+
+	// YAML 中 " #" 之后是注释
+	if idx := strings.Index(value, " #"); idx != -1 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return value
+}
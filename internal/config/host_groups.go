@@ -88,6 +88,7 @@ func loadHostGroupsMapFromDirectory(dirPath, group string) (map[string][]string,
 		".txt":   true,
 		".conf":  true,
 		".hosts": true,
+		".json":  true,
 		"":       true, // 无扩展名的文件也支持
 	}
 
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gossh/internal/executor"
+)
+
+// jsonInventoryHost JSON 主机列表中的一项，字段与 list-host --format json 的输出一致（输出中的 host 是显示名称，解析时忽略）
+type jsonInventoryHost struct {
+	Address string   `json:"address"`
+	Port    jsonPort `json:"port"`
+	User    string   `json:"user"`
+	KeyPath string   `json:"key_path"`
+	Groups  []string `json:"groups"`
+}
+
+// jsonPort 端口，兼容字符串（"22"）和数字（22）两种写法
+type jsonPort string
+
+// UnmarshalJSON 解析字符串或数字形式的端口
+func (p *jsonPort) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = jsonPort(strings.TrimSpace(s))
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("端口必须是字符串或整数: %s", data)
+	}
+	*p = jsonPort(strconv.Itoa(n))
+	return nil
+}
+
+// detectJSONFormat 检测文件是否是 JSON 格式的主机列表（以 "[" 开头，第一个元素是对象，或者是空数组）
+// INI 文件同样以 "[" 开头，但 "[" 后面紧跟的是分组名
+func detectJSONFormat(file *os.File) (bool, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return false, err
+	}
+	return isJSONInventory(data), nil
+}
+
+// isJSONInventory 判断内容是否是 JSON 格式的主机列表
+func isJSONInventory(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		return false
+	}
+	rest := bytes.TrimSpace(trimmed[1:])
+	return bytes.HasPrefix(rest, []byte("{")) || bytes.Equal(rest, []byte("]"))
+}
+
+// parseJSONInventory 解析 JSON 格式的主机列表
+func parseJSONInventory(file *os.File) ([]jsonInventoryHost, error) {
+	var entries []jsonInventoryHost
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("解析 JSON 主机列表失败: %w", err)
+	}
+
+	for i, entry := range entries {
+		if strings.TrimSpace(entry.Address) == "" {
+			return nil, fmt.Errorf("JSON 主机列表第 %d 项缺少 address", i+1)
+		}
+	}
+	return entries, nil
+}
+
+// loadHostsFromJSONWithGroups 从 JSON 格式文件加载所有主机和分组的映射关系
+// 每个分组一条记录，没有分组的主机记为空分组（与普通格式相同）
+func loadHostsFromJSONWithGroups(file *os.File) ([]hostWithGroup, error) {
+	entries, err := parseJSONInventory(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostsWithGroups []hostWithGroup
+	for _, entry := range entries {
+		host := executor.Host{
+			Address: strings.TrimSpace(entry.Address),
+			Port:    string(entry.Port),
+			User:    entry.User,
+			KeyPath: entry.KeyPath,
+		}
+		if len(entry.Groups) == 0 {
+			hostsWithGroups = append(hostsWithGroups, hostWithGroup{host: host})
+			continue
+		}
+		for _, group := range entry.Groups {
+			hostsWithGroups = append(hostsWithGroups, hostWithGroup{host: host, group: group})
+		}
+	}
+	return hostsWithGroups, nil
+}
+
+// loadGroupsFromJSON 从 JSON 格式文件加载所有组名（按首次出现的顺序）
+func loadGroupsFromJSON(file *os.File) ([]string, error) {
+	entries, err := parseJSONInventory(file)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []string{}
+	groupSet := make(map[string]bool)
+	for _, entry := range entries {
+		for _, group := range entry.Groups {
+			if group != "" && !groupSet[group] {
+				groupSet[group] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, nil
+}
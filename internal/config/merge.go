@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+
+	"gossh/internal/executor"
+	"gossh/internal/ssh"
+)
+
+// MergeStrategy 同一主机（address:port）在多个 inventory 来源中重复定义时的合并策略
+// 多个来源指 ansible.cfg 中逗号分隔的多个 inventory，以及目录中的多个文件
+type MergeStrategy string
+
+const (
+	MergeFirst  MergeStrategy = "first" // 使用最先出现的定义（默认）
+	MergeLast   MergeStrategy = "last"  // 使用最后出现的定义
+	MergeFields MergeStrategy = "merge" // 合并各定义的非空字段，都不为空时后出现的覆盖之前的
+)
+
+// ParseMergeStrategy 解析 --merge-strategy 参数，空字符串表示默认的 first
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch MergeStrategy(s) {
+	case "":
+		return MergeFirst, nil
+	case MergeFirst, MergeLast, MergeFields:
+		return MergeStrategy(s), nil
+	}
+	return "", fmt.Errorf("不支持的合并策略 %q，可选值: first、last、merge", s)
+}
+
+// mergeHost 按合并策略合并同一主机的两个定义（existing 先出现，incoming 后出现）
+// 分组始终取并集：主机属于哪些分组与采用哪个定义无关
+func mergeHost(existing, incoming executor.Host, strategy MergeStrategy) executor.Host {
+	var merged executor.Host
+	switch strategy {
+	case MergeLast:
+		merged = incoming
+	case MergeFields:
+		merged = existing
+		if incoming.User != "" {
+			merged.User = incoming.User
+		}
+		if incoming.KeyPath != "" {
+			merged.KeyPath = incoming.KeyPath
+		}
+		if incoming.Timeout > 0 {
+			merged.Timeout = incoming.Timeout
+		}
+		if incoming.Interpreter != "" {
+			merged.Interpreter = incoming.Interpreter
+		}
+		if len(incoming.Env) > 0 {
+			merged.Env = ssh.MergeEnv(existing.Env, incoming.Env)
+		}
+	default:
+		merged = existing
+	}
+
+	merged.Groups = appendUniqueGroups(append([]string(nil), existing.Groups...), incoming.Groups)
+	return merged
+}
+
+// appendUniqueGroups 追加不重复的分组
+func appendUniqueGroups(groups []string, more []string) []string {
+	for _, g := range more {
+		found := false
+		for _, existing := range groups {
+			if existing == g {
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gossh/internal/executor"
+)
+
+// varsFileExts vars 文件支持的扩展名（按查找顺序），空字符串表示无扩展名
+var varsFileExts = []string{"", ".yml", ".yaml", ".ini"}
+
+// varEntry vars 文件中的一个变量
+type varEntry struct {
+	key   string
+	value string
+}
+
+// ApplyVarsDir 从 vars 目录加载 Ansible 风格的 host_vars 和 group_vars，应用到主机上
+// 目录结构：
+//
+//	<varsDir>/group_vars/all[.yml|.yaml|.ini]
+//	<varsDir>/group_vars/<分组名>[.yml|.yaml|.ini]
+//	<varsDir>/host_vars/<主机地址>[.yml|.yaml|.ini]
+//
+// 也可以是同名目录，目录中的文件按文件名顺序读取。
+// 优先级：host_vars > group_vars/<分组>（多个分组按分组名排序，后面的覆盖前面的）> group_vars/all > inventory 中的值
+func ApplyVarsDir(hosts []executor.Host, varsDir string) error {
+	info, err := os.Stat(varsDir)
+	if err != nil {
+		return fmt.Errorf("vars 目录不存在或无法访问: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("vars 路径不是目录: %s", varsDir)
+	}
+
+	groupVars := make(map[string][]varEntry)
+	loadGroupVars := func(group string) ([]varEntry, error) {
+		if vars, ok := groupVars[group]; ok {
+			return vars, nil
+		}
+		vars, err := loadVarsFile(filepath.Join(varsDir, "group_vars", group))
+		if err != nil {
+			return nil, err
+		}
+		groupVars[group] = vars
+		return vars, nil
+	}
+
+	for i := range hosts {
+		host := &hosts[i]
+
+		groups := append([]string{"all"}, sortedGroups(host.Groups)...)
+		for _, group := range groups {
+			vars, err := loadGroupVars(group)
+			if err != nil {
+				return err
+			}
+			applyVars(host, vars)
+		}
+
+		vars, err := loadVarsFile(filepath.Join(varsDir, "host_vars", host.Address))
+		if err != nil {
+			return err
+		}
+		applyVars(host, vars)
+	}
+
+	return nil
+}
+
+// sortedGroups 返回排序后的分组列表（不含 all，避免重复应用）
+func sortedGroups(groups []string) []string {
+	sorted := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if g != "all" {
+			sorted = append(sorted, g)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// loadVarsFile 加载 base 对应的 vars 文件（base 本身或加上扩展名），base 为目录时读取目录中的所有文件
+// 文件不存在时返回空列表
+func loadVarsFile(base string) ([]varEntry, error) {
+	for _, ext := range varsFileExts {
+		path := base + ext
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			return parseVarsFile(path)
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取 vars 目录失败: %w", err)
+		}
+		var vars []varEntry
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			fileVars, err := parseVarsFile(filepath.Join(path, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			vars = append(vars, fileVars...)
+		}
+		return vars, nil
+	}
+	return nil, nil
+}
+
+// parseVarsFile 解析 vars 文件
+// 每行一个变量，支持 YAML 的 "key: value" 和 INI 的 "key=value"（以先出现的分隔符为准）；
+// 忽略空行、# 和 ; 注释、[section] 行，以及 YAML 的嵌套结构和列表（gossh 用不到这些变量）
+func parseVarsFile(path string) ([]varEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开 vars 文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var vars []varEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rawLine := scanner.Text()
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") ||
+			line == "---" || isSectionHeader(line) {
+			continue
+		}
+		// 缩进的行属于嵌套结构，- 开头的是列表项
+		if rawLine[0] == ' ' || rawLine[0] == '\t' || strings.HasPrefix(line, "- ") {
+			continue
+		}
+
+		sep := strings.IndexAny(line, ":=")
+		if sep <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := parseGosshConfigValue(line[sep+1:])
+		if value == "" {
+			continue
+		}
+		vars = append(vars, varEntry{key: key, value: value})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 vars 文件 %s 失败: %w", path, err)
+	}
+	return vars, nil
+}
+
+// applyVars 将变量应用到主机上，不认识的变量直接忽略
+// 支持的变量：
+// - ansible_user / ansible_ssh_user: SSH 用户名
+// - ansible_port / ansible_ssh_port: SSH 端口
+// - ansible_ssh_private_key_file / ansible_private_key_file: SSH 私钥路径
+// - ansible_become: 是否使用 sudo 执行（true/false、yes/no）
+// - ansible_become_user: sudo 切换的目标用户
+// - gossh_timeout、gossh_interpreter、gossh_env_<名称>: 与 inventory 中的主机变量相同
+func applyVars(host *executor.Host, vars []varEntry) {
+	for _, v := range vars {
+		switch v.key {
+		case "ansible_user", "ansible_ssh_user":
+			host.User = v.value
+		case "ansible_port", "ansible_ssh_port":
+			host.Port = v.value
+		case "ansible_ssh_private_key_file", "ansible_private_key_file":
+			host.KeyPath = v.value
+		case "ansible_become":
+			become, ok := parseVarsBool(v.value)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "警告: 主机 %s 的 ansible_become 无效（%s），已忽略\n", host.Address, v.value)
+				continue
+			}
+			host.Become = become
+		case "ansible_become_user":
+			host.BecomeUser = v.value
+		default:
+			applyHostVar(host, v.key+"="+v.value)
+		}
+	}
+}
+
+// parseVarsBool 解析 YAML/INI 中的布尔值
+func parseVarsBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
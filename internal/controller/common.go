@@ -1,24 +1,39 @@
 package controller
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"os"
+	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	"gossh/internal/config"
 	"gossh/internal/executor"
+	"gossh/internal/history"
+	"gossh/internal/logger"
+	"gossh/internal/ssh"
 )
 
 // CommonConfig 公共配置结构
 type CommonConfig struct {
-	ConfigFile  string // ansible.cfg 配置文件路径
-	Inventory   string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），多次指定 -i 时每项都必须是文件或目录路径
 	Group       string
 	User        string
 	KeyPath     string
 	Password    string
 	Port        string
 	Concurrency int
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars），为空表示不加载
+
+	Exclude     []string // 要排除的主机（address 或 address:port）
+	ExcludeFile string   // 要排除的主机列表文件
 }
 
 // MergeCommonConfig 合并公共配置（优先级：命令行参数 > ansible.cfg > 默认值）
@@ -32,6 +47,12 @@ func MergeCommonConfig(cfg *CommonConfig) *CommonConfig {
 		Password:    cfg.Password,
 		Port:        cfg.Port,
 		Concurrency: cfg.Concurrency,
+
+		MergeStrategy: cfg.MergeStrategy,
+		VarsDir:       cfg.VarsDir,
+
+		Exclude:     cfg.Exclude,
+		ExcludeFile: cfg.ExcludeFile,
 	}
 
 	ansibleCfg, err := config.LoadAnsibleConfig(cfg.ConfigFile)
@@ -65,6 +86,35 @@ func MergeCommonConfig(cfg *CommonConfig) *CommonConfig {
 	return merged
 }
 
+// newHostKeyChecker 确定是否校验主机密钥，需要校验时加载 ~/.ssh/known_hosts
+// 优先级：--host-key-check（yes/no）> ansible.cfg 的 host_key_checking > 不校验；不校验时返回 nil
+func newHostKeyChecker(configFile, hostKeyCheck string) (*ssh.HostKeyChecker, error) {
+	var enabled bool
+	if hostKeyCheck != "" {
+		value, err := config.ParseAnsibleBool(hostKeyCheck)
+		if err != nil {
+			return nil, fmt.Errorf("--host-key-check 只支持 yes 或 no: %s", hostKeyCheck)
+		}
+		enabled = value
+	} else if ansibleCfg, err := config.LoadAnsibleConfig(configFile); err == nil && ansibleCfg.HostKeyCheckingSet {
+		enabled = ansibleCfg.HostKeyChecking
+		slog.Debug("使用 ansible.cfg 的 host_key_checking", "enabled", enabled)
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+	return ssh.NewHostKeyChecker(ssh.DefaultKnownHostsPath)
+}
+
+// newIdentityAgent 根据 --identity-agent 连接 ssh-agent，socket 为空时返回 nil（不使用 ssh-agent）
+func newIdentityAgent(socket string) (*ssh.IdentityAgent, error) {
+	if socket == "" {
+		return nil, nil
+	}
+	return ssh.NewIdentityAgent(socket)
+}
+
 // LoadHosts 加载主机列表（公共方法）
 // 优先级：命令行参数 > ansible.cfg inventory > 错误
 // 返回的主机列表会按照 Address:Port 排序，确保每次执行顺序一致
@@ -72,15 +122,20 @@ func LoadHosts(cfg *CommonConfig, requireHosts bool) ([]executor.Host, error) {
 	var hosts []executor.Host
 	var err error
 
+	strategy, err := config.ParseMergeStrategy(cfg.MergeStrategy)
+	if err != nil {
+		return nil, err
+	}
+
 	// 如果未指定主机来源，尝试从 ansible.cfg 加载
-	if cfg.Inventory == "" {
-		hosts, err = loadHostsFromAnsibleConfig(cfg.ConfigFile, cfg.Group, requireHosts)
+	if len(cfg.Inventory) == 0 {
+		hosts, err = loadHostsFromAnsibleConfig(cfg.ConfigFile, cfg.Group, strategy, requireHosts)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// 从命令行参数加载主机列表
-		hosts, err = loadHostsFromConfig(cfg)
+		hosts, err = loadHostsFromConfig(cfg, strategy)
 		if err != nil {
 			return nil, err
 		}
@@ -90,17 +145,31 @@ func LoadHosts(cfg *CommonConfig, requireHosts bool) ([]executor.Host, error) {
 		return nil, fmt.Errorf("主机列表为空")
 	}
 
+	// 应用 host_vars 和 group_vars 中的连接变量
+	if cfg.VarsDir != "" {
+		if err := config.ApplyVarsDir(hosts, cfg.VarsDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// 去掉排除的主机
+	hosts, err = excludeHosts(hosts, cfg.Exclude, cfg.ExcludeFile)
+	if err != nil {
+		return nil, err
+	}
+
 	// 对主机列表进行排序，确保每次执行顺序一致
 	sortHosts(hosts)
+	slog.Debug("主机列表加载完成", "inventory", cfg.Inventory, "group", cfg.Group, "hosts", len(hosts))
 
 	return hosts, nil
 }
 
 // loadHostsFromAnsibleConfig 从 ansible.cfg 配置文件加载主机列表
-func loadHostsFromAnsibleConfig(configFile, group string, requireHosts bool) ([]executor.Host, error) {
+func loadHostsFromAnsibleConfig(configFile, group string, strategy config.MergeStrategy, requireHosts bool) ([]executor.Host, error) {
 	ansibleCfg, err := config.LoadAnsibleConfig(configFile)
 	if err == nil && ansibleCfg.Inventory != "" {
-		hosts, err := config.LoadHostsFromInventory(ansibleCfg.Inventory, group)
+		hosts, err := config.LoadHostsFromInventoryWithStrategy(ansibleCfg.Inventory, group, strategy)
 		if err != nil {
 			return nil, fmt.Errorf("从 ansible.cfg inventory 加载主机列表失败: %w", err)
 		}
@@ -115,26 +184,36 @@ func loadHostsFromAnsibleConfig(configFile, group string, requireHosts bool) ([]
 }
 
 // loadHostsFromConfig 从配置中加载主机列表
-// 支持从目录、文件或逗号分隔的字符串加载
-func loadHostsFromConfig(cfg *CommonConfig) ([]executor.Host, error) {
-	if cfg.Inventory == "" {
+// 支持从目录、文件或逗号分隔的字符串加载；多次指定 -i 时每项都作为文件或目录路径，聚合所有路径中的主机
+func loadHostsFromConfig(cfg *CommonConfig, strategy config.MergeStrategy) ([]executor.Host, error) {
+	if len(cfg.Inventory) == 0 {
 		return nil, nil
 	}
 
+	if len(cfg.Inventory) > 1 {
+		for _, path := range cfg.Inventory {
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("多次指定 -i 时每项都必须是文件或目录路径: %s", path)
+			}
+		}
+		return config.LoadHostsFromPathsWithStrategy(cfg.Inventory, cfg.Group, strategy)
+	}
+	inventory := cfg.Inventory[0]
+
 	// 判断是文件、目录还是逗号分隔的主机列表
-	info, err := os.Stat(cfg.Inventory)
+	info, err := os.Stat(inventory)
 	if err == nil {
 		// 路径存在，判断是文件还是目录
 		if info.IsDir() {
 			// 是目录
-			hosts, err := config.LoadHostsFromDirectory(cfg.Inventory, cfg.Group)
+			hosts, err := config.LoadHostsFromDirectoryWithStrategy(inventory, cfg.Group, strategy)
 			if err != nil {
 				return nil, fmt.Errorf("从目录加载主机列表失败: %w", err)
 			}
 			return hosts, nil
 		} else {
 			// 是文件
-			hosts, err := config.LoadHostsFromFileWithGroup(cfg.Inventory, cfg.Group)
+			hosts, err := config.LoadHostsFromFileWithGroup(inventory, cfg.Group)
 			if err != nil {
 				return nil, fmt.Errorf("加载主机列表失败: %w", err)
 			}
@@ -144,13 +223,127 @@ func loadHostsFromConfig(cfg *CommonConfig) ([]executor.Host, error) {
 
 	// 路径不存在，可能是IP地址或逗号分隔的主机列表
 	// 尝试作为主机列表解析（支持单个IP地址和逗号分隔的多个IP地址）
-	hosts, err := config.LoadHostsFromString(cfg.Inventory)
+	// 已在 ansible.cfg inventory 中定义的主机沿用其中的配置，显式指定的 :port 覆盖 inventory 中的端口
+	hosts, err := config.LoadHostsFromStringWithInventory(inventory, loadInventoryDefinitions(cfg.ConfigFile, strategy))
 	if err != nil {
-		return nil, fmt.Errorf("无效的主机列表格式: %s（必须是文件路径、目录路径或IP地址/逗号分隔的主机列表）", cfg.Inventory)
+		return nil, fmt.Errorf("无效的主机列表格式: %s（必须是文件路径、目录路径或IP地址/逗号分隔的主机列表）", inventory)
 	}
 	return hosts, nil
 }
 
+// loadInventoryDefinitions 加载 ansible.cfg inventory 中的所有主机，供命令行指定的主机沿用其中的配置
+// 没有配置 inventory 或加载失败时返回 nil（命令行主机按原样使用）
+func loadInventoryDefinitions(configFile string, strategy config.MergeStrategy) []executor.Host {
+	ansibleCfg, err := config.LoadAnsibleConfig(configFile)
+	if err != nil || ansibleCfg.Inventory == "" {
+		return nil
+	}
+
+	hosts, err := config.LoadHostsFromInventoryWithStrategy(ansibleCfg.Inventory, "all", strategy)
+	if err != nil {
+		slog.Debug("加载 ansible.cfg inventory 失败，命令行指定的主机不沿用 inventory 配置", "error", err)
+		return nil
+	}
+	return hosts
+}
+
+// appendHistory 将执行结果追加到 --db 指定的 SQLite 数据库，失败时只记录日志并打印警告（不影响命令的结果）
+func appendHistory(dbPath string, hookCtx hookContext, startTime time.Time, totalDuration time.Duration, results []*ssh.Result, log *logger.Logger) {
+	record := history.Record{
+		Command:   hookCtx.Command,
+		Target:    hookCtx.Target,
+		Group:     hookCtx.Group,
+		StartedAt: startTime,
+		Duration:  totalDuration,
+		Results:   results,
+	}
+	if err := history.Append(dbPath, record); err != nil {
+		log.LogError("写入执行记录失败", err)
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+}
+
+// inventoryText 返回用于显示和日志的主机列表来源，多次指定 -i 时以逗号分隔
+func inventoryText(inventory []string) string {
+	return strings.Join(inventory, ",")
+}
+
+// newTOTPProvider 创建二次验证码（TOTP）提供者
+// 未指定验证码、密钥且未开启交互式输入时返回 nil，表示不启用 keyboard-interactive 认证
+// 密钥为空时会尝试读取环境变量 GOSSH_TOTP_SECRET，避免密钥出现在命令行参数中
+// 开启交互式输入时在这里（连接主机和显示进度条之前）提示输入验证码，与 --confirm-over 的确认相同，
+// 避免提示出现在并发连接的进度输出中间
+func newTOTPProvider(code, secret string, prompt bool, password string) (*ssh.TOTPProvider, error) {
+	if secret == "" {
+		secret = os.Getenv("GOSSH_TOTP_SECRET")
+	}
+
+	if code == "" && secret == "" && !prompt {
+		return nil, nil
+	}
+
+	provider, err := ssh.NewTOTPProvider(code, secret, password)
+	if err != nil {
+		return nil, err
+	}
+	if prompt {
+		if _, err := provider.Code(); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+// validateLocalFile 检查本地文件存在、是普通文件且可读
+// 在连接任何主机之前调用，避免单个本地错误在每台主机上重复报错
+func validateLocalFile(localPath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("本地文件不存在: %s", localPath)
+		}
+		return fmt.Errorf("无法访问本地文件 %s: %w", localPath, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("本地路径是目录而不是文件: %s", localPath)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("本地文件不可读 %s: %w", localPath, err)
+	}
+	f.Close()
+
+	return nil
+}
+
+// validateTimeouts 检查 --connect-timeout 和 --timeout
+// 两者都设置时，连接超时不能超过操作总时间（否则连接阶段就会被操作超时中断）
+func validateTimeouts(connectTimeout, timeout time.Duration) error {
+	if connectTimeout < 0 {
+		return fmt.Errorf("--connect-timeout 不能为负数: %v", connectTimeout)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("--timeout 不能为负数: %v", timeout)
+	}
+	if connectTimeout > 0 && timeout > 0 && connectTimeout > timeout {
+		return fmt.Errorf("--connect-timeout（%v）不能大于 --timeout（%v）", connectTimeout, timeout)
+	}
+	return nil
+}
+
+// applyDeadline 为执行器设置整批执行的总时间上限（--deadline），从调用时开始计时，deadline 为 0 时不限制
+// 返回的函数释放计时器，需在执行结束后调用
+func applyDeadline(exec *executor.Executor, deadline time.Duration) context.CancelFunc {
+	if deadline <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	exec.SetContext(ctx)
+	return cancel
+}
+
 // sortHosts 对主机列表进行排序，按照 Address:Port 排序
 // 确保每次执行时主机顺序一致，这样 limit 和 offset 才能稳定工作
 func sortHosts(hosts []executor.Host) {
@@ -163,3 +356,84 @@ func sortHosts(hosts []executor.Host) {
 		return hosts[i].Port < hosts[j].Port
 	})
 }
+
+// shuffleHosts 随机打乱主机顺序（--shuffle），在 limit/offset 之前调用，使 --limit 选出的是随机的主机
+// seed 为 0 时使用随机种子（-v 时输出种子，便于重现）；指定 seed 时主机列表不变则打乱的结果相同
+func shuffleHosts(hosts []executor.Host, shuffle bool, seed int64) []executor.Host {
+	if !shuffle {
+		return hosts
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+	rng.Shuffle(len(hosts), func(i, j int) {
+		hosts[i], hosts[j] = hosts[j], hosts[i]
+	})
+	slog.Debug("随机打乱主机顺序", "seed", seed, "hosts", len(hosts))
+	return hosts
+}
+
+// excludeHosts 去掉 --exclude 和 --exclude-file 指定的主机，有主机被排除时打印排除的数量
+// 全部主机都被排除时返回错误
+func excludeHosts(hosts []executor.Host, exclude []string, excludeFile string) ([]executor.Host, error) {
+	entries := exclude
+	if excludeFile != "" {
+		fileEntries, err := config.LoadExcludeFile(excludeFile)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(append([]string{}, exclude...), fileEntries...)
+	}
+	if len(entries) == 0 {
+		return hosts, nil
+	}
+
+	kept, excluded := config.ExcludeHosts(hosts, config.ParseExcludeRules(entries))
+	slog.Debug("排除主机", "rules", len(entries), "excluded", excluded, "remaining", len(kept))
+	if excluded > 0 {
+		fmt.Fprintf(os.Stderr, "已排除 %d 台主机（剩余 %d 台）\n", excluded, len(kept))
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("排除后主机列表为空（共 %d 台主机全部被排除）", len(hosts))
+	}
+	return kept, nil
+}
+
+// validateBecomePassword 校验 --become-password 和 --become-prompt：sudo 密码需要配合 --become，提示符需要配合 sudo 密码且必须是有效的正则表达式
+func validateBecomePassword(become bool, password, prompt string) error {
+	if password != "" && !become {
+		return fmt.Errorf("--become-password 需要与 --become 一起使用")
+	}
+	if prompt != "" {
+		if password == "" {
+			return fmt.Errorf("--become-prompt 需要与 --become-password 一起使用")
+		}
+		if _, err := ssh.CompileBecomePrompt(prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// becomePrompt 返回 sudo 密码提示符的匹配规则，未设置 sudo 密码时为 nil（提示符已在 validateBecomePassword 中校验）
+func becomePrompt(password, prompt string) *regexp.Regexp {
+	if password == "" {
+		return nil
+	}
+	re, err := ssh.CompileBecomePrompt(prompt)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// preResolveHosts 预先解析所有主机名（--pre-resolve）
+// 无法解析的主机在执行前打印警告并记录日志，执行时直接记为连接失败
+func preResolveHosts(exec *executor.Executor, concurrency int, log *logger.Logger) {
+	for _, failure := range exec.PreResolve(concurrency) {
+		fmt.Fprintf(os.Stderr, "警告: 无法解析主机名 %s: %v\n", failure.Address, failure.Err)
+		log.LogError("无法解析主机名", failure.Err, "host", failure.Address)
+	}
+}
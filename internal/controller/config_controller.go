@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gossh/internal/config"
+	"gossh/internal/executor"
+	"gossh/internal/view"
+)
+
+// ConfigController 处理 config 命令的业务逻辑
+type ConfigController struct{}
+
+// NewConfigController 创建新的 ConfigController
+func NewConfigController() *ConfigController {
+	return &ConfigController{}
+}
+
+// ConfigRequest config 命令的请求参数（与其他命令相同的全局参数）
+type ConfigRequest struct {
+	ConfigFile     string   // ansible.cfg 配置文件路径
+	Inventory      []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group          string   // Ansible INI 格式的分组名称
+	User           string
+	KeyPath        string
+	Password       string
+	Port           string
+	Concurrency    int
+	Timeout        time.Duration // 单台主机的操作总时间上限（-T）
+	ConnectTimeout time.Duration // 连接超时（--connect-timeout）
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+
+	Exclude     []string // 要排除的主机（address 或 address:port）
+	ExcludeFile string   // 要排除的主机列表文件
+
+	GosshConfig string            // 使用的 gossh 配置文件路径，为空表示没有
+	FlagSources map[string]string // 显式设置的参数（key 为参数长名称）的来源：命令行参数或 gossh 配置文件
+}
+
+// ConfigResponse config 命令的响应
+type ConfigResponse struct {
+	Items []view.ResolvedConfigItem
+}
+
+// Execute 执行 config 命令：按其他命令相同的优先级合并配置并说明每项的来源，不连接任何主机
+func (c *ConfigController) Execute(req *ConfigRequest) (*ConfigResponse, error) {
+	cfgPath, cfgSource, err := config.ResolveAnsibleConfigPath(req.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	ansibleCfg, err := config.LoadAnsibleConfig(req.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfgPath == "" {
+		cfgSource = "未找到（ANSIBLE_CONFIG、当前目录及父目录、~/.ansible.cfg）"
+	}
+
+	merged := MergeCommonConfig(&CommonConfig{
+		ConfigFile:  req.ConfigFile,
+		Inventory:   req.Inventory,
+		Group:       req.Group,
+		User:        req.User,
+		KeyPath:     req.KeyPath,
+		Password:    req.Password,
+		Port:        req.Port,
+		Concurrency: req.Concurrency,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+
+		Exclude:     req.Exclude,
+		ExcludeFile: req.ExcludeFile,
+	})
+
+	var items []view.ResolvedConfigItem
+	add := func(name, value, source string) {
+		items = append(items, view.ResolvedConfigItem{Name: name, Value: value, Source: source})
+	}
+
+	gosshSource := ""
+	if req.GosshConfig == "" {
+		gosshSource = fmt.Sprintf("未找到（%s、~/.gossh.yaml）", config.GosshConfigEnv)
+	}
+	add("gossh 配置文件", req.GosshConfig, gosshSource)
+	add("ansible.cfg", cfgPath, cfgSource)
+
+	// 主机列表：-i 优先，未指定时使用 ansible.cfg 的 inventory
+	switch {
+	case len(req.Inventory) > 0:
+		add("主机列表", inventoryText(req.Inventory), req.flagSource("inventory"))
+	case ansibleCfg.Inventory != "":
+		add("主机列表", ansibleCfg.Inventory, "ansible.cfg inventory")
+	default:
+		add("主机列表", "", "")
+	}
+	add("分组", req.Group, req.flagSource("group"))
+	add("主机数", c.hostCount(merged), "")
+
+	add("用户名", merged.User, mergedSource(req.User, merged.User, req.flagSource("user"), "ansible.cfg remote_user"))
+	add("SSH 密钥", merged.KeyPath, mergedSource(req.KeyPath, merged.KeyPath, req.flagSource("key"), "ansible.cfg private_key_file"))
+	if merged.Password != "" {
+		add("密码", "***已设置***", req.flagSource("password"))
+	} else {
+		add("密码", "", "")
+	}
+	add("端口", merged.Port, sourceOrDefault(req.flagSource("port")))
+
+	forksSource := req.flagSource("forks")
+	if req.Concurrency <= 0 {
+		forksSource = "默认值"
+		if ansibleCfg.ForksSet {
+			forksSource = "ansible.cfg forks"
+		}
+	}
+	add("并发数", fmt.Sprintf("%d", merged.Concurrency), forksSource)
+
+	// -T 未指定时只有 ping 使用 ansible.cfg 的 timeout（默认 30s），其他命令不限制
+	if req.Timeout > 0 {
+		add("超时", req.Timeout.String(), req.flagSource("timeout"))
+	} else {
+		pingTimeout, timeoutSource := 30*time.Second, "默认值"
+		if ansibleCfg.TimeoutSet && ansibleCfg.Timeout > 0 {
+			pingTimeout, timeoutSource = time.Duration(ansibleCfg.Timeout)*time.Second, "ansible.cfg timeout"
+		}
+		add("超时", fmt.Sprintf("不限制（ping: %s）", pingTimeout), timeoutSource)
+	}
+	if req.ConnectTimeout > 0 {
+		add("连接超时", req.ConnectTimeout.String(), req.flagSource("connect-timeout"))
+	} else {
+		add("连接超时", executor.DefaultConnectTimeout.String(), "默认值")
+	}
+
+	add("合并策略", getMergeStrategy(req.MergeStrategy), sourceOrDefault(req.flagSource("merge-strategy")))
+	if req.VarsDir != "" {
+		add("vars 目录", req.VarsDir, req.flagSource("vars-dir"))
+	}
+	if len(req.Exclude) > 0 {
+		add("排除主机", strings.Join(req.Exclude, ","), req.flagSource("exclude"))
+	}
+	if req.ExcludeFile != "" {
+		add("排除主机文件", req.ExcludeFile, req.flagSource("exclude-file"))
+	}
+
+	return &ConfigResponse{Items: items}, nil
+}
+
+// hostCount 按合并后的配置加载主机列表（只读取本地文件，不连接主机），返回主机数或加载失败的原因
+func (c *ConfigController) hostCount(cfg *CommonConfig) string {
+	hosts, err := LoadHosts(cfg, false)
+	if err != nil {
+		return fmt.Sprintf("加载失败: %v", err)
+	}
+	return fmt.Sprintf("%d", len(hosts))
+}
+
+// flagSource 返回显式设置的参数的来源，未设置时为空
+func (req *ConfigRequest) flagSource(name string) string {
+	return req.FlagSources[name]
+}
+
+// mergedSource 返回合并后的值的来源：命令行（或 gossh 配置文件）指定时为 flagSource，否则来自 ansible.cfg
+func mergedSource(requested, merged, flagSource, ansibleSource string) string {
+	switch {
+	case requested != "":
+		return flagSource
+	case merged != "":
+		return ansibleSource
+	default:
+		return ""
+	}
+}
+
+// sourceOrDefault 参数有默认值时，未显式设置的来源为默认值
+func sourceOrDefault(source string) string {
+	if source == "" {
+		return "默认值"
+	}
+	return source
+}
+
+// getMergeStrategy 返回合并策略，为空时为 first
+func getMergeStrategy(strategy string) string {
+	if strategy == "" {
+		return "first"
+	}
+	return strategy
+}
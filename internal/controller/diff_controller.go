@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"gossh/internal/executor"
+	"gossh/internal/logger"
+	"gossh/internal/ssh"
+	"gossh/internal/view"
+)
+
+// DiffController 处理 diff 命令的业务逻辑
+type DiffController struct{}
+
+// NewDiffController 创建新的 DiffController
+func NewDiffController() *DiffController {
+	return &DiffController{}
+}
+
+// DiffCommandRequest diff 命令的请求参数
+type DiffCommandRequest struct {
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group       string   // Ansible INI 格式的分组名称
+	User        string
+	KeyPath     string
+	Password    string
+	Port        string
+	TOTP        string // 二次验证码（固定值）
+	TOTPSecret  string // TOTP 密钥（Base32），每次连接自动生成验证码
+	TOTPPrompt  bool   // 交互式输入二次验证码
+	LocalPath   string
+	RemotePath  string
+	Concurrency int
+	LogDir      string
+	Limit       int
+	Offset      int
+
+	ConnectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手，默认: 10s）
+	Timeout        time.Duration // 单台主机的操作总时间上限（0 表示不限制）
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+	PreResolve    bool   // 执行前预先解析所有主机名，之后的连接复用解析结果
+	ProxyCommand  string // ProxyCommand 模板（%h、%p、%r），通过该命令的标准输入输出连接主机
+	HostKeyCheck  string // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking，都未设置时不校验
+	IdentityAgent string // ssh-agent 的 socket 路径（SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取），为空时不使用 ssh-agent
+	NoDefaultKey  bool   // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
+
+	ReuseConnections bool // 复用连接：同一主机的多个步骤共用空闲连接，而不是每次重新建立连接
+
+	Shuffle bool  // 执行前随机打乱主机顺序（在 limit/offset 之前应用）
+	Seed    int64 // 打乱主机顺序的随机种子（0 表示每次随机），指定后结果可重现
+}
+
+// DiffCommandResponse diff 命令的响应
+type DiffCommandResponse struct {
+	Results       []*ssh.Result
+	TotalDuration time.Duration
+	Group         string          // 分组名称（用户指定的）
+	Hosts         []executor.Host // 主机列表（包含分组信息）
+}
+
+// Execute 执行 diff 命令
+func (c *DiffController) Execute(req *DiffCommandRequest) (*DiffCommandResponse, error) {
+	// 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
+	mergedReq := c.mergeConfig(req)
+
+	// 创建日志记录器
+	log, err := logger.NewLogger(mergedReq.LogDir, "diff")
+	if err != nil {
+		return nil, fmt.Errorf("创建日志记录器失败: %w", err)
+	}
+	defer log.Close()
+
+	// 打印当前配置参数
+	view.PrintDiffConfig(
+		inventoryText(mergedReq.Inventory),
+		mergedReq.Group,
+		mergedReq.User,
+		mergedReq.KeyPath,
+		mergedReq.Password,
+		mergedReq.Port,
+		mergedReq.LocalPath,
+		mergedReq.RemotePath,
+		mergedReq.Concurrency,
+	)
+
+	// 记录命令开始
+	log.LogCommandStart("diff", map[string]interface{}{
+		"inventory":   inventoryText(mergedReq.Inventory),
+		"group":       mergedReq.Group,
+		"user":        mergedReq.User,
+		"key_path":    mergedReq.KeyPath,
+		"port":        mergedReq.Port,
+		"local_path":  mergedReq.LocalPath,
+		"remote_path": mergedReq.RemotePath,
+		"concurrency": mergedReq.Concurrency,
+	})
+
+	// 验证参数
+	if err := c.validateRequest(mergedReq); err != nil {
+		log.LogError("参数验证失败", err)
+		return nil, err
+	}
+
+	// 加载主机列表
+	hosts, err := c.loadHosts(mergedReq)
+	if err != nil {
+		log.LogError("加载主机列表失败", err)
+		return nil, err
+	}
+
+	// 随机打乱主机顺序（--shuffle），在 offset 和 limit 之前应用
+	hosts = shuffleHosts(hosts, mergedReq.Shuffle, mergedReq.Seed)
+
+	// 应用 offset 和 limit
+	hosts = c.applyLimitAndOffset(hosts, mergedReq.Offset, mergedReq.Limit)
+
+	// 记录主机列表
+	hostAddresses := make([]string, len(hosts))
+	for i, h := range hosts {
+		hostAddresses[i] = h.DisplayName()
+	}
+	log.LogHosts(hostAddresses)
+
+	// 设置默认端口
+	port := mergedReq.Port
+	if port == "" {
+		port = "22"
+	}
+
+	// 创建二次验证码提供者
+	totp, err := newTOTPProvider(mergedReq.TOTP, mergedReq.TOTPSecret, mergedReq.TOTPPrompt, mergedReq.Password)
+	if err != nil {
+		log.LogError("创建 TOTP 提供者失败", err)
+		return nil, err
+	}
+
+	// 创建执行器
+	exec := executor.NewExecutorWithTimeouts(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, totp, mergedReq.ConnectTimeout, mergedReq.Timeout)
+	exec.SetProxyCommand(mergedReq.ProxyCommand)
+
+	// 主机密钥校验（--host-key-check 或 ansible.cfg 的 host_key_checking）
+	hostKeyChecker, err := newHostKeyChecker(mergedReq.ConfigFile, mergedReq.HostKeyCheck)
+	if err != nil {
+		log.LogError("加载主机密钥校验失败", err)
+		return nil, err
+	}
+	exec.SetHostKeyChecker(hostKeyChecker)
+
+	// ssh-agent 认证（--identity-agent）
+	identityAgent, err := newIdentityAgent(mergedReq.IdentityAgent)
+	if err != nil {
+		log.LogError("连接 ssh-agent 失败", err)
+		return nil, err
+	}
+	defer identityAgent.Close()
+	exec.SetIdentityAgent(identityAgent)
+	exec.SetNoDefaultKey(mergedReq.NoDefaultKey)
+
+	// 复用连接：连接用完后放回连接池，执行结束时关闭
+	if mergedReq.ReuseConnections {
+		pool := ssh.NewConnectionPool(ssh.DefaultPoolIdleTTL)
+		defer pool.Close()
+		exec.SetConnectionPool(pool)
+	}
+
+	// 预先解析主机名
+	if mergedReq.PreResolve {
+		preResolveHosts(exec, mergedReq.Concurrency, log)
+	}
+
+	// 创建进度跟踪器
+	progressTracker := view.NewProgressTracker(len(hosts), "对比文件")
+
+	// 记录开始时间
+	startTime := time.Now()
+
+	// 对比文件
+	results, err := exec.DiffFile(
+		mergedReq.LocalPath,
+		mergedReq.RemotePath,
+		mergedReq.Concurrency,
+		progressTracker,
+	)
+
+	// 记录结束时间并计算总耗时
+	totalDuration := time.Since(startTime)
+
+	// 停止进度跟踪器
+	progressTracker.Stop()
+
+	// 记录每个主机的对比结果
+	successCount := 0
+	for _, result := range results {
+		success := result.ExitCode == 0 && result.Error == nil
+		if success {
+			successCount++
+		}
+		log.LogHostResult(
+			result.Host,
+			result.Command,
+			result.ExitCode,
+			result.Duration,
+			success,
+			result.Stdout,
+			result.Stderr,
+			result.Error,
+		)
+	}
+
+	// 记录命令结束
+	commandSuccess := err == nil && successCount == len(results)
+	if err != nil {
+		log.LogCommandEnd("diff", totalDuration, false, err)
+		return nil, fmt.Errorf("对比失败: %w", err)
+	}
+
+	log.LogCommandEnd("diff", totalDuration, commandSuccess, nil)
+
+	return &DiffCommandResponse{
+		Results:       results,
+		TotalDuration: totalDuration,
+		Group:         mergedReq.Group,
+		Hosts:         hosts,
+	}, nil
+}
+
+// mergeConfig 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
+func (c *DiffController) mergeConfig(req *DiffCommandRequest) *DiffCommandRequest {
+	commonCfg := MergeCommonConfig(&CommonConfig{
+		ConfigFile:  req.ConfigFile,
+		Inventory:   req.Inventory,
+		Group:       req.Group,
+		User:        req.User,
+		KeyPath:     req.KeyPath,
+		Password:    req.Password,
+		Port:        req.Port,
+		Concurrency: req.Concurrency,
+	})
+
+	return &DiffCommandRequest{
+		ConfigFile:  req.ConfigFile,
+		Inventory:   commonCfg.Inventory,
+		Group:       commonCfg.Group,
+		User:        commonCfg.User,
+		KeyPath:     commonCfg.KeyPath,
+		Password:    commonCfg.Password,
+		Port:        commonCfg.Port,
+		TOTP:        req.TOTP,
+		TOTPSecret:  req.TOTPSecret,
+		TOTPPrompt:  req.TOTPPrompt,
+		LocalPath:   req.LocalPath,
+		RemotePath:  req.RemotePath,
+		Concurrency: commonCfg.Concurrency,
+		LogDir:      req.LogDir,
+		Limit:       req.Limit,
+		Offset:      req.Offset,
+		Shuffle:     req.Shuffle,
+		Seed:        req.Seed,
+
+		ConnectTimeout: req.ConnectTimeout,
+		Timeout:        req.Timeout,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+		PreResolve:    req.PreResolve,
+		ProxyCommand:  req.ProxyCommand,
+		HostKeyCheck:  req.HostKeyCheck,
+		IdentityAgent: req.IdentityAgent,
+		NoDefaultKey:  req.NoDefaultKey,
+
+		ReuseConnections: req.ReuseConnections,
+	}
+}
+
+// validateRequest 验证请求参数
+func (c *DiffController) validateRequest(req *DiffCommandRequest) error {
+	if req.Seed != 0 && !req.Shuffle {
+		return fmt.Errorf("--seed 需要与 --shuffle 一起使用")
+	}
+
+	if req.LocalPath == "" {
+		return fmt.Errorf("必须指定本地文件路径（-l）")
+	}
+
+	if req.RemotePath == "" {
+		return fmt.Errorf("必须指定远程文件路径（-r）")
+	}
+
+	if req.User == "" {
+		return fmt.Errorf("必须指定用户名（-u 或 ansible.cfg 中的 remote_user）")
+	}
+
+	if err := validateTimeouts(req.ConnectTimeout, req.Timeout); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadHosts 加载主机列表
+func (c *DiffController) loadHosts(req *DiffCommandRequest) ([]executor.Host, error) {
+	return LoadHosts(&CommonConfig{
+		ConfigFile: req.ConfigFile,
+		Inventory:  req.Inventory,
+		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+	}, true)
+}
+
+// applyLimitAndOffset 应用 limit 和 offset 来过滤主机列表
+func (c *DiffController) applyLimitAndOffset(hosts []executor.Host, offset, limit int) []executor.Host {
+	total := len(hosts)
+	if total == 0 {
+		return hosts
+	}
+
+	// 应用 offset
+	if offset > 0 {
+		if offset >= total {
+			return []executor.Host{}
+		}
+		hosts = hosts[offset:]
+	}
+
+	// 应用 limit
+	if limit > 0 && limit < len(hosts) {
+		hosts = hosts[:limit]
+	}
+
+	return hosts
+}
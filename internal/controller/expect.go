@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gossh/internal/ssh"
+)
+
+// outputExpectation 输出合规检查（--expect、--expect-file），二者只能指定一个
+type outputExpectation struct {
+	pattern  *regexp.Regexp // --expect: 在标准输出中查找的正则表达式
+	expected string         // --expect-file: 预期的完整输出（去掉首尾空白后比较）
+}
+
+// newOutputExpectation 编译 --expect 或读取 --expect-file，都未指定时返回 nil
+func newOutputExpectation(expect, expectFile string) (*outputExpectation, error) {
+	switch {
+	case expect != "" && expectFile != "":
+		return nil, fmt.Errorf("--expect 与 --expect-file 只能指定一个")
+	case expect != "":
+		pattern, err := regexp.Compile(expect)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 --expect 正则表达式 %q: %w", expect, err)
+		}
+		return &outputExpectation{pattern: pattern}, nil
+	case expectFile != "":
+		data, err := os.ReadFile(expectFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 --expect-file 失败: %w", err)
+		}
+		return &outputExpectation{expected: strings.TrimSpace(string(data))}, nil
+	}
+	return nil, nil
+}
+
+// matches 判断标准输出是否符合预期
+func (e *outputExpectation) matches(stdout string) bool {
+	if e.pattern != nil {
+		return e.pattern.MatchString(stdout)
+	}
+	return strings.TrimSpace(stdout) == e.expected
+}
+
+// apply 为每台主机设置合规检查结果（见 check），expectation 为 nil 时什么也不做
+func (e *outputExpectation) apply(results []*ssh.Result) {
+	if e == nil {
+		return
+	}
+	for _, result := range results {
+		e.check(result)
+	}
+}
+
+// hostHook 返回每台主机得到结果后立即设置合规检查结果的钩子，需要在打印输出等其他主机钩子之前添加，
+// 使 --sequential-output 逐台打印时已经有检查结果；expectation 为 nil 时返回 nil
+func (e *outputExpectation) hostHook() func(*ssh.Result) {
+	if e == nil {
+		return nil
+	}
+	return e.check
+}
+
+// check 设置一台主机的合规检查结果：命令执行成功且输出符合预期为合规，输出不符合预期或命令执行失败为不合规；
+// 跳过的主机和不可达的主机（命令没有执行）不检查
+func (e *outputExpectation) check(result *ssh.Result) {
+	if result == nil || result.Skipped || ssh.IsUnreachable(result.Error) {
+		return
+	}
+	if result.Error == nil && result.ExitCode == 0 && e.matches(result.Stdout) {
+		result.Compliance = ssh.ComplianceCompliant
+	} else {
+		result.Compliance = ssh.ComplianceNonCompliant
+	}
+}
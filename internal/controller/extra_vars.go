@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gossh/internal/executor"
+)
+
+// commandTemplateFields 命令模板中可以使用的主机字段，--extra-vars 中的变量与之并列
+var commandTemplateFields = []string{"Host", "Port", "User", "Groups"}
+
+// parseExtraVars 解析 --extra-vars（可以多次指定），每项可以是 JSON 对象（{"version":"1.2.3"}）
+// 或空格分隔的 key=value（version=1.2.3 env=prod），同名变量后指定的覆盖之前的；没有参数时返回 nil
+func parseExtraVars(entries []string) (map[string]any, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	vars := make(map[string]any)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, "{") {
+			decoder := json.NewDecoder(strings.NewReader(entry))
+			decoder.UseNumber() // 保留数字的原始写法，避免大整数被转换为浮点数
+			var object map[string]any
+			if err := decoder.Decode(&object); err != nil {
+				return nil, fmt.Errorf("无效的 --extra-vars %q: JSON 解析失败: %w", entry, err)
+			}
+			for key, value := range object {
+				vars[key] = value
+			}
+			continue
+		}
+
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("无效的 --extra-vars: 不能为空")
+		}
+		for _, field := range fields {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("无效的 --extra-vars %q，格式应为 JSON 对象或 key=value", field)
+			}
+			vars[key] = value
+		}
+	}
+
+	for _, field := range commandTemplateFields {
+		if _, ok := vars[field]; ok {
+			return nil, fmt.Errorf("--extra-vars 中的变量 %s 与主机字段同名，请换一个名称", field)
+		}
+	}
+	return vars, nil
+}
+
+// parseCommandTemplate 解析 -c 的命令模板，引用不存在的变量时渲染失败
+func parseCommandTemplate(command string) (*template.Template, error) {
+	tmpl, err := template.New("-c").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return nil, fmt.Errorf("命令模板解析失败: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderHostCommands 为每台主机渲染命令模板，返回 address:port -> 命令 的映射（与 --command-map 的格式相同）
+// 模板中可以使用主机字段 {{.Host}}、{{.Port}}、{{.User}}、{{.Groups}} 和 --extra-vars 中的变量；
+// 主机未单独指定用户和端口时使用全局的 user 和 port（都未指定时端口为 22）
+func renderHostCommands(command string, hosts []executor.Host, vars map[string]any, user, port string) (map[string]string, error) {
+	tmpl, err := parseCommandTemplate(command)
+	if err != nil {
+		return nil, err
+	}
+
+	if port == "" {
+		port = "22"
+	}
+
+	commands := make(map[string]string, len(hosts))
+	for _, h := range hosts {
+		data := make(map[string]any, len(vars)+len(commandTemplateFields))
+		for key, value := range vars {
+			data[key] = value
+		}
+		data["Host"] = h.Address
+		data["Port"] = h.Port
+		if data["Port"] == "" {
+			data["Port"] = port
+		}
+		data["User"] = h.User
+		if data["User"] == "" {
+			data["User"] = user
+		}
+		data["Groups"] = h.Groups
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			return nil, fmt.Errorf("主机 %s 的命令模板渲染失败: %w", h.DisplayName(), err)
+		}
+		commands[h.CommandKey(port)] = rendered.String()
+	}
+	return commands, nil
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gossh/internal/executor"
+	"gossh/internal/logger"
+	"gossh/internal/ssh"
+)
+
+// maxHostHookConcurrency 同时执行的 on-success/on-failure 本地命令的最大数量
+const maxHostHookConcurrency = 4
+
+// HookConfig 批量执行前后调用的本地钩子配置
+type HookConfig struct {
+	PreHook          string // 执行前调用的本地可执行文件
+	PostHook         string // 执行后调用的本地可执行文件
+	IgnoreHookErrors bool   // 忽略 pre-hook 的失败，继续执行
+	OnSuccess        string // 每台主机执行成功后执行的本地命令模板（{{.Host}}、{{.ExitCode}}）
+	OnFailure        string // 每台主机执行失败（包括连接失败）后执行的本地命令模板
+}
+
+// hookContext 描述本次批量执行，以环境变量的形式传给钩子
+type hookContext struct {
+	Command string // 子命令名称（run、script、upload）
+	Group   string
+	Target  string // 执行的命令、脚本路径或上传的本地路径
+	Hosts   []executor.Host
+}
+
+// validateHooks 检查钩子文件存在且可执行、主机命令模板可以解析，在连接任何主机之前调用
+func validateHooks(hooks HookConfig) error {
+	if _, err := newHostHookRunner(hooks, nil); err != nil {
+		return err
+	}
+
+	for _, hook := range []struct{ flag, path string }{
+		{"--pre-hook", hooks.PreHook},
+		{"--post-hook", hooks.PostHook},
+	} {
+		flag, hookPath := hook.flag, hook.path
+		if hookPath == "" {
+			continue
+		}
+		info, err := os.Stat(hookPath)
+		if err != nil {
+			return fmt.Errorf("%s 指定的钩子不存在: %s", flag, hookPath)
+		}
+		if info.IsDir() || info.Mode()&0111 == 0 {
+			return fmt.Errorf("%s 指定的钩子不是可执行文件: %s", flag, hookPath)
+		}
+	}
+	return nil
+}
+
+// runPreHook 执行 pre-hook
+// 钩子以非零退出码结束时返回错误，除非开启了 IgnoreHookErrors（此时只打印警告）
+func runPreHook(hooks HookConfig, hc hookContext) error {
+	if hooks.PreHook == "" {
+		return nil
+	}
+
+	if err := runHook(hooks.PreHook, hc.env("pre")); err != nil {
+		if !hooks.IgnoreHookErrors {
+			return fmt.Errorf("pre-hook 执行失败: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "警告: pre-hook 执行失败（已忽略）: %v\n", err)
+	}
+	return nil
+}
+
+// runPostHook 执行 post-hook，额外传入成功、失败、跳过的主机数和总耗时
+// 此时批量执行已经完成，失败只打印警告，不影响执行结果
+func runPostHook(hooks HookConfig, hc hookContext, results []*ssh.Result, totalDuration time.Duration) {
+	if hooks.PostHook == "" {
+		return
+	}
+
+	successCount, failCount, skippedCount := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result == nil:
+			continue
+		case result.Skipped:
+			skippedCount++
+		case result.Error == nil && result.ExitCode == 0:
+			successCount++
+		default:
+			failCount++
+		}
+	}
+
+	env := append(hc.env("post"),
+		"GOSSH_SUCCESS_COUNT="+strconv.Itoa(successCount),
+		"GOSSH_FAIL_COUNT="+strconv.Itoa(failCount),
+		"GOSSH_SKIPPED_COUNT="+strconv.Itoa(skippedCount),
+		fmt.Sprintf("GOSSH_DURATION=%.3f", totalDuration.Seconds()),
+	)
+	if err := runHook(hooks.PostHook, env); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: post-hook 执行失败: %v\n", err)
+	}
+}
+
+// env 生成钩子的环境变量（在当前进程环境变量的基础上追加）
+func (hc hookContext) env(phase string) []string {
+	addresses := make([]string, len(hc.Hosts))
+	for i, h := range hc.Hosts {
+		addresses[i] = h.Address
+	}
+
+	return append(os.Environ(),
+		"GOSSH_HOOK="+phase,
+		"GOSSH_COMMAND="+hc.Command,
+		"GOSSH_GROUP="+hc.Group,
+		"GOSSH_TARGET="+hc.Target,
+		"GOSSH_HOST_COUNT="+strconv.Itoa(len(hc.Hosts)),
+		"GOSSH_HOSTS="+strings.Join(addresses, ","),
+	)
+}
+
+// runHook 执行本地钩子，输出直接转发到终端
+func runHook(hookPath string, env []string) error {
+	cmd := exec.Command(hookPath)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// hostHookData 渲染 on-success/on-failure 命令模板时可用的字段
+type hostHookData struct {
+	Host     string
+	ExitCode int
+	Command  string // 在主机上执行的命令
+	Error    string // 连接或执行错误，没有错误时为空
+}
+
+// hostHookRunner 在每台主机得到结果后执行 on-success 或 on-failure 本地命令
+// 由执行器的结果处理函数在各主机的执行协程中调用，通过信号量限制同时执行的命令数；
+// 命令的输出和退出码只写入日志，失败时在终端打印警告，不影响主机的执行结果
+type hostHookRunner struct {
+	onSuccess *template.Template
+	onFailure *template.Template
+	semaphore chan struct{}
+	log       *logger.Logger
+}
+
+// newHostHookRunner 解析命令模板，没有配置 on-success 和 on-failure 时返回 nil
+func newHostHookRunner(hooks HookConfig, log *logger.Logger) (*hostHookRunner, error) {
+	if hooks.OnSuccess == "" && hooks.OnFailure == "" {
+		return nil, nil
+	}
+
+	r := &hostHookRunner{
+		semaphore: make(chan struct{}, maxHostHookConcurrency),
+		log:       log,
+	}
+	for _, hook := range []struct {
+		flag, text string
+		tmpl       **template.Template
+	}{
+		{"--on-success", hooks.OnSuccess, &r.onSuccess},
+		{"--on-failure", hooks.OnFailure, &r.onFailure},
+	} {
+		if hook.text == "" {
+			continue
+		}
+		tmpl, err := template.New(hook.flag).Option("missingkey=error").Parse(hook.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s 命令模板解析失败: %w", hook.flag, err)
+		}
+		*hook.tmpl = tmpl
+	}
+	return r, nil
+}
+
+// attach 将 runner 设置为执行器的主机结果钩子，runner 为 nil 时什么也不做
+func (r *hostHookRunner) attach(exec *executor.Executor) {
+	if r == nil {
+		return
+	}
+	exec.AddHostHook(r.run)
+}
+
+// run 根据主机结果执行对应的本地命令，跳过的主机不执行
+func (r *hostHookRunner) run(result *ssh.Result) {
+	if result.Skipped {
+		return
+	}
+
+	phase, tmpl := "on-success", r.onSuccess
+	if result.Error != nil || result.ExitCode != 0 {
+		phase, tmpl = "on-failure", r.onFailure
+	}
+	if tmpl == nil {
+		return
+	}
+
+	data := hostHookData{Host: result.Host, ExitCode: result.ExitCode, Command: result.Command}
+	if result.Error != nil {
+		data.Error = result.Error.Error()
+	}
+	var command bytes.Buffer
+	if err := tmpl.Execute(&command, data); err != nil {
+		r.warn(phase, result.Host, "", fmt.Errorf("渲染命令失败: %w", err))
+		return
+	}
+
+	r.semaphore <- struct{}{}
+	defer func() { <-r.semaphore }()
+
+	startTime := time.Now()
+	cmd := exec.Command("sh", "-c", command.String())
+	output, err := cmd.CombinedOutput()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	r.log.LogInfo(phase+" 命令执行完成",
+		"host", result.Host,
+		"command", command.String(),
+		"exit_code", exitCode,
+		"duration", time.Since(startTime).String(),
+		"output", string(output),
+	)
+	if err != nil {
+		r.warn(phase, result.Host, command.String(), err)
+	}
+}
+
+// warn 记录主机命令执行失败，并在终端打印警告
+func (r *hostHookRunner) warn(phase, host, command string, err error) {
+	r.log.LogError(phase+" 命令执行失败", err, "host", host, "command", command)
+	fmt.Fprintf(os.Stderr, "警告: %s 命令执行失败 (%s): %v\n", phase, host, err)
+}
@@ -15,10 +15,17 @@ func NewListController() *ListController {
 
 // ListRequest list 命令的请求参数
 type ListRequest struct {
-	ConfigFile string // ansible.cfg 配置文件路径
-	Inventory  string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group      string // Ansible INI 格式的分组名称
-	Format     string // 输出格式: ip, full, json
+	ConfigFile string   // ansible.cfg 配置文件路径
+	Inventory  []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group      string   // Ansible INI 格式的分组名称
+	Format     string   // 输出格式: ip, full, wide, json
+	CountOnly  bool     // 只输出主机数量（不打印配置参数，便于脚本使用）
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
 }
 
 // ListResponse list 命令的响应
@@ -28,12 +35,15 @@ type ListResponse struct {
 
 // Execute 执行 list 命令
 func (c *ListController) Execute(req *ListRequest) (*ListResponse, error) {
-	// 打印当前配置参数
-	view.PrintListConfig(
-		req.Inventory,
-		req.Group,
-		req.Format,
-	)
+	// 打印当前配置参数（只输出数量时不打印，保证输出只有一个整数；
+	// json 格式也不打印，保证输出可以直接作为 JSON 主机列表使用）
+	if !req.CountOnly && req.Format != "json" {
+		view.PrintListConfig(
+			inventoryText(req.Inventory),
+			req.Group,
+			req.Format,
+		)
+	}
 
 	// 验证参数
 	if err := c.validateRequest(req); err != nil {
@@ -63,5 +73,10 @@ func (c *ListController) loadHosts(req *ListRequest) ([]executor.Host, error) {
 		ConfigFile: req.ConfigFile,
 		Inventory:  req.Inventory,
 		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
 	}, true)
 }
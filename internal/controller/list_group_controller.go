@@ -18,8 +18,8 @@ func NewListGroupController() *ListGroupController {
 
 // ListGroupRequest list-group 命令的请求参数
 type ListGroupRequest struct {
-	ConfigFile string // ansible.cfg 配置文件路径
-	Inventory  string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
+	ConfigFile string   // ansible.cfg 配置文件路径
+	Inventory  []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
 }
 
 // ListGroupResponse list-group 命令的响应
@@ -54,12 +54,22 @@ func (c *ListGroupController) validateRequest(req *ListGroupRequest) error {
 // loadGroups 加载组列表
 func (c *ListGroupController) loadGroups(req *ListGroupRequest) ([]string, error) {
 	// 如果未指定主机来源，尝试从 ansible.cfg 加载
-	if req.Inventory == "" {
+	if len(req.Inventory) == 0 {
 		return c.loadGroupsFromAnsibleConfig(req.ConfigFile)
 	}
 
+	// 多次指定 -i 时每项都作为文件或目录路径，聚合所有路径中的组
+	if len(req.Inventory) > 1 {
+		for _, path := range req.Inventory {
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("多次指定 -i 时每项都必须是文件或目录路径: %s", path)
+			}
+		}
+		return config.LoadGroupsFromPaths(req.Inventory)
+	}
+
 	// 从命令行参数加载组列表
-	return c.loadGroupsFromConfig(req.Inventory)
+	return c.loadGroupsFromConfig(req.Inventory[0])
 }
 
 // loadGroupsFromAnsibleConfig 从 ansible.cfg 配置文件加载组列表
@@ -21,15 +21,38 @@ func NewPingController() *PingController {
 
 // PingRequest ping 命令的请求参数
 type PingRequest struct {
-	ConfigFile  string // ansible.cfg 配置文件路径
-	Inventory   string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group       string // Ansible INI 格式的分组名称
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group       string   // Ansible INI 格式的分组名称
 	User        string
 	KeyPath     string
 	Password    string
 	Port        string
+	TOTP        string // 二次验证码（固定值）
+	TOTPSecret  string // TOTP 密钥（Base32），每次连接自动生成验证码
+	TOTPPrompt  bool   // 交互式输入二次验证码
 	Concurrency int
-	Timeout     time.Duration // 连接超时时间
+	Timeout     time.Duration // 每台主机测试的总时间（TCP 连接、SSH 握手、创建会话）
+
+	ConnectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手，默认: 10s，不超过 Timeout）
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+	ProxyCommand  string // ProxyCommand 模板（%h、%p、%r），通过该命令的标准输入输出连接主机
+	HostKeyCheck  string // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking，都未设置时不校验
+	IdentityAgent string // ssh-agent 的 socket 路径（SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取），为空时不使用 ssh-agent
+	NoDefaultKey  bool   // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
+
+	CheckBecome    bool   // 连接成功后检查 sudo 是否可用（以 sudo 执行 id -u，没有 sudo 密码时加 -n）
+	BecomeUser     string // 检查 sudo 时的目标用户（默认: root），主机级 ansible_become_user 优先
+	SudoFlags      string // 检查 sudo 时追加到 sudo 的额外参数
+	BecomePassword string // sudo 密码，检测到密码提示符时发送
+	BecomePrompt   string // sudo 密码提示符的正则表达式（默认: (?i)password）
+
+	Output string // 输出格式: table（默认）、json（不输出配置参数和进度条）
 }
 
 // PingResponse ping 命令的响应
@@ -45,17 +68,24 @@ func (c *PingController) Execute(req *PingRequest) (*PingResponse, error) {
 	// 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
 	mergedReq := c.mergeConfig(req)
 
-	// 打印当前配置参数
-	view.PrintPingConfig(
-		mergedReq.Inventory,
-		mergedReq.Group,
-		mergedReq.User,
-		mergedReq.KeyPath,
-		mergedReq.Password,
-		mergedReq.Port,
-		mergedReq.Concurrency,
-		mergedReq.Timeout,
-	)
+	// 打印当前配置参数（JSON 输出时不打印，保证标准输出只有 JSON）
+	jsonOutput := mergedReq.Output == "json"
+	if !jsonOutput {
+		view.PrintPingConfig(
+			inventoryText(mergedReq.Inventory),
+			mergedReq.Group,
+			mergedReq.User,
+			mergedReq.KeyPath,
+			mergedReq.Password,
+			mergedReq.Port,
+			mergedReq.Concurrency,
+			mergedReq.Timeout,
+			mergedReq.ConnectTimeout,
+			mergedReq.CheckBecome,
+			mergedReq.BecomeUser,
+			mergedReq.SudoFlags,
+		)
+	}
 
 	// 验证参数
 	if err := c.validateRequest(mergedReq); err != nil {
@@ -74,16 +104,54 @@ func (c *PingController) Execute(req *PingRequest) (*PingResponse, error) {
 		port = "22"
 	}
 
-	// 创建进度跟踪器
-	progressTracker := view.NewProgressTracker(len(hosts), "SSH 连接测试")
+	// 创建二次验证码提供者
+	totp, err := newTOTPProvider(mergedReq.TOTP, mergedReq.TOTPSecret, mergedReq.TOTPPrompt, mergedReq.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 主机密钥校验（--host-key-check 或 ansible.cfg 的 host_key_checking）
+	hostKeyChecker, err := newHostKeyChecker(mergedReq.ConfigFile, mergedReq.HostKeyCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	// ssh-agent 认证（--identity-agent）
+	identityAgent, err := newIdentityAgent(mergedReq.IdentityAgent)
+	if err != nil {
+		return nil, err
+	}
+	defer identityAgent.Close()
+	clientOpts := ssh.ClientOptions{IdentityAgent: identityAgent, NoDefaultKey: mergedReq.NoDefaultKey}
+
+	// 创建进度跟踪器（JSON 输出时不显示进度）
+	var progressTracker executor.ProgressTracker = silentProgressTracker{}
+	stopProgress := func() {}
+	if !jsonOutput {
+		tracker := view.NewProgressTracker(len(hosts), "SSH 连接测试")
+		progressTracker = tracker
+		stopProgress = tracker.Stop
+	}
+
+	// sudo 检查（--check-become）
+	pingOpts := ssh.PingOptions{
+		CheckBecome: mergedReq.CheckBecome,
+		Become: ssh.ExecOptions{
+			BecomeUser: mergedReq.BecomeUser,
+			SudoFlags:  mergedReq.SudoFlags,
+
+			BecomePassword: mergedReq.BecomePassword,
+			BecomePrompt:   becomePrompt(mergedReq.BecomePassword, mergedReq.BecomePrompt),
+		},
+	}
 
 	// 记录开始时间
 	startTime := time.Now()
 
 	// 执行 ping 测试（超时时间已在 mergeConfig 中处理）
-	results, err := c.executePing(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, mergedReq.Concurrency, mergedReq.Timeout, progressTracker)
+	results, err := c.executePing(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, mergedReq.Concurrency, mergedReq.Timeout, mergedReq.ConnectTimeout, totp, mergedReq.ProxyCommand, hostKeyChecker, clientOpts, pingOpts, progressTracker)
 	if err != nil {
-		progressTracker.Stop()
+		stopProgress()
 		return nil, fmt.Errorf("执行失败: %w", err)
 	}
 
@@ -91,7 +159,7 @@ func (c *PingController) Execute(req *PingRequest) (*PingResponse, error) {
 	totalDuration := time.Since(startTime)
 
 	// 停止进度跟踪器
-	progressTracker.Stop()
+	stopProgress()
 
 	return &PingResponse{
 		Results:       results,
@@ -136,8 +204,30 @@ func (c *PingController) mergeConfig(req *PingRequest) *PingRequest {
 		KeyPath:     commonCfg.KeyPath,
 		Password:    commonCfg.Password,
 		Port:        commonCfg.Port,
+		TOTP:        req.TOTP,
+		TOTPSecret:  req.TOTPSecret,
+		TOTPPrompt:  req.TOTPPrompt,
 		Concurrency: commonCfg.Concurrency,
 		Timeout:     timeout,
+
+		ConnectTimeout: req.ConnectTimeout,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+		ProxyCommand:  req.ProxyCommand,
+		HostKeyCheck:  req.HostKeyCheck,
+		IdentityAgent: req.IdentityAgent,
+		NoDefaultKey:  req.NoDefaultKey,
+
+		CheckBecome:    req.CheckBecome,
+		BecomeUser:     req.BecomeUser,
+		SudoFlags:      req.SudoFlags,
+		BecomePassword: req.BecomePassword,
+		BecomePrompt:   req.BecomePrompt,
+
+		Output: req.Output,
 	}
 }
 
@@ -149,6 +239,28 @@ func (c *PingController) validateRequest(req *PingRequest) error {
 		return fmt.Errorf("必须指定用户名（-u 或 ansible.cfg 中的 remote_user）")
 	}
 
+	if err := validateTimeouts(req.ConnectTimeout, req.Timeout); err != nil {
+		return err
+	}
+
+	switch req.Output {
+	case "", "table", "json":
+	default:
+		return fmt.Errorf("不支持的输出格式 %q，可选值: table、json", req.Output)
+	}
+
+	if !req.CheckBecome && (req.BecomeUser != "" || req.SudoFlags != "" || req.BecomePassword != "" || req.BecomePrompt != "") {
+		return fmt.Errorf("--become-user、--sudo-flags、--become-password 和 --become-prompt 需要与 --check-become 一起使用")
+	}
+	if req.CheckBecome {
+		if err := ssh.ValidateSudoFlags(req.SudoFlags); err != nil {
+			return err
+		}
+		if err := validateBecomePassword(true, req.BecomePassword, req.BecomePrompt); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -158,15 +270,26 @@ func (c *PingController) loadHosts(req *PingRequest) ([]executor.Host, error) {
 		ConfigFile: req.ConfigFile,
 		Inventory:  req.Inventory,
 		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
 	}, true)
 }
 
 
 // executePing 并发执行 ping 测试
-func (c *PingController) executePing(hosts []executor.Host, user, keyPath, password, defaultPort string, concurrency int, timeout time.Duration, progressTracker *view.ProgressTracker) ([]*ssh.PingResult, error) {
+func (c *PingController) executePing(hosts []executor.Host, user, keyPath, password, defaultPort string, concurrency int, timeout, connectTimeout time.Duration, totp *ssh.TOTPProvider, proxyCommand string, hostKeyChecker *ssh.HostKeyChecker, clientOpts ssh.ClientOptions, pingOpts ssh.PingOptions, progressTracker executor.ProgressTracker) ([]*ssh.PingResult, error) {
 	if concurrency <= 0 {
 		concurrency = 5
 	}
+	if len(hosts) > 0 && concurrency > len(hosts) {
+		concurrency = len(hosts)
+	}
+	if connectTimeout <= 0 {
+		connectTimeout = executor.DefaultConnectTimeout
+	}
 
 	results := make([]*ssh.PingResult, len(hosts))
 	var wg sync.WaitGroup
@@ -183,19 +306,20 @@ func (c *PingController) executePing(hosts []executor.Host, user, keyPath, passw
 					mu.Lock()
 					if results[idx] == nil {
 						results[idx] = &ssh.PingResult{
-							Host:     h.Address,
+							Host:     h.DisplayName(),
 							Success:  false,
 							Duration: 0,
 							Error:    fmt.Errorf("panic: %v", r),
 						}
 					}
 					mu.Unlock()
-					progressTracker.MarkTrackerErrored(h.Address, fmt.Sprintf("panic: %v", r))
+					progressTracker.MarkTrackerErrored(h.DisplayName(), fmt.Sprintf("panic: %v", r))
 				}
 				wg.Done()
 			}()
 
-			hostAddr := h.Address
+			// 地址相同、端口不同的主机通过显示名称区分
+			hostAddr := h.DisplayName()
 
 			// 为主机创建 tracker
 			progressTracker.AddTracker(hostAddr)
@@ -218,14 +342,21 @@ func (c *PingController) executePing(hosts []executor.Host, user, keyPath, passw
 			if port == "" {
 				port = defaultPort
 			}
+			// 主机的 gossh_timeout 覆盖连接超时，总时间至少与之相同
+			hostConnectTimeout := connectTimeout
+			hostTimeout := timeout
+			if h.Timeout > 0 {
+				hostConnectTimeout = h.Timeout
+				hostTimeout = max(timeout, h.Timeout)
+			}
 
 			progressTracker.UpdateTracker(hostAddr, 30, fmt.Sprintf("%s (创建客户端...)", hostAddr))
 			// 使用带超时的客户端创建方法
-			client, err := ssh.NewClientWithTimeout(h.Address, port, hostUser, hostKeyPath, password, timeout)
+			client, err := ssh.NewClientWithOptions(h.Address, port, hostUser, hostKeyPath, password, hostConnectTimeout, totp, clientOpts)
 			if err != nil {
 				mu.Lock()
 				results[idx] = &ssh.PingResult{
-					Host:     h.Address,
+					Host:     hostAddr,
 					Success:  false,
 					Duration: 0,
 					Error:    fmt.Errorf("创建客户端失败: %v", err),
@@ -234,23 +365,37 @@ func (c *PingController) executePing(hosts []executor.Host, user, keyPath, passw
 				progressTracker.MarkTrackerErrored(hostAddr, fmt.Sprintf("连接失败: %v", err))
 				return
 			}
+			client.SetProxyCommand(proxyCommand)
+			client.SetHostKeyChecker(hostKeyChecker)
+
+			// 主机级 ansible_become_user 优先于 --become-user
+			hostPingOpts := pingOpts
+			if h.BecomeUser != "" {
+				hostPingOpts.Become.BecomeUser = h.BecomeUser
+			}
 
 			progressTracker.UpdateTracker(hostAddr, 60, fmt.Sprintf("%s (测试连接...)", hostAddr))
 			// 使用带超时的 Ping 方法
-			result, err := client.PingWithTimeout(timeout)
+			result, err := client.PingWithOptions(hostTimeout, hostPingOpts)
 			if err != nil {
-				mu.Lock()
-				results[idx] = &ssh.PingResult{
-					Host:     h.Address,
-					Success:  false,
-					Duration: 0,
-					Error:    err,
+				// 保留 PingWithTimeout 返回的结果（包含失败阶段和耗时）
+				if result == nil {
+					result = &ssh.PingResult{
+						Host:     hostAddr,
+						Success:  false,
+						Duration: 0,
+						Error:    err,
+					}
 				}
+				result.Host = hostAddr
+				mu.Lock()
+				results[idx] = result
 				mu.Unlock()
 				progressTracker.MarkTrackerErrored(hostAddr, fmt.Sprintf("测试失败: %v", err))
 				return
 			}
 
+			result.Host = hostAddr
 			mu.Lock()
 			results[idx] = result
 			mu.Unlock()
@@ -267,3 +412,14 @@ func (c *PingController) executePing(hosts []executor.Host, user, keyPath, passw
 	wg.Wait()
 	return results, nil
 }
+
+// silentProgressTracker 不显示任何进度的进度跟踪器（JSON 输出时使用，避免进度条混入输出）
+type silentProgressTracker struct{}
+
+func (silentProgressTracker) AddTracker(host string) interface{} { return nil }
+
+func (silentProgressTracker) UpdateTracker(host string, value int64, message string) {}
+
+func (silentProgressTracker) MarkTrackerDone(host string) {}
+
+func (silentProgressTracker) MarkTrackerErrored(host string, reason string) {}
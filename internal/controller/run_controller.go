@@ -2,12 +2,18 @@ package controller
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"gossh/internal/config"
 	"gossh/internal/executor"
 	"gossh/internal/logger"
 	"gossh/internal/ssh"
 	"gossh/internal/view"
+	"gossh/internal/webhook"
 )
 
 // RunController 处理 run 命令的业务逻辑
@@ -20,21 +26,105 @@ func NewRunController() *RunController {
 
 // RunCommandRequest run 命令的请求参数
 type RunCommandRequest struct {
-	ConfigFile  string // ansible.cfg 配置文件路径
-	Inventory   string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group       string // Ansible INI 格式的分组名称
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group       string   // Ansible INI 格式的分组名称
 	User        string
 	KeyPath     string
 	Password    string
 	Port        string
+	TOTP        string // 二次验证码（固定值）
+	TOTPSecret  string // TOTP 密钥（Base32），每次连接自动生成验证码
+	TOTPPrompt  bool   // 交互式输入二次验证码
 	Command     string
+	CommandMap  string // 按主机指定命令的映射文件路径（JSON 或 JSON Lines），与 Command 二选一
+	MapStrict   bool   // 不在命令映射中的主机记为失败（默认跳过）
+	CommandFile string // 命令文件路径（每行一条命令），每台主机在一个连接上执行所有命令，与 Command、CommandMap 三选一
 	Become      bool
 	BecomeUser  string
+	SudoFlags   string // 追加到 sudo 的额外参数（如 -H、-i、--preserve-env）
+	LoginShell  bool // 以登录 shell（bash -lc）执行命令
 	Concurrency int
 	ShowOutput  bool
 	LogDir      string
 	Limit       int
 	Offset      int
+	Hooks       HookConfig // 执行前后调用的本地钩子
+
+	RetryFailed   string // 从失败主机文件加载主机列表（替代 -i/-g）
+	WriteFailures string // 执行结束后将失败的主机写入该文件
+
+	SafeMode         bool     // 拒绝执行匹配危险命令规则的命令
+	DangerPatterns   []string // 追加的危险命令正则表达式（在默认规则之外）
+	IKnowWhatImDoing bool     // 跳过 --safe-mode 的危险命令检查
+
+	ConnectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手，默认: 10s）
+	Timeout        time.Duration // 单台主机的操作总时间上限（0 表示不限制）
+	Deadline       time.Duration // 整批执行的总时间上限（0 表示不限制），超过后未完成的主机记为超过时限
+
+	Webhook         string   // 执行完成后以 POST 发送 JSON 结果的地址
+	WebhookHeaders  []string // 发送 webhook 时附加的请求头（"Name: Value"）
+	WebhookRequired bool     // webhook 发送失败时命令返回错误（默认只打印警告）
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+	PreResolve    bool   // 执行前预先解析所有主机名，之后的连接复用解析结果
+	ProxyCommand  string // ProxyCommand 模板（%h、%p、%r），通过该命令的标准输入输出连接主机
+	HostKeyCheck  string // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking，都未设置时不校验
+	IdentityAgent string // ssh-agent 的 socket 路径（SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取），为空时不使用 ssh-agent
+	NoDefaultKey  bool   // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
+
+	ReuseConnections bool // 复用连接：同一主机的多个步骤共用空闲连接，而不是每次重新建立连接
+
+	Retries      int           // 连接失败时的最大重试次数（0 表示不重试）
+	RetryBackoff time.Duration // 第一次重试前的基础等待时间，之后每次翻倍
+	RetryJitter  float64       // 重试等待时间的随机抖动系数（0-1）
+
+	RetryExitCodes []int // 命令以这些退出码结束时也重试（需要 Retries > 0），其他非零退出码不重试
+
+	PrintCommand bool // 在详细输出中显示每台主机实际执行的命令
+	VerifyBecome bool // become 时先验证提权是否生效（id -u 与目标用户的 UID 一致），未生效的主机不执行并记为提权失败
+
+	BecomePassword string // sudo 密码（sudo -S），检测到密码提示符时才发送，NOPASSWD 的主机不会收到密码
+	BecomePrompt   string // sudo 密码提示符的正则表达式（默认不区分大小写匹配 password）
+
+	ReconnectOnDrop bool // 命令执行过程中连接断开时重新连接并重新执行命令（只适用于幂等的只读命令，不支持 --command-file）
+
+	ParallelCommands bool   // 同一主机上并发执行命令文件中的命令（每条命令一个会话，共用一个连接）
+	AggregateExit    string // 命令文件中多条命令合并后的退出码: first-nonzero（默认）、last、max
+	IgnoreErrors     bool   // 命令文件中某条命令失败后继续执行后续命令
+
+	GroupCommands   []string // 按主机所属分组指定命令（"分组=命令"），可以多次指定，与 Command、CommandMap、CommandFile 四选一
+	GroupPrecedence []string // 主机属于多个映射分组且命令不同时的分组优先级（靠前的优先）
+
+	Stdin     string // 写入远程命令标准输入的内容（所有主机相同），与 StdinFile 二选一
+	StdinFile string // 从文件读取写入远程命令标准输入的内容，"-" 表示读取本地标准输入
+
+	Env []string // 远程命令的环境变量（KEY=VALUE），可以多次指定，与主机变量 gossh_env_* 合并（主机级优先）
+
+	ExtraVars []string // 命令模板变量（JSON 对象或 key=value），指定后 Command 按主机渲染为模板
+
+	Expect     string // 输出合规检查: 在标准输出中查找的正则表达式，与 ExpectFile 二选一
+	ExpectFile string // 输出合规检查: 包含预期完整输出的文件（去掉首尾空白后比较）
+
+	ConfirmOver int  // 主机数超过该值时要求在终端输入 yes 确认（0 表示不确认）
+	AssumeYes   bool // 跳过 ConfirmOver 的确认
+
+	Output    string // 输出格式: table（默认）、json、json-grouped、html（JSON 和 HTML 格式不输出配置参数和进度条）
+	ParseJSON bool   // JSON 输出时把每台主机的标准输出解析为 JSON
+
+	Shuffle bool  // 执行前随机打乱主机顺序（在 limit/offset 之前应用）
+	Seed    int64 // 打乱主机顺序的随机种子（0 表示每次随机），指定后结果可重现
+
+	ListOnly bool // 只解析并返回目标主机（应用分组、排除、打乱顺序和 offset/limit），不连接主机，也不打印配置参数
+
+	SequentialOutput bool // 逐台执行（并发数为 1），每台主机完成后立即打印其完整输出，不显示进度条
+	TrimOutput       bool // 逐台打印输出时去掉首尾的空白（--trim-output），最终结果由调用方处理
+
+	DB string // 执行结果追加写入的 SQLite 数据库文件（--db），为空表示不写入
 }
 
 // RunCommandResponse run 命令的响应
@@ -50,6 +140,11 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 	// 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
 	mergedReq := c.mergeConfig(req)
 
+	// 只列出目标主机（--list-only）时不创建日志、不打印配置参数，也不连接主机
+	if mergedReq.ListOnly {
+		return c.listTargets(mergedReq)
+	}
+
 	// 创建日志记录器
 	log, err := logger.NewLogger(mergedReq.LogDir, "run")
 	if err != nil {
@@ -58,32 +153,74 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 	defer log.Close()
 
 	// 打印当前配置参数
-	view.PrintRunConfig(
-		mergedReq.Inventory,
-		mergedReq.Group,
-		mergedReq.User,
-		mergedReq.KeyPath,
-		mergedReq.Password,
-		mergedReq.Port,
-		mergedReq.Command,
-		mergedReq.Become,
-		mergedReq.BecomeUser,
-		mergedReq.Concurrency,
-		mergedReq.ShowOutput,
-	)
+	displayCommand := mergedReq.Command
+	if mergedReq.CommandMap != "" {
+		displayCommand = fmt.Sprintf("按主机命令映射: %s", mergedReq.CommandMap)
+	}
+	if len(mergedReq.GroupCommands) > 0 {
+		displayCommand = fmt.Sprintf("按分组指定命令: %s", strings.Join(mergedReq.GroupCommands, "; "))
+	}
+	if mergedReq.CommandFile != "" {
+		displayCommand = fmt.Sprintf("命令文件: %s", mergedReq.CommandFile)
+		if mergedReq.ParallelCommands {
+			displayCommand += "（并行执行）"
+		}
+		if mergedReq.AggregateExit != "" && mergedReq.AggregateExit != ssh.AggregateExitFirstNonzero {
+			displayCommand += fmt.Sprintf("（退出码: %s）", mergedReq.AggregateExit)
+		}
+	}
+	displayInventory := inventoryText(mergedReq.Inventory)
+	if mergedReq.RetryFailed != "" {
+		displayInventory = fmt.Sprintf("失败主机文件: %s", mergedReq.RetryFailed)
+	}
+	// JSON 和 HTML 输出时不打印，保证标准输出只有 JSON 或 HTML 页面
+	jsonOutput := mergedReq.Output == "json" || mergedReq.Output == "json-grouped" || mergedReq.Output == "html"
+	if !jsonOutput {
+		view.PrintRunConfig(
+			displayInventory,
+			mergedReq.Group,
+			mergedReq.User,
+			mergedReq.KeyPath,
+			mergedReq.Password,
+			mergedReq.Port,
+			displayCommand,
+			mergedReq.Become,
+			mergedReq.BecomeUser,
+			mergedReq.SudoFlags,
+			mergedReq.LoginShell,
+			mergedReq.Concurrency,
+			mergedReq.ShowOutput,
+		)
+	}
 
 	// 记录命令开始
 	log.LogCommandStart("run", map[string]interface{}{
-		"inventory":   mergedReq.Inventory,
-		"group":       mergedReq.Group,
-		"user":        mergedReq.User,
-		"key_path":    mergedReq.KeyPath,
-		"port":        mergedReq.Port,
-		"command":     mergedReq.Command,
-		"become":      mergedReq.Become,
-		"become_user": mergedReq.BecomeUser,
-		"concurrency": mergedReq.Concurrency,
-		"show_output": mergedReq.ShowOutput,
+		"inventory":         inventoryText(mergedReq.Inventory),
+		"group":             mergedReq.Group,
+		"user":              mergedReq.User,
+		"key_path":          mergedReq.KeyPath,
+		"port":              mergedReq.Port,
+		"command":           mergedReq.Command,
+		"command_map":       mergedReq.CommandMap,
+		"command_file":      mergedReq.CommandFile,
+		"map_strict":        mergedReq.MapStrict,
+		"group_commands":    mergedReq.GroupCommands,
+		"become":            mergedReq.Become,
+		"become_user":       mergedReq.BecomeUser,
+		"sudo_flags":        mergedReq.SudoFlags,
+		"login_shell":       mergedReq.LoginShell,
+		"concurrency":       mergedReq.Concurrency,
+		"show_output":       mergedReq.ShowOutput,
+		"retry_failed":      mergedReq.RetryFailed,
+		"write_failures":    mergedReq.WriteFailures,
+		"safe_mode":         mergedReq.SafeMode,
+		"retries":           mergedReq.Retries,
+		"reconnect_on_drop": mergedReq.ReconnectOnDrop,
+		"stdin_file":        mergedReq.StdinFile,
+		"env":               mergedReq.Env,
+		"extra_vars":        mergedReq.ExtraVars,
+		"expect":            mergedReq.Expect,
+		"expect_file":       mergedReq.ExpectFile,
 	})
 
 	// 验证参数
@@ -92,6 +229,80 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 		return nil, err
 	}
 
+	// 加载按主机指定的命令映射
+	var commands map[string]string
+	if mergedReq.CommandMap != "" {
+		commands, err = config.LoadCommandMap(mergedReq.CommandMap)
+		if err != nil {
+			log.LogError("加载命令映射失败", err)
+			return nil, err
+		}
+		if mergedReq.SafeMode && !mergedReq.IKnowWhatImDoing {
+			if err := checkDangerousCommandMap(commands, mergedReq.DangerPatterns); err != nil {
+				log.LogError("参数验证失败", err)
+				return nil, err
+			}
+		}
+	}
+
+	// 解析按分组指定的命令
+	var groupCommands map[string]string
+	if len(mergedReq.GroupCommands) > 0 {
+		groupCommands, err = config.ParseGroupCommands(mergedReq.GroupCommands)
+		if err != nil {
+			log.LogError("参数验证失败", err)
+			return nil, err
+		}
+		for _, group := range mergedReq.GroupPrecedence {
+			if _, ok := groupCommands[group]; !ok {
+				err := fmt.Errorf("--group-precedence 中的分组 %s 没有通过 --group-command 指定命令", group)
+				log.LogError("参数验证失败", err)
+				return nil, err
+			}
+		}
+		if mergedReq.SafeMode && !mergedReq.IKnowWhatImDoing {
+			for _, entry := range mergedReq.GroupCommands {
+				group, command, _ := strings.Cut(entry, "=")
+				if err := checkDangerousCommand(command, mergedReq.DangerPatterns); err != nil {
+					err = fmt.Errorf("分组 %s 的命令: %w", strings.TrimSpace(group), err)
+					log.LogError("参数验证失败", err)
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 加载命令文件
+	var commandList []string
+	if mergedReq.CommandFile != "" {
+		commandList, err = config.LoadCommandFile(mergedReq.CommandFile)
+		if err != nil {
+			log.LogError("加载命令文件失败", err)
+			return nil, err
+		}
+		if mergedReq.SafeMode && !mergedReq.IKnowWhatImDoing {
+			for _, command := range commandList {
+				if err := checkDangerousCommand(command, mergedReq.DangerPatterns); err != nil {
+					log.LogError("参数验证失败", err)
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 读取写入远程命令标准输入的内容
+	stdin, err := c.loadStdin(mergedReq)
+	if err != nil {
+		log.LogError("读取标准输入内容失败", err)
+		return nil, err
+	}
+
+	// 解析环境变量（已在 validateRequest 中校验格式）
+	env, _ := ssh.ParseEnv(mergedReq.Env)
+
+	// 加载输出合规检查（已在 validateRequest 中校验）
+	expectation, _ := newOutputExpectation(mergedReq.Expect, mergedReq.ExpectFile)
+
 	// 加载主机列表
 	hosts, err := c.loadHosts(mergedReq)
 	if err != nil {
@@ -99,46 +310,173 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 		return nil, err
 	}
 
+	// 随机打乱主机顺序（--shuffle），在 offset 和 limit 之前应用
+	hosts = shuffleHosts(hosts, mergedReq.Shuffle, mergedReq.Seed)
+
 	// 应用 offset 和 limit
 	hosts = c.applyLimitAndOffset(hosts, mergedReq.Offset, mergedReq.Limit)
 
 	// 记录主机列表
 	hostAddresses := make([]string, len(hosts))
 	for i, h := range hosts {
-		hostAddresses[i] = h.Address
+		hostAddresses[i] = h.DisplayName()
 	}
 	log.LogHosts(hostAddresses)
 
+	// 指定了 --extra-vars 时为每台主机渲染命令模板，按命令映射执行
+	if len(mergedReq.ExtraVars) > 0 {
+		vars, _ := parseExtraVars(mergedReq.ExtraVars)
+		commands, err = renderHostCommands(mergedReq.Command, hosts, vars, mergedReq.User, mergedReq.Port)
+		if err != nil {
+			log.LogError("渲染命令模板失败", err)
+			return nil, err
+		}
+		if mergedReq.SafeMode && !mergedReq.IKnowWhatImDoing {
+			if err := checkDangerousCommandMap(commands, mergedReq.DangerPatterns); err != nil {
+				log.LogError("参数验证失败", err)
+				return nil, err
+			}
+		}
+	}
+
+	// 连接主机之前检查是否有主机属于多个映射分组而无法确定命令
+	if groupCommands != nil {
+		if _, err := executor.ResolveGroupCommands(hosts, groupCommands, mergedReq.GroupPrecedence, mergedReq.Port); err != nil {
+			log.LogError("参数验证失败", err)
+			return nil, err
+		}
+	}
+
+	// 主机数超过 --confirm-over 时要求确认
+	if err := confirmHostCount(len(hosts), mergedReq.ConfirmOver, mergedReq.AssumeYes); err != nil {
+		log.LogError("执行未确认", err)
+		return nil, err
+	}
+
 	// 设置默认端口
 	port := mergedReq.Port
 	if port == "" {
 		port = "22"
 	}
 
-	// 创建进度跟踪器
-	progressTracker := view.NewProgressTracker(len(hosts), "执行命令")
+	// 创建二次验证码提供者
+	totp, err := newTOTPProvider(mergedReq.TOTP, mergedReq.TOTPSecret, mergedReq.TOTPPrompt, mergedReq.Password)
+	if err != nil {
+		log.LogError("创建 TOTP 提供者失败", err)
+		return nil, err
+	}
+
+	// 执行 pre-hook
+	hookCtx := hookContext{Command: "run", Group: mergedReq.Group, Target: displayCommand, Hosts: hosts}
+	if err := runPreHook(mergedReq.Hooks, hookCtx); err != nil {
+		log.LogError("pre-hook 执行失败", err)
+		return nil, err
+	}
 
 	// 创建执行器
-	exec := executor.NewExecutor(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port)
+	retry := executor.RetryPolicy{Retries: mergedReq.Retries, Backoff: mergedReq.RetryBackoff, Jitter: mergedReq.RetryJitter, ExitCodes: mergedReq.RetryExitCodes}
+	exec := executor.NewExecutorWithRetry(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, totp, mergedReq.ConnectTimeout, mergedReq.Timeout, retry)
+	exec.SetProxyCommand(mergedReq.ProxyCommand)
+
+	// 主机密钥校验（--host-key-check 或 ansible.cfg 的 host_key_checking）
+	hostKeyChecker, err := newHostKeyChecker(mergedReq.ConfigFile, mergedReq.HostKeyCheck)
+	if err != nil {
+		log.LogError("加载主机密钥校验失败", err)
+		return nil, err
+	}
+	exec.SetHostKeyChecker(hostKeyChecker)
+
+	// ssh-agent 认证（--identity-agent）
+	identityAgent, err := newIdentityAgent(mergedReq.IdentityAgent)
+	if err != nil {
+		log.LogError("连接 ssh-agent 失败", err)
+		return nil, err
+	}
+	defer identityAgent.Close()
+	exec.SetIdentityAgent(identityAgent)
+	exec.SetNoDefaultKey(mergedReq.NoDefaultKey)
+
+	// 每台主机得到结果后立即检查输出是否符合预期，之后的主机钩子（逐台打印输出等）可以看到检查结果
+	exec.AddHostHook(expectation.hostHook())
+
+	// 每台主机得到结果后执行 on-success/on-failure 本地命令
+	hostHooks, err := newHostHookRunner(mergedReq.Hooks, log)
+	if err != nil {
+		log.LogError("解析主机命令模板失败", err)
+		return nil, err
+	}
+	hostHooks.attach(exec)
+
+	// 逐台执行时每台主机完成后立即打印输出，下一台主机在打印完成后才开始
+	if mergedReq.SequentialOutput {
+		exec.AddHostHook(view.NewSequentialOutputPrinter(hosts, mergedReq.TrimOutput))
+	}
+
+	// 复用连接：连接用完后放回连接池，执行结束时关闭
+	if mergedReq.ReuseConnections {
+		pool := ssh.NewConnectionPool(ssh.DefaultPoolIdleTTL)
+		defer pool.Close()
+		exec.SetConnectionPool(pool)
+	}
+
+	// 预先解析主机名
+	if mergedReq.PreResolve {
+		preResolveHosts(exec, mergedReq.Concurrency, log)
+	}
+
+	// 创建进度跟踪器（JSON 输出和逐台输出时不显示进度）
+	var progressTracker executor.ProgressTracker = silentProgressTracker{}
+	stopProgress := func() {}
+	if !jsonOutput && !mergedReq.SequentialOutput {
+		tracker := view.NewProgressTracker(len(hosts), "执行命令")
+		progressTracker = tracker
+		stopProgress = tracker.Stop
+	}
+
+	// 整批执行的总时间上限（--deadline），从开始执行时计时
+	defer applyDeadline(exec, mergedReq.Deadline)()
 
 	// 记录开始时间
 	startTime := time.Now()
 
 	// 执行命令
+	execOpts := ssh.ExecOptions{
+		Become:     mergedReq.Become,
+		BecomeUser: mergedReq.BecomeUser,
+		LoginShell: mergedReq.LoginShell,
+		SudoFlags:  mergedReq.SudoFlags,
+
+		PrintCommand: mergedReq.PrintCommand,
+		VerifyBecome: mergedReq.VerifyBecome,
+
+		BecomePassword: mergedReq.BecomePassword,
+		BecomePrompt:   becomePrompt(mergedReq.BecomePassword, mergedReq.BecomePrompt),
+
+		ReconnectOnDrop: mergedReq.ReconnectOnDrop,
+
+		Stdin: stdin,
+		Env:   env,
+	}
 	var results []*ssh.Result
-	results, err = exec.ExecuteCommandWithBecome(
-		mergedReq.Command,
-		mergedReq.Concurrency,
-		mergedReq.Become,
-		mergedReq.BecomeUser,
-		progressTracker,
-	)
+	if commands != nil {
+		results, err = exec.ExecuteCommandMapWithOptions(commands, mergedReq.MapStrict, mergedReq.Concurrency, execOpts, progressTracker)
+	} else if groupCommands != nil {
+		results, err = exec.ExecuteGroupCommandsWithOptions(groupCommands, mergedReq.GroupPrecedence, mergedReq.MapStrict, mergedReq.Concurrency, execOpts, progressTracker)
+	} else if commandList != nil {
+		seqOpts := ssh.SequenceOptions{Parallel: mergedReq.ParallelCommands, AggregateExit: mergedReq.AggregateExit, IgnoreErrors: mergedReq.IgnoreErrors}
+		results, err = exec.ExecuteCommandSequenceWithOptions(commandList, seqOpts, mergedReq.Concurrency, execOpts, progressTracker)
+	} else {
+		results, err = exec.ExecuteCommandWithOptions(mergedReq.Command, mergedReq.Concurrency, execOpts, progressTracker)
+	}
 
 	// 记录结束时间并计算总耗时
 	totalDuration := time.Since(startTime)
 
 	// 停止进度跟踪器
-	progressTracker.Stop()
+	stopProgress()
+
+	// 检查输出是否符合预期（--expect、--expect-file），包括没有经过主机钩子的结果（如超过 --deadline 未执行的主机）
+	expectation.apply(results)
 
 	// 记录每个主机的执行结果
 	successCount := 0
@@ -159,6 +497,17 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 		)
 	}
 
+	// 执行 post-hook
+	runPostHook(mergedReq.Hooks, hookCtx, results, totalDuration)
+
+	// 写入失败主机文件，此时执行已经完成，写入失败只打印警告
+	if mergedReq.WriteFailures != "" && err == nil {
+		if werr := config.WriteFailuresFile(mergedReq.WriteFailures, c.failedHosts(hosts, results), displayCommand); werr != nil {
+			log.LogError("写入失败主机文件失败", werr)
+			fmt.Fprintf(os.Stderr, "警告: %v\n", werr)
+		}
+	}
+
 	// 记录命令结束
 	commandSuccess := err == nil && successCount == len(results)
 	if err != nil {
@@ -166,6 +515,24 @@ func (c *RunController) Execute(req *RunCommandRequest) (*RunCommandResponse, er
 		return nil, fmt.Errorf("执行失败: %w", err)
 	}
 
+	// 追加执行记录到 --db 指定的数据库
+	if mergedReq.DB != "" {
+		appendHistory(mergedReq.DB, hookCtx, startTime, totalDuration, results, log)
+	}
+
+	// 发送 webhook，失败时只记录日志并打印警告（除非指定了 --webhook-required）
+	if mergedReq.Webhook != "" {
+		payload := webhook.NewPayload("run", displayCommand, mergedReq.Group, startTime, totalDuration, results)
+		if werr := c.sendWebhook(mergedReq, payload); werr != nil {
+			log.LogError("发送 webhook 失败", werr)
+			if mergedReq.WebhookRequired {
+				log.LogCommandEnd("run", totalDuration, false, werr)
+				return nil, werr
+			}
+			fmt.Fprintf(os.Stderr, "警告: %v\n", werr)
+		}
+	}
+
 	log.LogCommandEnd("run", totalDuration, commandSuccess, nil)
 
 	return &RunCommandResponse{
@@ -188,6 +555,11 @@ func (c *RunController) mergeConfig(req *RunCommandRequest) *RunCommandRequest {
 		Concurrency: req.Concurrency,
 	})
 
+	// --sequential-output 逐台执行，未指定 --forks 时并发数为 1（指定了大于 1 的 --forks 时在 validateRequest 中报错）
+	if req.SequentialOutput && req.Concurrency <= 1 {
+		commonCfg.Concurrency = 1
+	}
+
 	return &RunCommandRequest{
 		ConfigFile:  req.ConfigFile,
 		Inventory:   commonCfg.Inventory,
@@ -196,39 +568,364 @@ func (c *RunController) mergeConfig(req *RunCommandRequest) *RunCommandRequest {
 		KeyPath:     commonCfg.KeyPath,
 		Password:    commonCfg.Password,
 		Port:        commonCfg.Port,
+		TOTP:        req.TOTP,
+		TOTPSecret:  req.TOTPSecret,
+		TOTPPrompt:  req.TOTPPrompt,
 		Command:     req.Command,
+		CommandMap:  req.CommandMap,
+		MapStrict:   req.MapStrict,
+		CommandFile: req.CommandFile,
 		Become:      req.Become,
 		BecomeUser:  req.BecomeUser,
+		SudoFlags:   req.SudoFlags,
+		LoginShell:  req.LoginShell,
 		Concurrency: commonCfg.Concurrency,
 		ShowOutput:  req.ShowOutput,
 		LogDir:      req.LogDir,
 		Limit:       req.Limit,
 		Offset:      req.Offset,
+		Shuffle:     req.Shuffle,
+		Seed:        req.Seed,
+		ListOnly:    req.ListOnly,
+		Hooks:       req.Hooks,
+
+		RetryFailed:   req.RetryFailed,
+		WriteFailures: req.WriteFailures,
+
+		SafeMode:         req.SafeMode,
+		DangerPatterns:   req.DangerPatterns,
+		IKnowWhatImDoing: req.IKnowWhatImDoing,
+
+		ConnectTimeout: req.ConnectTimeout,
+		Timeout:        req.Timeout,
+		Deadline:       req.Deadline,
+
+		Webhook:         req.Webhook,
+		WebhookHeaders:  req.WebhookHeaders,
+		WebhookRequired: req.WebhookRequired,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+		PreResolve:    req.PreResolve,
+		ProxyCommand:  req.ProxyCommand,
+		HostKeyCheck:  req.HostKeyCheck,
+		IdentityAgent: req.IdentityAgent,
+		NoDefaultKey:  req.NoDefaultKey,
+
+		ReuseConnections: req.ReuseConnections,
+
+		Retries:      req.Retries,
+		RetryBackoff: req.RetryBackoff,
+		RetryJitter:  req.RetryJitter,
+
+		RetryExitCodes: req.RetryExitCodes,
+
+		PrintCommand: req.PrintCommand,
+		VerifyBecome: req.VerifyBecome,
+
+		BecomePassword: req.BecomePassword,
+		BecomePrompt:   req.BecomePrompt,
+
+		ReconnectOnDrop: req.ReconnectOnDrop,
+
+		ParallelCommands: req.ParallelCommands,
+		AggregateExit:    req.AggregateExit,
+		IgnoreErrors:     req.IgnoreErrors,
+
+		GroupCommands:   req.GroupCommands,
+		GroupPrecedence: req.GroupPrecedence,
+
+		Stdin:     req.Stdin,
+		StdinFile: req.StdinFile,
+
+		Env: req.Env,
+
+		ExtraVars: req.ExtraVars,
+
+		Expect:     req.Expect,
+		ExpectFile: req.ExpectFile,
+
+		ConfirmOver: req.ConfirmOver,
+		DB:          req.DB,
+		AssumeYes:   req.AssumeYes,
+
+		Output:    req.Output,
+		ParseJSON: req.ParseJSON,
+
+		SequentialOutput: req.SequentialOutput,
+		TrimOutput:       req.TrimOutput,
 	}
 }
 
 // validateRequest 验证请求参数
 func (c *RunController) validateRequest(req *RunCommandRequest) error {
-	if req.Command == "" {
-		return fmt.Errorf("必须指定要执行的命令（-c）")
+	if req.Seed != 0 && !req.Shuffle {
+		return fmt.Errorf("--seed 需要与 --shuffle 一起使用")
+	}
+
+	sources := 0
+	for _, source := range []string{req.Command, req.CommandMap, req.CommandFile} {
+		if source != "" {
+			sources++
+		}
+	}
+	if len(req.GroupCommands) > 0 {
+		sources++
+	}
+	if sources == 0 {
+		return fmt.Errorf("必须指定要执行的命令（-c）、命令映射文件（--command-map）、命令文件（--command-file）或按分组指定的命令（--group-command）")
+	}
+	if sources > 1 {
+		return fmt.Errorf("-c、--command-map、--command-file 与 --group-command 只能指定一个")
+	}
+
+	if req.ParallelCommands && req.CommandFile == "" {
+		return fmt.Errorf("--parallel-commands 需要与 --command-file 一起使用")
+	}
+	if req.ReconnectOnDrop && req.CommandFile != "" {
+		return fmt.Errorf("--reconnect-on-drop 不能与 --command-file 一起使用（重新执行会重复执行已经完成的命令）")
+	}
+	if (req.AggregateExit != "" || req.IgnoreErrors) && req.CommandFile == "" {
+		return fmt.Errorf("--aggregate-exit 和 --ignore-errors 需要与 --command-file 一起使用")
+	}
+	switch req.AggregateExit {
+	case "", ssh.AggregateExitFirstNonzero, ssh.AggregateExitLast, ssh.AggregateExitMax:
+	default:
+		return fmt.Errorf("不支持的 --aggregate-exit %q，可选值: first-nonzero、last、max", req.AggregateExit)
+	}
+
+	if req.Stdin != "" && req.StdinFile != "" {
+		return fmt.Errorf("--stdin 与 --stdin-file 只能指定一个")
+	}
+	if req.StdinFile == "-" && req.TOTPPrompt {
+		return fmt.Errorf("--stdin-file - 读取本地标准输入，不能与 --totp-prompt 一起使用")
+	}
+
+	if _, err := ssh.ParseEnv(req.Env); err != nil {
+		return err
+	}
+
+	if _, err := newOutputExpectation(req.Expect, req.ExpectFile); err != nil {
+		return err
+	}
+
+	if len(req.ExtraVars) > 0 {
+		if req.Command == "" {
+			return fmt.Errorf("--extra-vars 需要与 -c 一起使用")
+		}
+		if _, err := parseExtraVars(req.ExtraVars); err != nil {
+			return err
+		}
+		if _, err := parseCommandTemplate(req.Command); err != nil {
+			return err
+		}
+	}
+
+	if req.MapStrict && req.CommandMap == "" && len(req.GroupCommands) == 0 {
+		return fmt.Errorf("--map-strict 需要与 --command-map 或 --group-command 一起使用")
+	}
+
+	if len(req.GroupPrecedence) > 0 && len(req.GroupCommands) == 0 {
+		return fmt.Errorf("--group-precedence 需要与 --group-command 一起使用")
 	}
 
 	if req.User == "" {
 		return fmt.Errorf("必须指定用户名（-u 或 ansible.cfg 中的 remote_user）")
 	}
 
+	if req.SudoFlags != "" {
+		if !req.Become {
+			return fmt.Errorf("--sudo-flags 需要与 --become 一起使用")
+		}
+		if err := ssh.ValidateSudoFlags(req.SudoFlags); err != nil {
+			return err
+		}
+	}
+
+	if err := validateBecomePassword(req.Become, req.BecomePassword, req.BecomePrompt); err != nil {
+		return err
+	}
+
+	if req.SequentialOutput {
+		if req.Concurrency > 1 {
+			return fmt.Errorf("--sequential-output 逐台执行主机，不能与 --forks %d 一起使用（省略 --forks 或指定 --forks 1）", req.Concurrency)
+		}
+		if req.Output != "" && req.Output != "table" {
+			return fmt.Errorf("--sequential-output 只能用于表格输出，不能与 --output %s 一起使用", req.Output)
+		}
+	}
+
+	if err := validateHooks(req.Hooks); err != nil {
+		return err
+	}
+
+	if len(req.DangerPatterns) > 0 && !req.SafeMode {
+		return fmt.Errorf("--danger-pattern 需要与 --safe-mode 一起使用")
+	}
+	if req.SafeMode {
+		// 先编译自定义规则，即使指定了 --i-know-what-im-doing 也报告无效的正则
+		if _, err := compileDangerPatterns(req.DangerPatterns); err != nil {
+			return err
+		}
+		if req.Command != "" && !req.IKnowWhatImDoing {
+			if err := checkDangerousCommand(req.Command, req.DangerPatterns); err != nil {
+				return err
+			}
+		}
+	}
+
+	if req.WriteFailures != "" {
+		dir := filepath.Dir(req.WriteFailures)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("--write-failures 所在目录不存在: %s", dir)
+		}
+	}
+
+	if err := validateTimeouts(req.ConnectTimeout, req.Timeout); err != nil {
+		return err
+	}
+	if req.Deadline < 0 {
+		return fmt.Errorf("--deadline 不能为负数: %v", req.Deadline)
+	}
+
+	if req.Retries < 0 {
+		return fmt.Errorf("--retries 不能为负数: %d", req.Retries)
+	}
+	if req.RetryBackoff < 0 {
+		return fmt.Errorf("--retry-backoff 不能为负数: %v", req.RetryBackoff)
+	}
+	if req.RetryJitter < 0 || req.RetryJitter > 1 {
+		return fmt.Errorf("--retry-jitter 必须在 0 到 1 之间: %v", req.RetryJitter)
+	}
+	if len(req.RetryExitCodes) > 0 && req.Retries == 0 {
+		return fmt.Errorf("--retry-exit-codes 需要配合 --retries 使用")
+	}
+	for _, code := range req.RetryExitCodes {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("--retry-exit-codes 中的退出码必须在 1 到 255 之间: %d", code)
+		}
+	}
+
+	if req.Webhook != "" {
+		if err := webhook.ValidateURL(req.Webhook); err != nil {
+			return err
+		}
+		if _, err := webhook.ParseHeaders(req.WebhookHeaders); err != nil {
+			return err
+		}
+	} else if len(req.WebhookHeaders) > 0 || req.WebhookRequired {
+		return fmt.Errorf("--webhook-header 和 --webhook-required 需要与 --webhook 一起使用")
+	}
+
+	if req.ConfirmOver < 0 {
+		return fmt.Errorf("--confirm-over 不能为负数")
+	}
+
+	switch req.Output {
+	case "", "table", "json", "json-grouped", "html":
+	default:
+		return fmt.Errorf("不支持的输出格式 %q，可选值: table、json、json-grouped、html", req.Output)
+	}
+	if req.ParseJSON && req.Output != "json" && req.Output != "json-grouped" {
+		return fmt.Errorf("--parse-json 需要与 --output json 或 --output json-grouped 一起使用")
+	}
+
 	return nil
 }
 
+// sendWebhook 将执行结果发送到 --webhook 指定的地址
+func (c *RunController) sendWebhook(req *RunCommandRequest, payload *webhook.Payload) error {
+	headers, err := webhook.ParseHeaders(req.WebhookHeaders)
+	if err != nil {
+		return err
+	}
+	return webhook.Send(req.Webhook, headers, payload, webhook.DefaultTimeout)
+}
+
+// loadStdin 读取写入远程命令标准输入的内容，未指定 --stdin 和 --stdin-file 时返回 nil
+func (c *RunController) loadStdin(req *RunCommandRequest) ([]byte, error) {
+	switch {
+	case req.StdinFile == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("读取本地标准输入失败: %w", err)
+		}
+		return data, nil
+	case req.StdinFile != "":
+		data, err := os.ReadFile(req.StdinFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取标准输入文件失败: %w", err)
+		}
+		return data, nil
+	case req.Stdin != "":
+		return []byte(req.Stdin), nil
+	}
+	return nil, nil
+}
+
 // loadHosts 加载主机列表
+// 指定了 --retry-failed 时从失败主机文件加载，忽略 -i 和 -g
 func (c *RunController) loadHosts(req *RunCommandRequest) ([]executor.Host, error) {
+	if req.RetryFailed != "" {
+		hosts, err := config.LoadFailuresFile(req.RetryFailed)
+		if err != nil {
+			return nil, err
+		}
+		if req.VarsDir != "" {
+			if err := config.ApplyVarsDir(hosts, req.VarsDir); err != nil {
+				return nil, err
+			}
+		}
+		hosts, err = excludeHosts(hosts, req.Exclude, req.ExcludeFile)
+		if err != nil {
+			return nil, err
+		}
+		sortHosts(hosts)
+		return hosts, nil
+	}
+
 	return LoadHosts(&CommonConfig{
 		ConfigFile: req.ConfigFile,
 		Inventory:  req.Inventory,
 		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
 	}, true)
 }
 
+// failedHosts 返回执行失败的主机（跳过的主机不算失败），results 与 hosts 按下标一一对应
+func (c *RunController) failedHosts(hosts []executor.Host, results []*ssh.Result) []executor.Host {
+	var failed []executor.Host
+	for i, result := range results {
+		if result == nil || result.Skipped || (result.Error == nil && result.ExitCode == 0) {
+			continue
+		}
+		failed = append(failed, hosts[i])
+	}
+	return failed
+}
+
+// listTargets 返回将要执行命令的主机（--list-only），主机的选取与实际执行命令时相同
+// 设置 --shuffle 而没有指定 --seed 时每次的顺序不同，需要与实际执行命令一致时应指定 --seed
+func (c *RunController) listTargets(req *RunCommandRequest) (*RunCommandResponse, error) {
+	hosts, err := c.loadHosts(req)
+	if err != nil {
+		return nil, err
+	}
+	hosts = shuffleHosts(hosts, req.Shuffle, req.Seed)
+	hosts = c.applyLimitAndOffset(hosts, req.Offset, req.Limit)
+
+	return &RunCommandResponse{
+		Group: req.Group,
+		Hosts: hosts,
+	}, nil
+}
+
 // applyLimitAndOffset 应用 limit 和 offset 来过滤主机列表
 func (c *RunController) applyLimitAndOffset(hosts []executor.Host, offset, limit int) []executor.Host {
 	total := len(hosts)
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dangerPattern 危险命令的匹配规则
+type dangerPattern struct {
+	pattern     *regexp.Regexp
+	description string
+}
+
+// defaultDangerPatterns --safe-mode 默认拒绝的危险命令
+var defaultDangerPatterns = []dangerPattern{
+	{regexp.MustCompile(`\brm\s+(-[a-zA-Z-]+\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z-]+\s+)*(/\*?|~/?|\$HOME/?|/[a-z]+/?)(\s|$|[;&|])`), "递归删除根目录、家目录或系统顶层目录（rm -rf /）"},
+	{regexp.MustCompile(`\brm\s+.*--no-preserve-root`), "rm --no-preserve-root"},
+	{regexp.MustCompile(`\bmkfs(\.[a-z0-9]+)?\b`), "格式化文件系统（mkfs）"},
+	{regexp.MustCompile(`\bdd\b.*\bof=/dev/`), "dd 写入块设备（dd of=/dev/...）"},
+	{regexp.MustCompile(`>\s*/dev/(sd|hd|vd|xvd|nvme|mmcblk|md|dm-)`), "重定向写入块设备"},
+	{regexp.MustCompile(`\b(wipefs|shred)\b.*\s/dev/`), "擦除块设备（wipefs、shred）"},
+	{regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`), "fork 炸弹"},
+	{regexp.MustCompile(`\bchmod\s+(-[a-zA-Z]+\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+[0-7]*7[0-7]*\s+/(\s|$|[;&|])`), "递归修改根目录权限（chmod -R 777 /）"},
+}
+
+// compileDangerPatterns 编译用户通过 --danger-pattern 追加的正则表达式
+func compileDangerPatterns(patterns []string) ([]dangerPattern, error) {
+	compiled := make([]dangerPattern, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 --danger-pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, dangerPattern{pattern: re, description: fmt.Sprintf("自定义规则 %s", p)})
+	}
+	return compiled, nil
+}
+
+// checkDangerousCommand 检查命令是否匹配危险命令规则（默认规则加上 extraPatterns）
+// 匹配时返回错误，提示使用 --i-know-what-im-doing 跳过检查
+func checkDangerousCommand(command string, extraPatterns []string) error {
+	extra, err := compileDangerPatterns(extraPatterns)
+	if err != nil {
+		return err
+	}
+
+	for _, dp := range append(append([]dangerPattern{}, defaultDangerPatterns...), extra...) {
+		if match := dp.pattern.FindString(command); match != "" {
+			return fmt.Errorf("--safe-mode 拒绝执行危险命令（%s，匹配: %q）。确认无误请追加 --i-know-what-im-doing", dp.description, match)
+		}
+	}
+	return nil
+}
+
+// checkDangerousCommandMap 按主机名顺序检查命令映射中的每条命令
+func checkDangerousCommandMap(commands map[string]string, extraPatterns []string) error {
+	hosts := make([]string, 0, len(commands))
+	for host := range commands {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		if err := checkDangerousCommand(commands[host], extraPatterns); err != nil {
+			return fmt.Errorf("主机 %s 的命令: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// confirmHostCount 主机数超过 threshold 时要求在终端输入 yes 确认，防止分组选错时误操作整个集群
+// threshold <= 0 或 assumeYes（--yes）时不确认；无法打开终端（如 CI 中）时直接返回错误，需要指定 --yes
+func confirmHostCount(count, threshold int, assumeYes bool) error {
+	if threshold <= 0 || count <= threshold || assumeYes {
+		return nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("主机数量 %d 超过 --confirm-over %d，当前没有可用的终端进行确认，请检查分组或指定 --yes", count, threshold)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "即将在 %d 台主机上执行（超过 --confirm-over %d），输入 yes 继续: ", count, threshold)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("读取确认输入失败: %w", err)
+	}
+	if strings.TrimSpace(line) != "yes" {
+		return fmt.Errorf("未确认，已取消执行")
+	}
+	return nil
+}
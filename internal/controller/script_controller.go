@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gossh/internal/executor"
@@ -20,22 +21,64 @@ func NewScriptController() *ScriptController {
 
 // ScriptCommandRequest script 命令的请求参数
 type ScriptCommandRequest struct {
-	ConfigFile  string // ansible.cfg 配置文件路径
-	Inventory   string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group       string // Ansible INI 格式的分组名称
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group       string   // Ansible INI 格式的分组名称
 	User        string
 	KeyPath     string
 	Password    string
 	Port        string
+	TOTP        string // 二次验证码（固定值）
+	TOTPSecret  string // TOTP 密钥（Base32），每次连接自动生成验证码
+	TOTPPrompt  bool   // 交互式输入二次验证码
 	ScriptPath  string
 	Become      bool
 	BecomeUser  string
+	SudoFlags   string // 追加到 sudo 的额外参数（如 -H、-i、--preserve-env）
 	Concurrency int
 	ShowOutput  bool
 	LogDir      string
 	Limit       int
 	Offset      int
-	Executor    string // 脚本执行器（默认: bash）
+	Hooks       HookConfig // 执行前后调用的本地钩子
+	Executor    string     // 脚本执行器（默认: bash）
+	Interpreter string     // 脚本解释器，优先于 Executor；主机变量 gossh_interpreter 优先于两者
+	KeepScript  bool       // 执行后保留远程临时脚本（调试用）
+	RemoteTmp   string     // 远程临时目录（默认: /tmp）
+	Env         []string   // 脚本的环境变量（KEY=VALUE），可以多次指定，与主机变量 gossh_env_* 合并（主机级优先）
+
+	ConnectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手，默认: 10s）
+	Timeout        time.Duration // 单台主机的操作总时间上限（0 表示不限制）
+	Deadline       time.Duration // 整批执行的总时间上限（0 表示不限制），超过后未完成的主机记为超过时限
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+	PreResolve    bool   // 执行前预先解析所有主机名，之后的连接复用解析结果
+	ProxyCommand  string // ProxyCommand 模板（%h、%p、%r），通过该命令的标准输入输出连接主机
+	HostKeyCheck  string // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking，都未设置时不校验
+	IdentityAgent string // ssh-agent 的 socket 路径（SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取），为空时不使用 ssh-agent
+	NoDefaultKey  bool   // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
+
+	ReuseConnections bool // 复用连接：同一主机的多个步骤共用空闲连接，而不是每次重新建立连接
+
+	PrintCommand bool // 在详细输出中显示每台主机实际执行的命令
+	VerifyBecome bool // become 时先验证提权是否生效（id -u 与目标用户的 UID 一致），未生效的主机不执行并记为提权失败
+
+	BecomePassword string // sudo 密码（sudo -S），检测到密码提示符时才发送，NOPASSWD 的主机不会收到密码
+	BecomePrompt   string // sudo 密码提示符的正则表达式（默认不区分大小写匹配 password）
+
+	ConfirmOver int  // 主机数超过该值时要求在终端输入 yes 确认（0 表示不确认）
+	AssumeYes   bool // 跳过 ConfirmOver 的确认
+
+	Shuffle bool  // 执行前随机打乱主机顺序（在 limit/offset 之前应用）
+	Seed    int64 // 打乱主机顺序的随机种子（0 表示每次随机），指定后结果可重现
+
+	ListOnly bool // 只解析并返回目标主机（应用分组、排除、打乱顺序和 offset/limit），不连接主机，也不打印配置参数
+
+	DB string // 执行结果追加写入的 SQLite 数据库文件（--db），为空表示不写入
 }
 
 // ScriptCommandResponse script 命令的响应
@@ -51,6 +94,11 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 	// 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
 	mergedReq := c.mergeConfig(req)
 
+	// 只列出目标主机（--list-only）时不创建日志、不打印配置参数，也不连接主机
+	if mergedReq.ListOnly {
+		return c.listTargets(mergedReq)
+	}
+
 	// 创建日志记录器
 	log, err := logger.NewLogger(mergedReq.LogDir, "script")
 	if err != nil {
@@ -60,7 +108,7 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 
 	// 打印当前配置参数
 	view.PrintScriptConfig(
-		mergedReq.Inventory,
+		inventoryText(mergedReq.Inventory),
 		mergedReq.Group,
 		mergedReq.User,
 		mergedReq.KeyPath,
@@ -69,13 +117,14 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 		mergedReq.ScriptPath,
 		mergedReq.Become,
 		mergedReq.BecomeUser,
+		mergedReq.SudoFlags,
 		mergedReq.Concurrency,
 		mergedReq.ShowOutput,
 	)
 
 	// 记录命令开始
 	log.LogCommandStart("script", map[string]interface{}{
-		"inventory":   mergedReq.Inventory,
+		"inventory":   inventoryText(mergedReq.Inventory),
 		"group":       mergedReq.Group,
 		"user":        mergedReq.User,
 		"key_path":    mergedReq.KeyPath,
@@ -83,8 +132,12 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 		"script_path": mergedReq.ScriptPath,
 		"become":      mergedReq.Become,
 		"become_user": mergedReq.BecomeUser,
+		"sudo_flags":  mergedReq.SudoFlags,
+		"keep_script": mergedReq.KeepScript,
+		"remote_tmp":  mergedReq.RemoteTmp,
 		"concurrency": mergedReq.Concurrency,
 		"show_output": mergedReq.ShowOutput,
+		"env":         mergedReq.Env,
 	})
 
 	// 验证参数
@@ -100,38 +153,121 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 		return nil, err
 	}
 
+	// 随机打乱主机顺序（--shuffle），在 offset 和 limit 之前应用
+	hosts = shuffleHosts(hosts, mergedReq.Shuffle, mergedReq.Seed)
+
 	// 应用 offset 和 limit
 	hosts = c.applyLimitAndOffset(hosts, mergedReq.Offset, mergedReq.Limit)
 
 	// 记录主机列表
 	hostAddresses := make([]string, len(hosts))
 	for i, h := range hosts {
-		hostAddresses[i] = h.Address
+		hostAddresses[i] = h.DisplayName()
 	}
 	log.LogHosts(hostAddresses)
 
+	// 主机数超过 --confirm-over 时要求确认
+	if err := confirmHostCount(len(hosts), mergedReq.ConfirmOver, mergedReq.AssumeYes); err != nil {
+		log.LogError("执行未确认", err)
+		return nil, err
+	}
+
 	// 设置默认端口
 	port := mergedReq.Port
 	if port == "" {
 		port = "22"
 	}
 
+	// 创建二次验证码提供者
+	totp, err := newTOTPProvider(mergedReq.TOTP, mergedReq.TOTPSecret, mergedReq.TOTPPrompt, mergedReq.Password)
+	if err != nil {
+		log.LogError("创建 TOTP 提供者失败", err)
+		return nil, err
+	}
+
+	// 执行 pre-hook
+	hookCtx := hookContext{Command: "script", Group: mergedReq.Group, Target: mergedReq.ScriptPath, Hosts: hosts}
+	if err := runPreHook(mergedReq.Hooks, hookCtx); err != nil {
+		log.LogError("pre-hook 执行失败", err)
+		return nil, err
+	}
+
+	// 创建执行器
+	exec := executor.NewExecutorWithTimeouts(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, totp, mergedReq.ConnectTimeout, mergedReq.Timeout)
+	exec.SetProxyCommand(mergedReq.ProxyCommand)
+
+	// 主机密钥校验（--host-key-check 或 ansible.cfg 的 host_key_checking）
+	hostKeyChecker, err := newHostKeyChecker(mergedReq.ConfigFile, mergedReq.HostKeyCheck)
+	if err != nil {
+		log.LogError("加载主机密钥校验失败", err)
+		return nil, err
+	}
+	exec.SetHostKeyChecker(hostKeyChecker)
+
+	// ssh-agent 认证（--identity-agent）
+	identityAgent, err := newIdentityAgent(mergedReq.IdentityAgent)
+	if err != nil {
+		log.LogError("连接 ssh-agent 失败", err)
+		return nil, err
+	}
+	defer identityAgent.Close()
+	exec.SetIdentityAgent(identityAgent)
+	exec.SetNoDefaultKey(mergedReq.NoDefaultKey)
+
+	// 每台主机得到结果后执行 on-success/on-failure 本地命令
+	hostHooks, err := newHostHookRunner(mergedReq.Hooks, log)
+	if err != nil {
+		log.LogError("解析主机命令模板失败", err)
+		return nil, err
+	}
+	hostHooks.attach(exec)
+
+	// 复用连接：连接用完后放回连接池，执行结束时关闭
+	if mergedReq.ReuseConnections {
+		pool := ssh.NewConnectionPool(ssh.DefaultPoolIdleTTL)
+		defer pool.Close()
+		exec.SetConnectionPool(pool)
+	}
+
+	// 预先解析主机名
+	if mergedReq.PreResolve {
+		preResolveHosts(exec, mergedReq.Concurrency, log)
+	}
+
 	// 创建进度跟踪器
 	progressTracker := view.NewProgressTracker(len(hosts), "执行脚本")
 
-	// 创建执行器
-	exec := executor.NewExecutor(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port)
+	// 解析环境变量（已在 validateRequest 中校验格式）
+	env, _ := ssh.ParseEnv(mergedReq.Env)
+
+	// 整批执行的总时间上限（--deadline），从开始执行时计时
+	defer applyDeadline(exec, mergedReq.Deadline)()
 
 	// 记录开始时间
 	startTime := time.Now()
 
 	// 执行脚本
-	results, err := exec.ExecuteScriptWithBecome(
+	results, err := exec.ExecuteScriptWithOptions(
 		mergedReq.ScriptPath,
 		mergedReq.Concurrency,
-		mergedReq.Become,
-		mergedReq.BecomeUser,
-		mergedReq.Executor,
+		ssh.ScriptOptions{
+			Executor:   mergedReq.Executor,
+			RemoteTmp:  mergedReq.RemoteTmp,
+			KeepScript: mergedReq.KeepScript,
+		},
+		ssh.ExecOptions{
+			Become:     mergedReq.Become,
+			BecomeUser: mergedReq.BecomeUser,
+			SudoFlags:  mergedReq.SudoFlags,
+
+			PrintCommand: mergedReq.PrintCommand,
+			VerifyBecome: mergedReq.VerifyBecome,
+
+			BecomePassword: mergedReq.BecomePassword,
+			BecomePrompt:   becomePrompt(mergedReq.BecomePassword, mergedReq.BecomePrompt),
+
+			Env: env,
+		},
 		progressTracker,
 	)
 
@@ -160,6 +296,9 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 		)
 	}
 
+	// 执行 post-hook
+	runPostHook(mergedReq.Hooks, hookCtx, results, totalDuration)
+
 	// 记录命令结束
 	commandSuccess := err == nil && successCount == len(results)
 	if err != nil {
@@ -167,6 +306,11 @@ func (c *ScriptController) Execute(req *ScriptCommandRequest) (*ScriptCommandRes
 		return nil, fmt.Errorf("执行失败: %w", err)
 	}
 
+	// 追加执行记录到 --db 指定的数据库
+	if mergedReq.DB != "" {
+		appendHistory(mergedReq.DB, hookCtx, startTime, totalDuration, results, log)
+	}
+
 	log.LogCommandEnd("script", totalDuration, commandSuccess, nil)
 
 	return &ScriptCommandResponse{
@@ -190,8 +334,11 @@ func (c *ScriptController) mergeConfig(req *ScriptCommandRequest) *ScriptCommand
 		Concurrency: req.Concurrency,
 	})
 
-	// 设置默认执行器
+	// 设置默认执行器（--interpreter 优先于 --executor）
 	executor := req.Executor
+	if req.Interpreter != "" {
+		executor = req.Interpreter
+	}
 	if executor == "" {
 		executor = "bash"
 	}
@@ -204,28 +351,110 @@ func (c *ScriptController) mergeConfig(req *ScriptCommandRequest) *ScriptCommand
 		KeyPath:     commonCfg.KeyPath,
 		Password:    commonCfg.Password,
 		Port:        commonCfg.Port,
+		TOTP:        req.TOTP,
+		TOTPSecret:  req.TOTPSecret,
+		TOTPPrompt:  req.TOTPPrompt,
 		ScriptPath:  req.ScriptPath,
 		Become:      req.Become,
 		BecomeUser:  req.BecomeUser,
+		SudoFlags:   req.SudoFlags,
 		Concurrency: commonCfg.Concurrency,
 		ShowOutput:  req.ShowOutput,
 		LogDir:      req.LogDir,
 		Limit:       req.Limit,
 		Offset:      req.Offset,
+		Shuffle:     req.Shuffle,
+		Seed:        req.Seed,
+		ListOnly:    req.ListOnly,
+		Hooks:       req.Hooks,
 		Executor:    executor,
+		Interpreter: req.Interpreter,
+		KeepScript:  req.KeepScript,
+		RemoteTmp:   req.RemoteTmp,
+		Env:         req.Env,
+
+		ConnectTimeout: req.ConnectTimeout,
+		Timeout:        req.Timeout,
+		Deadline:       req.Deadline,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+		PreResolve:    req.PreResolve,
+		ProxyCommand:  req.ProxyCommand,
+		HostKeyCheck:  req.HostKeyCheck,
+		IdentityAgent: req.IdentityAgent,
+		NoDefaultKey:  req.NoDefaultKey,
+
+		ReuseConnections: req.ReuseConnections,
+
+		PrintCommand: req.PrintCommand,
+		VerifyBecome: req.VerifyBecome,
+
+		BecomePassword: req.BecomePassword,
+		BecomePrompt:   req.BecomePrompt,
+
+		ConfirmOver: req.ConfirmOver,
+		DB:          req.DB,
+		AssumeYes:   req.AssumeYes,
 	}
 }
 
 // validateRequest 验证请求参数
 func (c *ScriptController) validateRequest(req *ScriptCommandRequest) error {
+	if req.Seed != 0 && !req.Shuffle {
+		return fmt.Errorf("--seed 需要与 --shuffle 一起使用")
+	}
+
 	if req.ScriptPath == "" {
 		return fmt.Errorf("必须指定要执行的脚本文件路径（-s）")
 	}
 
+	if err := validateLocalFile(req.ScriptPath); err != nil {
+		return err
+	}
+
 	if req.User == "" {
 		return fmt.Errorf("必须指定用户名（-u 或 ansible.cfg 中的 remote_user）")
 	}
 
+	if req.SudoFlags != "" {
+		if !req.Become {
+			return fmt.Errorf("--sudo-flags 需要与 --become 一起使用")
+		}
+		if err := ssh.ValidateSudoFlags(req.SudoFlags); err != nil {
+			return err
+		}
+	}
+
+	if err := validateBecomePassword(req.Become, req.BecomePassword, req.BecomePrompt); err != nil {
+		return err
+	}
+
+	if _, err := ssh.ParseEnv(req.Env); err != nil {
+		return err
+	}
+
+	if req.RemoteTmp != "" && !strings.HasPrefix(req.RemoteTmp, "/") {
+		return fmt.Errorf("--remote-tmp 必须是绝对路径: %s", req.RemoteTmp)
+	}
+
+	if err := validateHooks(req.Hooks); err != nil {
+		return err
+	}
+
+	if err := validateTimeouts(req.ConnectTimeout, req.Timeout); err != nil {
+		return err
+	}
+	if req.Deadline < 0 {
+		return fmt.Errorf("--deadline 不能为负数: %v", req.Deadline)
+	}
+
+	if req.ConfirmOver < 0 {
+		return fmt.Errorf("--confirm-over 不能为负数")
+	}
+
 	return nil
 }
 
@@ -235,9 +464,30 @@ func (c *ScriptController) loadHosts(req *ScriptCommandRequest) ([]executor.Host
 		ConfigFile: req.ConfigFile,
 		Inventory:  req.Inventory,
 		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
 	}, true)
 }
 
+// listTargets 返回将要执行脚本的主机（--list-only），主机的选取与实际执行脚本时相同
+// 设置 --shuffle 而没有指定 --seed 时每次的顺序不同，需要与实际执行脚本一致时应指定 --seed
+func (c *ScriptController) listTargets(req *ScriptCommandRequest) (*ScriptCommandResponse, error) {
+	hosts, err := c.loadHosts(req)
+	if err != nil {
+		return nil, err
+	}
+	hosts = shuffleHosts(hosts, req.Shuffle, req.Seed)
+	hosts = c.applyLimitAndOffset(hosts, req.Offset, req.Limit)
+
+	return &ScriptCommandResponse{
+		Group: req.Group,
+		Hosts: hosts,
+	}, nil
+}
+
 // applyLimitAndOffset 应用 limit 和 offset 来过滤主机列表
 func (c *ScriptController) applyLimitAndOffset(hosts []executor.Host, offset, limit int) []executor.Host {
 	total := len(hosts)
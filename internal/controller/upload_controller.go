@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gossh/internal/executor"
@@ -20,23 +21,61 @@ func NewUploadController() *UploadController {
 
 // UploadCommandRequest upload 命令的请求参数
 type UploadCommandRequest struct {
-	ConfigFile  string // ansible.cfg 配置文件路径
-	Inventory   string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group       string // Ansible INI 格式的分组名称
+	ConfigFile  string   // ansible.cfg 配置文件路径
+	Inventory   []string // 主机列表（文件路径、目录路径或逗号分隔的主机列表），可以多次指定
+	Group       string   // Ansible INI 格式的分组名称
 	User        string
 	KeyPath     string
 	Password    string
 	Port        string
+	TOTP        string // 二次验证码（固定值）
+	TOTPSecret  string // TOTP 密钥（Base32），每次连接自动生成验证码
+	TOTPPrompt  bool   // 交互式输入二次验证码
 	LocalPath   string
-	RemotePath  string
+	RemotePaths []string // 远程文件路径，指定多个时每台主机依次上传到所有路径
 	Mode        string
 	Concurrency int
 	ShowOutput  bool
 	LogDir      string
 	Limit       int
 	Offset      int
-	Backup      bool // 如果文件已存在，先备份再上传
-	Force       bool // 强制覆盖已存在的文件
+	Hooks       HookConfig // 执行前后调用的本地钩子
+	Backup      bool       // 如果文件已存在，先备份再上传
+	Force       bool       // 强制覆盖已存在的文件
+	Become      bool       // 上传到临时文件后通过 sudo 移动到目标路径
+	BecomeUser  string     // sudo 切换的目标用户，也是目标文件的属主（默认: root）
+	SudoFlags   string     // 追加到 sudo 的额外参数（如 -H、-i、--preserve-env）
+
+	BackupDir        string // 备份目录（保留原文件的完整路径），为空表示备份到原文件所在目录
+	BackupSuffix     string // 备份文件名后缀，{timestamp} 替换为时间戳（默认: .backup.{timestamp}）
+	BackupTimeFormat string // 备份文件名中时间戳的格式（Go 时间格式，默认: 20060102-150405）
+
+	ConnectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手，默认: 10s）
+	Timeout        time.Duration // 单台主机的操作总时间上限（0 表示不限制）
+	Deadline       time.Duration // 整批执行的总时间上限（0 表示不限制），超过后未完成的主机记为超过时限
+
+	MergeStrategy string // 同一主机在多个 inventory 来源中重复定义时的合并策略（first、last、merge）
+	VarsDir       string // Ansible 风格的 vars 目录（包含 host_vars 和 group_vars）
+	PreResolve    bool   // 执行前预先解析所有主机名，之后的连接复用解析结果
+	ProxyCommand  string // ProxyCommand 模板（%h、%p、%r），通过该命令的标准输入输出连接主机
+	HostKeyCheck  string // 是否校验主机密钥（yes、no），为空时使用 ansible.cfg 的 host_key_checking，都未设置时不校验
+	IdentityAgent string // ssh-agent 的 socket 路径（SSH_AUTH_SOCK 或 $环境变量名 表示从环境变量读取），为空时不使用 ssh-agent
+	NoDefaultKey  bool   // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥（id_ed25519、id_ecdsa、id_rsa）
+
+	Exclude     []string // 要排除的主机（address 或 address:port），在 limit/offset 之前应用
+	ExcludeFile string   // 要排除的主机列表文件（每行一个 address 或 address:port）
+
+	ReuseConnections bool // 复用连接：同一主机的多个步骤共用空闲连接，而不是每次重新建立连接
+
+	ConfirmOver int  // 主机数超过该值时要求在终端输入 yes 确认（0 表示不确认）
+	AssumeYes   bool // 跳过 ConfirmOver 的确认
+
+	Shuffle bool  // 执行前随机打乱主机顺序（在 limit/offset 之前应用）
+	Seed    int64 // 打乱主机顺序的随机种子（0 表示每次随机），指定后结果可重现
+
+	ListOnly bool // 只解析并返回目标主机（应用分组、排除、打乱顺序和 offset/limit），不连接主机，也不打印配置参数
+
+	DB string // 执行结果追加写入的 SQLite 数据库文件（--db），为空表示不写入
 }
 
 // UploadCommandResponse upload 命令的响应
@@ -52,6 +91,11 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 	// 合并配置（优先级：命令行参数 > ansible.cfg > 默认值）
 	mergedReq := c.mergeConfig(req)
 
+	// 只列出目标主机（--list-only）时不创建日志、不打印配置参数，也不连接主机
+	if mergedReq.ListOnly {
+		return c.listTargets(mergedReq)
+	}
+
 	// 创建日志记录器
 	log, err := logger.NewLogger(mergedReq.LogDir, "upload")
 	if err != nil {
@@ -61,35 +105,42 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 
 	// 打印当前配置参数
 	view.PrintUploadConfig(
-		mergedReq.Inventory,
+		inventoryText(mergedReq.Inventory),
 		mergedReq.Group,
 		mergedReq.User,
 		mergedReq.KeyPath,
 		mergedReq.Password,
 		mergedReq.Port,
 		mergedReq.LocalPath,
-		mergedReq.RemotePath,
+		strings.Join(mergedReq.RemotePaths, ", "),
 		mergedReq.Mode,
 		mergedReq.Concurrency,
 		mergedReq.ShowOutput,
 		mergedReq.Backup,
 		mergedReq.Force,
+		mergedReq.Become,
+		mergedReq.BecomeUser,
+		mergedReq.SudoFlags,
 	)
 
 	// 记录命令开始
 	log.LogCommandStart("upload", map[string]interface{}{
-		"inventory":   mergedReq.Inventory,
+		"inventory":   inventoryText(mergedReq.Inventory),
 		"group":       mergedReq.Group,
 		"user":        mergedReq.User,
 		"key_path":    mergedReq.KeyPath,
 		"port":        mergedReq.Port,
 		"local_path":  mergedReq.LocalPath,
-		"remote_path": mergedReq.RemotePath,
+		"remote_path": mergedReq.RemotePaths,
 		"mode":        mergedReq.Mode,
 		"concurrency": mergedReq.Concurrency,
 		"show_output": mergedReq.ShowOutput,
 		"backup":      mergedReq.Backup,
 		"force":       mergedReq.Force,
+		"become":      mergedReq.Become,
+		"become_user": mergedReq.BecomeUser,
+		"sudo_flags":  mergedReq.SudoFlags,
+		"backup_dir":  mergedReq.BackupDir,
 	})
 
 	// 验证参数
@@ -105,16 +156,25 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 		return nil, err
 	}
 
+	// 随机打乱主机顺序（--shuffle），在 offset 和 limit 之前应用
+	hosts = shuffleHosts(hosts, mergedReq.Shuffle, mergedReq.Seed)
+
 	// 应用 offset 和 limit
 	hosts = c.applyLimitAndOffset(hosts, mergedReq.Offset, mergedReq.Limit)
 
 	// 记录主机列表
 	hostAddresses := make([]string, len(hosts))
 	for i, h := range hosts {
-		hostAddresses[i] = h.Address
+		hostAddresses[i] = h.DisplayName()
 	}
 	log.LogHosts(hostAddresses)
 
+	// 主机数超过 --confirm-over 时要求确认
+	if err := confirmHostCount(len(hosts), mergedReq.ConfirmOver, mergedReq.AssumeYes); err != nil {
+		log.LogError("执行未确认", err)
+		return nil, err
+	}
+
 	// 设置默认端口
 	port := mergedReq.Port
 	if port == "" {
@@ -127,24 +187,90 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 		mode = "0644"
 	}
 
+	// 创建二次验证码提供者
+	totp, err := newTOTPProvider(mergedReq.TOTP, mergedReq.TOTPSecret, mergedReq.TOTPPrompt, mergedReq.Password)
+	if err != nil {
+		log.LogError("创建 TOTP 提供者失败", err)
+		return nil, err
+	}
+
+	// 执行 pre-hook
+	hookCtx := hookContext{Command: "upload", Group: mergedReq.Group, Target: mergedReq.LocalPath, Hosts: hosts}
+	if err := runPreHook(mergedReq.Hooks, hookCtx); err != nil {
+		log.LogError("pre-hook 执行失败", err)
+		return nil, err
+	}
+
+	// 创建执行器
+	exec := executor.NewExecutorWithTimeouts(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port, totp, mergedReq.ConnectTimeout, mergedReq.Timeout)
+	exec.SetProxyCommand(mergedReq.ProxyCommand)
+
+	// 主机密钥校验（--host-key-check 或 ansible.cfg 的 host_key_checking）
+	hostKeyChecker, err := newHostKeyChecker(mergedReq.ConfigFile, mergedReq.HostKeyCheck)
+	if err != nil {
+		log.LogError("加载主机密钥校验失败", err)
+		return nil, err
+	}
+	exec.SetHostKeyChecker(hostKeyChecker)
+
+	// ssh-agent 认证（--identity-agent）
+	identityAgent, err := newIdentityAgent(mergedReq.IdentityAgent)
+	if err != nil {
+		log.LogError("连接 ssh-agent 失败", err)
+		return nil, err
+	}
+	defer identityAgent.Close()
+	exec.SetIdentityAgent(identityAgent)
+	exec.SetNoDefaultKey(mergedReq.NoDefaultKey)
+
+	// 每台主机得到结果后执行 on-success/on-failure 本地命令
+	hostHooks, err := newHostHookRunner(mergedReq.Hooks, log)
+	if err != nil {
+		log.LogError("解析主机命令模板失败", err)
+		return nil, err
+	}
+	hostHooks.attach(exec)
+
+	// 复用连接：连接用完后放回连接池，执行结束时关闭
+	if mergedReq.ReuseConnections {
+		pool := ssh.NewConnectionPool(ssh.DefaultPoolIdleTTL)
+		defer pool.Close()
+		exec.SetConnectionPool(pool)
+	}
+
+	// 预先解析主机名
+	if mergedReq.PreResolve {
+		preResolveHosts(exec, mergedReq.Concurrency, log)
+	}
+
 	// 创建进度跟踪器
 	progressTracker := view.NewProgressTracker(len(hosts), "上传文件")
 
-	// 创建执行器
-	exec := executor.NewExecutor(hosts, mergedReq.User, mergedReq.KeyPath, mergedReq.Password, port)
+	// 整批执行的总时间上限（--deadline），从开始执行时计时
+	defer applyDeadline(exec, mergedReq.Deadline)()
 
 	// 记录开始时间
 	startTime := time.Now()
 
 	// 上传文件
-	results, err := exec.UploadFile(
+	results, err := exec.UploadFileToPathsWithOptions(
 		mergedReq.LocalPath,
-		mergedReq.RemotePath,
+		mergedReq.RemotePaths,
 		mode,
 		mergedReq.Concurrency,
-		progressTracker,
 		mergedReq.Backup,
 		mergedReq.Force,
+		ssh.ExecOptions{
+			Become:     mergedReq.Become,
+			BecomeUser: mergedReq.BecomeUser,
+			SudoFlags:  mergedReq.SudoFlags,
+			Backup: ssh.BackupOptions{
+				Dir:        mergedReq.BackupDir,
+				Suffix:     mergedReq.BackupSuffix,
+				TimeFormat: mergedReq.BackupTimeFormat,
+			},
+		},
+		progressTracker,
 	)
 
 	// 记录结束时间并计算总耗时
@@ -172,6 +298,9 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 		)
 	}
 
+	// 执行 post-hook
+	runPostHook(mergedReq.Hooks, hookCtx, results, totalDuration)
+
 	// 记录命令结束
 	commandSuccess := err == nil && successCount == len(results)
 	if err != nil {
@@ -179,6 +308,11 @@ func (c *UploadController) Execute(req *UploadCommandRequest) (*UploadCommandRes
 		return nil, fmt.Errorf("上传失败: %w", err)
 	}
 
+	// 追加执行记录到 --db 指定的数据库
+	if mergedReq.DB != "" {
+		appendHistory(mergedReq.DB, hookCtx, startTime, totalDuration, results, log)
+	}
+
 	log.LogCommandEnd("upload", totalDuration, commandSuccess, nil)
 
 	return &UploadCommandResponse{
@@ -210,26 +344,68 @@ func (c *UploadController) mergeConfig(req *UploadCommandRequest) *UploadCommand
 		KeyPath:     commonCfg.KeyPath,
 		Password:    commonCfg.Password,
 		Port:        commonCfg.Port,
+		TOTP:        req.TOTP,
+		TOTPSecret:  req.TOTPSecret,
+		TOTPPrompt:  req.TOTPPrompt,
 		LocalPath:   req.LocalPath,
-		RemotePath:  req.RemotePath,
+		RemotePaths: normalizeRemotePaths(req.RemotePaths),
 		Mode:        req.Mode,
 		Concurrency: commonCfg.Concurrency,
 		ShowOutput:  req.ShowOutput,
 		LogDir:      req.LogDir,
 		Limit:       req.Limit,
 		Offset:      req.Offset,
+		Shuffle:     req.Shuffle,
+		Seed:        req.Seed,
+		ListOnly:    req.ListOnly,
+		Hooks:       req.Hooks,
 		Backup:      req.Backup,
 		Force:       req.Force,
+		Become:      req.Become,
+		BecomeUser:  req.BecomeUser,
+		SudoFlags:   req.SudoFlags,
+
+		BackupDir:        req.BackupDir,
+		BackupSuffix:     req.BackupSuffix,
+		BackupTimeFormat: req.BackupTimeFormat,
+
+		ConnectTimeout: req.ConnectTimeout,
+		Timeout:        req.Timeout,
+		Deadline:       req.Deadline,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
+		PreResolve:    req.PreResolve,
+		ProxyCommand:  req.ProxyCommand,
+		HostKeyCheck:  req.HostKeyCheck,
+		IdentityAgent: req.IdentityAgent,
+		NoDefaultKey:  req.NoDefaultKey,
+
+		ReuseConnections: req.ReuseConnections,
+
+		ConfirmOver: req.ConfirmOver,
+		DB:          req.DB,
+		AssumeYes:   req.AssumeYes,
 	}
 }
 
 // validateRequest 验证请求参数
 func (c *UploadController) validateRequest(req *UploadCommandRequest) error {
+	if req.Seed != 0 && !req.Shuffle {
+		return fmt.Errorf("--seed 需要与 --shuffle 一起使用")
+	}
+
 	if req.LocalPath == "" {
 		return fmt.Errorf("必须指定本地文件路径（-l）")
 	}
 
-	if req.RemotePath == "" {
+	if err := validateLocalFile(req.LocalPath); err != nil {
+		return err
+	}
+
+	if len(req.RemotePaths) == 0 {
 		return fmt.Errorf("必须指定远程文件路径（-r）")
 	}
 
@@ -237,18 +413,85 @@ func (c *UploadController) validateRequest(req *UploadCommandRequest) error {
 		return fmt.Errorf("必须指定用户名（-u 或 ansible.cfg 中的 remote_user）")
 	}
 
+	if req.SudoFlags != "" {
+		if !req.Become {
+			return fmt.Errorf("--sudo-flags 需要与 --become 一起使用")
+		}
+		if err := ssh.ValidateSudoFlags(req.SudoFlags); err != nil {
+			return err
+		}
+	}
+
+	if (req.BackupDir != "" || req.BackupSuffix != "" || req.BackupTimeFormat != "") && !req.Backup {
+		return fmt.Errorf("--backup-dir、--backup-suffix 和 --backup-time-format 需要与 --backup 一起使用")
+	}
+	if err := ssh.ValidateBackupOptions(ssh.BackupOptions{Dir: req.BackupDir, Suffix: req.BackupSuffix, TimeFormat: req.BackupTimeFormat}); err != nil {
+		return err
+	}
+
+	if err := validateHooks(req.Hooks); err != nil {
+		return err
+	}
+
+	if err := validateTimeouts(req.ConnectTimeout, req.Timeout); err != nil {
+		return err
+	}
+	if req.Deadline < 0 {
+		return fmt.Errorf("--deadline 不能为负数: %v", req.Deadline)
+	}
+
+	if req.ConfirmOver < 0 {
+		return fmt.Errorf("--confirm-over 不能为负数")
+	}
+
 	return nil
 }
 
+// normalizeRemotePaths 去除远程路径两端的空白，并丢弃空路径和重复路径（保持原有顺序）
+func normalizeRemotePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	normalized := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		normalized = append(normalized, p)
+	}
+	return normalized
+}
+
 // loadHosts 加载主机列表
 func (c *UploadController) loadHosts(req *UploadCommandRequest) ([]executor.Host, error) {
 	return LoadHosts(&CommonConfig{
 		ConfigFile: req.ConfigFile,
 		Inventory:  req.Inventory,
 		Group:      req.Group,
+
+		MergeStrategy: req.MergeStrategy,
+		VarsDir:       req.VarsDir,
+		Exclude:       req.Exclude,
+		ExcludeFile:   req.ExcludeFile,
 	}, true)
 }
 
+// listTargets 返回将要上传文件的主机（--list-only），主机的选取与实际上传文件时相同
+// 设置 --shuffle 而没有指定 --seed 时每次的顺序不同，需要与实际上传文件一致时应指定 --seed
+func (c *UploadController) listTargets(req *UploadCommandRequest) (*UploadCommandResponse, error) {
+	hosts, err := c.loadHosts(req)
+	if err != nil {
+		return nil, err
+	}
+	hosts = shuffleHosts(hosts, req.Shuffle, req.Seed)
+	hosts = c.applyLimitAndOffset(hosts, req.Offset, req.Limit)
+
+	return &UploadCommandResponse{
+		Group: req.Group,
+		Hosts: hosts,
+	}, nil
+}
+
 // applyLimitAndOffset 应用 limit 和 offset 来过滤主机列表
 func (c *UploadController) applyLimitAndOffset(hosts []executor.Host, offset, limit int) []executor.Host {
 	total := len(hosts)
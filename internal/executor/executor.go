@@ -1,10 +1,16 @@
 package executor
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"gossh/internal/logger"
 	"gossh/internal/ssh"
 )
 
@@ -16,20 +22,108 @@ type Executor struct {
 	keyPath  string
 	password string
 	port     string
+	totp     *ssh.TOTPProvider // 二次验证码提供者（可选）
+
+	connectTimeout time.Duration // 连接超时（TCP 连接和 SSH 握手），主机的 gossh_timeout 优先
+	timeout        time.Duration // 单台主机的操作总时间上限，0 表示不限制
+
+	retry RetryPolicy // 连接失败时的重试策略
+
+	resolved      map[string]string // 预解析的主机名 -> IP（PreResolve 之后只读）
+	resolveErrors map[string]error  // 预解析失败的主机名 -> 错误
+
+	proxyCommand string // ProxyCommand 模板（支持 %h、%p、%r），为空表示直接建立 TCP 连接
+
+	pool *ssh.ConnectionPool // 连接池（可选），设置后所有主机的连接用完后放回连接池，供之后的执行复用
+
+	hostHooks []func(*ssh.Result) // 每台主机得到结果后按添加顺序调用（可选），在主机的执行协程中同步调用
+
+	hostKeyChecker *ssh.HostKeyChecker // 主机密钥校验（可选），为 nil 时不校验主机密钥
+	identityAgent  *ssh.IdentityAgent  // ssh-agent（可选），设置后同时使用 ssh-agent 中的私钥认证
+	noDefaultKey   bool                // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥
+
+	ctx context.Context // 整批执行的上下文（可选），结束（如超过 --deadline）后不再开始新的主机，执行中的主机断开连接
 }
 
 // Host 主机信息
 // 包含主机的地址、端口、用户和 SSH 密钥路径
 type Host struct {
-	Address string   // 主机地址（IP 或域名）
-	Port    string   // SSH 端口
-	User    string   // SSH 用户名
-	KeyPath string   // SSH 私钥路径
-	Groups  []string // 主机所属的分组列表（一个主机可能属于多个分组）
+	Address string        // 主机地址（IP 或域名）
+	Port    string        // SSH 端口
+	User    string        // SSH 用户名
+	KeyPath string        // SSH 私钥路径
+	Groups  []string      // 主机所属的分组列表（一个主机可能属于多个分组）
+	Timeout time.Duration // 主机级连接超时（inventory 变量 gossh_timeout），0 表示使用全局配置
+
+	Interpreter string // 主机级脚本解释器（inventory 变量 gossh_interpreter），为空表示使用全局配置
+	Become      bool   // 主机级 become（vars 目录中的 ansible_become），开启后该主机使用 sudo 执行
+	BecomeUser  string // 主机级 sudo 目标用户（vars 目录中的 ansible_become_user），为空表示使用全局配置
+
+	Env map[string]string // 主机级环境变量（inventory 变量 gossh_env_<名称>），与全局的 --env 合并，同名时主机级的优先
+}
+
+// DisplayName 返回主机在输出中的标识：使用非默认端口（22 以外）时为 "address:port"，否则为 address
+// 用于区分地址相同、端口不同的主机（例如同一台机器上的多个 SSH 服务或端口转发）
+func (h Host) DisplayName() string {
+	if h.Port == "" || h.Port == "22" {
+		return h.Address
+	}
+	return fmt.Sprintf("%s:%s", h.Address, h.Port)
+}
+
+// CommandKey 返回主机在命令映射中的 key "address:port"，主机未指定端口时使用 defaultPort（为空时为 22），
+// 与执行时查找命令使用的 key 一致（NewExecutor 会为未指定端口的主机填入默认端口）
+func (h Host) CommandKey(defaultPort string) string {
+	port := h.Port
+	if port == "" {
+		port = defaultPort
+	}
+	if port == "" {
+		port = "22"
+	}
+	return fmt.Sprintf("%s:%s", h.Address, port)
 }
 
+// execOptions 合并主机级 become 设置和环境变量：主机开启 become 时即使未指定 --become 也使用 sudo，
+// 主机指定的 sudo 目标用户和环境变量优先于全局配置
+func (h Host) execOptions(opts ssh.ExecOptions) ssh.ExecOptions {
+	if h.Become {
+		opts.Become = true
+	}
+	if h.BecomeUser != "" {
+		opts.BecomeUser = h.BecomeUser
+	}
+	opts.Env = ssh.MergeEnv(opts.Env, h.Env)
+	return opts
+}
+
+// DefaultConnectTimeout 默认连接超时（TCP 连接和 SSH 握手）
+const DefaultConnectTimeout = 10 * time.Second
+
 // NewExecutor 创建新的执行器
-func NewExecutor(hosts []Host, user, keyPath, password, defaultPort string) *Executor {
+// totp 为 nil 时不启用 keyboard-interactive 二次验证
+func NewExecutor(hosts []Host, user, keyPath, password, defaultPort string, totp *ssh.TOTPProvider) *Executor {
+	return NewExecutorWithTimeouts(hosts, user, keyPath, password, defaultPort, totp, DefaultConnectTimeout, 0)
+}
+
+// NewExecutorWithTimeouts 创建新的执行器，支持自定义超时
+// connectTimeout 限制 TCP 连接和 SSH 握手（<= 0 时使用 DefaultConnectTimeout，且不超过 timeout）；
+// timeout 限制单台主机从建立连接到操作完成的总时间，超过后断开连接并记为执行超时，0 表示不限制
+func NewExecutorWithTimeouts(hosts []Host, user, keyPath, password, defaultPort string, totp *ssh.TOTPProvider, connectTimeout, timeout time.Duration) *Executor {
+	return NewExecutorWithRetry(hosts, user, keyPath, password, defaultPort, totp, connectTimeout, timeout, RetryPolicy{})
+}
+
+// NewExecutorWithRetry 创建新的执行器，支持自定义超时和连接失败重试
+// 只重试连接失败和连接超时（命令尚未开始执行），认证失败、执行失败和操作超时不重试；
+// 重试的等待时间计入 timeout
+func NewExecutorWithRetry(hosts []Host, user, keyPath, password, defaultPort string, totp *ssh.TOTPProvider, connectTimeout, timeout time.Duration, retry RetryPolicy) *Executor {
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+	if timeout > 0 && connectTimeout > timeout {
+		connectTimeout = timeout
+	}
+
 	// 如果没有指定端口，使用默认端口
 	for i := range hosts {
 		if hosts[i].Port == "" {
@@ -49,6 +143,79 @@ func NewExecutor(hosts []Host, user, keyPath, password, defaultPort string) *Exe
 		keyPath:  keyPath,
 		password: password,
 		port:     defaultPort,
+		totp:     totp,
+
+		connectTimeout: connectTimeout,
+		timeout:        timeout,
+
+		retry: retry,
+	}
+}
+
+// SetProxyCommand 设置 ProxyCommand 模板，所有主机通过该命令的标准输入输出连接（见 ssh.Client.SetProxyCommand）
+func (e *Executor) SetProxyCommand(template string) {
+	e.proxyCommand = template
+}
+
+// SetConnectionPool 设置连接池，pool 为 nil 表示不复用连接
+// 连接池由调用方创建和关闭，可以在多个执行器之间共用（如对同一批主机连续执行多个操作）
+func (e *Executor) SetConnectionPool(pool *ssh.ConnectionPool) {
+	e.pool = pool
+}
+
+// SetHostKeyChecker 设置主机密钥校验，checker 为 nil 表示不校验（默认）
+func (e *Executor) SetHostKeyChecker(checker *ssh.HostKeyChecker) {
+	e.hostKeyChecker = checker
+}
+
+// SetIdentityAgent 设置 ssh-agent，identityAgent 为 nil 表示不使用 ssh-agent（默认）
+// ssh-agent 的连接由调用方创建和关闭
+func (e *Executor) SetIdentityAgent(identityAgent *ssh.IdentityAgent) {
+	e.identityAgent = identityAgent
+}
+
+// SetNoDefaultKey 设置是否禁用默认私钥：禁用后未指定私钥、密码和 ssh-agent 的主机直接连接失败，
+// 不再尝试 ~/.ssh 下的 id_rsa 等默认私钥，执行结果不依赖运行用户的主目录
+func (e *Executor) SetNoDefaultKey(noDefaultKey bool) {
+	e.noDefaultKey = noDefaultKey
+}
+
+// SetContext 设置整批执行的上下文，ctx 为 nil 表示不限制（默认）
+// ctx 结束后等待并发槽位的主机不再连接，执行中的主机断开连接，都记为超过时限（ssh.DeadlineExceededError）
+func (e *Executor) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// context 返回整批执行的上下文，未设置时为 context.Background()
+func (e *Executor) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// SetHostHook 设置每台主机得到结果（成功、失败或连接失败）后调用的函数，替换之前添加的所有函数，hook 为 nil 表示不调用
+// hook 在各主机的执行协程中并发调用，需要自行保证并发安全；所有主机的 hook 返回后执行才会结束
+func (e *Executor) SetHostHook(hook func(*ssh.Result)) {
+	e.hostHooks = nil
+	e.AddHostHook(hook)
+}
+
+// AddHostHook 追加每台主机得到结果后调用的函数（与 SetHostHook 相同的调用方式），hook 为 nil 时忽略
+// 连接和执行得到的结果在释放并发槽位之前调用 hook，因此并发数为 1 时下一台主机在 hook 返回后才开始连接
+func (e *Executor) AddHostHook(hook func(*ssh.Result)) {
+	if hook != nil {
+		e.hostHooks = append(e.hostHooks, hook)
+	}
+}
+
+// runHostHook 依次调用主机结果钩子（未设置时什么也不做）
+func (e *Executor) runHostHook(result *ssh.Result) {
+	if result == nil {
+		return
+	}
+	for _, hook := range e.hostHooks {
+		hook(result)
 	}
 }
 
@@ -72,12 +239,187 @@ func (e *Executor) ExecuteCommand(command string, concurrency int, progressTrack
 
 // ExecuteCommandWithBecome 并发执行命令，支持 become 模式
 func (e *Executor) ExecuteCommandWithBecome(command string, concurrency int, become bool, becomeUser string, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	return e.ExecuteCommandWithOptions(command, concurrency, ssh.ExecOptions{Become: become, BecomeUser: becomeUser}, progressTracker)
+}
+
+// ExecuteCommandWithOptions 并发执行命令，支持 become 模式和登录 shell 等执行选项
+func (e *Executor) ExecuteCommandWithOptions(command string, concurrency int, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
 	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
-		return client.ExecuteWithBecome(command, become, becomeUser)
+		return client.ExecuteWithOptions(command, h.execOptions(opts))
 	}
 	return e.executeConcurrent(task, command, concurrency, progressTracker)
 }
 
+// ExecuteCommandStream 并发执行命令，每台主机完成时立即调用 onResult，最后仍返回完整的结果列表
+// onResult 的调用是串行的（不会并发调用），调用顺序为主机完成的顺序；不依赖进度条等内置视图，
+// 便于实现自定义的实时展示或增量持久化
+func (e *Executor) ExecuteCommandStream(command string, concurrency int, become bool, becomeUser string, onResult func(*ssh.Result)) ([]*ssh.Result, error) {
+	opts := ssh.ExecOptions{Become: become, BecomeUser: becomeUser}
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		return client.ExecuteWithOptions(command, h.execOptions(opts))
+	}
+	return e.executeConcurrentWithCallback(task, command, concurrency, nil, onResult)
+}
+
+// ExecuteCommandSequenceWithOptions 并发在各主机上执行命令列表（如 --command-file），每台主机使用一个连接
+// seqOpts 控制同一主机上的命令是否并发执行、失败后是否继续以及退出码的计算方式（见 ssh.Client.ExecuteSequenceWithOptions）
+func (e *Executor) ExecuteCommandSequenceWithOptions(commands []string, seqOpts ssh.SequenceOptions, concurrency int, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		return client.ExecuteSequenceWithOptions(commands, seqOpts, h.execOptions(opts))
+	}
+	return e.executeConcurrent(task, strings.Join(commands, "; "), concurrency, progressTracker)
+}
+
+// ExecuteCommandMapWithOptions 并发执行按主机映射的命令，每台主机执行各自的命令
+// commands 的 key 为主机地址（"address:port" 优先于 "address"）；
+// 不在映射中的主机不会建立连接：strict 为 false 时标记为跳过，为 true 时记为失败
+func (e *Executor) ExecuteCommandMapWithOptions(commands map[string]string, strict bool, concurrency int, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	results := make([]*ssh.Result, len(e.hosts))
+
+	// 拆分出映射中存在的主机，缺失的主机直接生成结果
+	mapped := make([]Host, 0, len(e.hosts))
+	mappedIdx := make([]int, 0, len(e.hosts))
+	for i, h := range e.hosts {
+		if _, ok := lookupHostCommand(commands, h); ok {
+			mapped = append(mapped, h)
+			mappedIdx = append(mappedIdx, i)
+			continue
+		}
+		results[i] = e.handleUnmappedHost(h, strict, progressTracker)
+	}
+
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		command, _ := lookupHostCommand(commands, h)
+		return client.ExecuteWithOptions(command, h.execOptions(opts))
+	}
+
+	sub := *e
+	sub.hosts = mapped
+	mappedResults, err := sub.executeConcurrent(task, "", concurrency, progressTracker)
+	for i, result := range mappedResults {
+		// 连接失败等错误结果没有命令信息，补充为该主机映射的命令
+		if result != nil && result.Command == "" {
+			result.Command, _ = lookupHostCommand(commands, mapped[i])
+		}
+		results[mappedIdx[i]] = result
+	}
+
+	// 子执行器中的序号只对应映射中存在的主机，按完整的主机列表重新编号
+	for i, result := range results {
+		if result != nil {
+			result.Index = i + 1
+		}
+	}
+
+	return results, err
+}
+
+// ExecuteGroupCommandsWithOptions 按主机所属分组选择命令并执行（--group-command）
+// groupCommands 的 key 是分组名；主机属于多个映射分组且命令不同时，按 precedence 中的顺序选择，
+// precedence 未覆盖时返回错误。不属于任何映射分组的主机与命令映射一样处理（跳过或 strict 时记为失败）
+func (e *Executor) ExecuteGroupCommandsWithOptions(groupCommands map[string]string, precedence []string, strict bool, concurrency int, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	commands, err := ResolveGroupCommands(e.hosts, groupCommands, precedence, e.port)
+	if err != nil {
+		return nil, err
+	}
+	return e.ExecuteCommandMapWithOptions(commands, strict, concurrency, opts, progressTracker)
+}
+
+// ResolveGroupCommands 将按分组指定的命令展开为按主机指定的命令映射（key 为 "address:port"，未指定端口的主机使用 defaultPort）
+// 可在连接主机之前调用，提前发现属于多个映射分组而无法确定命令的主机
+func ResolveGroupCommands(hosts []Host, groupCommands map[string]string, precedence []string, defaultPort string) (map[string]string, error) {
+	rank := make(map[string]int, len(precedence))
+	for i, group := range precedence {
+		if _, exists := rank[group]; !exists {
+			rank[group] = i
+		}
+	}
+
+	commands := make(map[string]string, len(hosts))
+	for _, h := range hosts {
+		var matched []string
+		for _, group := range h.Groups {
+			if _, ok := groupCommands[group]; ok && !slices.Contains(matched, group) {
+				matched = append(matched, group)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		chosen := matched[0]
+		if len(matched) > 1 {
+			var err error
+			chosen, err = chooseGroupCommand(h, matched, groupCommands, rank)
+			if err != nil {
+				return nil, err
+			}
+		}
+		commands[h.CommandKey(defaultPort)] = groupCommands[chosen]
+	}
+	return commands, nil
+}
+
+// chooseGroupCommand 主机属于多个映射分组时选择使用的分组：命令都相同时直接使用，
+// 否则选择 precedence 中最靠前的分组，precedence 中没有任何一个分组时返回错误
+func chooseGroupCommand(h Host, matched []string, groupCommands map[string]string, rank map[string]int) (string, error) {
+	chosen := ""
+	for _, group := range matched {
+		if r, ok := rank[group]; ok && (chosen == "" || r < rank[chosen]) {
+			chosen = group
+		}
+	}
+	if chosen != "" {
+		return chosen, nil
+	}
+
+	for _, group := range matched[1:] {
+		if groupCommands[group] != groupCommands[matched[0]] {
+			sorted := slices.Clone(matched)
+			slices.Sort(sorted)
+			return "", fmt.Errorf("主机 %s 同时属于分组 %s，命令不同，请通过 --group-precedence 指定优先级", h.DisplayName(), strings.Join(sorted, "、"))
+		}
+	}
+	return matched[0], nil
+}
+
+// lookupHostCommand 查找主机对应的命令，"address:port" 优先于 "address"
+func lookupHostCommand(commands map[string]string, h Host) (string, bool) {
+	if command, ok := commands[h.CommandKey("")]; ok {
+		return command, true
+	}
+	command, ok := commands[h.Address]
+	return command, ok
+}
+
+// handleUnmappedHost 处理不在命令映射中的主机
+func (e *Executor) handleUnmappedHost(h Host, strict bool, progressTracker ProgressTracker) *ssh.Result {
+	name := h.DisplayName()
+	if progressTracker != nil {
+		progressTracker.AddTracker(name)
+	}
+
+	if strict {
+		err := fmt.Errorf("主机 %s 不在命令映射中", name)
+		if progressTracker != nil {
+			progressTracker.MarkTrackerErrored(name, "不在命令映射中")
+		}
+		result := e.createErrorResult(name, "", 0, err, "执行失败")
+		e.runHostHook(result)
+		return result
+	}
+
+	if progressTracker != nil {
+		progressTracker.UpdateTracker(name, 100, fmt.Sprintf("%s (已跳过)", name))
+		progressTracker.MarkTrackerDone(name)
+	}
+	return &ssh.Result{
+		Host:    name,
+		Stdout:  "主机不在命令映射中，已跳过",
+		Skipped: true,
+	}
+}
+
 // ExecuteScript 并发执行脚本（先上传到临时目录再执行）
 func (e *Executor) ExecuteScript(scriptPath string, concurrency int, progressTracker ProgressTracker) ([]*ssh.Result, error) {
 	return e.ExecuteScriptWithBecome(scriptPath, concurrency, false, "", "bash", progressTracker)
@@ -85,47 +427,161 @@ func (e *Executor) ExecuteScript(scriptPath string, concurrency int, progressTra
 
 // ExecuteScriptWithBecome 并发执行脚本（先上传到临时目录再执行），支持 become 模式
 func (e *Executor) ExecuteScriptWithBecome(scriptPath string, concurrency int, become bool, becomeUser string, executor string, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	return e.ExecuteScriptWithOptions(scriptPath, concurrency, ssh.ScriptOptions{Executor: executor}, ssh.ExecOptions{Become: become, BecomeUser: becomeUser}, progressTracker)
+}
+
+// ExecuteScriptWithOptions 并发执行脚本（先上传到临时目录再执行），支持 become 模式等执行选项
+// 主机设置了 Interpreter 时使用主机级解释器，覆盖 scriptOpts.Executor
+func (e *Executor) ExecuteScriptWithOptions(scriptPath string, concurrency int, scriptOpts ssh.ScriptOptions, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
 	// 设置默认执行器
-	if executor == "" {
-		executor = "bash"
+	if scriptOpts.Executor == "" {
+		scriptOpts.Executor = "bash"
 	}
 	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
-		return client.ExecuteScriptWithBecome(scriptPath, become, becomeUser, executor)
+		hostScriptOpts := scriptOpts
+		if h.Interpreter != "" {
+			hostScriptOpts.Executor = h.Interpreter
+		}
+		return client.ExecuteScriptWithOptions(scriptPath, hostScriptOpts, h.execOptions(opts))
 	}
 	return e.executeConcurrent(task, scriptPath, concurrency, progressTracker)
 }
 
 // UploadFile 并发上传文件
 func (e *Executor) UploadFile(localPath string, remotePath string, mode string, concurrency int, progressTracker ProgressTracker, backup bool, force bool) ([]*ssh.Result, error) {
+	return e.UploadFileWithOptions(localPath, remotePath, mode, concurrency, backup, force, ssh.ExecOptions{}, progressTracker)
+}
+
+// UploadFileWithOptions 并发上传文件，支持 become 模式（上传到临时文件后通过 sudo 移动到目标路径）
+func (e *Executor) UploadFileWithOptions(localPath string, remotePath string, mode string, concurrency int, backup bool, force bool, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
 	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
-		return client.UploadFile(localPath, remotePath, mode, backup, force)
+		hostOpts := h.execOptions(opts)
+		hostOpts.UploadProgress = uploadProgress(progressTracker, h.DisplayName())
+		return client.UploadFileWithOptions(localPath, remotePath, mode, backup, force, hostOpts)
 	}
 	command := fmt.Sprintf("upload %s -> %s", localPath, remotePath)
 	return e.executeConcurrent(task, command, concurrency, progressTracker)
 }
 
+// UploadFileToPathsWithOptions 并发上传文件，每台主机依次上传到所有远程路径，每台主机返回一条合并后的结果
+func (e *Executor) UploadFileToPathsWithOptions(localPath string, remotePaths []string, mode string, concurrency int, backup bool, force bool, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		hostOpts := h.execOptions(opts)
+		hostOpts.UploadProgress = uploadProgress(progressTracker, h.DisplayName())
+		return client.UploadFileToPathsWithOptions(localPath, remotePaths, mode, backup, force, hostOpts)
+	}
+	command := fmt.Sprintf("upload %s -> %s", localPath, strings.Join(remotePaths, ", "))
+	return e.executeConcurrent(task, command, concurrency, progressTracker)
+}
+
+// uploadProgress 返回按实际发送的字节数更新主机进度的回调，百分比变化时才更新，避免频繁刷新进度条
+// 上传完成前进度最多显示 99，完成后由 MarkTrackerDone 标记为 100
+func uploadProgress(progressTracker ProgressTracker, host string) func(sent, total int64) {
+	if progressTracker == nil {
+		return nil
+	}
+	last := int64(-1)
+	return func(sent, total int64) {
+		percent := int64(100)
+		if total > 0 {
+			percent = sent * 100 / total
+		}
+		if percent == last {
+			return
+		}
+		last = percent
+		progressTracker.UpdateTracker(host, min(max(percent, 1), 99), fmt.Sprintf("%s (上传中 %d%%)", host, percent))
+	}
+}
+
+// DiffFile 并发对比本地文件与远程文件的差异
+func (e *Executor) DiffFile(localPath string, remotePath string, concurrency int, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		return client.DiffFile(localPath, remotePath)
+	}
+	command := fmt.Sprintf("diff %s <-> %s", localPath, remotePath)
+	return e.executeConcurrent(task, command, concurrency, progressTracker)
+}
+
+// ChecksumFile 并发计算远程文件的校验和（algorithm: sha256 或 md5）
+func (e *Executor) ChecksumFile(remotePath string, algorithm string, concurrency int, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		return client.ChecksumFile(remotePath, algorithm)
+	}
+	command := fmt.Sprintf("checksum(%s) %s", algorithm, remotePath)
+	return e.executeConcurrent(task, command, concurrency, progressTracker)
+}
+
+// FetchFileWithOptions 并发从各主机下载远程文件到 localDir
+// 本地文件名为 <host>-<basename>-<timestamp>（见 ssh.FetchLocalName），所有主机使用同一个 timestamp
+func (e *Executor) FetchFileWithOptions(remotePath string, localDir string, timestamp string, concurrency int, opts ssh.ExecOptions, progressTracker ProgressTracker) ([]*ssh.Result, error) {
+	task := func(client *ssh.Client, h Host) (*ssh.Result, error) {
+		localPath := filepath.Join(localDir, ssh.FetchLocalName(h.Address, h.Port, remotePath, timestamp))
+		return client.FetchFileWithOptions(remotePath, localPath, h.execOptions(opts))
+	}
+	command := fmt.Sprintf("fetch %s -> %s", remotePath, localDir)
+	return e.executeConcurrent(task, command, concurrency, progressTracker)
+}
+
 // executeConcurrent 公共的并发执行逻辑
 // 使用信号量控制并发数量，支持进度跟踪和错误处理
 func (e *Executor) executeConcurrent(task taskFunc, command string, concurrency int, progressTracker ProgressTracker) ([]*ssh.Result, error) {
-	concurrency = normalizeConcurrency(concurrency)
+	return e.executeConcurrentWithCallback(task, command, concurrency, progressTracker, nil)
+}
+
+// executeConcurrentWithCallback 并发执行任务，每台主机完成后（包括连接失败和 panic）调用 onResult
+// onResult 为 nil 时不回调；回调之间通过互斥锁串行执行
+func (e *Executor) executeConcurrentWithCallback(task taskFunc, command string, concurrency int, progressTracker ProgressTracker, onResult func(*ssh.Result)) ([]*ssh.Result, error) {
+	concurrency = normalizeConcurrency(concurrency, len(e.hosts))
+	slog.Debug("开始并发执行", "hosts", len(e.hosts), "concurrency", concurrency, "command", command)
 	results := make([]*ssh.Result, len(e.hosts))
 	semaphore := make(chan struct{}, concurrency)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	var callbackMu sync.Mutex
 
 	for i, host := range e.hosts {
 		wg.Add(1)
-		go e.executeHostTask(i, host, task, command, results, semaphore, &mu, &wg, progressTracker)
+		go func(idx int, h Host) {
+			defer wg.Done()
+			if onResult != nil {
+				defer func() {
+					mu.Lock()
+					result := results[idx]
+					mu.Unlock()
+
+					callbackMu.Lock()
+					defer callbackMu.Unlock()
+					onResult(result)
+				}()
+			}
+			e.executeHostTask(idx, h, task, command, results, semaphore, &mu, progressTracker)
+
+			mu.Lock()
+			if results[idx] != nil {
+				results[idx].Index = idx + 1
+			}
+			mu.Unlock()
+		}(i, host)
+
+		// 并发数为 1 时按主机列表的顺序逐台执行（等待信号量的协程获得槽位的顺序是不确定的）
+		if concurrency == 1 {
+			wg.Wait()
+		}
 	}
 
 	wg.Wait()
+	slog.Debug("并发执行完成", "hosts", len(e.hosts), "command", command)
 	return results, nil
 }
 
-// normalizeConcurrency 规范化并发数
-func normalizeConcurrency(concurrency int) int {
+// normalizeConcurrency 规范化并发数：未设置时使用默认值 5，且不超过任务数（--forks 大于主机数时没有意义）
+func normalizeConcurrency(concurrency int, total int) int {
 	if concurrency <= 0 {
-		return 5
+		concurrency = 5
+	}
+	if total > 0 && concurrency > total {
+		concurrency = total
 	}
 	return concurrency
 }
@@ -139,14 +595,13 @@ func (e *Executor) executeHostTask(
 	results []*ssh.Result,
 	semaphore chan struct{},
 	mu *sync.Mutex,
-	wg *sync.WaitGroup,
 	progressTracker ProgressTracker,
 ) {
 	startTime := time.Now()
-	defer e.handleTaskPanic(idx, h, command, startTime, results, mu, wg, progressTracker)
-	defer wg.Done()
+	defer e.handleTaskPanic(idx, h, command, startTime, results, mu, progressTracker)
 
-	hostAddr := h.Address
+	// 进度和结果中使用主机的显示名称，地址相同、端口不同的主机互不覆盖
+	hostAddr := h.DisplayName()
 
 	// 为主机创建 tracker
 	if progressTracker != nil {
@@ -154,15 +609,39 @@ func (e *Executor) executeHostTask(
 		progressTracker.UpdateTracker(hostAddr, 10, fmt.Sprintf("%s (连接中...)", hostAddr))
 	}
 
-	// 获取信号量，控制并发数
-	semaphore <- struct{}{}
+	// 预解析失败的主机直接记为连接失败
+	if err, ok := e.resolveErrors[h.Address]; ok {
+		e.handleConnectionError(idx, h, command, startTime, &ssh.ConnectionError{Host: hostAddr, Err: fmt.Errorf("无法解析主机名: %w", err)}, results, mu, progressTracker)
+		return
+	}
+
+	// 获取信号量，控制并发数；等待期间整批执行结束（超过 --deadline）时不再执行
+	logger.Trace("等待并发槽位", "host", hostAddr, "port", h.Port)
+	ctx := e.context()
+	acquired := false
+	select {
+	case semaphore <- struct{}{}:
+		acquired = true
+	case <-ctx.Done():
+	}
+	if acquired && ctx.Err() != nil {
+		// 与 ctx.Done() 同时就绪时可能已经获得槽位
+		<-semaphore
+		acquired = false
+	}
+	if !acquired {
+		e.handleTaskError(idx, h, command, startTime, &ssh.DeadlineExceededError{Host: hostAddr}, results, mu, progressTracker)
+		return
+	}
 	defer func() { <-semaphore }()
+	logger.Trace("获得并发槽位", "host", hostAddr, "waited", time.Since(startTime))
 
 	if progressTracker != nil {
 		progressTracker.UpdateTracker(hostAddr, 30, fmt.Sprintf("%s (创建客户端...)", hostAddr))
 	}
 	client, err := e.createSSHClient(h)
 	if err != nil {
+		logger.Trace("创建客户端失败", "host", hostAddr, "error", err)
 		e.handleConnectionError(idx, h, command, startTime, err, results, mu, progressTracker)
 		return
 	}
@@ -170,11 +649,14 @@ func (e *Executor) executeHostTask(
 	if progressTracker != nil {
 		progressTracker.UpdateTracker(hostAddr, 60, fmt.Sprintf("%s (执行中...)", hostAddr))
 	}
-	result, err := task(client, h)
+	result, err := e.runTaskWithRetry(client, h, task, startTime, progressTracker)
 	if err != nil {
+		logger.Trace("主机任务失败", "host", hostAddr, "duration", time.Since(startTime), "error", err)
 		e.handleTaskError(idx, h, command, startTime, err, results, mu, progressTracker)
 		return
 	}
+	logger.Trace("主机任务完成", "host", hostAddr, "duration", time.Since(startTime), "exit_code", result.ExitCode, "skipped", result.Skipped)
+	result.Host = hostAddr
 
 	e.handleTaskSuccess(idx, result, results, mu, progressTracker)
 }
@@ -196,7 +678,121 @@ func (e *Executor) createSSHClient(h Host) (*ssh.Client, error) {
 		port = e.port
 	}
 
-	return ssh.NewClient(h.Address, port, user, keyPath, e.password)
+	connectTimeout := e.connectTimeout
+	if h.Timeout > 0 {
+		connectTimeout = h.Timeout
+	}
+	client, err := ssh.NewClientWithOptions(h.Address, port, user, keyPath, e.password, connectTimeout, e.totp, ssh.ClientOptions{
+		IdentityAgent: e.identityAgent,
+		NoDefaultKey:  e.noDefaultKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ip, ok := e.resolved[h.Address]; ok {
+		client.SetDialHost(ip)
+	}
+	client.SetProxyCommand(e.proxyCommand)
+	client.SetHostKeyChecker(e.hostKeyChecker)
+	client.SetConnectionPool(e.pool)
+	return client, nil
+}
+
+// runTaskWithRetry 执行单台主机的任务，连接失败（或以 RetryPolicy.ExitCodes 中的退出码结束）时按重试策略等待后重试
+// 设置了操作超时时，所有尝试和等待共用 startTime 开始的超时时间，剩余时间不足以等待时不再重试
+func (e *Executor) runTaskWithRetry(client *ssh.Client, h Host, task taskFunc, startTime time.Time, progressTracker ProgressTracker) (*ssh.Result, error) {
+	reason := "" // 上一次重试的原因
+	ctx := e.context()
+	for attempt := 0; ; attempt++ {
+		if ctx.Err() != nil {
+			return nil, &ssh.DeadlineExceededError{Host: h.DisplayName(), Started: true}
+		}
+		timeout := e.timeout
+		if timeout > 0 {
+			timeout -= time.Since(startTime)
+			if timeout <= 0 {
+				return nil, &ssh.OperationTimeoutError{Host: h.DisplayName(), Timeout: e.timeout}
+			}
+		}
+
+		result, err := e.runTask(client, h, task, timeout)
+		retryExitCode := err == nil && e.retry.isRetryableExitCode(result)
+		if attempt > 0 && err == nil && result != nil && !retryExitCode {
+			result.AddWarning("%s后第 %d 次重试成功", reason, attempt)
+		}
+		if (err == nil && !retryExitCode) || attempt >= e.retry.Retries || (err != nil && !isRetryableError(err)) {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w（已重试 %d 次）", err, attempt)
+			}
+			if retryExitCode && attempt > 0 {
+				result.AddWarning("退出码 %d，已重试 %d 次", result.ExitCode, attempt)
+			}
+			return result, err
+		}
+
+		if retryExitCode {
+			reason = fmt.Sprintf("退出码 %d ", result.ExitCode)
+		} else {
+			reason = "连接失败"
+		}
+		delay := e.retry.Delay(attempt + 1)
+		if e.timeout > 0 && time.Since(startTime)+delay >= e.timeout {
+			if retryExitCode {
+				result.AddWarning("退出码 %d，已重试 %d 次，剩余时间不足以继续重试", result.ExitCode, attempt)
+				return result, nil
+			}
+			return result, fmt.Errorf("%w（已重试 %d 次，剩余时间不足以继续重试）", err, attempt)
+		}
+		logger.Trace("等待后重试", "host", h.Address, "attempt", attempt+1, "delay", delay, "reason", reason, "error", err)
+		if progressTracker != nil {
+			progressTracker.UpdateTracker(h.DisplayName(), 60, fmt.Sprintf("%s (%s，%v 后第 %d 次重试...)", h.DisplayName(), strings.TrimSpace(reason), delay.Round(time.Millisecond), attempt+1))
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, &ssh.DeadlineExceededError{Host: h.DisplayName(), Started: true}
+		}
+	}
+}
+
+// runTask 执行单台主机的任务，timeout 大于 0 时在超时后关闭客户端的连接以中断任务，
+// 整批执行的上下文结束（超过 --deadline）时同样关闭连接
+func (e *Executor) runTask(client *ssh.Client, h Host, task taskFunc, timeout time.Duration) (*ssh.Result, error) {
+	if timeout <= 0 && e.ctx == nil {
+		return task(client, h)
+	}
+
+	parent := e.context()
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer cancel()
+	}
+
+	type taskResult struct {
+		result *ssh.Result
+		err    error
+	}
+	done := make(chan taskResult, 1)
+	go func() {
+		result, err := task(client, h)
+		done <- taskResult{result: result, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		logger.Trace("主机任务超时，断开连接", "host", h.Address, "timeout", e.timeout, "deadline", parent.Err() != nil)
+		client.Close()
+		// 等待任务因连接断开而返回，避免 goroutine 泄漏
+		<-done
+		if parent.Err() != nil {
+			return nil, &ssh.DeadlineExceededError{Host: h.DisplayName(), Started: true}
+		}
+		return nil, &ssh.OperationTimeoutError{Host: h.DisplayName(), Timeout: e.timeout}
+	}
 }
 
 // handleTaskPanic 处理任务 panic
@@ -207,7 +803,6 @@ func (e *Executor) handleTaskPanic(
 	startTime time.Time,
 	results []*ssh.Result,
 	mu *sync.Mutex,
-	wg *sync.WaitGroup,
 	progressTracker ProgressTracker,
 ) {
 	if r := recover(); r != nil {
@@ -216,13 +811,15 @@ func (e *Executor) handleTaskPanic(
 
 		mu.Lock()
 		if results[idx] == nil {
-			results[idx] = e.createErrorResult(h.Address, command, duration, err, "panic")
+			results[idx] = e.createErrorResult(h.DisplayName(), command, duration, err, "panic")
 		}
+		result := results[idx]
 		mu.Unlock()
 
 		if progressTracker != nil {
-			progressTracker.MarkTrackerErrored(h.Address, fmt.Sprintf("panic: %v", r))
+			progressTracker.MarkTrackerErrored(h.DisplayName(), fmt.Sprintf("panic: %v", r))
 		}
+		e.runHostHook(result)
 	}
 }
 
@@ -238,13 +835,15 @@ func (e *Executor) handleConnectionError(
 	progressTracker ProgressTracker,
 ) {
 	duration := time.Since(startTime)
+	result := e.createErrorResult(h.DisplayName(), command, duration, err, "连接失败")
 	mu.Lock()
-	results[idx] = e.createErrorResult(h.Address, command, duration, err, "连接失败")
+	results[idx] = result
 	mu.Unlock()
 
 	if progressTracker != nil {
-		progressTracker.MarkTrackerErrored(h.Address, fmt.Sprintf("连接失败: %v", err))
+		progressTracker.MarkTrackerErrored(h.DisplayName(), fmt.Sprintf("连接失败: %v", err))
 	}
+	e.runHostHook(result)
 }
 
 // handleTaskError 处理任务执行错误
@@ -261,13 +860,15 @@ func (e *Executor) handleTaskError(
 	duration := time.Since(startTime)
 	mu.Lock()
 	if results[idx] == nil {
-		results[idx] = e.createErrorResult(h.Address, command, duration, err, "执行失败")
+		results[idx] = e.createErrorResult(h.DisplayName(), command, duration, err, "执行失败")
 	}
+	result := results[idx]
 	mu.Unlock()
 
 	if progressTracker != nil {
-		progressTracker.MarkTrackerErrored(h.Address, fmt.Sprintf("执行失败: %v", err))
+		progressTracker.MarkTrackerErrored(h.DisplayName(), fmt.Sprintf("执行失败: %v", err))
 	}
+	e.runHostHook(result)
 }
 
 // handleTaskSuccess 处理任务成功
@@ -290,6 +891,7 @@ func (e *Executor) handleTaskSuccess(
 			progressTracker.MarkTrackerErrored(result.Host, fmt.Sprintf("失败(退出码:%d)", result.ExitCode))
 		}
 	}
+	e.runHostHook(result)
 }
 
 // createErrorResult 创建错误结果对象
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ResolveError 预解析时无法解析的主机
+type ResolveError struct {
+	Address string
+	Err     error
+}
+
+// PreResolve 并发解析所有主机名（IP 地址跳过），每个主机名只解析一次
+// 解析结果缓存在执行器中，之后该主机的所有连接（如 script 的上传、执行、清理）直接使用缓存的 IP；
+// 无法解析的主机在执行时直接记为连接失败，不再尝试连接。返回无法解析的主机（按地址排序）
+// 设置了 ProxyCommand 时主机名由代理解析（本地通常无法解析），不做预解析
+func (e *Executor) PreResolve(concurrency int) []ResolveError {
+	if e.proxyCommand != "" {
+		slog.Debug("已设置 ProxyCommand，跳过预解析")
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, h := range e.hosts {
+		if seen[h.Address] || net.ParseIP(h.Address) != nil {
+			continue
+		}
+		seen[h.Address] = true
+		names = append(names, h.Address)
+	}
+
+	e.resolved = make(map[string]string, len(names))
+	e.resolveErrors = make(map[string]error)
+	if len(names) == 0 {
+		return nil
+	}
+
+	startTime := time.Now()
+	semaphore := make(chan struct{}, normalizeConcurrency(concurrency, len(names)))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			ip, err := resolveHost(name, e.connectTimeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				e.resolveErrors[name] = err
+				return
+			}
+			e.resolved[name] = ip
+		}(name)
+	}
+	wg.Wait()
+	slog.Debug("主机名预解析完成", "hosts", len(names), "failed", len(e.resolveErrors), "duration", time.Since(startTime))
+
+	failures := make([]ResolveError, 0, len(e.resolveErrors))
+	for name, err := range e.resolveErrors {
+		failures = append(failures, ResolveError{Address: name, Err: err})
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Address < failures[j].Address })
+	return failures
+}
+
+// resolveHost 解析主机名，优先返回 IPv4 地址
+func resolveHost(name string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("没有解析到地址")
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP.String(), nil
+		}
+	}
+	return addrs[0].IP.String(), nil
+}
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"gossh/internal/ssh"
+)
+
+// maxRetryDelay 单次重试的最长等待时间（指数增长的上限）
+const maxRetryDelay = 30 * time.Second
+
+// RetryPolicy 连接失败时的重试策略
+// 第 n 次重试前等待 Backoff×2^(n-1)（不超过 maxRetryDelay），再加上随机抖动，
+// 避免大量主机在同一时刻重连，压垮刚恢复的服务端（如堡垒机）
+// ExitCodes 非空时，命令以其中的退出码结束也会重试（例如 75 EX_TEMPFAIL），其他非零退出码仍然不重试
+type RetryPolicy struct {
+	Retries   int           // 最大重试次数，0 表示不重试
+	Backoff   time.Duration // 第一次重试前的基础等待时间
+	Jitter    float64       // 抖动系数（0-1），实际等待时间在 基础时间×(1±Jitter) 之间随机
+	ExitCodes []int         // 需要重试的退出码（命令会重新执行，只应用于可以重复执行的命令）
+}
+
+// Delay 返回第 attempt 次重试（从 1 开始）前的等待时间
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if p.Backoff <= 0 || attempt <= 0 {
+		return 0
+	}
+
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+
+	if p.Jitter > 0 {
+		// 在 [1-Jitter, 1+Jitter) 范围内随机缩放
+		factor := 1 - p.Jitter + rand.Float64()*2*p.Jitter
+		delay = time.Duration(float64(delay) * factor)
+	}
+	return delay
+}
+
+// isRetryableError 判断错误是否可以重试
+// 只重试连接失败和连接超时：此时命令尚未开始执行，重试不会导致重复执行；
+// 认证失败重试也不会成功，执行失败和操作超时可能已经执行了部分命令
+func isRetryableError(err error) bool {
+	var connErr *ssh.ConnectionError
+	var timeoutErr *ssh.TimeoutError
+	var authErr *ssh.AuthError
+	if errors.As(err, &authErr) {
+		return false
+	}
+	if errors.As(err, &connErr) {
+		return !ssh.IsClientClosed(err)
+	}
+	return errors.As(err, &timeoutErr)
+}
+
+// isRetryableExitCode 判断命令的结果是否因为退出码需要重试（跳过的结果不重试）
+func (p RetryPolicy) isRetryableExitCode(result *ssh.Result) bool {
+	if result == nil || result.Skipped || result.ExitCode == 0 {
+		return false
+	}
+	return slices.Contains(p.ExitCodes, result.ExitCode)
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gossh/internal/ssh"
+
+	_ "modernc.org/sqlite" // 纯 Go 实现的 SQLite 驱动，不需要 cgo
+)
+
+// schema 数据库表结构，打开数据库时执行（已存在时不会重复创建）
+//   - runs: 每次执行一行（执行时间、子命令、执行的命令、分组、耗时和汇总）
+//   - host_results: 每台主机一行，通过 run_id 关联 runs
+const schema = `CREATE TABLE IF NOT EXISTS runs (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at    TEXT    NOT NULL,
+	command       TEXT    NOT NULL,
+	target        TEXT    NOT NULL,
+	host_group    TEXT    NOT NULL,
+	duration_ms   INTEGER NOT NULL,
+	total         INTEGER NOT NULL,
+	success       INTEGER NOT NULL,
+	failed        INTEGER NOT NULL,
+	skipped       INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS host_results (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	started_at  TEXT    NOT NULL,
+	command     TEXT    NOT NULL,
+	host        TEXT    NOT NULL,
+	status      TEXT    NOT NULL,
+	exit_code   INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	error       TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_host_results_host ON host_results(host);
+CREATE INDEX IF NOT EXISTS idx_host_results_run_id ON host_results(run_id);
+`
+
+// busyTimeout 数据库被其他进程（如同时结束的另一个 gossh）锁定时等待的时间
+const busyTimeout = 5 * time.Second
+
+// Record 一次执行的记录
+type Record struct {
+	Command   string // gossh 子命令（run、script、upload）
+	Target    string // 执行的命令、脚本路径或上传的本地路径
+	Group     string
+	StartedAt time.Time
+	Duration  time.Duration
+	Results   []*ssh.Result
+}
+
+// Store 执行记录数据库
+type Store struct {
+	db   *sql.DB
+	path string
+}
+
+// Open 打开 SQLite 数据库，文件不存在时自动创建，并创建 runs 和 host_results 表
+func Open(dbPath string) (*Store, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库 %s 失败: %w", dbPath, err)
+	}
+	// busy_timeout 是连接级别的设置，只使用一个连接保证所有语句都生效
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d", busyTimeout.Milliseconds())); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("打开数据库 %s 失败: %w", dbPath, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建数据库 %s 的表失败: %w", dbPath, err)
+	}
+	return &Store{db: db, path: dbPath}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Append 将一次执行的结果追加到数据库
+// 所有插入在一个事务中完成，写入失败时数据库中不会留下不完整的记录
+func (s *Store) Append(record Record) error {
+	if err := s.append(record); err != nil {
+		return fmt.Errorf("写入执行记录到 %s 失败: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *Store) append(record Record) error {
+	// 统一使用 UTC，便于直接用 SQLite 的 date('now', ...) 按字符串比较时间
+	startedAt := record.StartedAt.UTC().Format(time.RFC3339)
+
+	type hostRow struct {
+		result *ssh.Result
+		status string
+	}
+	rows := make([]hostRow, 0, len(record.Results))
+	total, success, failed, skipped := 0, 0, 0, 0
+	for _, result := range record.Results {
+		if result == nil {
+			continue
+		}
+		total++
+
+		status := "failed"
+		switch {
+		case result.Skipped:
+			status = "skipped"
+			skipped++
+		case result.Error == nil && result.ExitCode == 0:
+			status = "success"
+			success++
+		default:
+			failed++
+		}
+		rows = append(rows, hostRow{result: result, status: status})
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO runs (started_at, command, target, host_group, duration_ms, total, success, failed, skipped) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		startedAt, record.Command, record.Target, record.Group,
+		record.Duration.Milliseconds(), total, success, failed, skipped)
+	if err != nil {
+		return err
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO host_results (run_id, started_at, command, host, status, exit_code, duration_ms, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		errText := ""
+		if row.result.Error != nil {
+			errText = row.result.Error.Error()
+		}
+		if _, err := stmt.Exec(runID, startedAt, record.Command, row.result.Host, row.status,
+			row.result.ExitCode, row.result.Duration.Milliseconds(), errText); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Append 打开 dbPath 指定的数据库，追加一次执行的结果后关闭
+func Append(dbPath string, record Record) error {
+	store, err := Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Append(record)
+}
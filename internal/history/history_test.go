@@ -0,0 +1,115 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gossh/internal/ssh"
+)
+
+func TestAppend(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	startedAt := time.Date(2026, 10, 16, 8, 30, 0, 0, time.FixedZone("CST", 8*3600))
+
+	first := Record{
+		Command:   "run",
+		Target:    "echo 'it''s'; DROP TABLE runs; --",
+		Group:     "web",
+		StartedAt: startedAt,
+		Duration:  1500 * time.Millisecond,
+		Results: []*ssh.Result{
+			{Host: "10.0.0.1", ExitCode: 0, Duration: 200 * time.Millisecond},
+			{Host: "10.0.0.2", ExitCode: 3, Duration: 300 * time.Millisecond},
+			{Host: "10.0.0.3", ExitCode: -1, Error: errors.New("连接失败: it's down")},
+			{Host: "10.0.0.4", Skipped: true},
+			nil,
+		},
+	}
+	if err := Append(dbPath, first); err != nil {
+		t.Fatalf("Append() 失败: %v", err)
+	}
+	second := Record{Command: "script", Target: "deploy.sh", StartedAt: startedAt, Results: []*ssh.Result{{Host: "10.0.0.1"}}}
+	if err := Append(dbPath, second); err != nil {
+		t.Fatalf("第二次 Append() 失败: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var (
+		id                              int64
+		started, command, target, group string
+		durationMS                      int64
+		total, success, failed, skipped int
+	)
+	err = db.QueryRow("SELECT id, started_at, command, target, host_group, duration_ms, total, success, failed, skipped FROM runs ORDER BY id LIMIT 1").
+		Scan(&id, &started, &command, &target, &group, &durationMS, &total, &success, &failed, &skipped)
+	if err != nil {
+		t.Fatalf("查询 runs 失败: %v", err)
+	}
+	if started != "2026-10-16T00:30:00Z" {
+		t.Errorf("started_at = %q，期望 UTC 时间 2026-10-16T00:30:00Z", started)
+	}
+	if command != first.Command || target != first.Target || group != first.Group || durationMS != 1500 {
+		t.Errorf("runs 记录 = (%q, %q, %q, %d)，期望 (%q, %q, %q, 1500)", command, target, group, durationMS, first.Command, first.Target, first.Group)
+	}
+	if total != 4 || success != 1 || failed != 2 || skipped != 1 {
+		t.Errorf("汇总 = (%d, %d, %d, %d)，期望 (4, 1, 2, 1)", total, success, failed, skipped)
+	}
+
+	rows, err := db.Query("SELECT host, status, exit_code, error FROM host_results WHERE run_id = ? ORDER BY host", id)
+	if err != nil {
+		t.Fatalf("查询 host_results 失败: %v", err)
+	}
+	defer rows.Close()
+	type hostResult struct {
+		host, status string
+		exitCode     int
+		errText      string
+	}
+	var got []hostResult
+	for rows.Next() {
+		var r hostResult
+		if err := rows.Scan(&r.host, &r.status, &r.exitCode, &r.errText); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	want := []hostResult{
+		{"10.0.0.1", "success", 0, ""},
+		{"10.0.0.2", "failed", 3, ""},
+		{"10.0.0.3", "failed", -1, "连接失败: it's down"},
+		{"10.0.0.4", "skipped", 0, ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("host_results 有 %d 行，期望 %d 行: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("host_results[%d] = %v，期望 %v", i, got[i], want[i])
+		}
+	}
+
+	var runs, hostRows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM host_results").Scan(&hostRows); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 || hostRows != 5 {
+		t.Errorf("runs 有 %d 行、host_results 有 %d 行，期望 2 和 5", runs, hostRows)
+	}
+}
+
+func TestOpenInvalidPath(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing", "history.db")); err == nil {
+		t.Error("目录不存在时 Open() 应返回错误")
+	}
+}
@@ -1,16 +1,52 @@
 package logger
 
 import (
+	"encoding/base64"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // Logger 日志记录器
+// 可以在多个协程中并发使用：slog 的 JSON handler 在每条日志写入时加锁，
+// 日志文件的写入和关闭也通过 syncFile 串行化
 type Logger struct {
 	logger *slog.Logger
+	file   *syncFile
+
+	completed atomic.Int64 // LogHostCompleted 已记录的主机数，用于记录完成顺序
+}
+
+// syncFile 串行化日志文件的写入和关闭，关闭之后的写入直接返回错误，
+// 避免执行协程在命令结束、文件关闭后仍然写入
+type syncFile struct {
+	mu     sync.Mutex
 	file   *os.File
+	closed bool
+}
+
+func (f *syncFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.file.Write(p)
+}
+
+// Close 关闭日志文件，重复关闭时什么也不做
+func (f *syncFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.file.Close()
 }
 
 // NewLogger 创建新的日志记录器
@@ -38,7 +74,8 @@ func NewLogger(logDir string, command string) (*Logger, error) {
 	}
 
 	// 创建 JSON handler（结构化日志）
-	handler := slog.NewJSONHandler(file, &slog.HandlerOptions{
+	syncedFile := &syncFile{file: file}
+	handler := slog.NewJSONHandler(syncedFile, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
 
@@ -46,7 +83,7 @@ func NewLogger(logDir string, command string) (*Logger, error) {
 
 	return &Logger{
 		logger: logger,
-		file:   file,
+		file:   syncedFile,
 	}, nil
 }
 
@@ -122,7 +159,25 @@ func (l *Logger) LogHostResult(host string, command string, exitCode int, durati
 	if !l.IsEnabled() {
 		return
 	}
+	l.logHostResult(nil, host, command, exitCode, duration, success, stdout, stderr, err)
+}
 
+// LogHostCompleted 在主机执行完成时立即记录结果（实时日志），可以从多个执行协程中并发调用，
+// 例如在 executor 的主机结果钩子中调用。记录格式与 LogHostResult 相同，
+// 额外记录完成顺序 completed_seq（从 1 开始）和完成时间 completed_at
+func (l *Logger) LogHostCompleted(host string, command string, exitCode int, duration time.Duration, success bool, stdout string, stderr string, err error) {
+	if !l.IsEnabled() {
+		return
+	}
+	extra := []any{
+		"completed_seq", l.completed.Add(1),
+		"completed_at", time.Now().Format(time.RFC3339Nano),
+	}
+	l.logHostResult(extra, host, command, exitCode, duration, success, stdout, stderr, err)
+}
+
+// logHostResult 记录单个主机的执行结果，extra 追加在基本字段之后
+func (l *Logger) logHostResult(extra []any, host string, command string, exitCode int, duration time.Duration, success bool, stdout string, stderr string, err error) {
 	kv := []any{
 		"event", "host_result",
 		"host", host,
@@ -131,14 +186,10 @@ func (l *Logger) LogHostResult(host string, command string, exitCode int, durati
 		"duration", duration,
 		"success", success,
 	}
+	kv = append(kv, extra...)
 
-	if stdout != "" {
-		kv = append(kv, "stdout", stdout)
-	}
-
-	if stderr != "" {
-		kv = append(kv, "stderr", stderr)
-	}
+	kv = appendOutput(kv, "stdout", stdout)
+	kv = appendOutput(kv, "stderr", stderr)
 
 	if err != nil {
 		kv = append(kv, "error", err.Error())
@@ -151,6 +202,18 @@ func (l *Logger) LogHostResult(host string, command string, exitCode int, durati
 	}
 }
 
+// appendOutput 追加命令输出到日志字段，输出为空时不追加
+// 非 UTF-8 的输出（二进制内容）在 JSON 中会被替换成乱码，改为 base64 编码并追加 <key>_encoding=base64
+func appendOutput(kv []any, key, output string) []any {
+	if output == "" {
+		return kv
+	}
+	if !utf8.ValidString(output) {
+		return append(kv, key, base64.StdEncoding.EncodeToString([]byte(output)), key+"_encoding", "base64")
+	}
+	return append(kv, key, output)
+}
+
 // LogInfo 记录一般信息
 func (l *Logger) LogInfo(msg string, args ...any) {
 	if !l.IsEnabled() {
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// LevelTrace -vv 时才输出的日志级别，用于每台主机的连接生命周期
+const LevelTrace = slog.LevelDebug - 4
+
+// SetupVerbose 根据 -v 出现的次数设置默认 slog 的输出（写到 stderr，不影响结果输出）
+// 0: 不输出；1（-v）: Debug 级别（配置文件、主机匹配、认证方式、调度）；2 及以上（-vv）: 额外输出每台主机的连接过程
+func SetupVerbose(verbosity int) {
+	if verbosity <= 0 {
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		return
+	}
+
+	level := slog.LevelDebug
+	if verbosity >= 2 {
+		level = LevelTrace
+	}
+
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// slog 默认把 LevelTrace 显示为 DEBUG-4
+			if a.Key == slog.LevelKey && a.Value.Any() == LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	})
+	slog.SetDefault(slog.New(handler))
+}
+
+// Trace 输出 -vv 级别的日志
+func Trace(msg string, args ...any) {
+	slog.Log(context.Background(), LevelTrace, msg, args...)
+}
@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// IdentityAgent 通过 ssh-agent 获取私钥签名，可以被多个客户端并发使用（agent 客户端内部串行处理请求）
+type IdentityAgent struct {
+	conn   net.Conn
+	client agent.ExtendedAgent
+}
+
+// NewIdentityAgent 连接 ssh-agent 的 Unix socket
+// socket 为 "SSH_AUTH_SOCK" 或以 $ 开头（如 $MY_AGENT_SOCK）时从对应的环境变量读取路径，支持 ~ 开头的路径
+func NewIdentityAgent(socket string) (*IdentityAgent, error) {
+	path := socket
+	if path == "SSH_AUTH_SOCK" || strings.HasPrefix(path, "$") {
+		name := strings.TrimPrefix(path, "$")
+		path = os.Getenv(name)
+		if path == "" {
+			return nil, fmt.Errorf("环境变量 %s 未设置，无法连接 ssh-agent", name)
+		}
+	}
+	path = ExpandPath(path)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("连接 ssh-agent 失败: %w", err)
+	}
+	slog.Debug("连接 ssh-agent", "socket", path)
+	return &IdentityAgent{conn: conn, client: agent.NewClient(conn)}, nil
+}
+
+// Signers 返回 ssh-agent 中所有私钥的签名器
+func (a *IdentityAgent) Signers() ([]ssh.Signer, error) {
+	signers, err := a.client.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("从 ssh-agent 读取私钥失败: %w", err)
+	}
+	return signers, nil
+}
+
+// Close 关闭与 ssh-agent 的连接
+func (a *IdentityAgent) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.conn.Close()
+}
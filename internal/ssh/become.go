@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrBecomeNotEffective become 没有生效（sudo 执行失败，或切换后的 UID 与目标用户不一致）
+var ErrBecomeNotEffective = errors.New("become 未生效")
+
+// ErrSudoPasswordRequired 没有提供 --become-password，而主机的 sudo 需要密码（sudo -n 立即失败）
+var ErrSudoPasswordRequired = errors.New("sudo 需要密码")
+
+// sudoPasswordPrompts sudo 因为需要密码而失败时在标准错误第一行输出的信息
+// （-n 时为 a password is required，没有 -n 且没有终端时为 a terminal is required to read the password）
+var sudoPasswordPrompts = []string{"a password is required", "a terminal is required to read the password"}
+
+// errSudoPasswordRequired 返回 sudo 需要密码的错误，附带处理方法
+func errSudoPasswordRequired() error {
+	return fmt.Errorf("%w: 以 sudo -n 执行时主机要求输入密码，请在 sudoers 中配置 NOPASSWD 或指定 --become-password", ErrSudoPasswordRequired)
+}
+
+// isSudoPasswordRequired 判断 become 命令的结果是否表示 sudo 需要密码：
+// sudo 认证失败时退出码为 1，标准错误的第一行以 sudo 开头（命令本身没有开始执行）
+func isSudoPasswordRequired(result *Result) bool {
+	if result.ExitCode != 1 {
+		return false
+	}
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(result.Stderr), "\n")
+	if !strings.HasPrefix(firstLine, "sudo") {
+		return false
+	}
+	for _, prompt := range sudoPasswordPrompts {
+		if strings.Contains(firstLine, prompt) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyBecome 在 become 下执行 id -u，确认实际 UID 与目标用户的 UID 一致
+// sudoers 配置错误时 sudo 可能失败或切换到其他用户，命令的结果看起来正常但并没有以目标用户执行；
+// 验证失败时返回 ErrBecomeNotEffective，调用方不再执行命令
+func (c *Client) verifyBecome(conn *ssh.Client, opts ExecOptions) error {
+	targetUser := opts.BecomeUser
+	if targetUser == "" {
+		targetUser = "root"
+	}
+
+	expected, err := c.expectedBecomeUID(conn, opts.BecomeUser)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.executeOnConn(conn, "id -u", ExecOptions{
+		Become:     true,
+		BecomeUser: opts.BecomeUser,
+		SudoFlags:  opts.SudoFlags,
+
+		BecomePassword: opts.BecomePassword,
+		BecomePrompt:   opts.BecomePrompt,
+	})
+	if err != nil {
+		return err
+	}
+	if errors.Is(result.Error, ErrSudoPasswordRequired) {
+		return result.Error
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%w: sudo 执行 id -u 失败（退出码 %d）: %s", ErrBecomeNotEffective, result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	actual := strings.TrimSpace(result.Stdout)
+	if actual != expected {
+		return fmt.Errorf("%w: 目标用户 %s 的 UID 为 %s，实际 UID 为 %s", ErrBecomeNotEffective, targetUser, expected, actual)
+	}
+	return nil
+}
+
+// checkBecome 检查 sudo 是否可用（ping --check-become）：与 verifyBecome 一样以 sudo 执行 id -u 并确认 UID，
+// 没有 sudo 密码时以 sudo -n 执行（见 buildCommand），需要密码的主机立即失败而不是等待输入
+func (c *Client) checkBecome(conn *ssh.Client, opts ExecOptions) error {
+	opts.Become = true
+	return c.verifyBecome(conn, opts)
+}
+
+// expectedBecomeUID 返回 become 目标用户的 UID：root 为 0，数字（或 #UID）直接使用，
+// 用户名在远程主机上（不使用 sudo）通过 id -u 查询
+func (c *Client) expectedBecomeUID(conn *ssh.Client, becomeUser string) (string, error) {
+	if isRootBecomeUser(becomeUser) {
+		return "0", nil
+	}
+	if uid := strings.TrimPrefix(becomeUser, "#"); isNumericUID(uid) {
+		return uid, nil
+	}
+
+	result, err := c.executeOnConn(conn, fmt.Sprintf("id -u %s", shellQuote(becomeUser)), ExecOptions{})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("%w: 远程主机上不存在用户 %s", ErrBecomeNotEffective, becomeUser)
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
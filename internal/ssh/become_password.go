@@ -0,0 +1,190 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gossh/internal/logger"
+)
+
+// DefaultBecomePrompt 默认的 sudo 密码提示符匹配规则（不区分大小写匹配 password）
+const DefaultBecomePrompt = `(?i)password`
+
+// CompileBecomePrompt 编译 sudo 密码提示符的正则表达式，为空时使用 DefaultBecomePrompt
+func CompileBecomePrompt(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = DefaultBecomePrompt
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 --become-prompt %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// needsBecomeAuth become 且提供了 sudo 密码时需要处理密码交互
+func (opts ExecOptions) needsBecomeAuth() bool {
+	return opts.Become && opts.BecomePassword != ""
+}
+
+// becomeSuccessMarker sudo 认证通过后命令输出的第一行，用于判断认证已经完成（每个进程随机生成）
+var becomeSuccessMarker = newBecomeSuccessMarker()
+
+func newBecomeSuccessMarker() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "GOSSH-BECOME-SUCCESS-" + hex.EncodeToString(b)
+}
+
+// becomeAuth 处理 sudo -S 的密码交互：
+// 认证完成前在标准错误中检测密码提示符，检测到后才写入密码；sudo 配置为 NOPASSWD（或凭据已缓存）时不会出现提示符，
+// 命令输出成功标记后即认为认证完成，不发送密码。认证完成后再写入 ExecOptions.Stdin（如果有）并关闭标准输入，
+// 之后标准错误中出现的 password 等字样属于命令自身的输出，不再匹配
+type becomeAuth struct {
+	password string
+	prompt   *regexp.Regexp
+	stdin    io.WriteCloser
+	data     []byte // 认证完成后写入标准输入的内容，nil 表示不提供
+
+	mu            sync.Mutex
+	stdout        bytes.Buffer
+	stderr        bytes.Buffer
+	line          []byte   // 认证完成前标准错误中尚未换行的内容（sudo 的提示符不以换行结尾）
+	prompts       []string // 匹配到的提示符，从结果的标准错误中去掉
+	sent          bool     // 已发送密码
+	rejected      bool     // 发送密码后再次出现提示符（密码错误）
+	authenticated bool
+
+	success   chan struct{} // 看到成功标记时关闭
+	closeOnce sync.Once
+}
+
+// newBecomeAuth 为会话准备 sudo 密码交互，必须在 session.Start 之前调用
+func newBecomeAuth(stdin io.WriteCloser, opts ExecOptions) *becomeAuth {
+	prompt := opts.BecomePrompt
+	if prompt == nil {
+		prompt = regexp.MustCompile(DefaultBecomePrompt)
+	}
+	return &becomeAuth{
+		password: opts.BecomePassword,
+		prompt:   prompt,
+		stdin:    stdin,
+		data:     opts.Stdin,
+		success:  make(chan struct{}),
+	}
+}
+
+// prepare 返回实际执行的命令和选项：sudo 增加 -S（从标准输入读取密码），命令先输出成功标记
+func (a *becomeAuth) prepare(command string, opts ExecOptions) (string, ExecOptions) {
+	opts.SudoFlags = strings.TrimSpace("-S " + opts.SudoFlags)
+	return fmt.Sprintf("echo %s; %s", becomeSuccessMarker, command), opts
+}
+
+// run 同时读取标准输出和标准错误（sudo 等待密码时标准输出不会结束），返回去掉成功标记和提示符后的输出
+func (a *becomeAuth) run(host string, stdout, stderr io.Reader) ([]byte, []byte) {
+	finished := make(chan struct{})
+	go a.writeStdin(host, finished)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(writerFunc(a.writeStderr), stderr)
+	}()
+	_, _ = io.Copy(writerFunc(a.writeStdout), stdout)
+	wg.Wait()
+	close(finished)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	output := bytes.Replace(a.stdout.Bytes(), []byte(becomeSuccessMarker+"\n"), nil, 1)
+	errOutput := a.stderr.String()
+	for _, p := range a.prompts {
+		errOutput = strings.Replace(errOutput, p, "", 1)
+	}
+	return output, []byte(errOutput)
+}
+
+// writeStdin 认证完成后写入标准输入的内容并关闭标准输入；命令没有输出成功标记就结束时直接关闭
+func (a *becomeAuth) writeStdin(host string, finished <-chan struct{}) {
+	select {
+	case <-a.success:
+		if a.data != nil {
+			if _, err := a.stdin.Write(a.data); err != nil {
+				logger.Trace("写入标准输入失败", "host", host, "error", err)
+			}
+		}
+	case <-finished:
+	}
+	a.closeStdin()
+}
+
+// writeStdout 记录标准输出，出现成功标记时认为 sudo 认证已完成
+func (a *becomeAuth) writeStdout(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stdout.Write(p)
+	if !a.authenticated && bytes.Contains(a.stdout.Bytes(), []byte(becomeSuccessMarker+"\n")) {
+		a.authenticated = true
+		close(a.success)
+	}
+	return len(p), nil
+}
+
+// writeStderr 记录标准错误，认证完成前检测密码提示符：第一次出现时发送密码，
+// 再次出现说明密码错误，关闭标准输入让 sudo 结束，而不是一直等待
+func (a *becomeAuth) writeStderr(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stderr.Write(p)
+	if a.authenticated || a.rejected {
+		return len(p), nil
+	}
+
+	a.line = append(a.line, p...)
+	if i := bytes.LastIndexByte(a.line, '\n'); i >= 0 {
+		a.line = a.line[i+1:]
+	}
+	if !a.prompt.Match(a.line) {
+		return len(p), nil
+	}
+
+	a.prompts = append(a.prompts, string(a.line))
+	a.line = nil
+	if a.sent {
+		a.rejected = true
+		a.closeStdin()
+		return len(p), nil
+	}
+	a.sent = true
+	if _, err := io.WriteString(a.stdin, a.password+"\n"); err != nil {
+		a.closeStdin()
+	}
+	return len(p), nil
+}
+
+// closeStdin 关闭标准输入（只关闭一次）
+func (a *becomeAuth) closeStdin() {
+	a.closeOnce.Do(func() { a.stdin.Close() })
+}
+
+// warning 返回密码交互中需要提示的问题，没有时为空
+func (a *becomeAuth) warning() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.rejected {
+		return "sudo 再次提示输入密码，--become-password 可能不正确"
+	}
+	return ""
+}
+
+// writerFunc 将函数适配为 io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrRemotePermissionDenied 没有权限读取远程文件
+var ErrRemotePermissionDenied = errors.New("无权限读取远程文件")
+
+// checksumCommands 支持的校验和算法及其对应的远程命令
+var checksumCommands = map[string]string{
+	"sha256": "sha256sum",
+	"md5":    "md5sum",
+}
+
+// 远程检查脚本的退出码，避开 sha256sum/md5sum 自身使用的 0 和 1
+const (
+	checksumExitNotFound  = 100 // 远程文件不存在
+	checksumExitIsDir     = 101 // 远程路径是目录
+	checksumExitForbidden = 102 // 没有读取权限
+)
+
+// ValidateChecksumAlgorithm 校验校验和算法（sha256 或 md5）
+func ValidateChecksumAlgorithm(algorithm string) error {
+	if _, ok := checksumCommands[algorithm]; !ok {
+		return fmt.Errorf("不支持的校验和算法 %q，可选值: sha256、md5", algorithm)
+	}
+	return nil
+}
+
+// ChecksumFile 计算远程文件的校验和
+// 返回结果中 Stdout 为十六进制摘要；远程文件不存在时 Error 为 ErrRemoteFileNotFound，
+// 没有读取权限时 Error 为 ErrRemotePermissionDenied
+func (c *Client) ChecksumFile(remotePath string, algorithm string) (*Result, error) {
+	startTime := time.Now()
+	sumCommand, ok := checksumCommands[algorithm]
+	if !ok {
+		err := ValidateChecksumAlgorithm(algorithm)
+		return c.createErrorResult(algorithm, startTime, err, "计算校验和失败"), err
+	}
+	command := fmt.Sprintf("%s %s", sumCommand, remotePath)
+
+	conn, err := c.createSSHConnection()
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "连接失败"), err
+	}
+	defer c.releaseConnection(conn)
+
+	stdout, stderr, exitCode, err := c.runRemoteChecksum(conn, sumCommand, remotePath)
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "计算校验和失败"), err
+	}
+
+	result := &Result{
+		Host:     c.host,
+		Command:  command,
+		Stderr:   stderr,
+		ExitCode: exitCode,
+		Duration: time.Since(startTime),
+	}
+
+	switch {
+	case exitCode == checksumExitNotFound:
+		result.Stderr = fmt.Sprintf("远程文件不存在: %s", remotePath)
+		result.Error = ErrRemoteFileNotFound
+	case exitCode == checksumExitForbidden,
+		exitCode != 0 && strings.Contains(stderr, "Permission denied"):
+		result.Stderr = fmt.Sprintf("无权限读取远程文件: %s", remotePath)
+		result.Error = ErrRemotePermissionDenied
+	case exitCode == checksumExitIsDir:
+		result.Error = fmt.Errorf("远程路径是目录: %s", remotePath)
+	case exitCode != 0:
+		result.Error = fmt.Errorf("%s 执行失败（退出码: %d）", sumCommand, exitCode)
+	default:
+		// 从标准输入读取时输出格式为: <digest>  -（避免文件名中的特殊字符影响输出）
+		fields := strings.Fields(stdout)
+		if len(fields) == 0 {
+			result.ExitCode = -1
+			result.Error = fmt.Errorf("%s 没有输出校验和", sumCommand)
+			break
+		}
+		result.Stdout = fields[0]
+	}
+
+	return result, nil
+}
+
+// runRemoteChecksum 在远程主机检查文件状态并计算校验和
+// 文件不存在、是目录或不可读时以约定的退出码结束，便于区分错误类型
+func (c *Client) runRemoteChecksum(conn *ssh.Client, sumCommand, remotePath string) (string, string, int, error) {
+	session, err := c.createSession(conn)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	command := fmt.Sprintf(
+		`p=%s; [ -e "$p" ] || exit %d; [ -d "$p" ] && exit %d; [ -r "$p" ] || exit %d; %s < "$p"`,
+		shellQuote(remotePath), checksumExitNotFound, checksumExitIsDir, checksumExitForbidden, sumCommand,
+	)
+	if err := session.Run(command); err != nil {
+		if exitError, ok := err.(*ssh.ExitError); ok {
+			return stdout.String(), stderr.String(), exitError.ExitStatus(), nil
+		}
+		return "", "", 0, fmt.Errorf("执行 %s 失败: %w", sumCommand, err)
+	}
+
+	return stdout.String(), stderr.String(), 0, nil
+}
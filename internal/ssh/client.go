@@ -2,12 +2,20 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"gossh/internal/logger"
+
 	"github.com/bramvdbogaerde/go-scp"
 	"golang.org/x/crypto/ssh"
 )
@@ -17,40 +25,103 @@ type Client struct {
 	config  *ssh.ClientConfig
 	host    string
 	port    string
-	timeout time.Duration // 连接超时时间
+	timeout time.Duration // 连接超时时间（TCP 连接和 SSH 握手）
+
+	mu     sync.Mutex
+	conns  []*ssh.Client // 已建立的连接，Close 时统一关闭
+	closed bool
+
+	dialHost string // 预先解析得到的 IP（可选），建立 TCP 连接时代替 host，避免每次连接重复解析
+
+	proxyCommand string // ProxyCommand（已替换占位符，可选），设置后以该命令的标准输入输出代替 TCP 连接
+
+	pool *ConnectionPool // 连接池（可选），设置后优先复用空闲连接，使用完的连接放回连接池
+}
+
+// errClientClosed 客户端已被 Close（例如超过操作超时）后不再建立新连接
+var errClientClosed = errors.New("客户端已关闭")
+
+// IsClientClosed 判断错误是否因客户端已被 Close 而无法建立连接
+func IsClientClosed(err error) bool {
+	return errors.Is(err, errClientClosed)
 }
 
 // NewClient 创建新的 SSH 客户端
-func NewClient(host, port, user, keyPath, password string) (*Client, error) {
-	return NewClientWithTimeout(host, port, user, keyPath, password, 10*time.Second)
+func NewClient(host, port, user, keyPath, password string, totp *TOTPProvider) (*Client, error) {
+	return NewClientWithTimeout(host, port, user, keyPath, password, 10*time.Second, totp)
+}
+
+// NewClientWithTimeout 创建新的 SSH 客户端，支持自定义连接超时时间（TCP 连接和 SSH 握手）
+// totp 不为 nil 时会追加 keyboard-interactive 认证，用于回答堡垒机的二次验证码提示
+func NewClientWithTimeout(host, port, user, keyPath, password string, timeout time.Duration, totp *TOTPProvider) (*Client, error) {
+	return NewClientWithAgent(host, port, user, keyPath, password, timeout, totp, nil)
+}
+
+// NewClientWithAgent 创建新的 SSH 客户端，identityAgent 不为 nil 时同时使用 ssh-agent 中的私钥认证
+// 指定了 keyPath 时先尝试 keyPath 中的私钥，再尝试 ssh-agent 中的私钥；只有 ssh-agent 时，ssh-agent 认证失败后再尝试密码
+func NewClientWithAgent(host, port, user, keyPath, password string, timeout time.Duration, totp *TOTPProvider, identityAgent *IdentityAgent) (*Client, error) {
+	return NewClientWithOptions(host, port, user, keyPath, password, timeout, totp, ClientOptions{IdentityAgent: identityAgent})
+}
+
+// ClientOptions 创建 SSH 客户端的认证选项
+type ClientOptions struct {
+	IdentityAgent *IdentityAgent // ssh-agent（可选），不为 nil 时同时使用 ssh-agent 中的私钥认证
+	NoDefaultKey  bool           // 未指定私钥、密码和 ssh-agent 时不尝试 ~/.ssh 下的默认私钥，直接返回错误
 }
 
-// NewClientWithTimeout 创建新的 SSH 客户端，支持自定义超时时间
-func NewClientWithTimeout(host, port, user, keyPath, password string, timeout time.Duration) (*Client, error) {
+// defaultKeyPaths 未指定任何认证方式时尝试的默认私钥，优先尝试较新的密钥类型
+// 只有 ed25519 私钥的环境无需指定 -k；服务端限制了认证尝试次数（MaxAuthTries）时也能更早命中常用的私钥
+var defaultKeyPaths = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+
+// NewClientWithOptions 创建新的 SSH 客户端，认证方式的选择与 NewClientWithAgent 相同
+// 未指定私钥、密码和 ssh-agent 时依次加载 defaultKeyPaths 中存在的私钥（opts.NoDefaultKey 时不加载），
+// 所有可以加载的私钥放在同一个 publickey 认证方法中依次尝试
+func NewClientWithOptions(host, port, user, keyPath, password string, timeout time.Duration, totp *TOTPProvider, opts ClientOptions) (*Client, error) {
+	identityAgent := opts.IdentityAgent
 	var authMethod ssh.AuthMethod
+	var fallbackMethods []ssh.AuthMethod
 
 	// 优先使用 SSH key 认证
 	if keyPath != "" {
-		key, err := loadPrivateKey(keyPath)
+		signers, err := loadPrivateKeys(keyPath)
 		if err != nil {
 			return nil, fmt.Errorf("加载 SSH key 失败: %w", err)
 		}
-		authMethod = ssh.PublicKeys(key)
+		// 所有私钥放在同一个 publickey 认证方法中依次尝试（x/crypto 对同名认证方法只尝试一次）
+		authMethod = ssh.PublicKeys(signers...)
+		if identityAgent != nil {
+			authMethod = ssh.PublicKeysCallback(agentSignersCallback(signers, identityAgent))
+		}
+		slog.Debug("选择认证方式", "host", host, "method", "publickey", "key", keyPath, "keys", len(signers), "agent", identityAgent != nil)
+	} else if identityAgent != nil {
+		authMethod = ssh.PublicKeysCallback(agentSignersCallback(nil, identityAgent))
+		if password != "" {
+			fallbackMethods = append(fallbackMethods, ssh.Password(password))
+		}
+		slog.Debug("选择认证方式", "host", host, "method", "publickey", "agent", true, "password_fallback", password != "")
 	} else if password != "" {
 		authMethod = ssh.Password(password)
+		slog.Debug("选择认证方式", "host", host, "method", "password")
+	} else if opts.NoDefaultKey {
+		return nil, fmt.Errorf("未提供认证方式（key、password 或 ssh-agent），且已禁用默认私钥")
 	} else {
 		// 尝试使用默认的 SSH key
-		defaultKeyPath := filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa")
-		if key, err := loadPrivateKey(defaultKeyPath); err == nil {
-			authMethod = ssh.PublicKeys(key)
-		} else {
-			return nil, fmt.Errorf("未提供认证方式（key 或 password）")
+		signers := loadDefaultKeys(host)
+		if len(signers) == 0 {
+			return nil, fmt.Errorf("未提供认证方式（key、password 或 ssh-agent），且 ~/.ssh 下没有可用的默认私钥（id_ed25519、id_ecdsa、id_rsa）")
 		}
+		authMethod = ssh.PublicKeys(signers...)
+	}
+
+	authMethods := append([]ssh.AuthMethod{authMethod}, fallbackMethods...)
+	if totp != nil {
+		authMethods = append(authMethods, ssh.KeyboardInteractive(totp.Challenge()))
+		slog.Debug("追加 keyboard-interactive 认证（二次验证码）", "host", host)
 	}
 
 	config := &ssh.ClientConfig{
 		User:            user,
-		Auth:            []ssh.AuthMethod{authMethod},
+		Auth:            authMethods,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 生产环境应验证 host key
 		Timeout:         timeout,
 	}
@@ -63,64 +134,323 @@ func NewClientWithTimeout(host, port, user, keyPath, password string, timeout ti
 	}, nil
 }
 
+// loadDefaultKeys 加载 defaultKeyPaths 中存在且可以解析的私钥，不存在或无法解析（如有密码保护）的私钥跳过
+func loadDefaultKeys(host string) []ssh.Signer {
+	var signers []ssh.Signer
+	for _, keyPath := range defaultKeyPaths {
+		keyPath = ExpandPath(keyPath)
+		key, err := loadPrivateKey(keyPath)
+		if err != nil {
+			slog.Debug("默认私钥不可用", "host", host, "key", keyPath, "error", err)
+			continue
+		}
+		slog.Debug("选择认证方式", "host", host, "method", "publickey", "key", keyPath, "key_type", key.PublicKey().Type(), "default_key", true)
+		signers = append(signers, key)
+	}
+	return signers
+}
+
+// agentSignersCallback 返回 keys 和 ssh-agent 中的私钥（keys 在前），在每次认证时从 ssh-agent 读取
+func agentSignersCallback(keys []ssh.Signer, identityAgent *IdentityAgent) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		agentSigners, err := identityAgent.Signers()
+		if err != nil {
+			if len(keys) > 0 {
+				slog.Debug("ssh-agent 不可用，只使用私钥文件", "error", err)
+				return keys, nil
+			}
+			return nil, err
+		}
+		return append(append([]ssh.Signer{}, keys...), agentSigners...), nil
+	}
+}
+
+// SetDialHost 设置建立 TCP 连接时使用的地址（如预先解析得到的 IP）
+// 结果和日志中的主机名、SSH 握手使用的地址仍为原主机名，需在建立连接前调用
+func (c *Client) SetDialHost(ip string) {
+	c.dialHost = ip
+}
+
+// dialAddress 返回建立 TCP 连接的地址，设置了 dialHost 时使用 dialHost
+func (c *Client) dialAddress() string {
+	if c.dialHost != "" {
+		return net.JoinHostPort(c.dialHost, c.port)
+	}
+	return fmt.Sprintf("%s:%s", c.host, c.port)
+}
+
+// SetProxyCommand 设置 ProxyCommand 模板（支持 %h、%p、%r 占位符），需在建立连接前调用
+// 设置后每次连接都会启动该命令，以其标准输入输出作为 SSH 传输通道（如 cloudflared、aws ssm、nc -U）
+func (c *Client) SetProxyCommand(template string) {
+	if template == "" {
+		c.proxyCommand = ""
+		return
+	}
+	c.proxyCommand = ExpandProxyCommand(template, c.host, c.port, c.config.User)
+}
+
+// SetConnectionPool 设置连接池，需在建立连接前调用，pool 为 nil 表示不复用连接
+// 设置后建立连接时优先从连接池取出同一主机（用户名、地址、端口相同）的空闲连接，使用完后放回连接池
+func (c *Client) SetConnectionPool(pool *ConnectionPool) {
+	c.pool = pool
+}
+
+// poolKey 返回连接池中区分主机的键
+func (c *Client) poolKey() string {
+	return fmt.Sprintf("%s@%s:%s", c.config.User, c.host, c.port)
+}
+
+// dialTransport 建立 SSH 的传输连接：设置了 ProxyCommand 时启动该命令，否则建立 TCP 连接
+func (c *Client) dialTransport(timeout time.Duration) (net.Conn, error) {
+	if c.proxyCommand != "" {
+		logger.Trace("启动 ProxyCommand", "host", c.host, "command", c.proxyCommand)
+		return dialProxyCommand(c.proxyCommand)
+	}
+	return net.DialTimeout("tcp", c.dialAddress(), timeout)
+}
+
+// withProxyStderr 握手失败时附加 ProxyCommand 的错误输出，conn 需已关闭（保证输出已全部读取）
+func withProxyStderr(conn net.Conn, err error) error {
+	if pc, ok := conn.(*proxyConn); ok {
+		if stderr := pc.Stderr(); stderr != "" {
+			return fmt.Errorf("%w（ProxyCommand 输出: %s）", err, stderr)
+		}
+	}
+	return err
+}
+
 // Execute 执行命令并返回结果
 func (c *Client) Execute(command string) (*Result, error) {
 	return c.ExecuteWithBecome(command, false, "")
 }
 
+// ExecOptions 命令执行选项
+type ExecOptions struct {
+	Become     bool   // 是否使用 sudo 执行（类似 ansible 的 become）
+	BecomeUser string // sudo 切换的目标用户（默认: root）
+	LoginShell bool   // 是否以登录 shell（bash -lc）执行，加载 /etc/profile 和用户配置文件
+	SudoFlags  string // 追加到 sudo 与命令之间的额外参数（如 -H、-i、--preserve-env）
+
+	PrintCommand bool // 在结果中记录实际执行的命令（Result.ResolvedCommand），用于排查 sudo 等包装问题
+
+	Stdin []byte // 写入远程命令标准输入的内容，写完后关闭标准输入；nil 表示不提供标准输入
+
+	Env map[string]string // 远程命令的环境变量（--env 和主机变量 gossh_env_*），在命令之前 export，become 时由目标用户的 shell 设置
+
+	VerifyBecome bool // become 时先在同一连接上执行 id -u，确认提权生效后再执行命令（见 verifyBecome）
+
+	BecomePassword string         // sudo 密码，设置后以 sudo -S 执行，检测到密码提示符时才写入（见 becomeAuth）
+	BecomePrompt   *regexp.Regexp // 密码提示符的匹配规则，nil 表示使用 DefaultBecomePrompt
+
+	ReconnectOnDrop bool // 命令执行过程中连接断开时重新连接并重新执行命令（见 reconnectOnDrop），只适用于幂等的命令
+
+	UploadProgress func(sent, total int64) // 上传文件时按实际发送的字节数报告进度（可选），total 为文件大小
+	Backup         BackupOptions           // 上传前备份已存在的文件时备份文件的位置和命名
+}
+
 // ExecuteWithBecome 执行命令并返回结果，支持 become 模式（类似 sudo）
 func (c *Client) ExecuteWithBecome(command string, become bool, becomeUser string) (*Result, error) {
+	return c.ExecuteWithOptions(command, ExecOptions{Become: become, BecomeUser: becomeUser})
+}
+
+// ExecuteWithOptions 执行命令并返回结果，支持 become 模式和登录 shell
+func (c *Client) ExecuteWithOptions(command string, opts ExecOptions) (*Result, error) {
 	startTime := time.Now()
 
-	conn, err := c.createSSHConnection()
+	result, err := c.executeOnNewConnection(command, opts)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
+	if opts.ReconnectOnDrop {
+		result = c.reconnectOnDrop(command, opts, result)
+	}
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
 
+// executeOnConn 在已建立的连接上打开一个会话执行命令，返回的结果不包含总耗时
+// ConnectDuration 只包含创建会话的耗时，ExecDuration 为命令从启动到结束的耗时
+func (c *Client) executeOnConn(conn *ssh.Client, command string, opts ExecOptions) (*Result, error) {
+	sessionStart := time.Now()
 	session, err := c.createSession(conn)
 	if err != nil {
-		return nil, err
+		return nil, &ExecError{Host: c.host, Err: err}
 	}
 	defer session.Close()
 
 	stdout, stderr, err := c.setupPipes(session)
 	if err != nil {
-		return nil, err
+		return nil, &ExecError{Host: c.host, Err: err}
+	}
+
+	var stdin io.WriteCloser
+	if opts.Stdin != nil || opts.needsBecomeAuth() {
+		stdin, err = session.StdinPipe()
+		if err != nil {
+			return nil, &ExecError{Host: c.host, Err: fmt.Errorf("获取标准输入失败: %w", err)}
+		}
+	}
+
+	execCommand, execOpts := command, opts
+	var auth *becomeAuth
+	if opts.needsBecomeAuth() {
+		auth = newBecomeAuth(stdin, opts)
+		execCommand, execOpts = auth.prepare(command, opts)
 	}
 
-	finalCommand := c.buildCommand(command, become, becomeUser)
+	finalCommand := c.buildCommand(execCommand, execOpts)
+	logger.Trace("执行命令", "host", c.host, "command", finalCommand)
+	execStart := time.Now()
 	if err := session.Start(finalCommand); err != nil {
-		return nil, fmt.Errorf("启动命令失败: %w", err)
+		return nil, &ExecError{Host: c.host, Err: fmt.Errorf("启动命令失败: %w", err)}
 	}
 
-	output, _ := io.ReadAll(stdout)
-	errOutput, _ := io.ReadAll(stderr)
+	var output, errOutput []byte
+	if auth != nil {
+		output, errOutput = auth.run(c.host, stdout, stderr)
+	} else {
+		// 与读取输出同时写入标准输入，避免远程命令输出较多时双方互相等待
+		if stdin != nil {
+			go func() {
+				defer stdin.Close()
+				if _, err := stdin.Write(opts.Stdin); err != nil {
+					logger.Trace("写入标准输入失败", "host", c.host, "error", err)
+				}
+			}()
+		}
 
-	exitCode := c.waitForCommand(session)
-	duration := time.Since(startTime)
+		output, _ = io.ReadAll(stdout)
+		errOutput, _ = io.ReadAll(stderr)
+	}
 
-	return &Result{
+	exitCode, signal, waitErr := c.waitForCommand(session)
+
+	result := &Result{
 		Host:     c.host,
 		Command:  command,
 		Stdout:   string(output),
 		Stderr:   string(errOutput),
 		ExitCode: exitCode,
-		Duration: duration,
-		Error:    err,
-	}, nil
+
+		ConnectDuration: execStart.Sub(sessionStart),
+		ExecDuration:    time.Since(execStart),
+	}
+	if signal != "" {
+		result.AddWarning("远程命令被信号 SIG%s 终止", signal)
+	}
+	if auth != nil {
+		if warning := auth.warning(); warning != "" {
+			result.AddWarning("%s", warning)
+		}
+	}
+	// 命令已经开始执行，保留已读取的输出，错误记录在结果中（不作为连接失败重试）
+	if waitErr != nil {
+		result.Error = &ExecError{Host: c.host, Err: droppedError(conn, waitErr)}
+	} else if opts.Become && opts.BecomePassword == "" && isSudoPasswordRequired(result) {
+		result.Error = errSudoPasswordRequired()
+	}
+	if opts.PrintCommand {
+		result.ResolvedCommand = finalCommand
+	}
+	return result, nil
 }
 
 // createSSHConnection 创建 SSH 连接
+// 失败时返回 ConnectionError、AuthError 或 TimeoutError
+// 连接超时同时限制 TCP 连接和 SSH 握手（ssh.Dial 的 Timeout 只作用于 TCP 连接）
 func (c *Client) createSSHConnection() (*ssh.Client, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, &ConnectionError{Host: c.host, Err: errClientClosed}
+	}
+
+	if c.pool != nil {
+		if conn := c.pool.Get(c.poolKey()); conn != nil {
+			return c.trackConnection(conn)
+		}
+	}
+
 	address := fmt.Sprintf("%s:%s", c.host, c.port)
-	conn, err := ssh.Dial("tcp", address, c.config)
+	startTime := time.Now()
+	logger.Trace("建立 SSH 连接", "host", c.host, "address", c.dialAddress(), "user", c.config.User, "timeout", c.timeout)
+	conn, err := c.dial(address)
 	if err != nil {
-		return nil, fmt.Errorf("连接失败: %w", err)
+		logger.Trace("SSH 连接失败", "host", c.host, "duration", time.Since(startTime), "error", err)
+		return nil, classifyDialError(c.host, err)
+	}
+	logger.Trace("SSH 连接已建立", "host", c.host, "duration", time.Since(startTime), "server_version", string(conn.ServerVersion()))
+
+	return c.trackConnection(conn)
+}
+
+// trackConnection 记录已建立的连接，Close 时统一关闭；客户端已关闭时关闭该连接并返回错误
+func (c *Client) trackConnection(conn *ssh.Client) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		conn.Close()
+		return nil, &ConnectionError{Host: c.host, Err: errClientClosed}
 	}
+	c.conns = append(c.conns, conn)
 	return conn, nil
 }
 
+// releaseConnection 使用完连接后调用：设置了连接池时放回连接池，否则关闭连接
+// 客户端已被 Close（如超过操作超时）时连接已关闭，不再放回
+func (c *Client) releaseConnection(conn *ssh.Client) {
+	c.mu.Lock()
+	closed := c.closed
+	for i, tracked := range c.conns {
+		if tracked == conn {
+			c.conns = append(c.conns[:i], c.conns[i+1:]...)
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if c.pool == nil || closed {
+		conn.Close()
+		return
+	}
+	c.pool.Release(c.poolKey(), conn)
+}
+
+// dial 建立 TCP 连接（或启动 ProxyCommand）并完成 SSH 握手，握手期间设置连接超时的截止时间
+// TCP 连接使用 dialAddress（可能是预先解析的 IP），SSH 握手仍使用 address（原主机名）
+func (c *Client) dial(address string) (*ssh.Client, error) {
+	tcpConn, err := c.dialTransport(c.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.timeout > 0 {
+		tcpConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(tcpConn, address, c.config)
+	if err != nil {
+		tcpConn.Close()
+		return nil, withProxyStderr(tcpConn, err)
+	}
+	// 握手完成后取消截止时间，命令执行时间不受连接超时限制
+	tcpConn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// Close 关闭客户端建立的所有连接，正在执行的操作会因连接断开而返回错误；
+// 关闭后不再建立新连接。用于在超过操作超时时中断仍在进行的任务
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = nil
+}
+
 // createSession 创建 SSH 会话
 func (c *Client) createSession(conn *ssh.Client) (*ssh.Session, error) {
 	session, err := conn.NewSession()
@@ -145,28 +475,151 @@ func (c *Client) setupPipes(session *ssh.Session) (io.Reader, io.Reader, error)
 	return stdout, stderr, nil
 }
 
-// buildCommand 构建最终执行的命令（支持 become 模式）
-func (c *Client) buildCommand(command string, become bool, becomeUser string) string {
-	if !become {
+// buildCommand 构建最终执行的命令（支持 become 模式和登录 shell）
+// 登录 shell 先包装命令，再由 sudo 执行，即 sudo -u user bash -lc '<command>'，
+// 这样加载的是目标用户的环境。become 用户为空、root 或 UID 0 时生成 sudo <command>（不加 -u），
+// 其他用户生成 sudo -u <user> <command>。没有 sudo 密码时加 -n（没有终端时 sudo 可能一直等待输入密码，
+// 加 -n 后需要密码的主机立即失败，见 ErrSudoPasswordRequired）。环境变量在最内层 export（sudo 默认会清除调用者的环境变量，
+// 登录 shell 的配置文件也可能覆盖），即 sudo sh -c 'export FOO=bar; <command>'
+func (c *Client) buildCommand(command string, opts ExecOptions) string {
+	command = envPrefix(opts.Env) + command
+
+	if opts.LoginShell {
+		command = fmt.Sprintf("bash -lc %s", shellQuote(command))
+	}
+
+	if !opts.Become {
 		return command
 	}
 
-	if becomeUser != "" && becomeUser != "root" {
-		return fmt.Sprintf("sudo -u %s %s", becomeUser, command)
+	// 命令整体加引号交给 sh -c 执行，多条命令（&&、;）、管道和重定向都以 become 用户执行，
+	// 而不是只有第一条命令经过 sudo；登录 shell 已经包装为单个 bash -lc 命令，不需要再包装
+	if !opts.LoginShell {
+		command = fmt.Sprintf("sh -c %s", shellQuote(command))
+	}
+
+	flags := strings.TrimSpace(opts.SudoFlags)
+	if opts.BecomePassword == "" && !hasNonInteractiveFlag(flags) {
+		flags = strings.TrimSpace("-n " + flags)
+	}
+	sudo := fmt.Sprintf("sudo %s", flags)
+
+	if !isRootBecomeUser(opts.BecomeUser) {
+		return fmt.Sprintf("%s -u %s %s", sudo, sudoUserArg(opts.BecomeUser), command)
 	}
 
-	return fmt.Sprintf("sudo %s", command)
+	return fmt.Sprintf("%s %s", sudo, command)
+}
+
+// hasNonInteractiveFlag 判断 sudo 参数中是否已经包含 -n（--non-interactive）
+func hasNonInteractiveFlag(flags string) bool {
+	for _, flag := range strings.Fields(flags) {
+		if flag == "-n" || flag == "--non-interactive" {
+			return true
+		}
+	}
+	return false
+}
+
+// isRootBecomeUser 判断 become 用户是否为 root（未指定、root 或 UID 0）
+func isRootBecomeUser(user string) bool {
+	return user == "" || user == "root" || user == "0" || user == "#0"
+}
+
+// sudoUserArg 格式化 sudo -u 的参数
+// 纯数字的用户视为 UID，sudo 需要 #UID 的形式（已写成 #UID 的保持不变）；
+// # 在 shell 中会开始注释，因此加引号
+func sudoUserArg(user string) string {
+	uid := strings.TrimPrefix(user, "#")
+	if isNumericUID(uid) {
+		return shellQuote("#" + uid)
+	}
+	return user
+}
+
+// isNumericUID 判断字符串是否为纯数字的 UID
+func isNumericUID(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateSudoFlags 校验 --sudo-flags 参数
+// 每个参数都必须以 - 开头，且只能包含字母、数字和 -=_,.:/ 等字符，避免破坏命令的引号和 shell 解析；
+// 目标用户只能通过 --become-user 指定，不允许在 sudo 参数中使用 -u/--user
+func ValidateSudoFlags(flags string) error {
+	for _, flag := range strings.Fields(flags) {
+		if !strings.HasPrefix(flag, "-") {
+			return fmt.Errorf("无效的 sudo 参数 %q: 必须以 - 开头", flag)
+		}
+		for _, r := range flag {
+			if !isSafeSudoFlagRune(r) {
+				return fmt.Errorf("无效的 sudo 参数 %q: 包含不允许的字符 %q", flag, r)
+			}
+		}
+		if strings.HasPrefix(flag, "--user") || (strings.HasPrefix(flag, "-u") && !strings.HasPrefix(flag, "--")) {
+			return fmt.Errorf("sudo 参数中不能指定用户 %q，请使用 --become-user", flag)
+		}
+	}
+	return nil
+}
+
+// isSafeSudoFlagRune 判断字符是否可以安全地出现在 sudo 参数中
+func isSafeSudoFlagRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("-=_,.:/", r)
+}
+
+// shellQuote 使用单引号包裹字符串，供远程 shell 作为单个参数解析
+// 字符串中的单引号会被拆分为：结束引号、转义的单引号、重新开始引号
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// signalNumbers Linux 上的信号编号，用于与 shell 一样把被信号终止的命令的退出码记为 128+信号编号
+// （x/crypto 只认识其中一部分信号，不认识的信号退出码都是 128）
+var signalNumbers = map[string]int{
+	"HUP": 1, "INT": 2, "QUIT": 3, "ILL": 4, "TRAP": 5, "ABRT": 6, "BUS": 7, "FPE": 8,
+	"KILL": 9, "USR1": 10, "SEGV": 11, "USR2": 12, "PIPE": 13, "ALRM": 14, "TERM": 15,
+	"XCPU": 24, "XFSZ": 25, "VTALRM": 26, "PROF": 27, "SYS": 31,
 }
 
 // waitForCommand 等待命令完成并返回退出码
-func (c *Client) waitForCommand(session *ssh.Session) int {
-	err := session.Wait()
-	if err != nil {
-		if exitError, ok := err.(*ssh.ExitError); ok {
-			return exitError.ExitStatus()
+// 命令被信号终止时与 shell 一致返回 128+信号编号，同时返回信号名称（如 KILL）；
+// 没有收到退出码（如命令执行过程中连接断开）时返回 -1 和错误，而不是当作成功
+func (c *Client) waitForCommand(session *ssh.Session) (int, string, error) {
+	return commandExitStatus(session.Wait())
+}
+
+// commandExitStatus 将 session.Wait 返回的错误转换为退出码、信号名称和错误（见 waitForCommand）
+func commandExitStatus(err error) (int, string, error) {
+	if err == nil {
+		return 0, "", nil
+	}
+
+	var exitError *ssh.ExitError
+	if errors.As(err, &exitError) {
+		signal := exitError.Signal()
+		if number, ok := signalNumbers[signal]; ok {
+			return 128 + number, signal, nil
 		}
+		return exitError.ExitStatus(), signal, nil
+	}
+
+	var missingError *ssh.ExitMissingError
+	if errors.As(err, &missingError) {
+		return -1, "", errExitStatusMissing
 	}
-	return 0
+	return -1, "", fmt.Errorf("等待命令结束失败: %w", err)
 }
 
 // ExecuteScript 执行脚本文件（先上传到临时目录再执行）
@@ -176,15 +629,48 @@ func (c *Client) ExecuteScript(scriptPath string) (*Result, error) {
 
 // ExecuteScriptWithBecome 执行脚本文件（先上传到临时目录再执行），支持 become 模式
 func (c *Client) ExecuteScriptWithBecome(scriptPath string, become bool, becomeUser string, executor string) (*Result, error) {
+	return c.ExecuteScriptWithOptions(scriptPath, ScriptOptions{Executor: executor}, ExecOptions{Become: become, BecomeUser: becomeUser})
+}
+
+// ErrInterpreterNotFound 远程主机上找不到脚本解释器
+var ErrInterpreterNotFound = errors.New("远程主机上找不到解释器")
+
+// ScriptOptions 脚本执行相关选项
+type ScriptOptions struct {
+	Executor   string // 脚本执行器（解释器，可以带参数，如 "python3 -u"，默认: bash）
+	RemoteTmp  string // 远程临时目录（默认: /tmp）
+	KeepScript bool   // 执行后保留远程临时脚本，不清理
+}
+
+// ExecuteScriptWithOptions 执行脚本文件（先上传到临时目录再执行），支持 become 模式等执行选项
+func (c *Client) ExecuteScriptWithOptions(scriptPath string, scriptOpts ScriptOptions, opts ExecOptions) (*Result, error) {
 	startTime := time.Now()
 
 	// 设置默认执行器
+	executor := scriptOpts.Executor
 	if executor == "" {
 		executor = "bash"
 	}
 
+	// 设置默认远程临时目录
+	remoteTmp := scriptOpts.RemoteTmp
+	if remoteTmp == "" {
+		remoteTmp = "/tmp"
+	}
+
+	// 上传脚本前确认解释器存在，避免执行时出现难以理解的 "python: not found"
+	if err := c.checkInterpreter(executor, opts); err != nil {
+		result := c.createErrorResult(scriptPath, startTime, err, "检查解释器失败")
+		if errors.Is(err, ErrInterpreterNotFound) {
+			return result, nil
+		}
+		return result, err
+	}
+	// 检查解释器时已经验证过 become，执行脚本时不再重复验证
+	opts.VerifyBecome = false
+
 	// 生成唯一的临时文件名（使用时间戳和随机数）
-	tempFileName := fmt.Sprintf("/tmp/gossh_script_%d_%d", time.Now().UnixNano(), os.Getpid())
+	tempFileName := path.Join(remoteTmp, fmt.Sprintf("gossh_script_%d_%d", time.Now().UnixNano(), os.Getpid()))
 
 	// 使用 UploadFile 方法上传脚本文件（临时文件总是强制覆盖）
 	_, err := c.UploadFile(scriptPath, tempFileName, "0755", false, true)
@@ -200,19 +686,31 @@ func (c *Client) ExecuteScriptWithBecome(scriptPath string, become bool, becomeU
 		}, err
 	}
 
+	// 保留脚本时不清理，直接执行并在 stdout 末尾报告脚本位置
+	if scriptOpts.KeepScript {
+		result, err := c.ExecuteWithOptions(fmt.Sprintf("%s %s", executor, shellQuote(tempFileName)), opts)
+		if result != nil {
+			if result.Stdout != "" && !strings.HasSuffix(result.Stdout, "\n") {
+				result.Stdout += "\n"
+			}
+			result.Stdout += fmt.Sprintf("[gossh] 脚本已保留在远程主机: %s\n", tempFileName)
+			result.Duration = time.Since(startTime)
+		}
+		return result, err
+	}
+
 	// 获取 SSH 连接用于清理临时文件
-	address := fmt.Sprintf("%s:%s", c.host, c.port)
-	conn, err := ssh.Dial("tcp", address, c.config)
-	if err != nil {
+	conn, connErr := c.createSSHConnection()
+	if connErr != nil {
 		// 如果连接失败，仍然尝试执行脚本
 		conn = nil
 	} else {
-		defer conn.Close()
+		defer c.releaseConnection(conn)
 	}
 
 	// 执行脚本，使用指定的执行器
-	executeCommand := fmt.Sprintf("%s %s", executor, tempFileName)
-	result, err := c.ExecuteWithBecome(executeCommand, become, becomeUser)
+	executeCommand := fmt.Sprintf("%s %s", executor, shellQuote(tempFileName))
+	result, err := c.ExecuteWithOptions(executeCommand, opts)
 	if err != nil {
 		// 即使执行失败，也尝试清理临时文件
 		if conn != nil {
@@ -221,13 +719,13 @@ func (c *Client) ExecuteScriptWithBecome(scriptPath string, become bool, becomeU
 		return result, err
 	}
 
-	// 清理临时文件
+	// 清理临时文件，清理失败记录为 gossh 内部警告，不混入脚本本身的 stderr
 	if conn != nil {
-		cleanupResult := c.cleanupTempFile(conn, tempFileName)
-		if cleanupResult != nil && result.ExitCode == 0 {
-			// 如果清理失败但原命令成功，在 stderr 中记录
-			result.Stderr += fmt.Sprintf("\n警告: 清理临时文件失败: %v", cleanupResult)
+		if err := c.cleanupTempFile(conn, tempFileName); err != nil {
+			result.AddWarning("清理临时文件 %s 失败: %v", tempFileName, err)
 		}
+	} else {
+		result.AddWarning("无法建立清理连接，临时文件 %s 未删除: %v", tempFileName, connErr)
 	}
 
 	// 更新总耗时
@@ -236,6 +734,26 @@ func (c *Client) ExecuteScriptWithBecome(scriptPath string, become bool, becomeU
 	return result, nil
 }
 
+// checkInterpreter 通过 command -v 检查解释器在远程主机上是否存在
+// 与执行脚本使用相同的 become 选项，确保检查的是实际执行用户的 PATH
+func (c *Client) checkInterpreter(executor string, opts ExecOptions) error {
+	fields := strings.Fields(executor)
+	if len(fields) == 0 {
+		return nil
+	}
+	interpreter := fields[0]
+
+	script := fmt.Sprintf("command -v %s", shellQuote(interpreter))
+	result, err := c.ExecuteWithOptions(fmt.Sprintf("sh -c %s", shellQuote(script)), opts)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%w: %s（command -v %s 失败），请在远程主机安装该解释器，或通过 --interpreter 或主机变量 gossh_interpreter 指定", ErrInterpreterNotFound, interpreter, interpreter)
+	}
+	return nil
+}
+
 // cleanupTempFile 清理临时文件
 func (c *Client) cleanupTempFile(conn *ssh.Client, filePath string) error {
 	session, err := conn.NewSession()
@@ -244,14 +762,22 @@ func (c *Client) cleanupTempFile(conn *ssh.Client, filePath string) error {
 	}
 	defer session.Close()
 
-	// 执行删除命令，忽略错误
-	_ = session.Run(fmt.Sprintf("rm -f %s", filePath))
-	return nil
+	// 执行删除命令，使用引号包裹路径以防止特殊字符问题
+	return session.Run(fmt.Sprintf("rm -f %q", filePath))
 }
 
 // UploadFile 上传文件到远程主机
 func (c *Client) UploadFile(localPath string, remotePath string, mode string, backup bool, force bool) (*Result, error) {
+	return c.UploadFileWithOptions(localPath, remotePath, mode, backup, force, ExecOptions{})
+}
+
+// UploadFileWithOptions 上传文件到远程主机，支持 become 模式
+// become 模式下 SCP 仍以登录用户运行：先上传到 /tmp 下的临时文件，
+// 再通过 sudo install 放到目标路径（属主为 become 用户，权限为 mode），最后删除临时文件；
+// 检查目标文件是否存在和备份也通过 sudo 执行。opts 中的 LoginShell 对上传无效
+func (c *Client) UploadFileWithOptions(localPath string, remotePath string, mode string, backup bool, force bool, opts ExecOptions) (*Result, error) {
 	startTime := time.Now()
+	opts.LoginShell = false
 	command := fmt.Sprintf("upload %s -> %s", localPath, remotePath)
 
 	localFile, err := c.openLocalFile(localPath)
@@ -260,14 +786,19 @@ func (c *Client) UploadFile(localPath string, remotePath string, mode string, ba
 	}
 	defer localFile.Close()
 
+	localInfo, err := localFile.Stat()
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "读取本地文件信息失败"), err
+	}
+
 	conn, err := c.createSSHConnection()
 	if err != nil {
 		return c.createErrorResult(command, startTime, err, "连接失败"), err
 	}
-	defer conn.Close()
+	defer c.releaseConnection(conn)
 
 	// 检查文件是否存在
-	fileExists, err := c.checkFileExists(conn, remotePath)
+	fileExists, err := c.checkFileExists(conn, remotePath, opts)
 	if err != nil {
 		return c.createErrorResult(command, startTime, err, "检查远程文件失败"), err
 	}
@@ -277,22 +808,23 @@ func (c *Client) UploadFile(localPath string, remotePath string, mode string, ba
 	if fileExists {
 		// 如果既没有 force 也没有 backup，则跳过
 		if !force && !backup {
-			// 默认行为：不覆盖，跳过（标记为失败）
+			// 默认行为：不覆盖，跳过（标记为跳过，不计入失败）
 			return &Result{
 				Host:     c.host,
 				Command:  command,
-				Stdout:   "",
-				Stderr:   fmt.Sprintf("文件已存在，已跳过: %s（或使用 --force / --backup）", remotePath),
-				ExitCode: 1,
+				Stdout:   fmt.Sprintf("文件已存在，已跳过: %s（或使用 --force / --backup）", remotePath),
+				Stderr:   "",
+				ExitCode: 0,
 				Duration: time.Since(startTime),
-				Error:    fmt.Errorf("文件已存在，已跳过"),
+				Error:    nil,
+				Skipped:  true,
 			}, nil
 		}
 
 		// 如果启用了 backup，先备份再上传（无论是否有 force）
 		if backup {
 			var err error
-			backupPath, err = c.backupRemoteFile(conn, remotePath)
+			backupPath, err = c.backupRemoteFile(conn, remotePath, opts)
 			if err != nil {
 				return c.createErrorResult(command, startTime, err, "备份远程文件失败"), err
 			}
@@ -307,7 +839,12 @@ func (c *Client) UploadFile(localPath string, remotePath string, mode string, ba
 	defer scpClient.Close()
 
 	mode = c.normalizeFileMode(mode)
-	if err := c.copyFile(scpClient, localFile, remotePath, mode); err != nil {
+	if opts.Become {
+		err = c.copyFileWithBecome(conn, scpClient, localFile, remotePath, mode, opts)
+	} else {
+		err = c.copyFile(scpClient, localFile, remotePath, mode, opts.UploadProgress)
+	}
+	if err != nil {
 		return c.createErrorResult(command, startTime, err, "上传文件失败"), err
 	}
 
@@ -319,16 +856,72 @@ func (c *Client) UploadFile(localPath string, remotePath string, mode string, ba
 	}
 
 	return &Result{
-		Host:     c.host,
-		Command:  command,
-		Stdout:   stdoutMsg,
-		Stderr:   "",
-		ExitCode: 0,
-		Duration: time.Since(startTime),
-		Error:    nil,
+		Host:             c.host,
+		Command:          command,
+		Stdout:           stdoutMsg,
+		Stderr:           "",
+		ExitCode:         0,
+		Duration:         time.Since(startTime),
+		Error:            nil,
+		BytesTransferred: localInfo.Size(),
 	}, nil
 }
 
+// UploadFileToPathsWithOptions 将同一个本地文件依次上传到多个远程路径，并将结果合并为一条
+// 某个路径失败后继续上传其余路径；全部路径都被跳过时结果才标记为跳过
+func (c *Client) UploadFileToPathsWithOptions(localPath string, remotePaths []string, mode string, backup bool, force bool, opts ExecOptions) (*Result, error) {
+	if len(remotePaths) == 1 {
+		return c.UploadFileWithOptions(localPath, remotePaths[0], mode, backup, force, opts)
+	}
+
+	merged := &Result{
+		Host:    c.host,
+		Command: fmt.Sprintf("upload %s -> %s", localPath, strings.Join(remotePaths, ", ")),
+		Skipped: len(remotePaths) > 0,
+	}
+	var stdout, stderr []string
+	var firstErr error
+	failCount := 0
+
+	for i, remotePath := range remotePaths {
+		result, err := c.UploadFileWithOptions(localPath, remotePath, mode, backup, force, opts)
+		merged.Duration += result.Duration
+		merged.BytesTransferred += result.BytesTransferred
+		merged.Warnings = append(merged.Warnings, result.Warnings...)
+		if !result.Skipped {
+			merged.Skipped = false
+		}
+		if result.Stdout != "" {
+			stdout = append(stdout, result.Stdout)
+		}
+		if err != nil {
+			failCount++
+			if firstErr == nil {
+				firstErr = err
+				merged.ExitCode = result.ExitCode
+			}
+			stderr = append(stderr, fmt.Sprintf("%s: %s", remotePath, result.Stderr))
+
+			// 连接或认证失败时其余路径也无法上传，不再逐个重试
+			var authErr *AuthError
+			var timeoutErr *TimeoutError
+			var connErr *ConnectionError
+			if errors.As(err, &authErr) || errors.As(err, &timeoutErr) || errors.As(err, &connErr) {
+				failCount += len(remotePaths) - i - 1
+				break
+			}
+		}
+	}
+
+	merged.Stdout = strings.Join(stdout, "\n")
+	merged.Stderr = strings.Join(stderr, "\n")
+	if firstErr != nil {
+		merged.Error = fmt.Errorf("%d/%d 个远程路径上传失败: %w", failCount, len(remotePaths), firstErr)
+		return merged, merged.Error
+	}
+	return merged, nil
+}
+
 // openLocalFile 打开本地文件并重置文件指针
 func (c *Client) openLocalFile(localPath string) (*os.File, error) {
 	localFile, err := os.Open(localPath)
@@ -363,54 +956,177 @@ func (c *Client) normalizeFileMode(mode string) string {
 }
 
 // copyFile 使用 SCP 客户端复制文件
-func (c *Client) copyFile(scpClient scp.Client, localFile *os.File, remotePath, mode string) error {
+// progress 不为 nil 时包装读取本地文件的 reader，每读取一块数据报告一次已发送的字节数
+func (c *Client) copyFile(scpClient scp.Client, localFile *os.File, remotePath, mode string, progress func(sent, total int64)) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
-	return scpClient.CopyFromFile(ctx, *localFile, remotePath, mode)
+	if progress == nil {
+		return scpClient.CopyFromFile(ctx, *localFile, remotePath, mode)
+	}
+	return scpClient.CopyFromFilePassThru(ctx, *localFile, remotePath, mode, func(r io.Reader, total int64) io.Reader {
+		return &progressReader{reader: r, total: total, report: progress}
+	})
+}
+
+// progressReader 统计已读取（即已交给 SCP 发送）的字节数并报告上传进度
+type progressReader struct {
+	reader io.Reader
+	sent   int64
+	total  int64
+	report func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.report(p.sent, p.total)
+	}
+	return n, err
+}
+
+// copyFileWithBecome 以 become 模式上传文件
+// 先以登录用户上传到临时文件，再以 become 用户执行 install 放到目标路径
+func (c *Client) copyFileWithBecome(conn *ssh.Client, scpClient scp.Client, localFile *os.File, remotePath, mode string, opts ExecOptions) error {
+	tempPath := fmt.Sprintf("/tmp/gossh_upload_%d_%d", time.Now().UnixNano(), os.Getpid())
+
+	// 临时文件默认只允许登录用户读取；非 root 的 become 用户无法借助 root 权限读取，需要放开读权限
+	tempMode := "0600"
+	if !isRootBecomeUser(opts.BecomeUser) {
+		tempMode = "0644"
+	}
+
+	if err := c.copyFile(scpClient, localFile, tempPath, tempMode, opts.UploadProgress); err != nil {
+		return fmt.Errorf("上传临时文件失败: %w", err)
+	}
+	defer c.cleanupTempFile(conn, tempPath)
+
+	// install 会重新创建目标文件，属主为执行 install 的 become 用户
+	install := fmt.Sprintf("install -m %s %s %s", shellQuote(mode), shellQuote(tempPath), shellQuote(remotePath))
+	if err := c.runWithStderr(conn, c.buildCommand(install, opts)); err != nil {
+		return fmt.Errorf("移动到目标路径失败: %w", err)
+	}
+	return nil
+}
+
+// runWithStderr 执行远程命令，失败时将远程 stderr 附加到错误信息中
+func (c *Client) runWithStderr(conn *ssh.Client, command string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建会话失败: %w", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
 }
 
+// fileMissingExit checkFileExists 中远程文件不存在时的退出码
+const fileMissingExit = 100
+
 // checkFileExists 检查远程文件是否存在
-func (c *Client) checkFileExists(conn *ssh.Client, remotePath string) (bool, error) {
+// become 模式下通过 sudo 检查，避免登录用户无权访问目标目录；sudo 需要密码时返回 ErrSudoPasswordRequired
+func (c *Client) checkFileExists(conn *ssh.Client, remotePath string, opts ExecOptions) (bool, error) {
 	session, err := conn.NewSession()
 	if err != nil {
 		return false, fmt.Errorf("创建会话失败: %w", err)
 	}
 	defer session.Close()
 
-	// 使用 test -f 命令检查文件是否存在，使用引号包裹路径以防止特殊字符问题
-	command := fmt.Sprintf("test -f %q", remotePath)
+	// 使用 test -f 命令检查文件是否存在，使用引号包裹路径以防止特殊字符问题；
+	// 文件不存在时以 fileMissingExit 退出，与 sudo 失败（如 sudo -n 需要密码）的退出码 1 区分开
+	var stderr strings.Builder
+	session.Stderr = &stderr
+	command := c.buildCommand(fmt.Sprintf("test -f %q || exit %d", remotePath, fileMissingExit), opts)
 	err = session.Run(command)
 	if err != nil {
 		if exitError, ok := err.(*ssh.ExitError); ok {
-			// 退出码为 1 表示文件不存在
-			if exitError.ExitStatus() == 1 {
+			if exitError.ExitStatus() == fileMissingExit {
 				return false, nil
 			}
+			if opts.Become && opts.BecomePassword == "" && isSudoPasswordRequired(&Result{ExitCode: exitError.ExitStatus(), Stderr: stderr.String()}) {
+				return false, fmt.Errorf("检查文件存在性失败: %w", errSudoPasswordRequired())
+			}
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return false, fmt.Errorf("检查文件存在性失败: %w: %s", err, msg)
 		}
 		return false, fmt.Errorf("检查文件存在性失败: %w", err)
 	}
 	return true, nil
 }
 
-// getBackupPath 生成备份文件路径
-func (c *Client) getBackupPath(remotePath string) string {
-	now := time.Now()
-	timestamp := now.Format("20060102-150405")
-	// 生成备份文件名：原文件名.backup.YYYYMMDD-HHMMSS
-	return fmt.Sprintf("%s.backup.%s", remotePath, timestamp)
+// 备份文件命名的默认值：原文件名.backup.YYYYMMDD-HHMMSS
+const (
+	DefaultBackupSuffix     = ".backup.{timestamp}"
+	DefaultBackupTimeFormat = "20060102-150405"
+)
+
+// BackupOptions 备份文件的位置和命名
+type BackupOptions struct {
+	Dir        string // 备份目录，备份文件在其中保留原文件的完整路径（如 <Dir>/etc/app.conf.backup.<时间戳>），为空表示放在原文件旁边
+	Suffix     string // 追加到原文件名后的后缀，{timestamp} 替换为时间戳，为空表示 DefaultBackupSuffix
+	TimeFormat string // 时间戳格式（Go 的时间格式，如 20060102-150405），为空表示 DefaultBackupTimeFormat
+}
+
+// ValidateBackupOptions 校验备份的命名：后缀和时间戳只能用于文件名，不能包含路径分隔符
+func ValidateBackupOptions(opts BackupOptions) error {
+	if strings.Contains(opts.Suffix, "/") {
+		return fmt.Errorf("--backup-suffix 不能包含 /: %s", opts.Suffix)
+	}
+	if opts.TimeFormat != "" && strings.Contains(time.Now().Format(opts.TimeFormat), "/") {
+		return fmt.Errorf("--backup-time-format 生成的时间戳不能包含 /: %s", opts.TimeFormat)
+	}
+	return nil
+}
+
+// getBackupPath 生成备份文件路径：原文件路径（指定了备份目录时放在该目录下）加上后缀，后缀中的 {timestamp} 替换为当前时间
+func (c *Client) getBackupPath(remotePath string, opts BackupOptions) string {
+	suffix := opts.Suffix
+	if suffix == "" {
+		suffix = DefaultBackupSuffix
+	}
+	format := opts.TimeFormat
+	if format == "" {
+		format = DefaultBackupTimeFormat
+	}
+	suffix = strings.ReplaceAll(suffix, "{timestamp}", time.Now().Format(format))
+
+	if opts.Dir == "" {
+		return remotePath + suffix
+	}
+	return path.Join(opts.Dir, remotePath) + suffix
 }
 
 // backupRemoteFile 备份远程文件
-func (c *Client) backupRemoteFile(conn *ssh.Client, remotePath string) (string, error) {
-	backupPath := c.getBackupPath(remotePath)
+// become 模式下通过 sudo 备份，备份文件保留原文件的属主和权限
+func (c *Client) backupRemoteFile(conn *ssh.Client, remotePath string, opts ExecOptions) (string, error) {
+	backupPath := c.getBackupPath(remotePath, opts.Backup)
 	session, err := conn.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("创建会话失败: %w", err)
 	}
 	defer session.Close()
 
-	// 使用 cp 命令备份文件，使用引号包裹路径以防止特殊字符问题
+	// 使用 cp 命令备份文件，使用引号包裹路径以防止特殊字符问题；
+	// 指定了备份目录时先创建备份文件所在的目录
 	command := fmt.Sprintf("cp %q %q", remotePath, backupPath)
+	if opts.Become {
+		command = fmt.Sprintf("cp -p %q %q", remotePath, backupPath)
+	}
+	if opts.Backup.Dir != "" {
+		command = fmt.Sprintf("mkdir -p %q && %s", path.Dir(backupPath), command)
+	}
+	if opts.Become {
+		command = c.buildCommand(command, opts)
+	}
 	err = session.Run(command)
 	if err != nil {
 		return "", fmt.Errorf("备份文件失败: %w", err)
@@ -437,14 +1153,50 @@ func (c *Client) Ping() (*PingResult, error) {
 }
 
 // PingWithTimeout 测试 SSH 连接是否成功，支持自定义超时时间
+// timeout 为整个测试（TCP 连接、SSH 握手、创建会话）的总时间；TCP 连接和 SSH 握手
+// 还受客户端的连接超时限制（取两者中较小的值）
 func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
+	return c.PingWithOptions(timeout, PingOptions{})
+}
+
+// PingOptions ping 测试的选项
+type PingOptions struct {
+	CheckBecome bool        // 连接成功后检查 sudo 是否可用（见 checkBecome），结果记录在 PingResult.BecomeChecked 和 BecomeError 中
+	Become      ExecOptions // 检查 sudo 时使用的 BecomeUser、SudoFlags、BecomePassword 和 BecomePrompt
+}
+
+// PingWithOptions 与 PingWithTimeout 相同，opts.CheckBecome 为 true 时在创建会话后检查 sudo 是否可用，
+// 检查同样受 timeout 限制；sudo 不可用不影响连接测试的结果（Success 仍为 true）
+func (c *Client) PingWithOptions(timeout time.Duration, opts PingOptions) (*PingResult, error) {
 	startTime := time.Now()
-	address := fmt.Sprintf("%s:%s", c.host, c.port)
+	address := net.JoinHostPort(c.host, c.port)
 
 	// 使用 context 强制超时
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	connectTimeout := c.timeout
+	if connectTimeout <= 0 || connectTimeout > timeout {
+		connectTimeout = timeout
+	}
+
+	// 先建立 TCP 连接，区分端口不可达与 SSH 握手/认证失败
+	logger.Trace("ping: 建立 TCP 连接", "host", c.host, "address", address, "timeout", timeout, "connect_timeout", connectTimeout)
+	tcpConn, err := c.dialTransport(connectTimeout)
+	tcpDuration := time.Since(startTime)
+	if err != nil {
+		logger.Trace("ping: TCP 连接失败", "host", c.host, "duration", tcpDuration, "error", err)
+		err = &ConnectionError{Host: c.host, Err: err}
+		return &PingResult{
+			Host:        c.host,
+			Success:     false,
+			Duration:    tcpDuration,
+			Error:       err,
+			Phase:       PingPhaseTCP,
+			TCPDuration: tcpDuration,
+		}, err
+	}
+
 	// 创建一个 channel 来接收连接结果
 	type dialResult struct {
 		conn *ssh.Client
@@ -452,9 +1204,19 @@ func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
 	}
 	dialCh := make(chan dialResult, 1)
 
-	// 在 goroutine 中执行连接，以便可以被 context 取消
+	// 在 goroutine 中基于已建立的 TCP 连接进行 SSH 握手，以便可以被 context 取消
+	handshakeStart := time.Now()
 	go func() {
-		conn, err := ssh.Dial("tcp", address, c.config)
+		var conn *ssh.Client
+		tcpConn.SetDeadline(time.Now().Add(connectTimeout))
+		sshConn, chans, reqs, err := ssh.NewClientConn(tcpConn, address, c.config)
+		if err != nil {
+			tcpConn.Close()
+			err = withProxyStderr(tcpConn, err)
+		} else {
+			tcpConn.SetDeadline(time.Time{})
+			conn = ssh.NewClient(sshConn, chans, reqs)
+		}
 		select {
 		case dialCh <- dialResult{conn: conn, err: err}:
 			// 成功发送结果
@@ -467,26 +1229,36 @@ func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
 	}()
 
 	var conn *ssh.Client
-	var err error
 	select {
 	case result := <-dialCh:
 		conn = result.conn
-		err = result.err
+		if result.err != nil {
+			err = classifyDialError(c.host, result.err)
+		}
 	case <-ctx.Done():
-		err = fmt.Errorf("连接超时（超过 %v）", timeout)
+		// 关闭 TCP 连接以中断仍在进行的握手
+		tcpConn.Close()
+		err = &TimeoutError{Host: c.host, Err: fmt.Errorf("SSH 握手超过 %v", timeout)}
 	}
 
 	duration := time.Since(startTime)
+	handshakeDuration := time.Since(handshakeStart)
 
 	if err != nil {
+		logger.Trace("ping: SSH 握手失败", "host", c.host, "duration", handshakeDuration, "error", err)
 		return &PingResult{
-			Host:     c.host,
-			Success:  false,
-			Duration: duration,
-			Error:    err,
+			Host:              c.host,
+			Success:           false,
+			Duration:          duration,
+			Error:             err,
+			Phase:             PingPhaseHandshake,
+			TCPDuration:       tcpDuration,
+			HandshakeDuration: handshakeDuration,
 		}, err
 	}
 
+	logger.Trace("ping: SSH 握手完成", "host", c.host, "tcp", tcpDuration, "handshake", handshakeDuration)
+
 	// 确保连接总是被关闭
 	defer func() {
 		if conn != nil {
@@ -498,6 +1270,7 @@ func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
 	// 使用 context 控制会话创建的超时
 	sessionCh := make(chan *ssh.Session, 1)
 	sessionErrCh := make(chan error, 1)
+	sessionStart := time.Now()
 	go func() {
 		session, err := conn.NewSession()
 		if err != nil {
@@ -521,19 +1294,28 @@ func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
 		// 会话创建成功
 	case err := <-sessionErrCh:
 		return &PingResult{
-			Host:     c.host,
-			Success:  false,
-			Duration: duration,
-			Error:    fmt.Errorf("创建会话失败: %w", err),
+			Host:              c.host,
+			Success:           false,
+			Duration:          duration,
+			Error:             &ConnectionError{Host: c.host, Err: fmt.Errorf("创建会话失败: %w", err)},
+			Phase:             PingPhaseSession,
+			TCPDuration:       tcpDuration,
+			HandshakeDuration: handshakeDuration,
+			SessionDuration:   time.Since(sessionStart),
 		}, err
 	case <-ctx.Done():
 		return &PingResult{
-			Host:     c.host,
-			Success:  false,
-			Duration: duration,
-			Error:    fmt.Errorf("创建会话超时（超过 %v）", timeout),
+			Host:              c.host,
+			Success:           false,
+			Duration:          duration,
+			Error:             &TimeoutError{Host: c.host, Err: fmt.Errorf("创建会话超过 %v", timeout)},
+			Phase:             PingPhaseSession,
+			TCPDuration:       tcpDuration,
+			HandshakeDuration: handshakeDuration,
+			SessionDuration:   time.Since(sessionStart),
 		}, ctx.Err()
 	}
+	sessionDuration := time.Since(sessionStart)
 
 	// 确保 session 总是被关闭，使用 defer 确保即使发生 panic 也能关闭
 	defer func() {
@@ -542,20 +1324,58 @@ func (c *Client) PingWithTimeout(timeout time.Duration) (*PingResult, error) {
 		}
 	}()
 
-	return &PingResult{
-		Host:     c.host,
-		Success:  true,
-		Duration: duration,
-		Error:    nil,
-	}, nil
+	result := &PingResult{
+		Host:              c.host,
+		Success:           true,
+		Duration:          duration,
+		Error:             nil,
+		TCPDuration:       tcpDuration,
+		HandshakeDuration: handshakeDuration,
+		SessionDuration:   sessionDuration,
+	}
+
+	if opts.CheckBecome {
+		result.BecomeChecked = true
+		becomeCh := make(chan error, 1)
+		go func() {
+			becomeCh <- c.checkBecome(conn, opts.Become)
+		}()
+		select {
+		case result.BecomeError = <-becomeCh:
+		case <-ctx.Done():
+			// 关闭连接（defer 中）会让仍在执行的检查结束
+			result.BecomeError = &TimeoutError{Host: c.host, Err: fmt.Errorf("检查 sudo 超过 %v", timeout)}
+		}
+		logger.Trace("ping: sudo 检查完成", "host", c.host, "error", result.BecomeError)
+	}
+
+	return result, nil
 }
 
+// PingPhase ping 测试失败时所处的阶段
+type PingPhase string
+
+const (
+	PingPhaseTCP       PingPhase = "tcp"       // TCP 连接阶段（端口关闭、被防火墙拦截或主机不可达）
+	PingPhaseHandshake PingPhase = "handshake" // SSH 握手与认证阶段
+	PingPhaseSession   PingPhase = "session"   // 创建会话阶段
+)
+
 // PingResult ping 测试结果
 type PingResult struct {
 	Host     string
 	Success  bool
 	Duration time.Duration
 	Error    error
+	Phase    PingPhase // 失败时所处的阶段，成功时为空
+
+	// 各阶段耗时，未到达的阶段为 0
+	TCPDuration       time.Duration // TCP 连接耗时（网络延迟）
+	HandshakeDuration time.Duration // SSH 握手与认证耗时（密钥交换、认证）
+	SessionDuration   time.Duration // 创建会话耗时（服务器负载）
+
+	BecomeChecked bool  // 是否检查了 sudo（PingOptions.CheckBecome 且连接成功）
+	BecomeError   error // sudo 检查失败的原因，检查通过时为 nil
 }
 
 // Result 执行结果
@@ -567,10 +1387,87 @@ type Result struct {
 	ExitCode int
 	Duration time.Duration
 	Error    error
+	Skipped  bool     // 是否被跳过（例如上传时文件已存在），跳过不计入失败
+	Warnings []string // gossh 内部产生的警告（如清理临时文件失败），与远程命令的 Stderr 分开记录
+
+	BytesTransferred int64 // 上传成功时传输的字节数（本地文件大小），其他情况为 0
+
+	ResolvedCommand string // 实际发送到远程主机的命令（经过 sudo、登录 shell 等包装），只在 ExecOptions.PrintCommand 时记录
+
+	Index int // 主机在主机列表中的序号（从 1 开始），由执行器设置，0 表示未设置
+
+	ConnectDuration time.Duration // 建立连接（TCP 连接、SSH 握手和认证）和创建会话的耗时，只在 ExecuteWithOptions 中记录
+	ExecDuration    time.Duration // 命令从启动到结束的耗时，只在 ExecuteWithOptions 中记录
+
+	Compliance string // 输出合规检查结果（--expect、--expect-file）: ComplianceCompliant 或 ComplianceNonCompliant，未检查时为空
+}
+
+// 输出合规检查结果
+const (
+	ComplianceCompliant    = "compliant"     // 命令执行成功且输出符合预期
+	ComplianceNonCompliant = "non-compliant" // 输出不符合预期或命令执行失败
+)
+
+// AddWarning 追加一条 gossh 内部警告
+func (r *Result) AddWarning(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// warnedKeys 记录已经打印过加载失败警告的私钥路径，避免每台主机重复警告
+var warnedKeys sync.Map
+
+// loadPrivateKeys 加载逗号分隔的多个私钥（类似 OpenSSH 的多个 IdentityFile）
+// 加载失败的私钥只打印警告并跳过，至少有一个私钥加载成功即可；全部失败时返回第一个错误
+func loadPrivateKeys(keyPaths string) ([]ssh.Signer, error) {
+	paths := SplitKeyPaths(keyPaths)
+	if len(paths) == 1 {
+		signer, err := loadPrivateKey(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}
+
+	var signers []ssh.Signer
+	var firstErr error
+	for _, p := range paths {
+		signer, err := loadPrivateKey(p)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if _, warned := warnedKeys.LoadOrStore(p, true); !warned {
+				fmt.Fprintf(os.Stderr, "警告: 跳过无法加载的私钥 %s: %v\n", p, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(signers) == 0 {
+		if firstErr == nil {
+			return nil, fmt.Errorf("私钥列表为空")
+		}
+		return nil, fmt.Errorf("所有私钥都无法加载: %w", firstErr)
+	}
+	return signers, nil
+}
+
+// SplitKeyPaths 拆分逗号分隔的私钥路径列表，去除空白和空项
+func SplitKeyPaths(keyPaths string) []string {
+	var paths []string
+	for _, p := range strings.Split(keyPaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
 }
 
 // loadPrivateKey 加载私钥文件
+// keyPath 中的 ~ 和环境变量会先被展开
 func loadPrivateKey(keyPath string) (ssh.Signer, error) {
+	keyPath = ExpandPath(keyPath)
 	key, err := os.ReadFile(keyPath)
 	if err != nil {
 		return nil, err
@@ -582,5 +1479,36 @@ func loadPrivateKey(keyPath string) (ssh.Signer, error) {
 		return nil, fmt.Errorf("解析私钥失败，可能需要密码: %w", err)
 	}
 
-	return signer, nil
+	return loadCertSigner(keyPath, signer)
+}
+
+// loadCertSigner 加载与私钥配套的 OpenSSH 证书（<keyPath>-cert.pub，与 ssh 客户端的约定一致）
+// 证书存在时返回基于证书的 signer，用于 CA 签发证书的认证方式；证书不存在时原样返回私钥 signer
+func loadCertSigner(keyPath string, signer ssh.Signer) (ssh.Signer, error) {
+	certPath := keyPath + "-cert.pub"
+	certBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("读取证书 %s 失败: %w", certPath, err)
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析证书 %s 失败: %w", certPath, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是 OpenSSH 证书", certPath)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("证书 %s 与私钥不匹配: %w", certPath, err)
+	}
+	slog.Debug("使用 OpenSSH 证书", "cert", certPath, "key_id", cert.KeyId, "principals", cert.ValidPrincipals)
+
+	return certSigner, nil
 }
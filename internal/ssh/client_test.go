@@ -0,0 +1,268 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opts    ExecOptions
+		want    string
+	}{
+		{
+			name:    "不使用 become",
+			command: "uptime",
+			want:    "uptime",
+		},
+		{
+			name:    "become 用户为空",
+			command: "whoami",
+			opts:    ExecOptions{Become: true},
+			want:    "sudo -n sh -c 'whoami'",
+		},
+		{
+			name:    "become 用户为 root",
+			command: "whoami",
+			opts:    ExecOptions{Become: true, BecomeUser: "root"},
+			want:    "sudo -n sh -c 'whoami'",
+		},
+		{
+			name:    "become 非 root 用户",
+			command: "whoami",
+			opts:    ExecOptions{Become: true, BecomeUser: "app"},
+			want:    "sudo -n -u app sh -c 'whoami'",
+		},
+		{
+			name:    "become 数字 UID",
+			command: "whoami",
+			opts:    ExecOptions{Become: true, BecomeUser: "#1001"},
+			want:    "sudo -n -u '#1001' sh -c 'whoami'",
+		},
+		{
+			name:    "多条命令包含单引号",
+			command: "echo 'hello world' && id -u",
+			opts:    ExecOptions{Become: true},
+			want:    `sudo -n sh -c 'echo '\''hello world'\'' && id -u'`,
+		},
+		{
+			name:    "提供 sudo 密码时不加 -n",
+			command: "whoami",
+			opts:    ExecOptions{Become: true, BecomeUser: "app", BecomePassword: "secret", SudoFlags: "-S"},
+			want:    "sudo -S -u app sh -c 'whoami'",
+		},
+		{
+			name:    "sudo 参数中已有 -n",
+			command: "whoami",
+			opts:    ExecOptions{Become: true, SudoFlags: "-H -n"},
+			want:    "sudo -H -n sh -c 'whoami'",
+		},
+		{
+			name:    "登录 shell",
+			command: "echo 'a b'",
+			opts:    ExecOptions{Become: true, BecomeUser: "app", LoginShell: true},
+			want:    `sudo -n -u app bash -lc 'echo '\''a b'\'''`,
+		},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.buildCommand(tt.command, tt.opts); got != tt.want {
+				t.Errorf("buildCommand(%q) = %q，期望 %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSudoUserArg(t *testing.T) {
+	tests := []struct {
+		user string
+		want string
+	}{
+		{"app", "app"},
+		{"root", "root"},
+		{"1001", "'#1001'"},
+		{"#1001", "'#1001'"},
+		{"app1001", "app1001"},
+	}
+
+	for _, tt := range tests {
+		if got := sudoUserArg(tt.user); got != tt.want {
+			t.Errorf("sudoUserArg(%q) = %q，期望 %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestIsRootBecomeUser(t *testing.T) {
+	for _, user := range []string{"", "root", "0", "#0"} {
+		if !isRootBecomeUser(user) {
+			t.Errorf("isRootBecomeUser(%q) = false，期望 true", user)
+		}
+	}
+	for _, user := range []string{"app", "1001", "#1001", "rootless"} {
+		if isRootBecomeUser(user) {
+			t.Errorf("isRootBecomeUser(%q) = true，期望 false", user)
+		}
+	}
+}
+
+// newTestSession 在本地回环地址上启动只接受一个连接的 SSH 服务端并建立会话，服务端收到 exec 请求后调用 handle，
+// handle 负责发送退出状态并关闭通道（或直接关闭连接）
+func newTestSession(t *testing.T, handle func(ch ssh.Channel, conn net.Conn)) (*ssh.Client, *ssh.Session) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		serverSide, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(serverSide, serverConfig)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			ch, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for req := range requests {
+					req.Reply(req.Type == "exec", nil)
+					if req.Type == "exec" {
+						handle(ch, serverSide)
+					}
+				}
+			}()
+		}
+	}()
+
+	clientSide, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(clientSide, "test", &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	t.Cleanup(func() { client.Close() })
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Start("test"); err != nil {
+		t.Fatal(err)
+	}
+	return client, session
+}
+
+func TestWaitForCommand(t *testing.T) {
+	exitStatus := func(code uint32) func(ssh.Channel, net.Conn) {
+		return func(ch ssh.Channel, _ net.Conn) {
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{code}))
+			ch.Close()
+		}
+	}
+	exitSignal := func(signal string) func(ssh.Channel, net.Conn) {
+		return func(ch ssh.Channel, _ net.Conn) {
+			ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+				Signal     string
+				CoreDumped bool
+				Error      string
+				Lang       string
+			}{Signal: signal}))
+			ch.Close()
+		}
+	}
+
+	tests := []struct {
+		name       string
+		handle     func(ssh.Channel, net.Conn)
+		wantCode   int
+		wantSignal string
+		wantErr    error
+	}{
+		{name: "正常结束", handle: exitStatus(0), wantCode: 0},
+		{name: "非零退出码", handle: exitStatus(3), wantCode: 3},
+		{name: "被 KILL 信号终止", handle: exitSignal("KILL"), wantCode: 137, wantSignal: "KILL"},
+		{name: "被 TERM 信号终止", handle: exitSignal("TERM"), wantCode: 143, wantSignal: "TERM"},
+		{name: "被 XCPU 信号终止", handle: exitSignal("XCPU"), wantCode: 152, wantSignal: "XCPU"},
+		{
+			name:     "没有返回退出码",
+			handle:   func(ch ssh.Channel, _ net.Conn) { ch.Close() },
+			wantCode: -1,
+			wantErr:  errExitStatusMissing,
+		},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, session := newTestSession(t, tt.handle)
+			code, signal, err := c.waitForCommand(session)
+			if code != tt.wantCode || signal != tt.wantSignal || !errors.Is(err, tt.wantErr) {
+				t.Errorf("waitForCommand() = (%d, %q, %v)，期望 (%d, %q, %v)", code, signal, err, tt.wantCode, tt.wantSignal, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWaitForCommandConnectionDropped(t *testing.T) {
+	client, session := newTestSession(t, func(_ ssh.Channel, conn net.Conn) {
+		conn.Close()
+	})
+
+	code, signal, err := (&Client{}).waitForCommand(session)
+	if code != -1 || signal != "" || !errors.Is(err, errExitStatusMissing) {
+		t.Fatalf("waitForCommand() = (%d, %q, %v)，期望 (-1, \"\", %v)", code, signal, err, errExitStatusMissing)
+	}
+	if err := droppedError(client, err); !errors.Is(err, ErrConnectionDropped) {
+		t.Errorf("droppedError() = %v，期望 %v", err, ErrConnectionDropped)
+	}
+}
+
+func TestCommandExitStatus(t *testing.T) {
+	code, signal, err := commandExitStatus(&ssh.ExitMissingError{})
+	if code != -1 || signal != "" || !errors.Is(err, errExitStatusMissing) {
+		t.Errorf("commandExitStatus(ExitMissingError) = (%d, %q, %v)，期望 (-1, \"\", %v)", code, signal, err, errExitStatusMissing)
+	}
+
+	other := errors.New("read: connection reset by peer")
+	code, _, err = commandExitStatus(other)
+	if code != -1 || !errors.Is(err, other) {
+		t.Errorf("commandExitStatus(%v) = (%d, %v)，期望 (-1, 包含原错误)", other, code, err)
+	}
+
+	if code, signal, err := commandExitStatus(nil); code != 0 || signal != "" || err != nil {
+		t.Errorf("commandExitStatus(nil) = (%d, %q, %v)，期望 (0, \"\", nil)", code, signal, err)
+	}
+}
@@ -0,0 +1,102 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrRemoteFileNotFound 远程文件不存在
+var ErrRemoteFileNotFound = errors.New("远程文件不存在")
+
+// DiffFile 对比本地文件与远程文件的差异
+// 本地文件内容通过标准输入发送到远程主机，由远程的 diff -u 计算统一格式差异
+// 返回结果中 Stdout 为差异内容（内容相同时为空），远程文件不存在时 Error 为 ErrRemoteFileNotFound
+func (c *Client) DiffFile(localPath string, remotePath string) (*Result, error) {
+	startTime := time.Now()
+	command := fmt.Sprintf("diff %s <-> %s", localPath, remotePath)
+
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "读取本地文件失败"), err
+	}
+
+	conn, err := c.createSSHConnection()
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "连接失败"), err
+	}
+	defer c.releaseConnection(conn)
+
+	fileExists, err := c.checkFileExists(conn, remotePath, ExecOptions{})
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "检查远程文件失败"), err
+	}
+	if !fileExists {
+		return &Result{
+			Host:     c.host,
+			Command:  command,
+			Stdout:   "",
+			Stderr:   fmt.Sprintf("远程文件不存在: %s", remotePath),
+			ExitCode: 1,
+			Duration: time.Since(startTime),
+			Error:    ErrRemoteFileNotFound,
+		}, nil
+	}
+
+	stdout, stderr, exitCode, err := c.runRemoteDiff(conn, content, localPath, remotePath)
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "对比文件失败"), err
+	}
+
+	// diff 退出码：0 表示相同，1 表示存在差异，其他表示出错
+	if exitCode > 1 {
+		return &Result{
+			Host:     c.host,
+			Command:  command,
+			Stdout:   stdout,
+			Stderr:   stderr,
+			ExitCode: exitCode,
+			Duration: time.Since(startTime),
+			Error:    fmt.Errorf("diff 执行失败（退出码: %d）", exitCode),
+		}, nil
+	}
+
+	return &Result{
+		Host:     c.host,
+		Command:  command,
+		Stdout:   stdout,
+		Stderr:   stderr,
+		ExitCode: 0,
+		Duration: time.Since(startTime),
+		Error:    nil,
+	}, nil
+}
+
+// runRemoteDiff 在远程主机执行 diff -u，本地内容通过标准输入传入
+func (c *Client) runRemoteDiff(conn *ssh.Client, content []byte, localPath, remotePath string) (string, string, int, error) {
+	session, err := c.createSession(conn)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = bytes.NewReader(content)
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	// 使用引号包裹路径以防止特殊字符问题，-L 指定差异头部显示的文件标签
+	command := fmt.Sprintf("diff -u -L %q -L %q - %q", localPath, remotePath, remotePath)
+	if err := session.Run(command); err != nil {
+		if exitError, ok := err.(*ssh.ExitError); ok {
+			return stdout.String(), stderr.String(), exitError.ExitStatus(), nil
+		}
+		return "", "", 0, fmt.Errorf("执行 diff 失败: %w", err)
+	}
+
+	return stdout.String(), stderr.String(), 0, nil
+}
@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envNamePattern 环境变量名称：字母或下划线开头，只包含字母、数字和下划线
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName 判断是否为有效的环境变量名称
+func ValidEnvName(name string) bool {
+	return envNamePattern.MatchString(name)
+}
+
+// ParseEnv 解析 --env 参数（KEY=VALUE，可以多次指定），同名变量后指定的覆盖之前的，没有参数时返回 nil
+func ParseEnv(entries []string) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的 --env %q，格式应为 KEY=VALUE", entry)
+		}
+		if !ValidEnvName(name) {
+			return nil, fmt.Errorf("无效的 --env %q: 环境变量名称只能包含字母、数字和下划线，且不能以数字开头", entry)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// MergeEnv 合并全局（--env）和主机级（gossh_env_*）的环境变量，同名时主机级的优先；都为空时返回 nil
+func MergeEnv(global, host map[string]string) map[string]string {
+	if len(host) == 0 {
+		return global
+	}
+	if len(global) == 0 {
+		return host
+	}
+	merged := make(map[string]string, len(global)+len(host))
+	for name, value := range global {
+		merged[name] = value
+	}
+	for name, value := range host {
+		merged[name] = value
+	}
+	return merged
+}
+
+// envPrefix 生成设置环境变量的命令前缀（export A='1' B='2'; ），按名称排序，保证同样的变量生成同样的命令
+func envPrefix(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = name + "=" + shellQuote(env[name])
+	}
+	return "export " + strings.Join(assignments, " ") + "; "
+}
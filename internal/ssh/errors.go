@@ -0,0 +1,192 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ConnectionError 连接错误（如端口不可达、连接被拒绝、握手失败）
+type ConnectionError struct {
+	Host string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("连接失败: %v", e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// AuthError 认证错误（如密钥或密码不正确、没有可用的认证方式）
+type AuthError struct {
+	Host string
+	Err  error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("认证失败: %v", e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError 超时错误（如连接超时、创建会话超时）
+type TimeoutError struct {
+	Host string
+	Err  error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("连接超时: %v", e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// HostKeyError 主机密钥校验失败（主机不在 known_hosts 中、密钥与 known_hosts 中记录的不一致，或密钥已被 @revoked 吊销）
+type HostKeyError struct {
+	Host string
+	Err  error
+}
+
+func (e *HostKeyError) Error() string {
+	var keyErr *knownhosts.KeyError
+	if errors.As(e.Err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return "主机密钥校验失败: 主机不在 known_hosts 中"
+		}
+		return fmt.Sprintf("主机密钥校验失败: 密钥与 known_hosts 中的记录不一致（%s:%d）", keyErr.Want[0].Filename, keyErr.Want[0].Line)
+	}
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(e.Err, &revokedErr) {
+		return fmt.Sprintf("主机密钥校验失败: 密钥已被吊销（%s:%d）", revokedErr.Revoked.Filename, revokedErr.Revoked.Line)
+	}
+	return fmt.Sprintf("主机密钥校验失败: %v", e.Err)
+}
+
+func (e *HostKeyError) Unwrap() error {
+	return e.Err
+}
+
+// ExecError 执行错误（连接成功后创建会话、启动命令等阶段失败）
+// 注意：命令本身以非零退出码结束不属于执行错误，通过 Result.ExitCode 体现
+type ExecError struct {
+	Host string
+	Err  error
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("执行失败: %v", e.Err)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// OperationTimeoutError 单台主机的操作超过 --timeout 指定的总时间
+type OperationTimeoutError struct {
+	Host    string
+	Timeout time.Duration
+}
+
+func (e *OperationTimeoutError) Error() string {
+	return fmt.Sprintf("操作超时: 超过 %v", e.Timeout)
+}
+
+// DeadlineExceededError 整批执行超过 --deadline 指定的总时间，主机被取消
+// Started 为 false 表示主机还没有开始执行（等待并发槽位时被取消），为 true 表示执行中被中断
+type DeadlineExceededError struct {
+	Host    string
+	Started bool
+}
+
+func (e *DeadlineExceededError) Error() string {
+	if e.Started {
+		return "超过总时限: 整批执行超过 --deadline，执行中被中断"
+	}
+	return "超过总时限: 整批执行超过 --deadline，未开始执行"
+}
+
+// classifyDialError 根据建立 SSH 连接时的错误返回对应的错误类型
+func classifyDialError(host string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &TimeoutError{Host: host, Err: err}
+	}
+
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(err, &keyErr) || errors.As(err, &revokedErr) {
+		return &HostKeyError{Host: host, Err: err}
+	}
+
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return &AuthError{Host: host, Err: err}
+	}
+
+	return &ConnectionError{Host: host, Err: err}
+}
+
+// ErrorCategory 返回错误的分类名称（超过时限、认证失败、主机密钥错误、连接超时、连接失败、连接断开、执行失败、执行超时、解释器不存在、需要 sudo 密码、提权失败）
+// 无法识别的错误返回空字符串
+func ErrorCategory(err error) string {
+	var authErr *AuthError
+	var timeoutErr *TimeoutError
+	var connErr *ConnectionError
+	var execErr *ExecError
+	var opTimeoutErr *OperationTimeoutError
+	var hostKeyErr *HostKeyError
+	var deadlineErr *DeadlineExceededError
+
+	switch {
+	case errors.As(err, &deadlineErr):
+		return "超过时限"
+	case errors.As(err, &opTimeoutErr):
+		return "执行超时"
+	case errors.As(err, &authErr):
+		return "认证失败"
+	case errors.As(err, &hostKeyErr):
+		return "主机密钥错误"
+	case errors.As(err, &timeoutErr):
+		return "连接超时"
+	case errors.As(err, &connErr):
+		return "连接失败"
+	case errors.Is(err, ErrConnectionDropped):
+		return "连接断开"
+	case errors.As(err, &execErr):
+		return "执行失败"
+	case errors.Is(err, ErrInterpreterNotFound):
+		return "解释器不存在"
+	case errors.Is(err, ErrSudoPasswordRequired):
+		return "需要 sudo 密码"
+	case errors.Is(err, ErrBecomeNotEffective):
+		return "提权失败"
+	}
+	return ""
+}
+
+// IsUnreachable 判断错误是否表示主机不可达（连接失败、连接超时、认证失败或主机密钥错误），即命令没有在主机上执行
+// 用于在结果摘要中区分需要修复连通性的主机和命令执行失败的主机
+func IsUnreachable(err error) bool {
+	var authErr *AuthError
+	var timeoutErr *TimeoutError
+	var connErr *ConnectionError
+	var hostKeyErr *HostKeyError
+	var opTimeoutErr *OperationTimeoutError
+	var deadlineErr *DeadlineExceededError
+
+	if errors.As(err, &opTimeoutErr) || errors.As(err, &deadlineErr) {
+		return false
+	}
+	return errors.As(err, &authErr) || errors.As(err, &hostKeyErr) ||
+		errors.As(err, &timeoutErr) || errors.As(err, &connErr)
+}
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// FetchFile 从远程主机下载文件到本地
+func (c *Client) FetchFile(remotePath string, localPath string) (*Result, error) {
+	return c.FetchFileWithOptions(remotePath, localPath, ExecOptions{})
+}
+
+// FetchFileWithOptions 从远程主机下载文件到本地，支持 become 模式（以 sudo 读取远程文件）
+// 远程文件内容通过 cat 写到会话的标准输出，先写入本地临时文件，完成后再重命名为 localPath，
+// 避免下载中断时留下不完整的文件。返回结果中 Stdout 为本地文件路径；
+// 远程文件不存在时 Error 为 ErrRemoteFileNotFound，没有读取权限时 Error 为 ErrRemotePermissionDenied。
+// opts 中的 LoginShell 对下载无效
+func (c *Client) FetchFileWithOptions(remotePath string, localPath string, opts ExecOptions) (*Result, error) {
+	startTime := time.Now()
+	opts.LoginShell = false
+	command := fmt.Sprintf("fetch %s -> %s", remotePath, localPath)
+
+	conn, err := c.createSSHConnection()
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "连接失败"), err
+	}
+	defer c.releaseConnection(conn)
+
+	tempPath := fmt.Sprintf("%s.part", localPath)
+	localFile, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return c.createErrorResult(command, startTime, err, "创建本地文件失败"), err
+	}
+
+	written, stderr, exitCode, err := c.runRemoteFetch(conn, localFile, remotePath, opts)
+	closeErr := localFile.Close()
+	if err == nil && closeErr != nil {
+		err = fmt.Errorf("写入本地文件失败: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return c.createErrorResult(command, startTime, err, "下载文件失败"), err
+	}
+
+	result := &Result{
+		Host:     c.host,
+		Command:  command,
+		Stderr:   stderr,
+		ExitCode: exitCode,
+		Duration: time.Since(startTime),
+	}
+
+	switch {
+	case exitCode == checksumExitNotFound:
+		result.Stderr = fmt.Sprintf("远程文件不存在: %s", remotePath)
+		result.Error = ErrRemoteFileNotFound
+	case exitCode == checksumExitForbidden,
+		exitCode != 0 && strings.Contains(stderr, "Permission denied"):
+		result.Stderr = fmt.Sprintf("无权限读取远程文件: %s", remotePath)
+		result.Error = ErrRemotePermissionDenied
+	case exitCode == checksumExitIsDir:
+		result.Error = fmt.Errorf("远程路径是目录: %s", remotePath)
+	case exitCode != 0:
+		result.Error = fmt.Errorf("读取远程文件失败（退出码: %d）", exitCode)
+	}
+	if result.Error != nil {
+		os.Remove(tempPath)
+		return result, nil
+	}
+
+	if err := os.Rename(tempPath, localPath); err != nil {
+		os.Remove(tempPath)
+		return c.createErrorResult(command, startTime, err, "保存本地文件失败"), err
+	}
+
+	result.Stdout = localPath
+	result.BytesTransferred = written
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// runRemoteFetch 在远程主机检查文件状态并把文件内容写到 w
+// 使用与 checksum 相同的退出码约定区分文件不存在、是目录和不可读
+func (c *Client) runRemoteFetch(conn *ssh.Client, w io.Writer, remotePath string, opts ExecOptions) (int64, string, int, error) {
+	session, err := c.createSession(conn)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer session.Close()
+
+	counter := &countingWriter{w: w}
+	var stderr bytes.Buffer
+	session.Stdout = counter
+	session.Stderr = &stderr
+
+	script := fmt.Sprintf(
+		`p=%s; [ -e "$p" ] || exit %d; [ -d "$p" ] && exit %d; [ -r "$p" ] || exit %d; cat < "$p"`,
+		shellQuote(remotePath), checksumExitNotFound, checksumExitIsDir, checksumExitForbidden,
+	)
+	command := c.buildCommand(fmt.Sprintf("sh -c %s", shellQuote(script)), opts)
+	if err := session.Run(command); err != nil {
+		if exitError, ok := err.(*ssh.ExitError); ok {
+			return counter.n, stderr.String(), exitError.ExitStatus(), nil
+		}
+		return 0, "", 0, fmt.Errorf("读取远程文件失败: %w", err)
+	}
+
+	return counter.n, stderr.String(), 0, nil
+}
+
+// countingWriter 统计写入字节数的 Writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// FetchLocalName 生成下载到本地的文件名: <host>-<basename>-<timestamp>
+// 非默认端口时主机部分为 host_port，避免同一地址的多个实例互相覆盖；
+// 主机名中的 : 和 / 等不适合作为文件名的字符替换为 _
+func FetchLocalName(host, port, remotePath, timestamp string) string {
+	hostPart := host
+	if port != "" && port != "22" {
+		hostPart = fmt.Sprintf("%s_%s", host, port)
+	}
+	hostPart = strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '/', '\\', '[', ']', '%':
+			return '_'
+		}
+		return r
+	}, hostPart)
+
+	return fmt.Sprintf("%s-%s-%s", hostPart, filepath.Base(remotePath), timestamp)
+}
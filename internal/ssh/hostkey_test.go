@@ -0,0 +1,132 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestSigner 生成一个 ed25519 密钥
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newTestHostKeyChecker 将 lines 写入临时目录中的 known_hosts 文件并加载
+func newTestHostKeyChecker(t *testing.T, lines ...string) *HostKeyChecker {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewHostKeyChecker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checker
+}
+
+// newTestHostCert 用 ca 为 hostKey 签发主机证书
+func newTestHostCert(t *testing.T, hostKey ssh.PublicKey, ca ssh.Signer, principals ...string) *ssh.Certificate {
+	t.Helper()
+	cert := &ssh.Certificate{
+		Key:             hostKey,
+		CertType:        ssh.HostCert,
+		ValidPrincipals: principals,
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestHostKeyCheckerHashedHostname(t *testing.T) {
+	hostKey := newTestSigner(t).PublicKey()
+	checker := newTestHostKeyChecker(t, knownhosts.HashHostname("10.0.0.1")+" "+authorizedKey(hostKey))
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	if err := checker.callback("10.0.0.1:22", remote, hostKey); err != nil {
+		t.Errorf("哈希过的主机名应校验通过，实际错误: %v", err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	otherKey := newTestSigner(t).PublicKey()
+	if err := checker.callback("10.0.0.1:22", remote, otherKey); !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		t.Errorf("密钥不一致时应返回带有 Want 的 KeyError，实际错误: %v", err)
+	}
+
+	otherRemote := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 22}
+	if err := checker.callback("10.0.0.2:22", otherRemote, hostKey); !errors.As(err, &keyErr) || len(keyErr.Want) != 0 {
+		t.Errorf("不在 known_hosts 中的主机应返回没有 Want 的 KeyError，实际错误: %v", err)
+	}
+}
+
+func TestHostKeyCheckerCertAuthority(t *testing.T) {
+	ca := newTestSigner(t)
+	hostKey := newTestSigner(t).PublicKey()
+	checker := newTestHostKeyChecker(t, "@cert-authority *.example.com "+authorizedKey(ca.PublicKey()))
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	cert := newTestHostCert(t, hostKey, ca, "web1.example.com")
+	if err := checker.callback("web1.example.com:22", remote, cert); err != nil {
+		t.Errorf("受信任的 CA 签发的主机证书应校验通过，实际错误: %v", err)
+	}
+
+	untrusted := newTestHostCert(t, hostKey, newTestSigner(t), "web1.example.com")
+	if err := checker.callback("web1.example.com:22", remote, untrusted); err == nil {
+		t.Error("不受信任的 CA 签发的主机证书应校验失败")
+	}
+
+	if err := checker.callback("web1.example.com:22", remote, hostKey); err == nil {
+		t.Error("@cert-authority 不应信任没有证书的主机公钥")
+	}
+}
+
+func TestHostKeyCheckerCertificateFallback(t *testing.T) {
+	hostKey := newTestSigner(t).PublicKey()
+	checker := newTestHostKeyChecker(t, "web1.example.com "+authorizedKey(hostKey))
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	// 没有对应的 @cert-authority 时改用证书中的公钥与普通条目比较
+	cert := newTestHostCert(t, hostKey, newTestSigner(t), "web1.example.com")
+	if err := checker.callback("web1.example.com:22", remote, cert); err != nil {
+		t.Errorf("证书中的公钥在 known_hosts 中时应校验通过，实际错误: %v", err)
+	}
+}
+
+func TestHostKeyCheckerRevokedCA(t *testing.T) {
+	ca := newTestSigner(t)
+	hostKey := newTestSigner(t).PublicKey()
+	checker := newTestHostKeyChecker(t,
+		"@cert-authority *.example.com "+authorizedKey(ca.PublicKey()),
+		"@revoked * "+authorizedKey(ca.PublicKey()),
+	)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	var revokedErr *knownhosts.RevokedError
+	cert := newTestHostCert(t, hostKey, ca, "web1.example.com")
+	if err := checker.callback("web1.example.com:22", remote, cert); !errors.As(err, &revokedErr) {
+		t.Errorf("签发证书的 CA 被吊销时应返回 RevokedError，实际错误: %v", err)
+	}
+}
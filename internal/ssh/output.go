@@ -0,0 +1,32 @@
+package ssh
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// binaryControlRatio 控制字符占比超过该值时视为二进制输出
+const binaryControlRatio = 0.1
+
+// IsBinaryOutput 判断命令输出是否为二进制或非 UTF-8 内容（直接打印会破坏终端显示）
+// 满足以下任一条件即视为二进制：不是合法的 UTF-8、包含 NUL 字节、
+// 控制字符（不含 \t、\n、\r 和 ANSI 颜色序列使用的 ESC）占比超过 10%
+func IsBinaryOutput(s string) bool {
+	if s == "" {
+		return false
+	}
+	if !utf8.ValidString(s) || strings.IndexByte(s, 0) != -1 {
+		return true
+	}
+
+	runes, controls := 0, 0
+	for _, r := range s {
+		runes++
+		switch {
+		case r == '\t' || r == '\n' || r == '\r' || r == 0x1b:
+		case r < 0x20 || r == 0x7f:
+			controls++
+		}
+	}
+	return float64(controls) > float64(runes)*binaryControlRatio
+}
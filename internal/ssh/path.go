@@ -0,0 +1,40 @@
+package ssh
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath 展开路径中的 ~ 和环境变量
+// 支持 ~、~/path、~user/path 以及 $HOME、${HOME} 等环境变量；无法解析的 ~user 保持原样
+func ExpandPath(path string) string {
+	if path == "" {
+		return path
+	}
+
+	path = os.ExpandEnv(path)
+
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+
+	name, rest, _ := strings.Cut(path[1:], "/")
+	var home string
+	if name == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		home = dir
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return path
+		}
+		home = u.HomeDir
+	}
+
+	return filepath.Join(home, rest)
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GOSSH_TEST_DIR", "/srv/app")
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("无法获取当前用户: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"~", home},
+		{"~/", home},
+		{"~/x", filepath.Join(home, "x")},
+		{"~/.ssh/id_ed25519", filepath.Join(home, ".ssh/id_ed25519")},
+		{"~" + current.Username + "/x", filepath.Join(current.HomeDir, "x")},
+		{"~gossh-no-such-user/x", "~gossh-no-such-user/x"},
+		{"$HOME/x", filepath.Join(home, "x")},
+		{"${HOME}/.ssh/known_hosts", filepath.Join(home, ".ssh/known_hosts")},
+		{"$GOSSH_TEST_DIR/keys", "/srv/app/keys"},
+		{"/etc/ssh/ssh_host_ed25519_key", "/etc/ssh/ssh_host_ed25519_key"},
+		{"keys/~x", "keys/~x"},
+	}
+
+	for _, tt := range tests {
+		if got := ExpandPath(tt.path); got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q，期望 %q", tt.path, got, tt.want)
+		}
+	}
+}
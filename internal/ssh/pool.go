@@ -0,0 +1,113 @@
+package ssh
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultPoolIdleTTL 连接池中空闲连接的默认保留时间
+const DefaultPoolIdleTTL = 60 * time.Second
+
+// pooledConn 连接池中的空闲连接
+type pooledConn struct {
+	conn       *ssh.Client
+	releasedAt time.Time
+}
+
+// ConnectionPool 按主机缓存空闲的 SSH 连接，供多次执行（多个执行器、同一主机的多个步骤）复用
+// 连接使用完后通过 Release 放回，空闲超过 TTL 的连接被关闭；取出时会发送 keepalive 检查连接是否仍然可用。
+// 可以被多个客户端并发使用
+type ConnectionPool struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	idle   map[string][]pooledConn // 主机键 -> 空闲连接（后放回的在后面）
+	closed bool
+}
+
+// NewConnectionPool 创建连接池，ttl <= 0 时使用 DefaultPoolIdleTTL
+func NewConnectionPool(ttl time.Duration) *ConnectionPool {
+	if ttl <= 0 {
+		ttl = DefaultPoolIdleTTL
+	}
+	return &ConnectionPool{
+		ttl:  ttl,
+		idle: make(map[string][]pooledConn),
+	}
+}
+
+// Get 取出主机的一个空闲连接，没有可用连接时返回 nil
+// 优先取最近放回的连接；已经断开的连接（keepalive 失败）直接关闭并丢弃
+func (p *ConnectionPool) Get(host string) *ssh.Client {
+	for {
+		p.mu.Lock()
+		p.evictExpired(time.Now())
+		conns := p.idle[host]
+		if p.closed || len(conns) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		pc := conns[len(conns)-1]
+		p.idle[host] = conns[:len(conns)-1]
+		if len(p.idle[host]) == 0 {
+			delete(p.idle, host)
+		}
+		p.mu.Unlock()
+
+		if _, _, err := pc.conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			slog.Debug("连接池中的连接已断开", "host", host, "error", err)
+			pc.conn.Close()
+			continue
+		}
+		slog.Debug("复用连接池中的连接", "host", host, "idle", time.Since(pc.releasedAt).Round(time.Millisecond))
+		return pc.conn
+	}
+}
+
+// Release 将使用完的连接放回连接池，连接池已关闭时直接关闭连接
+func (p *ConnectionPool) Release(host string, conn *ssh.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		conn.Close()
+		return
+	}
+	now := time.Now()
+	p.evictExpired(now)
+	p.idle[host] = append(p.idle[host], pooledConn{conn: conn, releasedAt: now})
+}
+
+// Close 关闭连接池中的所有空闲连接，之后放回的连接会被直接关闭
+func (p *ConnectionPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for host, conns := range p.idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+		delete(p.idle, host)
+	}
+}
+
+// evictExpired 关闭空闲超过 TTL 的连接，调用方需持有锁
+func (p *ConnectionPool) evictExpired(now time.Time) {
+	for host, conns := range p.idle {
+		kept := conns[:0]
+		for _, pc := range conns {
+			if now.Sub(pc.releasedAt) > p.ttl {
+				pc.conn.Close()
+				continue
+			}
+			kept = append(kept, pc)
+		}
+		if len(kept) == 0 {
+			delete(p.idle, host)
+		} else {
+			p.idle[host] = kept
+		}
+	}
+}
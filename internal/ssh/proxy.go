@@ -0,0 +1,179 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxProxyStderr 保留 ProxyCommand 标准错误输出的最大字节数（用于错误信息）
+	maxProxyStderr = 1024
+
+	// proxyWaitDelay 结束 ProxyCommand 后等待其输出关闭的最长时间
+	// 命令启动的子进程可能继续持有标准错误输出，不等待其退出
+	proxyWaitDelay = 500 * time.Millisecond
+)
+
+// ExpandProxyCommand 替换 ProxyCommand 模板中的占位符（与 OpenSSH 一致）
+// %h: 主机地址，%p: 端口，%r: 用户名，%%: 字面量 %
+func ExpandProxyCommand(template, host, port, user string) string {
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 >= len(template) {
+			b.WriteByte(template[i])
+			continue
+		}
+		i++
+		switch template[i] {
+		case 'h':
+			b.WriteString(host)
+		case 'p':
+			b.WriteString(port)
+		case 'r':
+			b.WriteString(user)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(template[i])
+		}
+	}
+	return b.String()
+}
+
+// proxyConn 以 ProxyCommand 进程的标准输入输出作为 SSH 传输通道
+// 读写使用 os.Pipe，支持 SetDeadline（握手超时依赖截止时间）
+type proxyConn struct {
+	cmd    *exec.Cmd
+	reader *os.File // 进程的标准输出
+	writer *os.File // 进程的标准输入
+	stderr *limitedBuffer
+
+	closeOnce sync.Once
+}
+
+// dialProxyCommand 通过 sh -c 启动 ProxyCommand，返回基于其标准输入输出的连接
+func dialProxyCommand(command string) (*proxyConn, error) {
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建 ProxyCommand 管道失败: %w", err)
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdinReader.Close()
+		stdinWriter.Close()
+		return nil, fmt.Errorf("创建 ProxyCommand 管道失败: %w", err)
+	}
+
+	stderr := &limitedBuffer{limit: maxProxyStderr}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdinReader
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderr
+	cmd.WaitDelay = proxyWaitDelay
+
+	err = cmd.Start()
+	// 子进程持有的一端在父进程中关闭，进程退出后读取才会得到 EOF
+	stdinReader.Close()
+	stdoutWriter.Close()
+	if err != nil {
+		stdinWriter.Close()
+		stdoutReader.Close()
+		return nil, fmt.Errorf("启动 ProxyCommand 失败: %w", err)
+	}
+
+	return &proxyConn{
+		cmd:    cmd,
+		reader: stdoutReader,
+		writer: stdinWriter,
+		stderr: stderr,
+	}, nil
+}
+
+func (p *proxyConn) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+func (p *proxyConn) Write(b []byte) (int, error) {
+	return p.writer.Write(b)
+}
+
+// Close 关闭管道并结束 ProxyCommand 进程
+func (p *proxyConn) Close() error {
+	p.closeOnce.Do(func() {
+		p.writer.Close()
+		p.reader.Close()
+		if p.cmd.Process != nil {
+			p.cmd.Process.Kill()
+		}
+		p.cmd.Wait()
+	})
+	return nil
+}
+
+// Stderr 返回 ProxyCommand 的标准错误输出（最多保留 maxProxyStderr 字节）
+func (p *proxyConn) Stderr() string {
+	return strings.TrimSpace(p.stderr.String())
+}
+
+func (p *proxyConn) LocalAddr() net.Addr {
+	return proxyAddr{}
+}
+
+func (p *proxyConn) RemoteAddr() net.Addr {
+	return proxyAddr{}
+}
+
+func (p *proxyConn) SetDeadline(t time.Time) error {
+	if err := p.reader.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return p.writer.SetWriteDeadline(t)
+}
+
+func (p *proxyConn) SetReadDeadline(t time.Time) error {
+	return p.reader.SetReadDeadline(t)
+}
+
+func (p *proxyConn) SetWriteDeadline(t time.Time) error {
+	return p.writer.SetWriteDeadline(t)
+}
+
+// proxyAddr ProxyCommand 连接的地址（没有真实的网络地址）
+type proxyAddr struct{}
+
+func (proxyAddr) Network() string { return "proxy-command" }
+
+func (proxyAddr) String() string { return "proxy-command" }
+
+// limitedBuffer 只保留前 limit 字节的并发安全缓冲区
+type limitedBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gossh/internal/logger"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrConnectionDropped 命令执行过程中连接断开：会话没有返回退出码就结束，并且连接已经不可用
+var ErrConnectionDropped = errors.New("命令执行过程中连接断开")
+
+// errExitStatusMissing 会话结束时没有返回退出码（服务器可能正常关闭了会话，也可能是连接断开）
+var errExitStatusMissing = errors.New("远程命令结束时没有返回退出码（连接可能已断开）")
+
+const (
+	// maxReconnectOnDrop --reconnect-on-drop 时最多重新连接的次数
+	maxReconnectOnDrop = 3
+	// reconnectDelay 连接断开后等待多久再重新连接
+	reconnectDelay = time.Second
+)
+
+// connectionDropped 会话没有返回退出码就结束时，通过 keepalive 判断连接是否已经断开
+// 服务器正常关闭会话但不发送退出码时（RFC 4254 允许）连接仍然可用，不视为断开
+func connectionDropped(conn *ssh.Client) bool {
+	_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+	return err != nil
+}
+
+// reconnectOnDrop 命令执行过程中连接断开时（ExecOptions.ReconnectOnDrop）重新连接并重新执行命令，最多 maxReconnectOnDrop 次，
+// 适用于幂等的只读命令（如监控、查看日志）。每次执行的输出依次追加到结果中，断开的情况以警告说明；
+// 重新连接失败或次数用完时保留已有的输出，错误仍为 ErrConnectionDropped
+func (c *Client) reconnectOnDrop(command string, opts ExecOptions, result *Result) *Result {
+	for attempt := 1; errors.Is(result.Error, ErrConnectionDropped); attempt++ {
+		if attempt > maxReconnectOnDrop {
+			result.AddWarning("连接已断开 %d 次，不再重新连接", attempt-1)
+			return result
+		}
+		result.AddWarning("第 %d 次执行时连接断开，已保留断开前的输出，重新连接后重新执行命令", attempt)
+		logger.Trace("命令执行过程中连接断开，重新连接", "host", c.host, "attempt", attempt, "delay", reconnectDelay)
+		time.Sleep(reconnectDelay)
+
+		next, err := c.executeOnNewConnection(command, opts)
+		if err != nil {
+			result.AddWarning("重新连接失败: %v", err)
+			return result
+		}
+
+		// 断开前的输出可能停在一行的中间，追加前先换行
+		result.Stdout = appendOutput(result.Stdout, next.Stdout)
+		result.Stderr = appendOutput(result.Stderr, next.Stderr)
+		result.ExitCode = next.ExitCode
+		result.Error = next.Error
+		result.Warnings = append(result.Warnings, next.Warnings...)
+		result.ConnectDuration += next.ConnectDuration
+		result.ExecDuration += next.ExecDuration
+	}
+	return result
+}
+
+// executeOnNewConnection 建立连接（或从连接池取出）后执行命令，become 时按 VerifyBecome 先验证提权
+func (c *Client) executeOnNewConnection(command string, opts ExecOptions) (*Result, error) {
+	startTime := time.Now()
+	conn, err := c.createSSHConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseConnection(conn)
+	dialDuration := time.Since(startTime)
+
+	if opts.Become && opts.VerifyBecome {
+		if err := c.verifyBecome(conn, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := c.executeOnConn(conn, command, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.ConnectDuration += dialDuration
+	return result, nil
+}
+
+// appendOutput 将重新执行的输出追加到已有的输出之后，已有的输出不以换行结尾时补一个换行
+func appendOutput(previous, next string) string {
+	if previous != "" && next != "" && !strings.HasSuffix(previous, "\n") {
+		previous += "\n"
+	}
+	return previous + next
+}
+
+// droppedError 会话没有返回退出码就结束且连接已断开时返回 ErrConnectionDropped，否则原样返回
+func droppedError(conn *ssh.Client, err error) error {
+	if errors.Is(err, errExitStatusMissing) && connectionDropped(conn) {
+		return fmt.Errorf("%w（已保留断开前的输出）", ErrConnectionDropped)
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxParallelSessions 并行执行命令时单个连接上同时打开的最大会话数
+// OpenSSH 服务端默认 MaxSessions 为 10，超过后打开会话会失败
+const maxParallelSessions = 10
+
+// 多条命令合并后的退出码计算方式（--aggregate-exit）
+const (
+	AggregateExitFirstNonzero = "first-nonzero" // 第一条失败命令的退出码，顺序执行时遇到失败即停止（默认）
+	AggregateExitLast         = "last"          // 最后一条执行的命令的退出码，顺序执行时不会因失败停止
+	AggregateExitMax          = "max"           // 所有命令中最大的退出码，顺序执行时不会因失败停止
+)
+
+// SequenceOptions 多条命令（命令文件）执行相关选项
+type SequenceOptions struct {
+	Parallel      bool   // 同一连接上通过多个会话并发执行命令
+	AggregateExit string // 合并后的退出码计算方式（见 AggregateExit* 常量），为空时使用 first-nonzero
+	IgnoreErrors  bool   // 顺序执行时某条命令失败后继续执行后续命令（first-nonzero 时生效）
+}
+
+// stopOnFailure 顺序执行时某条命令失败后是否停止执行后续命令
+func (o SequenceOptions) stopOnFailure() bool {
+	if o.IgnoreErrors {
+		return false
+	}
+	return o.AggregateExit == "" || o.AggregateExit == AggregateExitFirstNonzero
+}
+
+// ExecuteSequenceWithOptions 在同一个连接上执行多条命令，每条命令使用单独的会话
+// 顺序执行时，first-nonzero（默认）在某条命令失败（退出码非 0 或无法执行）后不再执行后续命令，
+// 指定 IgnoreErrors 或使用 last、max 时继续执行；
+// Parallel 为 true 时并发执行所有命令（同时最多 maxParallelSessions 个会话），命令之间没有先后顺序保证，
+// 全部结束后返回。无论哪种方式，合并后的输出都按命令在列表中的顺序排列，退出码按 AggregateExit 计算
+func (c *Client) ExecuteSequenceWithOptions(commands []string, seqOpts SequenceOptions, opts ExecOptions) (*Result, error) {
+	startTime := time.Now()
+
+	conn, err := c.createSSHConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseConnection(conn)
+
+	if opts.Become && opts.VerifyBecome {
+		if err := c.verifyBecome(conn, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]*Result, len(commands))
+	errs := make([]error, len(commands))
+	if seqOpts.Parallel {
+		semaphore := make(chan struct{}, maxParallelSessions)
+		var wg sync.WaitGroup
+		for i, command := range commands {
+			wg.Add(1)
+			go func(idx int, command string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				results[idx], errs[idx] = c.executeOnConn(conn, command, opts)
+			}(i, command)
+		}
+		wg.Wait()
+	} else {
+		for i, command := range commands {
+			results[i], errs[i] = c.executeOnConn(conn, command, opts)
+			if (errs[i] != nil || results[i].ExitCode != 0) && seqOpts.stopOnFailure() {
+				break
+			}
+		}
+	}
+
+	result := mergeSequenceResults(c.host, commands, results, errs, seqOpts.AggregateExit)
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// mergeSequenceResults 按命令顺序合并每条命令的结果
+// 每条命令的输出前加上 "[序号/总数] $ 命令" 标题；未执行的命令（顺序执行时前面的命令失败）记为警告
+// 退出码按 aggregate 计算，只统计得到了结果的命令（无法打开会话的命令只记录错误）
+func mergeSequenceResults(host string, commands []string, results []*Result, errs []error, aggregate string) *Result {
+	merged := &Result{
+		Host:    host,
+		Command: strings.Join(commands, "; "),
+	}
+
+	var stdout, stderr strings.Builder
+	var resolved []string
+	notRun := 0
+	executed := 0
+	for i, command := range commands {
+		title := fmt.Sprintf("[%d/%d] $ %s\n", i+1, len(commands), command)
+		if errs[i] != nil {
+			fmt.Fprintf(&stderr, "%s%v\n", title, errs[i])
+			if merged.Error == nil {
+				merged.Error = errs[i]
+			}
+			continue
+		}
+		r := results[i]
+		if r == nil {
+			notRun++
+			continue
+		}
+
+		stdout.WriteString(title)
+		writeLines(&stdout, r.Stdout)
+		if r.Stderr != "" {
+			stderr.WriteString(title)
+			writeLines(&stderr, r.Stderr)
+		}
+		switch aggregate {
+		case AggregateExitLast:
+			merged.ExitCode = r.ExitCode
+		case AggregateExitMax:
+			if executed == 0 || r.ExitCode > merged.ExitCode {
+				merged.ExitCode = r.ExitCode
+			}
+		default:
+			if r.ExitCode != 0 && merged.ExitCode == 0 {
+				merged.ExitCode = r.ExitCode
+			}
+		}
+		executed++
+		if r.Error != nil && merged.Error == nil {
+			merged.Error = r.Error
+		}
+		merged.Warnings = append(merged.Warnings, r.Warnings...)
+		if r.ResolvedCommand != "" {
+			resolved = append(resolved, r.ResolvedCommand)
+		}
+	}
+
+	merged.Stdout = stdout.String()
+	merged.Stderr = stderr.String()
+	merged.ResolvedCommand = strings.Join(resolved, "\n")
+	if notRun > 0 {
+		merged.AddWarning("前面的命令失败，后续 %d 条命令未执行", notRun)
+	}
+	return merged
+}
+
+// writeLines 写入输出，不以换行结尾时补充换行，避免与下一条命令的标题连在一起
+func writeLines(b *strings.Builder, output string) {
+	b.WriteString(output)
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		b.WriteByte('\n')
+	}
+}
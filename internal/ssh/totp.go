@@ -0,0 +1,165 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// totpPeriod TOTP 验证码的有效周期（RFC 6238 默认 30 秒）
+	totpPeriod = 30 * time.Second
+	// totpDigits TOTP 验证码位数
+	totpDigits = 6
+)
+
+// TOTPProvider 二次验证码（TOTP/2FA）提供者
+// 用于在 keyboard-interactive 认证中回答验证码提示，支持三种来源：
+// 1. 固定验证码（--totp）
+// 2. TOTP 密钥（--totp-secret），每次连接时根据当前时间生成验证码
+// 3. 交互式输入，输入的验证码在当前 TOTP 周期结束前被所有主机复用，过期后重新提示
+type TOTPProvider struct {
+	code     string // 固定验证码
+	secret   []byte // 解码后的 TOTP 密钥
+	password string // 用于回答密码提示
+
+	mu          sync.Mutex
+	cached      string    // 交互式输入的验证码
+	cachedUntil time.Time // 交互式输入的验证码所在 TOTP 周期的结束时间
+}
+
+// stdinReader 读取交互式输入的验证码，所有提示共用一个 reader，
+// 避免每次新建 bufio.Reader 时把已缓冲但未读取的输入丢掉
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// NewTOTPProvider 创建新的 TOTP 提供者
+// code 为固定验证码，secret 为 Base32 编码的 TOTP 密钥（两者都为空时交互式输入）
+func NewTOTPProvider(code, secret, password string) (*TOTPProvider, error) {
+	provider := &TOTPProvider{
+		code:     strings.TrimSpace(code),
+		password: password,
+	}
+
+	if secret != "" {
+		key, err := decodeTOTPSecret(secret)
+		if err != nil {
+			return nil, fmt.Errorf("解析 TOTP 密钥失败: %w", err)
+		}
+		provider.secret = key
+	}
+
+	return provider, nil
+}
+
+// Code 获取当前可用的验证码
+func (p *TOTPProvider) Code() (string, error) {
+	if len(p.secret) > 0 {
+		return generateTOTP(p.secret, time.Now()), nil
+	}
+	if p.code != "" {
+		return p.code, nil
+	}
+	return p.promptCode()
+}
+
+// promptCode 交互式读取验证码
+// 使用互斥锁保证并发连接时只提示一次，验证码在输入时所在的 TOTP 周期结束前复用
+// （验证码按固定的 30 秒周期轮换，而不是从输入时开始计算有效期）
+func (p *TOTPProvider) promptCode() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached != "" && time.Now().Before(p.cachedUntil) {
+		return p.cached, nil
+	}
+
+	fmt.Fprint(os.Stderr, "请输入二次验证码 (TOTP): ")
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("读取验证码失败: %w", err)
+	}
+
+	code := strings.TrimSpace(line)
+	if code == "" {
+		return "", fmt.Errorf("验证码为空")
+	}
+
+	p.cached = code
+	p.cachedUntil = totpPeriodEnd(time.Now())
+	return code, nil
+}
+
+// totpPeriodEnd 返回 t 所在 TOTP 周期的结束时间
+func totpPeriodEnd(t time.Time) time.Time {
+	return t.Truncate(totpPeriod).Add(totpPeriod)
+}
+
+// Challenge 返回 keyboard-interactive 认证回调
+// 验证码类提示使用 TOTP 回答，密码类提示使用 SSH 密码回答
+func (p *TOTPProvider) Challenge() ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if isPasswordPrompt(question) {
+				answers[i] = p.password
+				continue
+			}
+
+			code, err := p.Code()
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = code
+		}
+		return answers, nil
+	}
+}
+
+// isPasswordPrompt 判断提示是否是密码提示（而不是验证码提示）
+func isPasswordPrompt(question string) bool {
+	q := strings.ToLower(question)
+	if strings.Contains(q, "code") || strings.Contains(q, "token") ||
+		strings.Contains(q, "otp") || strings.Contains(q, "verification") ||
+		strings.Contains(q, "验证码") {
+		return false
+	}
+	return strings.Contains(q, "password") || strings.Contains(q, "密码")
+}
+
+// decodeTOTPSecret 解码 Base32 编码的 TOTP 密钥（忽略空格、大小写和填充）
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
+
+// generateTOTP 根据 RFC 6238 生成指定时间的 TOTP 验证码（HMAC-SHA1，6 位）
+func generateTOTP(key []byte, t time.Time) string {
+	counter := uint64(t.Unix() / int64(totpPeriod/time.Second))
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// 动态截断（RFC 4226）
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
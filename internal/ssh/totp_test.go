@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量（原文为 8 位，取后 6 位）
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := generateTOTP(key, time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("generateTOTP(T=%d) = %q，期望 %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeTOTPSecret(t *testing.T) {
+	// "12345678901234567890" 的 Base32 编码，带空格、小写和填充
+	key, err := decodeTOTPSecret("gezd gnbv gy3t qojq gezd gnbv gy3t qojq==")
+	if err != nil {
+		t.Fatalf("decodeTOTPSecret() 失败: %v", err)
+	}
+	if string(key) != "12345678901234567890" {
+		t.Errorf("decodeTOTPSecret() = %q，期望 %q", key, "12345678901234567890")
+	}
+}
+
+func TestIsPasswordPrompt(t *testing.T) {
+	tests := []struct {
+		question string
+		want     bool
+	}{
+		{"Password: ", true},
+		{"user@host's password: ", true},
+		{"请输入密码: ", true},
+		{"Verification code: ", false},
+		{"One-time password (OTP): ", false},
+		{"Enter token: ", false},
+		{"请输入验证码: ", false},
+		{"Passcode or option (1-3): ", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPasswordPrompt(tt.question); got != tt.want {
+			t.Errorf("isPasswordPrompt(%q) = %v，期望 %v", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPPeriodEnd(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want int64
+	}{
+		{1111111109, 1111111110},
+		{1111111110, 1111111140},
+		{1111111139, 1111111140},
+	}
+
+	for _, tt := range tests {
+		if got := totpPeriodEnd(time.Unix(tt.unix, 0)); got.Unix() != tt.want {
+			t.Errorf("totpPeriodEnd(%d) = %d，期望 %d", tt.unix, got.Unix(), tt.want)
+		}
+	}
+}
+
+func TestPromptCodeCached(t *testing.T) {
+	saved := stdinReader
+	t.Cleanup(func() { stdinReader = saved })
+	stdinReader = bufio.NewReader(strings.NewReader("123456\n654321\n"))
+
+	provider, err := NewTOTPProvider("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := provider.Code()
+	if err != nil {
+		t.Fatalf("Code() 失败: %v", err)
+	}
+	if first != "123456" {
+		t.Fatalf("Code() = %q，期望 %q", first, "123456")
+	}
+	if until := provider.cachedUntil; until.Sub(time.Now()) > totpPeriod || until.Unix()%int64(totpPeriod/time.Second) != 0 {
+		t.Errorf("验证码的缓存截止时间 %v 不是当前 TOTP 周期的结束时间", until)
+	}
+
+	// 周期结束前不重新提示（延长缓存时间，避免测试恰好跨过周期边界）
+	provider.cachedUntil = time.Now().Add(time.Minute)
+	second, err := provider.Code()
+	if err != nil {
+		t.Fatalf("Code() 失败: %v", err)
+	}
+	if second != first {
+		t.Errorf("周期结束前 Code() = %q，期望复用 %q", second, first)
+	}
+
+	// 周期结束后重新提示
+	provider.cachedUntil = time.Now()
+	third, err := provider.Code()
+	if err != nil {
+		t.Fatalf("Code() 失败: %v", err)
+	}
+	if third != "654321" {
+		t.Errorf("周期结束后 Code() = %q，期望重新读取到 %q", third, "654321")
+	}
+}
@@ -0,0 +1,207 @@
+package view
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gossh/internal/ssh"
+)
+
+// htmlReportHost HTML 报告中一台主机的结果
+type htmlReportHost struct {
+	Index      int
+	Host       string
+	Status     string // success、failed、unreachable 或 skipped
+	StatusText string
+	ExitCode   int
+	DurationMs int64
+	Duration   string
+	Command    string
+	Stdout     string
+	Stderr     string
+	Error      string
+	Warnings   []string
+}
+
+// htmlReport HTML 报告的数据
+type htmlReport struct {
+	Title         string
+	Group         string
+	GeneratedAt   string
+	TotalDuration string
+	Total         int
+	Success       int
+	Unreachable   int
+	Failed        int
+	Skipped       int
+	Hosts         []htmlReportHost
+}
+
+// PrintRunResultsHTML 以自包含的 HTML 页面输出 run 命令的执行结果（--output html），写到标准输出
+// 页面包含汇总、可按列排序的主机表格和可折叠的每台主机输出，不依赖任何外部资源，可以直接作为附件分享
+func PrintRunResultsHTML(results []*ssh.Result, totalDuration time.Duration, group string) {
+	if err := writeRunResultsHTML(os.Stdout, results, totalDuration, group); err != nil {
+		fmt.Fprintf(os.Stderr, "生成 HTML 报告失败: %v\n", err)
+	}
+}
+
+// writeRunResultsHTML 将 run 命令的执行结果渲染为 HTML 页面写入 w
+// 非 UTF-8 的输出（二进制内容）中的无效字节替换为 U+FFFD，保证页面编码正确；只包含 --output-stream 指定的输出流
+func writeRunResultsHTML(w io.Writer, results []*ssh.Result, totalDuration time.Duration, group string) error {
+	report := htmlReport{
+		Title:         "gossh 执行报告",
+		Group:         getValueOrDefault(group, "-"),
+		GeneratedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		TotalDuration: totalDuration.Round(time.Millisecond).String(),
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		stdout, stderr := selectOutputStreams(result)
+		host := htmlReportHost{
+			Index:      result.Index,
+			Host:       result.Host,
+			ExitCode:   result.ExitCode,
+			DurationMs: result.Duration.Milliseconds(),
+			Duration:   result.Duration.Round(time.Millisecond).String(),
+			Command:    result.Command,
+			Stdout:     strings.ToValidUTF8(stdout, "\uFFFD"),
+			Stderr:     strings.ToValidUTF8(stderr, "\uFFFD"),
+			Warnings:   result.Warnings,
+		}
+		if result.Error != nil {
+			host.Error = result.Error.Error()
+		}
+		switch {
+		case result.Skipped:
+			host.Status, host.StatusText = "skipped", "跳过"
+			report.Skipped++
+		case result.Error == nil && result.ExitCode == 0:
+			host.Status, host.StatusText = "success", "成功"
+			switch result.Compliance {
+			case ssh.ComplianceCompliant:
+				host.StatusText = "合规"
+			case ssh.ComplianceNonCompliant:
+				host.Status, host.StatusText = "failed", "不合规"
+			}
+			report.Success++
+		case ssh.IsUnreachable(result.Error):
+			host.Status, host.StatusText = "unreachable", "不可达"
+			report.Unreachable++
+		default:
+			host.Status, host.StatusText = "failed", "失败"
+			report.Failed++
+		}
+		report.Total++
+		report.Hosts = append(report.Hosts, host)
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
+
+// htmlReportTemplate HTML 报告模板，样式和排序脚本都内嵌在页面中
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.meta { color: #666; margin-bottom: 16px; }
+.summary span { display: inline-block; margin-right: 16px; font-weight: bold; }
+table { border-collapse: collapse; width: 100%; margin-top: 16px; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; cursor: pointer; user-select: none; }
+th.sorted-asc::after { content: " ▲"; }
+th.sorted-desc::after { content: " ▼"; }
+.success { color: #1a7f37; }
+.failed { color: #cf222e; }
+.unreachable { color: #8250df; }
+.skipped { color: #9a6700; }
+details { margin: 4px 0; }
+summary { cursor: pointer; color: #0969da; }
+pre { background: #f6f8fa; padding: 8px; margin: 4px 0; white-space: pre-wrap; word-break: break-all; max-height: 480px; overflow: auto; }
+pre.stderr { background: #fff5f5; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">分组: {{.Group}} | 生成时间: {{.GeneratedAt}} | 总耗时: {{.TotalDuration}}</div>
+<div class="summary">
+<span>总计: {{.Total}}</span>
+<span class="success">成功: {{.Success}}</span>
+<span class="unreachable">不可达: {{.Unreachable}}</span>
+<span class="failed">失败: {{.Failed}}</span>
+<span class="skipped">跳过: {{.Skipped}}</span>
+</div>
+<table id="results">
+<thead>
+<tr>
+<th data-type="number">序号</th>
+<th>主机</th>
+<th>状态</th>
+<th data-type="number">退出码</th>
+<th data-type="number">耗时</th>
+<th>输出</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Hosts}}
+<tr>
+<td>{{.Index}}</td>
+<td>{{.Host}}</td>
+<td class="{{.Status}}">{{.StatusText}}</td>
+<td>{{.ExitCode}}</td>
+<td data-value="{{.DurationMs}}">{{.Duration}}</td>
+<td>
+{{- if .Command}}<div><code>{{.Command}}</code></div>{{end}}
+{{- if .Error}}<div class="failed">{{.Error}}</div>{{end}}
+{{- range .Warnings}}<div class="skipped">{{.}}</div>{{end}}
+{{- if .Stdout}}<details{{if ne .Status "success"}} open{{end}}><summary>标准输出</summary><pre>{{.Stdout}}</pre></details>{{end}}
+{{- if .Stderr}}<details{{if ne .Status "success"}} open{{end}}><summary>标准错误</summary><pre class="stderr">{{.Stderr}}</pre></details>{{end}}
+</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("results");
+  var headers = table.tHead.rows[0].cells;
+  for (var i = 0; i < headers.length; i++) {
+    headers[i].addEventListener("click", sortBy.bind(null, i));
+  }
+  function cellValue(row, col, numeric) {
+    var cell = row.cells[col];
+    var value = cell.getAttribute("data-value") || cell.textContent.trim();
+    return numeric ? parseFloat(value) || 0 : value;
+  }
+  function sortBy(col) {
+    var th = headers[col];
+    var asc = !th.classList.contains("sorted-asc");
+    var numeric = th.getAttribute("data-type") === "number";
+    var body = table.tBodies[0];
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = cellValue(a, col, numeric), y = cellValue(b, col, numeric);
+      var cmp = numeric ? x - y : String(x).localeCompare(String(y));
+      return asc ? cmp : -cmp;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+    for (var i = 0; i < headers.length; i++) {
+      headers[i].classList.remove("sorted-asc", "sorted-desc");
+    }
+    th.classList.add(asc ? "sorted-asc" : "sorted-desc");
+  }
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,93 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// 进度事件类型（--progress-fd 输出的 event 字段）
+const (
+	progressEventStart     = "start"         // 开始一轮执行（一个进度跟踪器）
+	progressEventHostStart = "host_start"    // 主机开始执行
+	progressEventProgress  = "host_progress" // 主机进入新的阶段
+	progressEventHostDone  = "host_done"     // 主机执行结束（成功、失败或超时）
+	progressEventFinish    = "finish"        // 本轮执行结束
+)
+
+// progressEventWriter 通过 --progress-fd 指定的进度事件输出，为 nil 表示不输出
+var progressEventWriter io.Writer
+
+// SetProgressFD 设置输出进度事件（NDJSON）的文件描述符，fd <= 0 表示不输出
+// 文件描述符需要由调用方（如 GUI、包装脚本）在启动 gossh 时打开，例如 `gossh run ... --progress-fd 3 3>events.ndjson`
+func SetProgressFD(fd int) error {
+	if fd <= 0 {
+		progressEventWriter = nil
+		return nil
+	}
+
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("progress-fd-%d", fd))
+	if file == nil {
+		return fmt.Errorf("--progress-fd %d 不可用", fd)
+	}
+	if _, err := file.Stat(); err != nil {
+		return fmt.Errorf("--progress-fd %d 不可用（文件描述符未打开）: %w", fd, err)
+	}
+	progressEventWriter = file
+	return nil
+}
+
+// progressEvent 一条进度事件，每条事件占一行
+type progressEvent struct {
+	Time    string `json:"time"`
+	Event   string `json:"event"`
+	Title   string `json:"title,omitempty"`   // start、finish：本轮执行的标题
+	Host    string `json:"host,omitempty"`    // host_*：主机地址
+	Phase   string `json:"phase,omitempty"`   // host_progress：当前阶段（如 连接中、执行中）
+	Percent int64  `json:"percent,omitempty"` // host_*：主机的进度（0-100）
+	Status  string `json:"status,omitempty"`  // host_done：success、failed 或 timeout
+	Reason  string `json:"reason,omitempty"`  // host_done：失败原因
+
+	Total     int `json:"total,omitempty"`     // start、finish：主机总数
+	Completed int `json:"completed,omitempty"` // finish：已完成的主机数（包括失败和超时）
+	Failed    int `json:"failed,omitempty"`    // finish：失败的主机数（包括超时）
+}
+
+// progressEventEncoder 把进度事件编码为 NDJSON，写入失败后不再输出（只记录一次调试日志）
+type progressEventEncoder struct {
+	encoder *json.Encoder
+	failed  bool
+}
+
+// newProgressEventEncoder 创建进度事件编码器，没有设置 --progress-fd 时返回 nil
+func newProgressEventEncoder() *progressEventEncoder {
+	if progressEventWriter == nil {
+		return nil
+	}
+	return &progressEventEncoder{encoder: json.NewEncoder(progressEventWriter)}
+}
+
+// emit 输出一条进度事件，调用方需持有进度跟踪器的锁；e 为 nil 时什么也不做
+func (e *progressEventEncoder) emit(event progressEvent) {
+	if e == nil || e.failed {
+		return
+	}
+	event.Time = time.Now().Format(time.RFC3339Nano)
+	if err := e.encoder.Encode(event); err != nil {
+		e.failed = true
+		slog.Debug("输出进度事件失败，不再输出", "error", err)
+	}
+}
+
+// progressPhase 从进度消息中提取阶段名称，例如 "10.0.0.1 (连接中...)" -> "连接中"
+func progressPhase(host, message string) string {
+	phase := strings.TrimPrefix(message, host)
+	phase = strings.TrimSpace(phase)
+	phase = strings.TrimPrefix(phase, "(")
+	phase = strings.TrimSuffix(phase, ")")
+	return strings.TrimSuffix(phase, "...")
+}
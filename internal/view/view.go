@@ -1,21 +1,169 @@
 package view
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"gossh/internal/config"
 	"gossh/internal/executor"
 	"gossh/internal/ssh"
 
 	"github.com/jedib0t/go-pretty/v6/progress"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
+	"golang.org/x/term"
 )
 
+const (
+	// defaultTableWidth 无法检测终端宽度时（如输出重定向到文件）使用的默认表格宽度
+	defaultTableWidth = 115
+	// resultFixedColumnsWidth 结果表格中除错误信息列以外各列（含边框）的估算宽度
+	resultFixedColumnsWidth = 65
+	// configKeyColumnWidth 配置表格参数名列（含边框）的宽度
+	configKeyColumnWidth = 35
+	// minColumnWidth 动态列的最小宽度，避免窄终端下列宽为负数或过窄
+	minColumnWidth = 20
+	// throughputColumnWidth 上传结果表格中速率列（含边框）的估算宽度
+	throughputColumnWidth = 14
+	// pingPhaseColumnsWidth ping 结果表格中三个阶段耗时列（含边框）的估算宽度
+	pingPhaseColumnsWidth = 30
+	// pingBecomeColumnWidth ping 结果表格中 sudo 列（含边框）的估算宽度
+	pingBecomeColumnWidth = 14
+	// timingColumnsWidth run 结果表格中连接耗时、执行耗时两列（含边框）的估算宽度
+	timingColumnsWidth = 24
+	// renderStopTimeout 停止进度条时等待渲染结束的最长时间
+	renderStopTimeout = time.Second
+)
+
+// tableWidthOverride 通过 --table-width 指定的表格宽度（0 表示自动检测）
+var tableWidthOverride int
+
+// SetTableWidth 设置表格宽度，0 表示根据终端宽度自动检测
+func SetTableWidth(width int) {
+	tableWidthOverride = width
+}
+
+// detailedOutputHead、detailedOutputTail 详细输出中每台主机只显示标准输出和标准错误的前、后 N 行（--head、--tail），0 表示不限制
+var (
+	detailedOutputHead int
+	detailedOutputTail int
+)
+
+// SetDetailedOutputLines 设置详细输出中每台主机显示的行数（--head、--tail），同时指定时显示前 head 行和后 tail 行
+// 只影响终端的详细输出，JSON 输出、日志和 webhook 中保留完整输出
+func SetDetailedOutputLines(head, tail int) error {
+	if head < 0 || tail < 0 {
+		return fmt.Errorf("--head 和 --tail 不能为负数")
+	}
+	detailedOutputHead = head
+	detailedOutputTail = tail
+	return nil
+}
+
+// 显示的输出流（--output-stream）
+const (
+	OutputStreamBoth   = "both"   // 标准输出和标准错误（默认）
+	OutputStreamStdout = "stdout" // 只显示标准输出
+	OutputStreamStderr = "stderr" // 只显示标准错误
+)
+
+// outputStream 通过 --output-stream 指定的输出流
+var outputStream = OutputStreamBoth
+
+// SetOutputStream 设置详细输出、JSON 和 HTML 输出中显示的输出流（--output-stream），不支持的值返回错误
+// 执行时始终同时采集两个输出流，日志和 webhook 中保留完整输出，结果表格的状态和错误信息也不受影响
+func SetOutputStream(stream string) error {
+	switch stream {
+	case "":
+		outputStream = OutputStreamBoth
+	case OutputStreamBoth, OutputStreamStdout, OutputStreamStderr:
+		outputStream = stream
+	default:
+		return fmt.Errorf("不支持的 --output-stream %q，可选值: stdout、stderr、both", stream)
+	}
+	return nil
+}
+
+// selectOutputStreams 按 --output-stream 返回要显示的标准输出和标准错误，不显示的输出流为空
+func selectOutputStreams(result *ssh.Result) (stdout, stderr string) {
+	switch outputStream {
+	case OutputStreamStdout:
+		return result.Stdout, ""
+	case OutputStreamStderr:
+		return "", result.Stderr
+	default:
+		return result.Stdout, result.Stderr
+	}
+}
+
+// 进度显示方式（--progress）
+const (
+	ProgressAuto  = "auto"  // 标准输出是终端时显示进度条，否则逐行输出
+	ProgressBar   = "bar"   // 始终显示进度条
+	ProgressPlain = "plain" // 每台主机完成时输出一行，不使用控制字符，适用于 CI 日志
+	ProgressNone  = "none"  // 不显示进度
+)
+
+// progressMode 通过 --progress 指定的进度显示方式
+var progressMode = ProgressAuto
+
+// SetProgressMode 设置进度显示方式，不支持的值返回错误
+func SetProgressMode(mode string) error {
+	switch mode {
+	case "":
+		progressMode = ProgressAuto
+	case ProgressAuto, ProgressBar, ProgressPlain, ProgressNone:
+		progressMode = mode
+	default:
+		return fmt.Errorf("不支持的进度显示方式: %s（支持 auto、bar、plain、none）", mode)
+	}
+	return nil
+}
+
+// resolveProgressMode 确定实际使用的进度显示方式
+// auto 模式下标准输出不是终端（如重定向到文件或管道）时改为逐行输出，避免进度条的控制字符混入输出
+func resolveProgressMode() string {
+	if progressMode != ProgressAuto {
+		return progressMode
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return ProgressBar
+	}
+	return ProgressPlain
+}
+
+// getTableWidth 获取表格可用宽度
+// 优先级：--table-width > 终端宽度 > 默认宽度
+func getTableWidth() int {
+	if tableWidthOverride > 0 {
+		return tableWidthOverride
+	}
+	fd := int(os.Stdout.Fd())
+	if term.IsTerminal(fd) {
+		if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+			return width
+		}
+	}
+	return defaultTableWidth
+}
+
+// errorColumnWidth 根据表格可用宽度计算错误信息列的最大长度
+func errorColumnWidth() int {
+	return max(getTableWidth()-resultFixedColumnsWidth, minColumnWidth)
+}
+
+// configValueWidth 根据表格可用宽度计算配置表格值列的最大宽度
+func configValueWidth() int {
+	return max(getTableWidth()-configKeyColumnWidth, minColumnWidth)
+}
+
 // setupTableStyle 设置表格样式
 // 配置表格的显示样式，包括分隔符、颜色等
 func setupTableStyle(t table.Writer) {
@@ -29,54 +177,119 @@ func setupTableStyle(t table.Writer) {
 
 // PrintRunResults 打印 run 命令的执行结果
 func PrintRunResults(results []*ssh.Result, totalDuration time.Duration, showOutput bool, group string, hosts []executor.Host) {
+	PrintRunResultsWithTiming(results, totalDuration, showOutput, group, hosts, false)
+}
+
+// TrimResultsOutput 去掉每台主机标准输出和标准错误首尾的空白（包括末尾的换行），在打印结果之前调用（--trim-output）
+// 只影响显示和 JSON 输出，日志和 webhook 中保留原始输出
+func TrimResultsOutput(results []*ssh.Result) {
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		result.Stdout = strings.TrimSpace(result.Stdout)
+		result.Stderr = strings.TrimSpace(result.Stderr)
+	}
+}
+
+// PrintRunResultsWithTiming 打印 run 命令的执行结果
+// showTiming 为 true 时在结果表格中分别显示连接耗时和执行耗时，并在摘要中显示平均值，用于区分连接建立慢还是命令本身慢
+func PrintRunResultsWithTiming(results []*ssh.Result, totalDuration time.Duration, showOutput bool, group string, hosts []executor.Host, showTiming bool) {
 	stats := collectRunStatistics(results)
 
-	printRunResultsTable(results, stats, group, hosts)
+	printRunResultsTable(results, stats, group, hosts, showTiming)
 
 	if showOutput {
 		printRunDetailedOutput(results)
 	}
 
 	printRunSummary(results, stats, totalDuration, group)
+	if showTiming {
+		printTimingSummary(results)
+	}
 }
 
 // runStatistics 执行结果统计信息
+// 未成功的主机分为不可达（连接失败、认证失败等，命令没有执行）和失败（命令已执行但返回非零退出码或执行出错）两类
 type runStatistics struct {
-	successCount int
-	failCount    int
-	successHosts []string
-	failHosts    []string
+	successCount     int
+	unreachableCount int
+	failCount        int
+	skippedCount     int
+	successHosts     []string
+	unreachableHosts []string
+	failHosts        []string
+	skippedHosts     []string
+
+	// 输出合规检查（--expect、--expect-file），没有检查任何主机时都为 0
+	compliantCount    int
+	nonCompliantHosts []string
 }
 
 // collectRunStatistics 收集执行结果统计信息
 func collectRunStatistics(results []*ssh.Result) *runStatistics {
 	stats := &runStatistics{
-		successHosts: make([]string, 0),
-		failHosts:    make([]string, 0),
+		successHosts:     make([]string, 0),
+		unreachableHosts: make([]string, 0),
+		failHosts:        make([]string, 0),
+		skippedHosts:     make([]string, 0),
 	}
 
 	for _, result := range results {
-		if result.Error == nil && result.ExitCode == 0 {
+		if result.Skipped {
+			stats.skippedCount++
+			stats.skippedHosts = append(stats.skippedHosts, result.Host)
+		} else if result.Error == nil && result.ExitCode == 0 {
 			stats.successCount++
 			stats.successHosts = append(stats.successHosts, result.Host)
+		} else if ssh.IsUnreachable(result.Error) {
+			stats.unreachableCount++
+			stats.unreachableHosts = append(stats.unreachableHosts, result.Host)
 		} else {
 			stats.failCount++
 			stats.failHosts = append(stats.failHosts, result.Host)
 		}
+
+		switch result.Compliance {
+		case ssh.ComplianceCompliant:
+			stats.compliantCount++
+		case ssh.ComplianceNonCompliant:
+			stats.nonCompliantHosts = append(stats.nonCompliantHosts, result.Host)
+		}
 	}
 
+	// 按地址排序，保证摘要输出与结果的完成顺序无关
+	sort.Strings(stats.successHosts)
+	sort.Strings(stats.unreachableHosts)
+	sort.Strings(stats.failHosts)
+	sort.Strings(stats.skippedHosts)
+	sort.Strings(stats.nonCompliantHosts)
+
 	return stats
 }
 
 // printRunResultsTable 打印执行结果表格
-func printRunResultsTable(results []*ssh.Result, stats *runStatistics, group string, hosts []executor.Host) {
+// showTiming 为 true 时在耗时列之后增加连接耗时和执行耗时两列
+func printRunResultsTable(results []*ssh.Result, stats *runStatistics, group string, hosts []executor.Host, showTiming bool) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 	setupTableStyle(t)
-	t.AppendHeader(table.Row{"主机", "分组", "状态", "退出码", "耗时", "错误信息"})
+	if showTiming {
+		t.AppendHeader(table.Row{"主机", "分组", "状态", "退出码", "耗时", "连接耗时", "执行耗时", "错误信息"})
+	} else {
+		t.AppendHeader(table.Row{"主机", "分组", "状态", "退出码", "耗时", "错误信息"})
+	}
 
+	errWidth := errorColumnWidth()
+	if showTiming {
+		errWidth = max(errWidth-timingColumnsWidth, minColumnWidth)
+	}
 	for _, result := range results {
-		row := buildResultTableRow(result, group, hosts)
+		row := buildResultTableRow(result, group, hosts, errWidth)
+		if showTiming {
+			// 在耗时列之后插入连接耗时和执行耗时
+			row = append(row[:5:5], formatPhaseDuration(result.ConnectDuration), formatPhaseDuration(result.ExecDuration), row[5])
+		}
 		t.AppendRow(row)
 	}
 
@@ -84,22 +297,179 @@ func printRunResultsTable(results []*ssh.Result, stats *runStatistics, group str
 	t.Render()
 }
 
+// printTimingSummary 打印记录了阶段耗时的主机的平均连接耗时和平均执行耗时
+func printTimingSummary(results []*ssh.Result) {
+	var connectTotal, execTotal time.Duration
+	count := 0
+	for _, result := range results {
+		if result.ConnectDuration <= 0 && result.ExecDuration <= 0 {
+			continue
+		}
+		connectTotal += result.ConnectDuration
+		execTotal += result.ExecDuration
+		count++
+	}
+	if count == 0 {
+		return
+	}
+	fmt.Printf("平均连接耗时: %s | 平均执行耗时: %s（%d 台主机）\n\n",
+		(connectTotal / time.Duration(count)).Round(time.Millisecond),
+		(execTotal / time.Duration(count)).Round(time.Millisecond),
+		count)
+}
+
+// PrintSlowestHosts 按耗时从高到低打印最慢的 n 台主机（--top-slow），n 不大于 0 时不打印
+// 跳过的主机没有执行，不参与排序；耗时相同时按主机地址排序，保证输出与结果的完成顺序无关
+func PrintSlowestHosts(results []*ssh.Result, n int) {
+	if n <= 0 {
+		return
+	}
+
+	slowest := make([]*ssh.Result, 0, len(results))
+	for _, result := range results {
+		if result != nil && !result.Skipped {
+			slowest = append(slowest, result)
+		}
+	}
+	if len(slowest) == 0 {
+		return
+	}
+	sort.SliceStable(slowest, func(i, j int) bool {
+		if slowest[i].Duration != slowest[j].Duration {
+			return slowest[i].Duration > slowest[j].Duration
+		}
+		return slowest[i].Host < slowest[j].Host
+	})
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.AppendHeader(table.Row{"排名", "主机", "耗时", "连接耗时", "执行耗时", "状态"})
+	for i, result := range slowest {
+		status := text.Colors{text.FgGreen}.Sprint("成功")
+		if result.Error != nil || result.ExitCode != 0 {
+			status = text.Colors{text.FgRed}.Sprint("失败")
+		}
+		t.AppendRow(table.Row{
+			fmt.Sprint(i + 1),
+			result.Host,
+			text.Colors{text.FgYellow}.Sprint(result.Duration.Round(time.Millisecond).String()),
+			formatPhaseDuration(result.ConnectDuration),
+			formatPhaseDuration(result.ExecDuration),
+			status,
+		})
+	}
+
+	fmt.Println(text.Colors{text.FgCyan, text.Bold}.Sprintf("最慢的 %d 台主机", len(slowest)))
+	t.Render()
+	fmt.Println()
+}
+
+// PrintUploadResults 打印 upload 命令的执行结果
+// 在 run 结果表格的基础上增加每台主机的传输速率，并在摘要中显示总传输量和总体速率
+func PrintUploadResults(results []*ssh.Result, totalDuration time.Duration, showOutput bool, group string, hosts []executor.Host) {
+	stats := collectRunStatistics(results)
+
+	printUploadResultsTable(results, group, hosts)
+
+	if showOutput {
+		printRunDetailedOutput(results)
+	}
+
+	printUploadThroughput(results, totalDuration)
+	printRunSummary(results, stats, totalDuration, group)
+}
+
+// printUploadResultsTable 打印上传结果表格（速率列位于耗时与错误信息之间）
+func printUploadResultsTable(results []*ssh.Result, group string, hosts []executor.Host) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.AppendHeader(table.Row{"主机", "分组", "状态", "退出码", "耗时", "速率", "错误信息"})
+
+	errWidth := max(errorColumnWidth()-throughputColumnWidth, minColumnWidth)
+	for _, result := range results {
+		row := buildResultTableRow(result, group, hosts, errWidth)
+		throughput := ""
+		if result.BytesTransferred > 0 {
+			throughput = formatThroughput(result.BytesTransferred, result.Duration)
+		}
+		row = append(row[:5], throughput, row[5])
+		t.AppendRow(row)
+	}
+
+	fmt.Println()
+	t.Render()
+}
+
+// printUploadThroughput 打印总传输量和总体速率（按总耗时计算，反映并发上传的整体吞吐）
+func printUploadThroughput(results []*ssh.Result, totalDuration time.Duration) {
+	var totalBytes int64
+	for _, result := range results {
+		totalBytes += result.BytesTransferred
+	}
+	if totalBytes == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s | %s\n",
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("总传输: %s", formatBytes(totalBytes))),
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("总体速率: %s", formatThroughput(totalBytes, totalDuration))))
+}
+
+// formatThroughput 格式化传输速率（MB/s）
+func formatThroughput(bytes int64, duration time.Duration) string {
+	if duration <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(bytes)/(1024*1024)/duration.Seconds())
+}
+
+// formatBytes 格式化字节数
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value := float64(bytes)
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		value /= unit
+		if value < unit || suffix == "GB" {
+			return fmt.Sprintf("%.2f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
 // buildResultTableRow 构建结果表格行
-func buildResultTableRow(result *ssh.Result, group string, hosts []executor.Host) table.Row {
+// errWidth 为错误信息列的最大长度
+func buildResultTableRow(result *ssh.Result, group string, hosts []executor.Host, errWidth int) table.Row {
 	var status string
 	var exitCode string
 	var duration string
 	var errorMsg string
 
-	if result.Error == nil && result.ExitCode == 0 {
-		status = text.Colors{text.FgGreen}.Sprint("✓ 成功")
+	if result.Skipped {
+		status = text.Colors{text.FgYellow}.Sprint("- 跳过")
+	} else if result.Error == nil && result.ExitCode == 0 {
+		switch result.Compliance {
+		case ssh.ComplianceCompliant:
+			status = text.Colors{text.FgGreen}.Sprint("✓ 合规")
+		case ssh.ComplianceNonCompliant:
+			status = text.Colors{text.FgRed}.Sprint("✗ 不合规")
+		default:
+			status = text.Colors{text.FgGreen}.Sprint("✓ 成功")
+		}
 	} else {
-		status = text.Colors{text.FgRed}.Sprint("✗ 失败")
+		status = text.Colors{text.FgRed}.Sprint(failureStatus(result.Error))
 		if result.ExitCode != 0 {
 			exitCode = fmt.Sprintf("%d", result.ExitCode)
 		}
 		if result.Error != nil {
-			errorMsg = truncateError(result.Error.Error(), 50)
+			errorMsg = truncateError(result.Error.Error(), errWidth)
 		}
 	}
 
@@ -110,7 +480,7 @@ func buildResultTableRow(result *ssh.Result, group string, hosts []executor.Host
 	// 查找主机所属的分组
 	groups := "-"
 	for _, host := range hosts {
-		if host.Address == result.Host {
+		if host.DisplayName() == result.Host {
 			if len(host.Groups) > 0 {
 				groups = strings.Join(host.Groups, ",")
 			}
@@ -121,14 +491,217 @@ func buildResultTableRow(result *ssh.Result, group string, hosts []executor.Host
 	return table.Row{result.Host, groups, status, exitCode, duration, errorMsg}
 }
 
+// failureStatus 根据错误类型生成失败状态文本
+// 能识别错误分类时显示具体分类（如 "✗ 认证失败"、"✗ 连接超时"），否则显示 "✗ 失败"
+func failureStatus(err error) string {
+	if category := ssh.ErrorCategory(err); category != "" {
+		return "✗ " + category
+	}
+	return "✗ 失败"
+}
+
+// formatPhaseDuration 格式化单个阶段的耗时（ping 的各阶段、run 的连接和执行），未到达或未记录的阶段显示为空
+func formatPhaseDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// pingFailureStatus 根据 ping 失败阶段生成状态文本
+// TCP 阶段失败显示 "✗ 端口不可达"，其余阶段按错误类型显示（如 "✗ 认证失败"）
+func pingFailureStatus(result *ssh.PingResult) string {
+	if result.Phase == ssh.PingPhaseTCP {
+		return "✗ 端口不可达"
+	}
+	return failureStatus(result.Error)
+}
+
 // truncateError 截断过长的错误信息
+// 按字符（而非字节）截断，避免截断中文等多字节字符
 func truncateError(errorMsg string, maxLen int) string {
-	if len(errorMsg) > maxLen {
-		return errorMsg[:maxLen-3] + "..."
+	runes := []rune(errorMsg)
+	if len(runes) > maxLen {
+		return string(runes[:maxLen-3]) + "..."
 	}
 	return errorMsg
 }
 
+// PrintRunResultsJSON 以 JSON 数组输出 run 命令的执行结果，每台主机一个对象，耗时为毫秒数
+// parseJSON 为 true 时把每台主机的标准输出解析为 JSON，解析成功时放在 stdout_json 中（不再输出 stdout 字符串），
+// 解析失败时保留 stdout 并在 stdout_json_error 中说明原因；标准输出为空的主机不解析
+func PrintRunResultsJSON(results []*ssh.Result, parseJSON bool) {
+	type RunInfo struct {
+		Host            string          `json:"host"`
+		Status          string          `json:"status"` // success、failed 或 skipped
+		ExitCode        int             `json:"exit_code"`
+		DurationMs      int64           `json:"duration_ms"`
+		Stdout          string          `json:"stdout,omitempty"`
+		StdoutJSON      json.RawMessage `json:"stdout_json,omitempty"`
+		StdoutJSONError string          `json:"stdout_json_error,omitempty"`
+		Stderr          string          `json:"stderr,omitempty"`
+		Error           *string         `json:"error"`
+		Compliance      string          `json:"compliance,omitempty"` // compliant 或 non-compliant，只在 --expect、--expect-file 时输出
+	}
+
+	runInfos := make([]RunInfo, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		stdout, stderr := selectOutputStreams(result)
+		info := RunInfo{
+			Host:       result.Host,
+			Status:     "failed",
+			ExitCode:   result.ExitCode,
+			DurationMs: result.Duration.Milliseconds(),
+			Stdout:     stdout,
+			Stderr:     stderr,
+			Compliance: result.Compliance,
+		}
+		switch {
+		case result.Skipped:
+			info.Status = "skipped"
+		case result.Error == nil && result.ExitCode == 0:
+			info.Status = "success"
+		}
+		if result.Error != nil {
+			errMsg := result.Error.Error()
+			info.Error = &errMsg
+		}
+		if parseJSON {
+			info.StdoutJSON, info.StdoutJSONError = parseJSONOutput(stdout)
+			if info.StdoutJSON != nil {
+				info.Stdout = ""
+			}
+		}
+		runInfos = append(runInfos, info)
+	}
+
+	jsonData, err := json.MarshalIndent(runInfos, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON 序列化失败: %v\n", err)
+		return
+	}
+
+	fmt.Println(string(jsonData))
+}
+
+// PrintRunResultsGroupedJSON 以 JSON 数组输出 run 命令的执行结果，退出码和标准输出都相同的主机合并为一组
+// 每组一个 {exit_code, output, count, hosts} 对象，按主机数从多到少排列（主机数相同时按第一台主机的顺序），
+// 适合分析整个集群的命令结果（如哪些主机的配置与大多数不同）。parseJSON 为 true 时额外输出解析后的 output_json
+// （或解析失败原因 output_json_error），output 中仍保留原始输出。--output-stream stderr 时按标准错误分组
+func PrintRunResultsGroupedJSON(results []*ssh.Result, parseJSON bool) {
+	type OutputGroup struct {
+		ExitCode        int             `json:"exit_code"`
+		Output          string          `json:"output"`
+		OutputJSON      json.RawMessage `json:"output_json,omitempty"`
+		OutputJSONError string          `json:"output_json_error,omitempty"`
+		Count           int             `json:"count"`
+		Hosts           []string        `json:"hosts"`
+	}
+
+	type groupKey struct {
+		exitCode int
+		output   string
+	}
+	groups := make([]*OutputGroup, 0)
+	groupIndex := make(map[groupKey]*OutputGroup)
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		output := result.Stdout
+		if outputStream == OutputStreamStderr {
+			output = result.Stderr
+		}
+		key := groupKey{exitCode: result.ExitCode, output: output}
+		group, exists := groupIndex[key]
+		if !exists {
+			group = &OutputGroup{ExitCode: result.ExitCode, Output: output, Hosts: []string{}}
+			if parseJSON {
+				group.OutputJSON, group.OutputJSONError = parseJSONOutput(output)
+			}
+			groupIndex[key] = group
+			groups = append(groups, group)
+		}
+		group.Hosts = append(group.Hosts, result.Host)
+		group.Count++
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+
+	jsonData, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON 序列化失败: %v\n", err)
+		return
+	}
+
+	fmt.Println(string(jsonData))
+}
+
+// parseJSONOutput 把命令输出解析为 JSON（--parse-json），输出为空时不解析，返回 nil 和空字符串
+// 解析成功时返回去掉首尾空白的原始 JSON，失败时返回失败原因
+func parseJSONOutput(output string) (json.RawMessage, string) {
+	trimmed := strings.TrimSpace(output)
+	if trimmed == "" {
+		return nil, ""
+	}
+	var parsed any
+	if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+		return nil, fmt.Sprintf("标准输出不是有效的 JSON: %v", err)
+	}
+	return json.RawMessage(trimmed), ""
+}
+
+// printPingJSON 以 JSON 数组输出 ping 结果，耗时为毫秒数，成功时 error 为 null
+// 检查了 sudo 的主机额外包含 become_ok 和 become_error
+func printPingJSON(results []*ssh.PingResult) {
+	type PingInfo struct {
+		Host        string  `json:"host"`
+		Success     bool    `json:"success"`
+		DurationMs  int64   `json:"duration_ms"`
+		Error       *string `json:"error"`
+		BecomeOK    *bool   `json:"become_ok,omitempty"`
+		BecomeError *string `json:"become_error,omitempty"`
+	}
+
+	pingInfos := make([]PingInfo, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		info := PingInfo{
+			Host:       result.Host,
+			Success:    result.Success,
+			DurationMs: result.Duration.Milliseconds(),
+		}
+		if result.Error != nil {
+			errMsg := result.Error.Error()
+			info.Error = &errMsg
+		}
+		if result.BecomeChecked {
+			becomeOK := result.BecomeError == nil
+			info.BecomeOK = &becomeOK
+			if result.BecomeError != nil {
+				errMsg := result.BecomeError.Error()
+				info.BecomeError = &errMsg
+			}
+		}
+		pingInfos = append(pingInfos, info)
+	}
+
+	jsonData, err := json.MarshalIndent(pingInfos, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON 序列化失败: %v\n", err)
+		return
+	}
+
+	fmt.Println(string(jsonData))
+}
+
 // printRunDetailedOutput 打印详细输出信息
 func printRunDetailedOutput(results []*ssh.Result) {
 	fmt.Println("\n" + text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
@@ -136,37 +709,146 @@ func printRunDetailedOutput(results []*ssh.Result) {
 	fmt.Println(text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
 
 	for _, result := range results {
-		printHostDetailedOutput(result)
+		printHostDetailedOutput(result, len(results))
+	}
+}
+
+// NewSequentialOutputPrinter 返回每台主机完成后立即打印其详细输出的函数（--sequential-output），作为执行器的主机结果钩子使用
+// hosts 为执行的主机列表，用于在标题中显示主机的位置；trim 为 true 时打印前去掉输出首尾的空白（--trim-output，不修改结果本身）。
+// 打印通过互斥锁串行执行，第一台主机完成时先打印"详细输出"标题
+func NewSequentialOutputPrinter(hosts []executor.Host, trim bool) func(*ssh.Result) {
+	positions := make(map[string]int, len(hosts))
+	for i, host := range hosts {
+		positions[host.DisplayName()] = i + 1
+	}
+
+	var mu sync.Mutex
+	started := false
+	return func(result *ssh.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !started {
+			started = true
+			fmt.Println("\n" + text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+			fmt.Println(text.Colors{text.FgHiCyan, text.Bold}.Sprint("详细输出（逐台）"))
+			fmt.Println(text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+		}
+
+		printed := *result
+		printed.Index = positions[result.Host]
+		if trim {
+			printed.Stdout = strings.TrimSpace(printed.Stdout)
+			printed.Stderr = strings.TrimSpace(printed.Stderr)
+		}
+		printHostDetailedOutput(&printed, len(hosts))
 	}
 }
 
 // printHostDetailedOutput 打印单个主机的详细输出
-func printHostDetailedOutput(result *ssh.Result) {
-	isSuccess := result.Error == nil && result.ExitCode == 0
+// 标题中显示主机在主机列表中的位置（如 "(37/500)"），便于在长输出中定位和对照 inventory
+func printHostDetailedOutput(result *ssh.Result, total int) {
+	isSuccess := result.Error == nil && result.ExitCode == 0 && result.Compliance != ssh.ComplianceNonCompliant
 	hostColor := getHostColor(isSuccess)
-	fmt.Printf("\n%s\n", hostColor.Sprint("["+result.Host+"]"))
+	if result.Skipped {
+		hostColor = text.Colors{text.FgYellow, text.Bold}
+	}
+	header := hostColor.Sprint("[" + result.Host + "]")
+	if result.Index > 0 {
+		header += " " + text.Colors{text.FgHiBlack}.Sprint(fmt.Sprintf("(%d/%d)", result.Index, total))
+	}
+	fmt.Printf("\n%s\n", header)
+
+	if result.ResolvedCommand != "" {
+		fmt.Printf("%s %s\n",
+			text.Colors{text.FgHiCyan}.Sprint("执行命令:"),
+			text.Colors{text.FgHiBlack}.Sprint(result.ResolvedCommand))
+	}
 
-	if result.Stdout != "" {
+	stdout, stderr := selectOutputStreams(result)
+	if stdout != "" {
 		fmt.Printf("%s\n%s\n",
 			text.Colors{text.FgHiWhite}.Sprint("标准输出:"),
-			result.Stdout)
+			formatLimitedOutput(stdout, text.Colors{}))
 	}
 
-	if result.Stderr != "" {
+	if stderr != "" {
 		fmt.Printf("%s\n%s\n",
 			text.Colors{text.FgRed}.Sprint("标准错误:"),
-			text.Colors{text.FgRed}.Sprint(result.Stderr))
+			formatLimitedOutput(stderr, text.Colors{text.FgRed}))
 	}
 
 	if result.Error != nil {
 		fmt.Printf("%s %s\n",
 			text.Colors{text.FgRed}.Sprint("错误:"),
 			text.Colors{text.FgRed}.Sprint(result.Error.Error()))
+	} else if result.ExitCode == 0 && result.Compliance == ssh.ComplianceNonCompliant {
+		fmt.Printf("%s %s\n",
+			text.Colors{text.FgRed}.Sprint("合规检查:"),
+			text.Colors{text.FgRed}.Sprint("✗ 不合规，输出不符合预期"))
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Println(text.Colors{text.FgYellow}.Sprint("gossh 警告:"))
+		for _, warning := range result.Warnings {
+			fmt.Println(text.Colors{text.FgYellow}.Sprint("  - " + warning))
+		}
 	}
 
 	fmt.Println(text.Colors{text.FgHiBlack}.Sprint(strings.Repeat("-", 80)))
 }
 
+// formatLimitedOutput 按 --head、--tail 截取输出后格式化，在截取处显示省略的行数
+// 未指定 --head、--tail，输出行数不超过限制，或者是二进制输出时与 formatCommandOutput 相同
+func formatLimitedOutput(output string, color text.Colors) string {
+	if (detailedOutputHead == 0 && detailedOutputTail == 0) || ssh.IsBinaryOutput(output) {
+		return formatCommandOutput(output, color)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	omitted := len(lines) - detailedOutputHead - detailedOutputTail
+	if omitted <= 0 {
+		return formatCommandOutput(output, color)
+	}
+
+	var parts []string
+	if detailedOutputHead > 0 {
+		parts = append(parts, color.Sprint(strings.Join(lines[:detailedOutputHead], "\n")))
+	}
+	parts = append(parts, text.Colors{text.FgHiBlack}.Sprint(fmt.Sprintf("...（省略 %d 行，共 %d 行）...", omitted, len(lines))))
+	if detailedOutputTail > 0 {
+		parts = append(parts, color.Sprint(strings.Join(lines[len(lines)-detailedOutputTail:], "\n")))
+	}
+
+	formatted := strings.Join(parts, "\n")
+	if strings.HasSuffix(output, "\n") {
+		formatted += "\n"
+	}
+	return formatted
+}
+
+// binaryPreviewBytes 二进制输出以十六进制预览的最大字节数
+const binaryPreviewBytes = 256
+
+// formatCommandOutput 格式化命令输出用于终端显示
+// 文本输出按 color 着色原样返回；二进制或非 UTF-8 输出替换为 "[二进制输出，N 字节]" 提示和前 256 字节的十六进制预览，避免破坏终端
+func formatCommandOutput(output string, color text.Colors) string {
+	if !ssh.IsBinaryOutput(output) {
+		return color.Sprint(output)
+	}
+
+	notice := text.Colors{text.FgYellow}.Sprint(fmt.Sprintf("[二进制输出，%d 字节]", len(output)))
+	preview := output
+	if len(preview) > binaryPreviewBytes {
+		preview = preview[:binaryPreviewBytes]
+	}
+	dump := strings.TrimRight(hex.Dump([]byte(preview)), "\n")
+	if len(output) > binaryPreviewBytes {
+		dump += fmt.Sprintf("\n...（仅显示前 %d 字节）", binaryPreviewBytes)
+	}
+	return notice + "\n" + text.Colors{text.FgHiBlack}.Sprint(dump)
+}
+
 // getHostColor 根据执行结果获取主机颜色
 func getHostColor(isSuccess bool) text.Colors {
 	if isSuccess {
@@ -181,11 +863,21 @@ func printRunSummary(results []*ssh.Result, stats *runStatistics, totalDuration
 	if groupText == "" {
 		groupText = "-"
 	}
-	fmt.Printf("\n总计: %d 台主机 | %s | %s | %s | 总耗时: %s\n",
+	unreachableText := ""
+	if stats.unreachableCount > 0 {
+		unreachableText = " | " + text.Colors{text.FgMagenta}.Sprint(fmt.Sprintf("不可达: %d", stats.unreachableCount))
+	}
+	skippedText := ""
+	if stats.skippedCount > 0 {
+		skippedText = " | " + text.Colors{text.FgYellow}.Sprint(fmt.Sprintf("跳过: %d", stats.skippedCount))
+	}
+	fmt.Printf("\n总计: %d 台主机 | %s | %s%s | %s%s | 总耗时: %s\n",
 		len(results),
 		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
 		text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("成功: %d", stats.successCount)),
+		unreachableText,
 		text.Colors{text.FgRed}.Sprint(fmt.Sprintf("失败: %d", stats.failCount)),
+		skippedText,
 		totalDuration.Round(time.Millisecond).String())
 
 	if len(stats.successHosts) > 0 {
@@ -194,20 +886,53 @@ func printRunSummary(results []*ssh.Result, stats *runStatistics, totalDuration
 			text.Colors{text.FgGreen}.Sprint(strings.Join(stats.successHosts, ", ")))
 	}
 
+	if len(stats.unreachableHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgMagenta, text.Bold}.Sprint("不可达主机（连接或认证失败）"),
+			text.Colors{text.FgMagenta}.Sprint(strings.Join(stats.unreachableHosts, ", ")))
+	}
+
 	if len(stats.failHosts) > 0 {
 		fmt.Printf("%s: %s\n",
 			text.Colors{text.FgRed, text.Bold}.Sprint("失败主机"),
 			text.Colors{text.FgRed}.Sprint(strings.Join(stats.failHosts, ", ")))
 	}
 
+	if len(stats.skippedHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgYellow, text.Bold}.Sprint("跳过主机"),
+			text.Colors{text.FgYellow}.Sprint(strings.Join(stats.skippedHosts, ", ")))
+	}
+
+	if stats.compliantCount > 0 || len(stats.nonCompliantHosts) > 0 {
+		fmt.Printf("合规检查: %s | %s\n",
+			text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("合规: %d", stats.compliantCount)),
+			text.Colors{text.FgRed}.Sprint(fmt.Sprintf("不合规: %d", len(stats.nonCompliantHosts))))
+	}
+	if len(stats.nonCompliantHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgRed, text.Bold}.Sprint("不合规主机（输出不符合预期或命令执行失败）"),
+			text.Colors{text.FgRed}.Sprint(strings.Join(stats.nonCompliantHosts, ", ")))
+	}
+
 	fmt.Println()
 }
 
 // PrintPingResults 打印 ping 命令的测试结果
 // 显示所有主机的连接测试结果，包括成功/失败状态、延迟和错误信息
-func PrintPingResults(results []*ssh.PingResult, totalDuration time.Duration, group string, hosts []executor.Host) {
+// showPhases 为 true 时额外显示 TCP 连接、SSH 握手、创建会话三个阶段的耗时
+// checkBecome 为 true 时额外显示 sudo 列，连接成功但 sudo 不可用的主机在错误信息中显示原因，并在汇总中单独统计
+// 主机来自多个分组时，在汇总之前按分组显示成功/失败的主机数（见 printPingGroupSummary）
+// format 为 json 时以 JSON 数组输出（见 printPingJSON），不输出表格
+func PrintPingResults(results []*ssh.PingResult, totalDuration time.Duration, group string, hosts []executor.Host, showPhases, checkBecome bool, format string) {
+	if format == "json" {
+		printPingJSON(results)
+		return
+	}
+
 	successCount := 0
 	failCount := 0
+	becomeFailCount := 0
 
 	validResults := make([]*ssh.PingResult, 0, len(results))
 	for _, result := range results {
@@ -216,10 +941,12 @@ func PrintPingResults(results []*ssh.PingResult, totalDuration time.Duration, gr
 		}
 	}
 
-	// 构建主机地址到分组的映射
+	// 构建主机显示名称到分组的映射
 	hostGroupsMap := make(map[string]string)
+	hostGroupList := make(map[string][]string)
 	for _, host := range hosts {
-		key := fmt.Sprintf("%s:%s", host.Address, host.Port)
+		key := host.DisplayName()
+		hostGroupList[key] = host.Groups
 		if len(host.Groups) > 0 {
 			hostGroupsMap[key] = strings.Join(host.Groups, ",")
 		} else {
@@ -232,48 +959,500 @@ func PrintPingResults(results []*ssh.PingResult, totalDuration time.Duration, gr
 		if groupText == "" {
 			groupText = "-"
 		}
-		fmt.Printf("\n总计: 0 台主机 | %s | 总耗时: %s\n\n",
-			text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
-			totalDuration.Round(time.Millisecond).String())
-		return
+		fmt.Printf("\n总计: 0 台主机 | %s | 总耗时: %s\n\n",
+			text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
+			totalDuration.Round(time.Millisecond).String())
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	errWidth := errorColumnWidth()
+	header := table.Row{"主机", "分组", "状态", "延迟"}
+	if showPhases {
+		errWidth = max(errWidth-pingPhaseColumnsWidth, minColumnWidth)
+		header = append(header, "TCP", "握手", "会话")
+	}
+	if checkBecome {
+		errWidth = max(errWidth-pingBecomeColumnWidth, minColumnWidth)
+		header = append(header, "sudo")
+	}
+	t.AppendHeader(append(header, "错误信息"))
+
+	for _, result := range validResults {
+		var status string
+		var duration string
+		var errorMsg string
+		var becomeStatus string
+
+		if result.Success {
+			successCount++
+			status = text.Colors{text.FgGreen}.Sprint("✓ 成功")
+			duration = result.Duration.Round(time.Millisecond).String()
+			if result.BecomeChecked {
+				if result.BecomeError == nil {
+					becomeStatus = text.Colors{text.FgGreen}.Sprint("✓ 可用")
+				} else {
+					becomeFailCount++
+					becomeStatus = text.Colors{text.FgRed}.Sprint(pingBecomeFailureStatus(result.BecomeError))
+					errorMsg = truncateError(result.BecomeError.Error(), errWidth)
+				}
+			}
+		} else {
+			failCount++
+			status = text.Colors{text.FgRed}.Sprint(pingFailureStatus(result))
+			if result.Duration > 0 {
+				duration = result.Duration.Round(time.Millisecond).String()
+			}
+			if result.Error != nil {
+				errorMsg = truncateError(result.Error.Error(), errWidth)
+			}
+		}
+
+		// 查找主机所属的分组
+		groups := "-"
+		if g, ok := hostGroupsMap[result.Host]; ok {
+			groups = g
+		}
+		row := table.Row{result.Host, groups, status, duration}
+		if showPhases {
+			row = append(row,
+				formatPhaseDuration(result.TCPDuration),
+				formatPhaseDuration(result.HandshakeDuration),
+				formatPhaseDuration(result.SessionDuration))
+		}
+		if checkBecome {
+			row = append(row, becomeStatus)
+		}
+		t.AppendRow(append(row, errorMsg))
+	}
+
+	fmt.Println()
+	t.Render()
+
+	printPingGroupSummary(validResults, hostGroupList, checkBecome)
+
+	groupText := group
+	if groupText == "" {
+		groupText = "-"
+	}
+	becomeText := ""
+	if checkBecome {
+		becomeText = " | " + text.Colors{text.FgRed}.Sprint(fmt.Sprintf("sudo 不可用: %d", becomeFailCount))
+	}
+	fmt.Printf("\n总计: %d 台主机 | %s | %s | %s%s | 总耗时: %s\n\n",
+		len(validResults),
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
+		text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("成功: %d", successCount)),
+		text.Colors{text.FgRed}.Sprint(fmt.Sprintf("失败: %d", failCount)),
+		becomeText,
+		totalDuration.Round(time.Millisecond).String())
+}
+
+// pingGroupStats 一个分组的 ping 统计
+type pingGroupStats struct {
+	name       string
+	total      int
+	success    int
+	fail       int
+	becomeFail int
+}
+
+// printPingGroupSummary 按分组统计 ping 结果并以表格显示，便于发现整个分组都无法连接的情况
+// 属于多个分组的主机在每个分组中都计数，不属于任何分组的主机计入 "-"；
+// 只有一个分组（或都不属于分组）时与总计相同，不显示
+func printPingGroupSummary(results []*ssh.PingResult, hostGroups map[string][]string, checkBecome bool) {
+	statsByGroup := make(map[string]*pingGroupStats)
+	for _, result := range results {
+		groups := hostGroups[result.Host]
+		if len(groups) == 0 {
+			groups = []string{"-"}
+		}
+		for _, name := range groups {
+			stats, ok := statsByGroup[name]
+			if !ok {
+				stats = &pingGroupStats{name: name}
+				statsByGroup[name] = stats
+			}
+			stats.total++
+			switch {
+			case !result.Success:
+				stats.fail++
+			case result.BecomeChecked && result.BecomeError != nil:
+				stats.success++
+				stats.becomeFail++
+			default:
+				stats.success++
+			}
+		}
+	}
+	if len(statsByGroup) < 2 {
+		return
+	}
+
+	// 按分组名排序，不属于任何分组的主机排在最后
+	names := make([]string, 0, len(statsByGroup))
+	for name := range statsByGroup {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "-") != (names[j] == "-") {
+			return names[j] == "-"
+		}
+		return names[i] < names[j]
+	})
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	header := table.Row{"分组", "主机数", "成功", "失败"}
+	if checkBecome {
+		header = append(header, "sudo 不可用")
+	}
+	t.AppendHeader(header)
+	for _, name := range names {
+		stats := statsByGroup[name]
+		failText := fmt.Sprint(stats.fail)
+		if stats.fail > 0 {
+			failText = text.Colors{text.FgRed}.Sprint(failText)
+			if stats.success == 0 {
+				failText += text.Colors{text.FgRed, text.Bold}.Sprint("（全部失败）")
+			}
+		}
+		row := table.Row{text.Colors{text.FgCyan}.Sprint(name), fmt.Sprint(stats.total), text.Colors{text.FgGreen}.Sprint(stats.success), failText}
+		if checkBecome {
+			becomeText := fmt.Sprint(stats.becomeFail)
+			if stats.becomeFail > 0 {
+				becomeText = text.Colors{text.FgRed}.Sprint(becomeText)
+			}
+			row = append(row, becomeText)
+		}
+		t.AppendRow(row)
+	}
+
+	fmt.Println()
+	fmt.Println(text.Colors{text.FgCyan, text.Bold}.Sprint("按分组汇总"))
+	t.Render()
+}
+
+// pingBecomeFailureStatus 根据 sudo 检查的错误生成 sudo 列的状态文本
+// 需要密码（sudo -n 失败）与超时分别显示，其余显示 "✗ 不可用"
+func pingBecomeFailureStatus(err error) string {
+	var timeoutErr *ssh.TimeoutError
+	switch {
+	case errors.As(err, &timeoutErr):
+		return "✗ 超时"
+	case errors.Is(err, ssh.ErrSudoPasswordRequired):
+		return "✗ 需要密码"
+	default:
+		return "✗ 不可用"
+	}
+}
+
+// PrintDiffResults 打印 diff 命令的对比结果
+// 差异内容相同的主机会被合并显示，远程文件不存在的主机单独列出
+func PrintDiffResults(results []*ssh.Result, totalDuration time.Duration, group string, hosts []executor.Host) {
+	var identicalHosts, missingHosts, failHosts []string
+	diffOrder := make([]string, 0)
+	diffHosts := make(map[string][]string)
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.AppendHeader(table.Row{"主机", "分组", "状态", "耗时", "错误信息"})
+
+	for _, result := range results {
+		var status string
+		var errorMsg string
+
+		switch {
+		case errors.Is(result.Error, ssh.ErrRemoteFileNotFound):
+			status = text.Colors{text.FgYellow}.Sprint("? 远程缺失")
+			missingHosts = append(missingHosts, result.Host)
+		case result.Error != nil || result.ExitCode != 0:
+			status = text.Colors{text.FgRed}.Sprint(failureStatus(result.Error))
+			if result.Error != nil {
+				errorMsg = truncateError(result.Error.Error(), errorColumnWidth())
+			}
+			failHosts = append(failHosts, result.Host)
+		case result.Stdout == "":
+			status = text.Colors{text.FgGreen}.Sprint("= 相同")
+			identicalHosts = append(identicalHosts, result.Host)
+		default:
+			status = text.Colors{text.FgHiYellow}.Sprint("≠ 有差异")
+			if _, exists := diffHosts[result.Stdout]; !exists {
+				diffOrder = append(diffOrder, result.Stdout)
+			}
+			diffHosts[result.Stdout] = append(diffHosts[result.Stdout], result.Host)
+		}
+
+		var duration string
+		if result.Duration > 0 {
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+
+		// 查找主机所属的分组
+		groups := "-"
+		for _, host := range hosts {
+			if host.DisplayName() == result.Host {
+				if len(host.Groups) > 0 {
+					groups = strings.Join(host.Groups, ",")
+				}
+				break
+			}
+		}
+		t.AppendRow(table.Row{result.Host, groups, status, duration, errorMsg})
+	}
+
+	fmt.Println()
+	t.Render()
+
+	// 打印差异内容（相同差异的主机合并显示）
+	if len(diffOrder) > 0 {
+		fmt.Println("\n" + text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+		fmt.Println(text.Colors{text.FgHiCyan, text.Bold}.Sprint("差异内容"))
+		fmt.Println(text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+
+		for _, diff := range diffOrder {
+			diffHostList := diffHosts[diff]
+			fmt.Printf("\n%s\n", text.Colors{text.FgHiYellow, text.Bold}.Sprint(
+				fmt.Sprintf("[%s] (%d 台主机)", strings.Join(diffHostList, ", "), len(diffHostList))))
+			printUnifiedDiff(diff)
+			fmt.Println(text.Colors{text.FgHiBlack}.Sprint(strings.Repeat("-", 80)))
+		}
+	}
+
+	groupText := group
+	if groupText == "" {
+		groupText = "-"
+	}
+	diffCount := len(results) - len(identicalHosts) - len(missingHosts) - len(failHosts)
+	fmt.Printf("\n总计: %d 台主机 | %s | %s | %s | %s | %s | 总耗时: %s\n",
+		len(results),
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
+		text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("相同: %d", len(identicalHosts))),
+		text.Colors{text.FgHiYellow}.Sprint(fmt.Sprintf("有差异: %d", diffCount)),
+		text.Colors{text.FgYellow}.Sprint(fmt.Sprintf("远程缺失: %d", len(missingHosts))),
+		text.Colors{text.FgRed}.Sprint(fmt.Sprintf("失败: %d", len(failHosts))),
+		totalDuration.Round(time.Millisecond).String())
+
+	if len(missingHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgYellow, text.Bold}.Sprint("远程缺失主机"),
+			text.Colors{text.FgYellow}.Sprint(strings.Join(missingHosts, ", ")))
+	}
+
+	if len(failHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgRed, text.Bold}.Sprint("失败主机"),
+			text.Colors{text.FgRed}.Sprint(strings.Join(failHosts, ", ")))
+	}
+
+	fmt.Println()
+}
+
+// printUnifiedDiff 按行着色打印统一格式的差异内容
+func printUnifiedDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+			fmt.Println(text.Colors{text.Bold}.Sprint(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println(text.Colors{text.FgCyan}.Sprint(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(text.Colors{text.FgGreen}.Sprint(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(text.Colors{text.FgRed}.Sprint(line))
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+// checksumShortLength 结果表格中显示的摘要长度，完整摘要在分组部分显示
+const checksumShortLength = 12
+
+// PrintChecksumResults 打印 checksum 命令的执行结果
+// 校验和相同的主机归为一组，按主机数从多到少显示，便于发现配置漂移；
+// 远程缺失、无权限读取和其他失败分别统计
+func PrintChecksumResults(results []*ssh.Result, totalDuration time.Duration, group string, hosts []executor.Host) {
+	var missingHosts, forbiddenHosts, failHosts []string
+	digestHosts := make(map[string][]string)
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.AppendHeader(table.Row{"主机", "分组", "状态", "校验和", "耗时", "错误信息"})
+
+	for _, result := range results {
+		var status, digest, errorMsg string
+
+		switch {
+		case errors.Is(result.Error, ssh.ErrRemoteFileNotFound):
+			status = text.Colors{text.FgYellow}.Sprint("? 远程缺失")
+			missingHosts = append(missingHosts, result.Host)
+		case errors.Is(result.Error, ssh.ErrRemotePermissionDenied):
+			status = text.Colors{text.FgMagenta}.Sprint("✗ 无权限")
+			forbiddenHosts = append(forbiddenHosts, result.Host)
+		case result.Error != nil || result.ExitCode != 0:
+			status = text.Colors{text.FgRed}.Sprint(failureStatus(result.Error))
+			if result.Error != nil {
+				errorMsg = truncateError(result.Error.Error(), errorColumnWidth())
+			}
+			failHosts = append(failHosts, result.Host)
+		default:
+			status = text.Colors{text.FgGreen}.Sprint("✓ 成功")
+			digest = result.Stdout
+			if len(digest) > checksumShortLength {
+				digest = digest[:checksumShortLength]
+			}
+			digestHosts[result.Stdout] = append(digestHosts[result.Stdout], result.Host)
+		}
+
+		var duration string
+		if result.Duration > 0 {
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+
+		// 查找主机所属的分组
+		groups := "-"
+		for _, host := range hosts {
+			if host.DisplayName() == result.Host {
+				if len(host.Groups) > 0 {
+					groups = strings.Join(host.Groups, ",")
+				}
+				break
+			}
+		}
+		t.AppendRow(table.Row{result.Host, groups, status, digest, duration, errorMsg})
+	}
+
+	fmt.Println()
+	t.Render()
+
+	// 打印校验和分组（主机数多的在前，主机数相同时按摘要排序）
+	digests := make([]string, 0, len(digestHosts))
+	for digest := range digestHosts {
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if len(digestHosts[digests[i]]) != len(digestHosts[digests[j]]) {
+			return len(digestHosts[digests[i]]) > len(digestHosts[digests[j]])
+		}
+		return digests[i] < digests[j]
+	})
+
+	if len(digests) > 0 {
+		fmt.Println("\n" + text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+		fmt.Println(text.Colors{text.FgHiCyan, text.Bold}.Sprint("校验和分组"))
+		fmt.Println(text.Colors{text.FgHiCyan}.Sprint(strings.Repeat("=", 80)))
+
+		for i, digest := range digests {
+			digestHostList := digestHosts[digest]
+			sort.Strings(digestHostList)
+			color := text.Colors{text.FgGreen, text.Bold}
+			if i > 0 {
+				color = text.Colors{text.FgHiYellow, text.Bold}
+			}
+			fmt.Printf("\n%s\n", color.Sprint(fmt.Sprintf("%s (%d 台主机)", digest, len(digestHostList))))
+			fmt.Println(strings.Join(digestHostList, ", "))
+		}
+
+		if len(digests) > 1 {
+			fmt.Printf("\n%s\n", text.Colors{text.FgHiYellow, text.Bold}.Sprint(
+				fmt.Sprintf("检测到 %d 种不同的校验和", len(digests))))
+		}
+	}
+
+	groupText := group
+	if groupText == "" {
+		groupText = "-"
+	}
+	fmt.Printf("\n总计: %d 台主机 | %s | %s | %s | %s | %s | 总耗时: %s\n",
+		len(results),
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
+		text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("校验和种类: %d", len(digests))),
+		text.Colors{text.FgYellow}.Sprint(fmt.Sprintf("远程缺失: %d", len(missingHosts))),
+		text.Colors{text.FgMagenta}.Sprint(fmt.Sprintf("无权限: %d", len(forbiddenHosts))),
+		text.Colors{text.FgRed}.Sprint(fmt.Sprintf("失败: %d", len(failHosts))),
+		totalDuration.Round(time.Millisecond).String())
+
+	if len(missingHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgYellow, text.Bold}.Sprint("远程缺失主机"),
+			text.Colors{text.FgYellow}.Sprint(strings.Join(missingHosts, ", ")))
+	}
+
+	if len(forbiddenHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgMagenta, text.Bold}.Sprint("无权限主机"),
+			text.Colors{text.FgMagenta}.Sprint(strings.Join(forbiddenHosts, ", ")))
+	}
+
+	if len(failHosts) > 0 {
+		fmt.Printf("%s: %s\n",
+			text.Colors{text.FgRed, text.Bold}.Sprint("失败主机"),
+			text.Colors{text.FgRed}.Sprint(strings.Join(failHosts, ", ")))
+	}
+
+	fmt.Println()
+}
+
+// BenchLevel bench 命令中一个并发级别的测试结果
+type BenchLevel struct {
+	Forks           int           // 并发数
+	Total           int           // 主机总数
+	Success         int           // 成功的主机数
+	Duration        time.Duration // 该级别的总耗时
+	AvgHostDuration time.Duration // 单台主机的平均耗时（含等待并发槽位的时间）
+}
+
+// SuccessRate 返回成功率（百分比）
+func (l *BenchLevel) SuccessRate() float64 {
+	if l.Total == 0 {
+		return 0
+	}
+	return float64(l.Success) * 100 / float64(l.Total)
+}
+
+// PrintBenchResults 打印 bench 命令各并发级别的对比结果
+// 成功率最高的级别中总耗时最短的标记为推荐值
+func PrintBenchResults(levels []*BenchLevel, totalDuration time.Duration, group string, hostCount int) {
+	best := -1
+	for i, level := range levels {
+		if best < 0 || level.Success > levels[best].Success ||
+			(level.Success == levels[best].Success && level.Duration < levels[best].Duration) {
+			best = i
+		}
 	}
 
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 	setupTableStyle(t)
-	t.AppendHeader(table.Row{"主机", "分组", "状态", "延迟", "错误信息"})
-
-	for _, result := range validResults {
-		var status string
-		var duration string
-		var errorMsg string
+	t.AppendHeader(table.Row{"并发数", "总耗时", "成功", "失败", "成功率", "平均单机耗时", ""})
 
-		if result.Success {
-			successCount++
-			status = text.Colors{text.FgGreen}.Sprint("✓ 成功")
-			duration = result.Duration.Round(time.Millisecond).String()
-		} else {
-			failCount++
-			status = text.Colors{text.FgRed}.Sprint("✗ 失败")
-			if result.Duration > 0 {
-				duration = result.Duration.Round(time.Millisecond).String()
-			}
-			if result.Error != nil {
-				errorMsg = truncateError(result.Error.Error(), 50)
-			}
+	for i, level := range levels {
+		rate := fmt.Sprintf("%.1f%%", level.SuccessRate())
+		rateColor := text.Colors{text.FgGreen}
+		if level.Success < level.Total {
+			rateColor = text.Colors{text.FgRed}
 		}
 
-		// 查找主机所属的分组
-		groups := "-"
-		for _, host := range hosts {
-			if host.Address == result.Host {
-				if len(host.Groups) > 0 {
-					groups = strings.Join(host.Groups, ",")
-				}
-				break
-			}
+		var mark string
+		if i == best && level.Success > 0 {
+			mark = text.Colors{text.FgHiGreen, text.Bold}.Sprint("★ 推荐")
 		}
-		t.AppendRow(table.Row{result.Host, groups, status, duration, errorMsg})
+
+		t.AppendRow(table.Row{
+			level.Forks,
+			level.Duration.Round(time.Millisecond).String(),
+			level.Success,
+			level.Total - level.Success,
+			rateColor.Sprint(rate),
+			level.AvgHostDuration.Round(time.Millisecond).String(),
+			mark,
+		})
 	}
 
 	fmt.Println()
@@ -283,24 +1462,70 @@ func PrintPingResults(results []*ssh.PingResult, totalDuration time.Duration, gr
 	if groupText == "" {
 		groupText = "-"
 	}
-	fmt.Printf("\n总计: %d 台主机 | %s | %s | %s | 总耗时: %s\n\n",
-		len(validResults),
+	fmt.Printf("\n总计: %d 台主机 | %s | %s | 总耗时: %s\n",
+		hostCount,
 		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("分组: %s", groupText)),
-		text.Colors{text.FgGreen}.Sprint(fmt.Sprintf("成功: %d", successCount)),
-		text.Colors{text.FgRed}.Sprint(fmt.Sprintf("失败: %d", failCount)),
+		text.Colors{text.FgCyan}.Sprint(fmt.Sprintf("测试级别: %d", len(levels))),
 		totalDuration.Round(time.Millisecond).String())
+
+	if best >= 0 && levels[best].Success > 0 {
+		fmt.Printf("%s: --forks %d\n",
+			text.Colors{text.FgHiGreen, text.Bold}.Sprint("推荐并发数"),
+			levels[best].Forks)
+	}
+
+	fmt.Println()
+}
+
+// PrintFetchResults 打印 fetch 命令的下载结果
+// 在上传结果表格的基础上列出下载到本地的文件；destDir 为空表示文件只保存在打包文件中
+func PrintFetchResults(results []*ssh.Result, totalDuration time.Duration, group string, hosts []executor.Host, destDir, archive string) {
+	stats := collectRunStatistics(results)
+
+	printUploadResultsTable(results, group, hosts)
+
+	var files []string
+	for _, result := range results {
+		if result.Error == nil && result.ExitCode == 0 && result.Stdout != "" {
+			files = append(files, result.Stdout)
+		}
+	}
+	sort.Strings(files)
+
+	if destDir != "" && len(files) > 0 {
+		fmt.Printf("\n%s\n", text.Colors{text.FgHiCyan, text.Bold}.Sprint(fmt.Sprintf("已下载文件（%s）", destDir)))
+		for _, file := range files {
+			fmt.Println(file)
+		}
+	}
+	if archive != "" {
+		fmt.Printf("\n%s: %s\n",
+			text.Colors{text.FgHiCyan, text.Bold}.Sprint("打包文件"),
+			text.Colors{text.FgYellow}.Sprint(fmt.Sprintf("%s（%d 个文件）", archive, len(files))))
+	}
+
+	printUploadThroughput(results, totalDuration)
+	printRunSummary(results, stats, totalDuration, group)
 }
 
 // PrintListResults 打印 list 命令的主机列表
 // format: ip（仅IP地址）、full（完整信息）、json（JSON格式）
 // oneLine: 是否一行输出（逗号分隔）
-func PrintListResults(hosts []executor.Host, format string, oneLine bool) {
+// countOnly: 只输出主机数量（忽略 format 和 oneLine）
+func PrintListResults(hosts []executor.Host, format string, oneLine bool, countOnly bool) {
+	if countOnly {
+		fmt.Println(len(hosts))
+		return
+	}
+
 	switch format {
 	case "json":
 		printListJSON(hosts)
-	case "full":
+	case "full", "wide":
 		if oneLine {
 			printListFullOneLine(hosts)
+		} else if format == "wide" {
+			printListWide(hosts)
 		} else {
 			printListFull(hosts)
 		}
@@ -313,9 +1538,10 @@ func PrintListResults(hosts []executor.Host, format string, oneLine bool) {
 	}
 }
 
+// printListIP 每行输出一台主机，使用非默认端口的主机输出 address:port，以区分地址相同、端口不同的主机
 func printListIP(hosts []executor.Host) {
 	for _, host := range hosts {
-		fmt.Println(host.Address)
+		fmt.Println(host.DisplayName())
 	}
 }
 
@@ -325,7 +1551,7 @@ func printListIPOneLine(hosts []executor.Host) {
 	}
 	addresses := make([]string, len(hosts))
 	for i, host := range hosts {
-		addresses[i] = host.Address
+		addresses[i] = host.DisplayName()
 	}
 	fmt.Println(strings.Join(addresses, ","))
 }
@@ -353,7 +1579,7 @@ func printListFull(hosts []executor.Host) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 	setupTableStyle(t)
-	t.AppendHeader(table.Row{"IP地址", "端口", "用户", "SSH Key"})
+	t.AppendHeader(table.Row{"IP地址", "端口", "用户", "SSH Key", "分组"})
 
 	for _, host := range hosts {
 		port := host.Port
@@ -368,7 +1594,44 @@ func printListFull(hosts []executor.Host) {
 		if keyPath == "" {
 			keyPath = "-"
 		}
-		t.AppendRow(table.Row{host.Address, port, user, keyPath})
+		t.AppendRow(table.Row{host.Address, port, user, keyPath, config.FormatHostGroups(host.Groups)})
+	}
+
+	fmt.Println()
+	t.Render()
+	fmt.Printf("\n总计: %d 台主机\n\n", len(hosts))
+}
+
+// printListWide 在 full 格式的基础上显示主机级变量（gossh_timeout、gossh_interpreter、vars 目录中的 become 设置）
+func printListWide(hosts []executor.Host) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.AppendHeader(table.Row{"IP地址", "端口", "用户", "SSH Key", "分组", "连接超时", "解释器", "Become"})
+
+	for _, host := range hosts {
+		port := host.Port
+		if port == "" {
+			port = "22"
+		}
+		timeout := "-"
+		if host.Timeout > 0 {
+			timeout = host.Timeout.String()
+		}
+		become := "-"
+		if host.Become {
+			become = getValueOrDefault(host.BecomeUser, "root")
+		}
+		t.AppendRow(table.Row{
+			host.Address,
+			port,
+			getValueOrDefault(host.User, "-"),
+			getValueOrDefault(host.KeyPath, "-"),
+			config.FormatHostGroups(host.Groups),
+			timeout,
+			getValueOrDefault(host.Interpreter, "-"),
+			become,
+		})
 	}
 
 	fmt.Println()
@@ -378,10 +1641,12 @@ func printListFull(hosts []executor.Host) {
 
 func printListJSON(hosts []executor.Host) {
 	type HostInfo struct {
-		Address string `json:"address"`
-		Port    string `json:"port"`
-		User    string `json:"user,omitempty"`
-		KeyPath string `json:"key_path,omitempty"`
+		Host    string   `json:"host"` // 显示名称，使用非默认端口时为 address:port
+		Address string   `json:"address"`
+		Port    string   `json:"port"`
+		User    string   `json:"user,omitempty"`
+		KeyPath string   `json:"key_path,omitempty"`
+		Groups  []string `json:"groups"`
 	}
 
 	hostInfos := make([]HostInfo, len(hosts))
@@ -391,8 +1656,13 @@ func printListJSON(hosts []executor.Host) {
 			port = "22"
 		}
 		hostInfos[i] = HostInfo{
+			Host:    host.DisplayName(),
 			Address: host.Address,
 			Port:    port,
+			Groups:  host.Groups,
+		}
+		if hostInfos[i].Groups == nil {
+			hostInfos[i].Groups = []string{}
 		}
 		if host.User != "" {
 			hostInfos[i].User = host.User
@@ -413,7 +1683,13 @@ func printListJSON(hosts []executor.Host) {
 
 // PrintGroupResults 打印 list-group 命令的组列表
 // oneLine: 是否一行输出（逗号分隔）
-func PrintGroupResults(groups []string, oneLine bool) {
+// countOnly: 只输出组的数量（忽略 oneLine）
+func PrintGroupResults(groups []string, oneLine bool, countOnly bool) {
+	if countOnly {
+		fmt.Println(len(groups))
+		return
+	}
+
 	if len(groups) == 0 {
 		fmt.Println("未找到任何组")
 		return
@@ -446,6 +1722,10 @@ type ProgressTracker struct {
 	failed         int                          // 失败数量
 	showIndividual bool                         // 是否显示独立 tracker
 	allHosts       map[string]bool              // 所有主机地址集合，用于跟踪未完成的主机
+	renderDone     chan struct{}                // 渲染 goroutine 退出时关闭
+	mode           string                       // 进度显示方式（bar、plain、none），非 bar 时不渲染进度条
+	events         *progressEventEncoder        // --progress-fd 的进度事件输出（与进度显示方式无关），为 nil 表示不输出
+	title          string                       // 标题（用于进度事件）
 	mu             sync.Mutex
 }
 
@@ -490,7 +1770,12 @@ func NewProgressTracker(total int, title string) *ProgressTracker {
 		failed:         0,
 		showIndividual: showIndividual,
 		allHosts:       make(map[string]bool),
+		renderDone:     make(chan struct{}),
+		mode:           resolveProgressMode(),
+		events:         newProgressEventEncoder(),
+		title:          title,
 	}
+	progressTracker.events.emit(progressEvent{Event: progressEventStart, Title: title, Total: total})
 
 	// 如果主机数量较多，创建总体 tracker
 	if !showIndividual {
@@ -503,8 +1788,17 @@ func NewProgressTracker(total int, title string) *ProgressTracker {
 		progressTracker.overallTracker = overallTracker
 	}
 
+	// 逐行输出或不显示进度时不启动渲染，tracker 仍然照常记录状态
+	if progressTracker.mode != ProgressBar {
+		close(progressTracker.renderDone)
+		return progressTracker
+	}
+
 	// 启动渲染
-	go pw.Render()
+	go func() {
+		defer close(progressTracker.renderDone)
+		pw.Render()
+	}()
 
 	return progressTracker
 }
@@ -516,6 +1810,7 @@ func (pt *ProgressTracker) AddTracker(host string) interface{} {
 
 	// 记录所有主机
 	pt.allHosts[host] = false // false 表示未完成
+	pt.events.emit(progressEvent{Event: progressEventHostStart, Host: host})
 
 	// 如果主机数量较多，不创建独立 tracker
 	if !pt.showIndividual {
@@ -538,6 +1833,10 @@ func (pt *ProgressTracker) UpdateTracker(host string, value int64, message strin
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
 
+	if message != "" {
+		pt.events.emit(progressEvent{Event: progressEventProgress, Host: host, Phase: progressPhase(host, message), Percent: value})
+	}
+
 	if pt.showIndividual {
 		// 显示独立 tracker 模式
 		tracker, exists := pt.trackers[host]
@@ -584,6 +1883,8 @@ func (pt *ProgressTracker) MarkTrackerDone(host string) {
 	if _, exists := pt.allHosts[host]; exists && !pt.allHosts[host] {
 		pt.allHosts[host] = true
 		pt.completed++
+		pt.printLine(host, "成功")
+		pt.events.emit(progressEvent{Event: progressEventHostDone, Host: host, Percent: 100, Status: "success"})
 	}
 
 	if pt.showIndividual {
@@ -617,6 +1918,8 @@ func (pt *ProgressTracker) MarkTrackerErrored(host string, reason string) {
 		pt.allHosts[host] = true
 		pt.completed++
 		pt.failed++
+		pt.printLine(host, "失败: "+reason)
+		pt.events.emit(progressEvent{Event: progressEventHostDone, Host: host, Percent: 100, Status: "failed", Reason: reason})
 	}
 
 	if pt.showIndividual {
@@ -641,14 +1944,22 @@ func (pt *ProgressTracker) MarkTrackerErrored(host string, reason string) {
 func (pt *ProgressTracker) Stop() {
 	pt.mu.Lock()
 
-	// 检查未完成的主机并标记为超时
+	// 检查未完成的主机并标记为超时（按地址排序遍历，保证输出顺序稳定）
+	hosts := make([]string, 0, len(pt.allHosts))
+	for host := range pt.allHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
 	timeoutCount := 0
-	for host, completed := range pt.allHosts {
-		if !completed {
+	for _, host := range hosts {
+		if completed := pt.allHosts[host]; !completed {
 			timeoutCount++
 			pt.allHosts[host] = true // 标记为已完成（超时）
 			pt.completed++
 			pt.failed++
+			pt.printLine(host, "超时")
+			pt.events.emit(progressEvent{Event: progressEventHostDone, Host: host, Status: "timeout"})
 
 			if pt.showIndividual {
 				tracker, exists := pt.trackers[host]
@@ -676,14 +1987,43 @@ func (pt *ProgressTracker) Stop() {
 		pt.overallTracker.MarkAsDone()
 	}
 
+	pt.events.emit(progressEvent{Event: progressEventFinish, Title: pt.title, Total: pt.total, Completed: pt.completed, Failed: pt.failed})
+
 	pt.mu.Unlock()
 
-	pt.pw.Stop()
-	time.Sleep(200 * time.Millisecond)
+	pt.waitRenderDone()
+}
+
+// printLine 逐行输出模式下输出一台主机的最终状态（写到 stderr，不影响标准输出中的结果），调用方需持有锁
+func (pt *ProgressTracker) printLine(host, status string) {
+	if pt.mode != ProgressPlain {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[%d/%d] %s %s\n", pt.completed, pt.total, host, status)
+}
+
+// waitRenderDone 停止渲染并等待最后一帧输出完成
+// Render 尚未开始时 pw.Stop 不会生效，因此在等待期间重复调用；最多等待 renderStopTimeout
+func (pt *ProgressTracker) waitRenderDone() {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(renderStopTimeout)
+
+	for {
+		pt.pw.Stop()
+		select {
+		case <-pt.renderDone:
+			return
+		case <-timeout:
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // PrintPingConfig 打印 ping 命令的配置参数
-func PrintPingConfig(inventory, group, user, keyPath, password, port string, concurrency int, timeout time.Duration) {
+// checkBecome 为 true 时显示检查 sudo 使用的目标用户和参数
+func PrintPingConfig(inventory, group, user, keyPath, password, port string, concurrency int, timeout, connectTimeout time.Duration, checkBecome bool, becomeUser, sudoFlags string) {
 	t := createConfigTable(false)
 	data := &ConfigData{
 		Inventory:   inventory,
@@ -693,21 +2033,47 @@ func PrintPingConfig(inventory, group, user, keyPath, password, port string, con
 		Password:    password,
 		Port:        port,
 		Concurrency: concurrency,
+		CheckBecome: checkBecome,
 	}
-	printCommonConfig(t, data)
 
 	// 显示超时时间
 	timeoutValue := timeout
 	if timeoutValue <= 0 {
 		timeoutValue = 30 * time.Second
 	}
-	t.AppendRow(table.Row{"连接超时", text.Colors{text.FgCyan}.Sprint(timeoutValue.String())})
+	connectTimeoutValue := connectTimeout
+	if connectTimeoutValue <= 0 {
+		connectTimeoutValue = executor.DefaultConnectTimeout
+	}
+	data.Timeout = timeoutValue.String()
+	data.ConnectTimeout = min(connectTimeoutValue, timeoutValue).String()
+	if checkBecome {
+		data.BecomeUser = becomeUser
+		data.SudoFlags = sudoFlags
+	}
+	if printConfigJSON(data) {
+		return
+	}
+	printCommonConfig(t, data)
+	t.AppendRow(table.Row{"连接超时", text.Colors{text.FgCyan}.Sprint(min(connectTimeoutValue, timeoutValue).String())})
+	t.AppendRow(table.Row{"测试超时", text.Colors{text.FgCyan}.Sprint(timeoutValue.String())})
+	if checkBecome {
+		becomeUserText := becomeUser
+		if becomeUserText == "" {
+			becomeUserText = "root"
+		}
+		t.AppendRow(table.Row{"检查 sudo", text.Colors{text.FgGreen}.Sprint("是")})
+		t.AppendRow(table.Row{"Become 用户", text.Colors{text.FgCyan}.Sprint(becomeUserText)})
+		if sudoFlags != "" {
+			t.AppendRow(table.Row{"Sudo 参数", text.Colors{text.FgCyan}.Sprint(sudoFlags)})
+		}
+	}
 
 	renderConfigTable(t)
 }
 
 // PrintRunConfig 打印 run 命令的配置参数
-func PrintRunConfig(inventory, group, user, keyPath, password, port, command string, become bool, becomeUser string, concurrency int, showOutput bool) {
+func PrintRunConfig(inventory, group, user, keyPath, password, port, command string, become bool, becomeUser, sudoFlags string, loginShell bool, concurrency int, showOutput bool) {
 	t := createConfigTable(true)
 	data := &ConfigData{
 		Inventory:    inventory,
@@ -722,6 +2088,12 @@ func PrintRunConfig(inventory, group, user, keyPath, password, port, command str
 		BecomeUser:   becomeUser,
 		ShowOutput:   showOutput,
 		NeedWrapText: true,
+
+		SudoFlags:  sudoFlags,
+		LoginShell: loginShell,
+	}
+	if printConfigJSON(data) {
+		return
 	}
 	printCommonConfig(t, data)
 
@@ -730,16 +2102,19 @@ func PrintRunConfig(inventory, group, user, keyPath, password, port, command str
 			return text.WrapHard(s, maxWidth)
 		}
 		commandText := text.Colors{text.FgYellow}.Sprint(command)
-		t.AppendRow(table.Row{"执行命令", wrapText(commandText, 80)})
+		t.AppendRow(table.Row{"执行命令", wrapText(commandText, configValueWidth())})
 	}
 
-	printBecomeConfig(t, become, becomeUser)
+	printBecomeConfig(t, become, becomeUser, sudoFlags)
+	if loginShell {
+		t.AppendRow(table.Row{"登录 Shell", text.Colors{text.FgGreen}.Sprint("是")})
+	}
 	printOutputConfig(t, showOutput)
 	renderConfigTable(t)
 }
 
 // PrintScriptConfig 打印 script 命令的配置参数
-func PrintScriptConfig(inventory, group, user, keyPath, password, port, scriptPath string, become bool, becomeUser string, concurrency int, showOutput bool) {
+func PrintScriptConfig(inventory, group, user, keyPath, password, port, scriptPath string, become bool, becomeUser, sudoFlags string, concurrency int, showOutput bool) {
 	t := createConfigTable(true)
 	data := &ConfigData{
 		Inventory:    inventory,
@@ -754,6 +2129,11 @@ func PrintScriptConfig(inventory, group, user, keyPath, password, port, scriptPa
 		BecomeUser:   becomeUser,
 		ShowOutput:   showOutput,
 		NeedWrapText: true,
+
+		SudoFlags: sudoFlags,
+	}
+	if printConfigJSON(data) {
+		return
 	}
 	printCommonConfig(t, data)
 
@@ -762,16 +2142,16 @@ func PrintScriptConfig(inventory, group, user, keyPath, password, port, scriptPa
 			return text.WrapHard(s, maxWidth)
 		}
 		scriptText := text.Colors{text.FgYellow}.Sprint(scriptPath)
-		t.AppendRow(table.Row{"执行脚本", wrapText(scriptText, 80)})
+		t.AppendRow(table.Row{"执行脚本", wrapText(scriptText, configValueWidth())})
 	}
 
-	printBecomeConfig(t, become, becomeUser)
+	printBecomeConfig(t, become, becomeUser, sudoFlags)
 	printOutputConfig(t, showOutput)
 	renderConfigTable(t)
 }
 
 // PrintUploadConfig 打印 upload 命令的配置参数
-func PrintUploadConfig(inventory, group, user, keyPath, password, port, localPath, remotePath, mode string, concurrency int, showOutput bool, backup bool, force bool) {
+func PrintUploadConfig(inventory, group, user, keyPath, password, port, localPath, remotePath, mode string, concurrency int, showOutput bool, backup bool, force bool, become bool, becomeUser, sudoFlags string) {
 	t := createConfigTable(true)
 	data := &ConfigData{
 		Inventory:    inventory,
@@ -783,9 +2163,18 @@ func PrintUploadConfig(inventory, group, user, keyPath, password, port, localPat
 		Concurrency:  concurrency,
 		LocalPath:    localPath,
 		RemotePath:   remotePath,
-		Mode:         mode,
+		Mode:         getValueOrDefault(mode, "0644"),
+		Become:       become,
+		BecomeUser:   becomeUser,
 		ShowOutput:   showOutput,
 		NeedWrapText: true,
+
+		SudoFlags: sudoFlags,
+		Backup:    backup,
+		Force:     force,
+	}
+	if printConfigJSON(data) {
+		return
 	}
 	printCommonConfig(t, data)
 
@@ -795,27 +2184,173 @@ func PrintUploadConfig(inventory, group, user, keyPath, password, port, localPat
 
 	if localPath != "" {
 		localText := text.Colors{text.FgYellow}.Sprint(localPath)
-		t.AppendRow(table.Row{"本地路径", wrapText(localText, 80)})
+		t.AppendRow(table.Row{"本地路径", wrapText(localText, configValueWidth())})
 	}
 	if remotePath != "" {
 		remoteText := text.Colors{text.FgYellow}.Sprint(remotePath)
-		t.AppendRow(table.Row{"远程路径", wrapText(remoteText, 80)})
+		t.AppendRow(table.Row{"远程路径", wrapText(remoteText, configValueWidth())})
 	}
 	t.AppendRow(table.Row{"文件权限", text.Colors{text.FgCyan}.Sprint(getValueOrDefault(mode, "0644"))})
 	t.AppendRow(table.Row{"备份文件", text.Colors{text.FgCyan}.Sprint(formatBool(backup))})
 	t.AppendRow(table.Row{"强制覆盖", text.Colors{text.FgCyan}.Sprint(formatBool(force))})
 
+	printBecomeConfig(t, become, becomeUser, sudoFlags)
 	printOutputConfig(t, showOutput)
 	renderConfigTable(t)
 }
 
+// PrintDiffConfig 打印 diff 命令的配置参数
+func PrintDiffConfig(inventory, group, user, keyPath, password, port, localPath, remotePath string, concurrency int) {
+	t := createConfigTable(true)
+	data := &ConfigData{
+		Inventory:    inventory,
+		Group:        group,
+		User:         user,
+		KeyPath:      keyPath,
+		Password:     password,
+		Port:         port,
+		Concurrency:  concurrency,
+		LocalPath:    localPath,
+		RemotePath:   remotePath,
+		NeedWrapText: true,
+	}
+	if printConfigJSON(data) {
+		return
+	}
+	printCommonConfig(t, data)
+
+	wrapText := func(s string, maxWidth int) string {
+		return text.WrapHard(s, maxWidth)
+	}
+
+	if localPath != "" {
+		localText := text.Colors{text.FgYellow}.Sprint(localPath)
+		t.AppendRow(table.Row{"本地路径", wrapText(localText, configValueWidth())})
+	}
+	if remotePath != "" {
+		remoteText := text.Colors{text.FgYellow}.Sprint(remotePath)
+		t.AppendRow(table.Row{"远程路径", wrapText(remoteText, configValueWidth())})
+	}
+
+	renderConfigTable(t)
+}
+
+// PrintChecksumConfig 打印 checksum 命令的配置参数
+func PrintChecksumConfig(inventory, group, user, keyPath, password, port, remotePath, algorithm string, concurrency int) {
+	t := createConfigTable(true)
+	data := &ConfigData{
+		Inventory:    inventory,
+		Group:        group,
+		User:         user,
+		KeyPath:      keyPath,
+		Password:     password,
+		Port:         port,
+		Concurrency:  concurrency,
+		RemotePath:   remotePath,
+		NeedWrapText: true,
+
+		Algorithm: getValueOrDefault(algorithm, "sha256"),
+	}
+	if printConfigJSON(data) {
+		return
+	}
+	printCommonConfig(t, data)
+
+	if remotePath != "" {
+		remoteText := text.Colors{text.FgYellow}.Sprint(remotePath)
+		t.AppendRow(table.Row{"远程路径", text.WrapHard(remoteText, configValueWidth())})
+	}
+	t.AppendRow(table.Row{"校验和算法", text.Colors{text.FgCyan}.Sprint(getValueOrDefault(algorithm, "sha256"))})
+
+	renderConfigTable(t)
+}
+
+// PrintBenchConfig 打印 bench 命令的配置参数
+func PrintBenchConfig(inventory, group, user, keyPath, password, port, command string, forksSweep []int) {
+	t := createConfigTable(true)
+	data := &ConfigData{
+		Inventory:    inventory,
+		Group:        group,
+		User:         user,
+		KeyPath:      keyPath,
+		Password:     password,
+		Port:         port,
+		Command:      command,
+		NeedWrapText: true,
+
+		ForksSweep: forksSweep,
+	}
+	if printConfigJSON(data) {
+		return
+	}
+	printCommonConfig(t, data)
+
+	if command != "" {
+		commandText := text.Colors{text.FgYellow}.Sprint(command)
+		t.AppendRow(table.Row{"执行命令", text.WrapHard(commandText, configValueWidth())})
+	}
+
+	sweep := make([]string, len(forksSweep))
+	for i, forks := range forksSweep {
+		sweep[i] = fmt.Sprintf("%d", forks)
+	}
+	t.AppendRow(table.Row{"测试并发数", text.Colors{text.FgCyan}.Sprint(strings.Join(sweep, ", "))})
+
+	renderConfigTable(t)
+}
+
+// PrintFetchConfig 打印 fetch 命令的配置参数
+func PrintFetchConfig(inventory, group, user, keyPath, password, port, remotePath, destDir, archive string, become bool, becomeUser, sudoFlags string, concurrency int) {
+	t := createConfigTable(true)
+	data := &ConfigData{
+		Inventory:    inventory,
+		Group:        group,
+		User:         user,
+		KeyPath:      keyPath,
+		Password:     password,
+		Port:         port,
+		Concurrency:  concurrency,
+		RemotePath:   remotePath,
+		Become:       become,
+		BecomeUser:   becomeUser,
+		NeedWrapText: true,
+
+		SudoFlags: sudoFlags,
+		DestDir:   destDir,
+		Archive:   archive,
+	}
+	if printConfigJSON(data) {
+		return
+	}
+	printCommonConfig(t, data)
+
+	if remotePath != "" {
+		remoteText := text.Colors{text.FgYellow}.Sprint(remotePath)
+		t.AppendRow(table.Row{"远程路径", text.WrapHard(remoteText, configValueWidth())})
+	}
+	if destDir != "" {
+		localText := text.Colors{text.FgYellow}.Sprint(destDir)
+		t.AppendRow(table.Row{"本地目录", text.WrapHard(localText, configValueWidth())})
+	}
+	if archive != "" {
+		archiveText := text.Colors{text.FgYellow}.Sprint(archive)
+		t.AppendRow(table.Row{"打包文件", text.WrapHard(archiveText, configValueWidth())})
+	}
+
+	printBecomeConfig(t, become, becomeUser, sudoFlags)
+	renderConfigTable(t)
+}
+
 // PrintListConfig 打印 list 命令的配置参数
 func PrintListConfig(inventory, group, format string) {
 	t := createConfigTable(false)
 	data := &ConfigData{
 		Inventory: inventory,
 		Group:     group,
-		Format:    format,
+		Format:    getValueOrDefault(format, "ip"),
+	}
+	if printConfigJSON(data) {
+		return
 	}
 	printCommonConfig(t, data)
 
@@ -825,6 +2360,30 @@ func PrintListConfig(inventory, group, format string) {
 	renderConfigTable(t)
 }
 
+// ResolvedConfigItem 合并后的一项配置及其来源（config 命令）
+type ResolvedConfigItem struct {
+	Name   string
+	Value  string
+	Source string // 命令行参数、gossh 配置文件、ansible.cfg、默认值等
+}
+
+// PrintResolvedConfig 打印合并后实际生效的配置及每项的来源（config 命令）
+func PrintResolvedConfig(items []ResolvedConfigItem) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	setupTableStyle(t)
+	t.SetTitle(text.Colors{text.FgHiCyan, text.Bold}.Sprint("实际生效的配置"))
+	t.AppendHeader(table.Row{"参数", "值", "来源"})
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, WidthMax: configValueWidth()},
+	})
+	for _, item := range items {
+		value := getValueOrDefault(item.Value, text.Colors{text.FgHiBlack}.Sprint("(未设置)"))
+		t.AppendRow(table.Row{item.Name, value, text.Colors{text.FgHiBlack}.Sprint(item.Source)})
+	}
+	renderConfigTable(t)
+}
+
 // getValueOrDefault 获取值或默认值
 func getValueOrDefault(value, defaultValue string) string {
 	if value == "" {
@@ -842,24 +2401,85 @@ func formatBool(b bool) string {
 }
 
 // ConfigData 配置数据结构
+// --config-output json 时以 JSON 输出（密码只输出是否设置），各命令没有的参数不输出
 type ConfigData struct {
-	Inventory    string // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
-	Group        string
-	User         string
-	KeyPath      string
-	Password     string
-	Port         string
-	Concurrency  int
-	Command      string
-	ScriptPath   string
-	LocalPath    string
-	RemotePath   string
-	Mode         string
-	Become       bool
-	BecomeUser   string
-	ShowOutput   bool
-	Format       string
-	NeedWrapText bool
+	Inventory    string `json:"inventory,omitempty"` // 主机列表（文件路径、目录路径或逗号分隔的主机列表）
+	Group        string `json:"group,omitempty"`
+	User         string `json:"user,omitempty"`
+	KeyPath      string `json:"key_path,omitempty"`
+	Password     string `json:"-"`
+	Port         string `json:"port"`
+	Concurrency  int    `json:"concurrency,omitempty"`
+	Command      string `json:"command,omitempty"`
+	ScriptPath   string `json:"script_path,omitempty"`
+	LocalPath    string `json:"local_path,omitempty"`
+	RemotePath   string `json:"remote_path,omitempty"`
+	Mode         string `json:"mode,omitempty"`
+	Become       bool   `json:"become,omitempty"`
+	BecomeUser   string `json:"become_user,omitempty"`
+	ShowOutput   bool   `json:"show_output,omitempty"`
+	Format       string `json:"format,omitempty"`
+	NeedWrapText bool   `json:"-"`
+
+	SudoFlags      string `json:"sudo_flags,omitempty"`
+	LoginShell     bool   `json:"login_shell,omitempty"`
+	Backup         bool   `json:"backup,omitempty"`
+	Force          bool   `json:"force,omitempty"`
+	Algorithm      string `json:"algorithm,omitempty"`
+	ForksSweep     []int  `json:"forks_sweep,omitempty"`
+	DestDir        string `json:"dest_dir,omitempty"`
+	Archive        string `json:"archive,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`         // ping 的测试超时
+	ConnectTimeout string `json:"connect_timeout,omitempty"` // ping 的连接超时
+	CheckBecome    bool   `json:"check_become,omitempty"`
+}
+
+// 配置参数的输出格式（--config-output）
+const (
+	ConfigOutputTable = "table" // 表格（默认）
+	ConfigOutputJSON  = "json"  // 一行 JSON 对象，便于包装 gossh 的程序记录实际使用的参数
+)
+
+// configOutput 通过 --config-output 指定的配置参数输出格式
+var configOutput = ConfigOutputTable
+
+// SetConfigOutput 设置执行前打印配置参数的格式（--config-output），不支持的值返回错误
+func SetConfigOutput(format string) error {
+	switch format {
+	case "":
+		configOutput = ConfigOutputTable
+	case ConfigOutputTable, ConfigOutputJSON:
+		configOutput = format
+	default:
+		return fmt.Errorf("不支持的 --config-output %q，可选值: table、json", format)
+	}
+	return nil
+}
+
+// printConfigJSON --config-output json 时以一行 JSON 输出配置参数并返回 true，否则返回 false（由调用方打印表格）
+// 密码只输出是否设置（password_set），未指定端口时输出默认端口 22
+func printConfigJSON(data *ConfigData) bool {
+	if configOutput != ConfigOutputJSON {
+		return false
+	}
+
+	resolved := *data
+	resolved.Port = getValueOrDefault(data.Port, "22")
+	if resolved.Become || resolved.CheckBecome {
+		resolved.BecomeUser = getValueOrDefault(data.BecomeUser, "root")
+	}
+	out := struct {
+		*ConfigData
+		PasswordSet bool `json:"password_set"`
+	}{&resolved, data.Password != ""}
+
+	jsonData, err := json.Marshal(out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON 序列化失败: %v\n", err)
+		return true
+	}
+	fmt.Println(string(jsonData))
+	return true
 }
 
 // printCommonConfig 打印公共配置部分
@@ -877,7 +2497,7 @@ func printCommonConfig(t table.Writer, data *ConfigData) {
 	} else {
 		hostsSource = text.Colors{text.FgHiYellow}.Sprint("ansible.cfg inventory")
 	}
-	t.AppendRow(table.Row{"主机列表来源", wrapText(hostsSource, 80)})
+	t.AppendRow(table.Row{"主机列表来源", wrapText(hostsSource, configValueWidth())})
 
 	if data.Group != "" {
 		t.AppendRow(table.Row{"分组", text.Colors{text.FgCyan}.Sprint(data.Group)})
@@ -886,7 +2506,7 @@ func printCommonConfig(t table.Writer, data *ConfigData) {
 	t.AppendRow(table.Row{"用户名", getValueOrDefault(data.User, text.Colors{text.FgHiBlack}.Sprint("(未设置)"))})
 
 	keyPathValue := getValueOrDefault(data.KeyPath, text.Colors{text.FgHiBlack}.Sprint("(未设置)"))
-	t.AppendRow(table.Row{"SSH 密钥", wrapText(keyPathValue, 80)})
+	t.AppendRow(table.Row{"SSH 密钥", wrapText(keyPathValue, configValueWidth())})
 
 	if data.Password != "" {
 		t.AppendRow(table.Row{"密码", text.Colors{text.FgGreen}.Sprint("***已设置***")})
@@ -902,7 +2522,7 @@ func printCommonConfig(t table.Writer, data *ConfigData) {
 }
 
 // printBecomeConfig 打印 Become 相关配置
-func printBecomeConfig(t table.Writer, become bool, becomeUser string) {
+func printBecomeConfig(t table.Writer, become bool, becomeUser, sudoFlags string) {
 	if become {
 		becomeUserText := becomeUser
 		if becomeUserText == "" {
@@ -910,6 +2530,9 @@ func printBecomeConfig(t table.Writer, become bool, becomeUser string) {
 		}
 		t.AppendRow(table.Row{"Become 模式", text.Colors{text.FgGreen}.Sprint("是")})
 		t.AppendRow(table.Row{"Become 用户", text.Colors{text.FgCyan}.Sprint(becomeUserText)})
+		if sudoFlags != "" {
+			t.AppendRow(table.Row{"Sudo 参数", text.Colors{text.FgCyan}.Sprint(sudoFlags)})
+		}
 	} else {
 		t.AppendRow(table.Row{"Become 模式", text.Colors{text.FgHiBlack}.Sprint("否")})
 	}
@@ -936,7 +2559,7 @@ func createConfigTable(needWrap bool) table.Writer {
 	if needWrap {
 		t.SetColumnConfigs([]table.ColumnConfig{
 			{Number: 1, WidthMax: 15},
-			{Number: 2, WidthMax: 80},
+			{Number: 2, WidthMax: configValueWidth()},
 		})
 	}
 	return t
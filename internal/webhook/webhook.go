@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gossh/internal/ssh"
+)
+
+// DefaultTimeout 发送 webhook 的默认超时时间
+const DefaultTimeout = 10 * time.Second
+
+// Payload 发送到 webhook 的 JSON 内容
+type Payload struct {
+	Command    string       `json:"command"` // gossh 子命令（如 run）
+	Target     string       `json:"target"`  // 执行的命令（或命令映射说明）
+	Group      string       `json:"group,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMs int64        `json:"duration_ms"`
+	Summary    Summary      `json:"summary"`
+	Hosts      []HostResult `json:"hosts"`
+}
+
+// Summary 执行结果汇总
+type Summary struct {
+	Total   int  `json:"total"`
+	Success int  `json:"success"`
+	Failed  int  `json:"failed"`
+	Skipped int  `json:"skipped"`
+	OK      bool `json:"ok"` // 没有失败的主机
+}
+
+// HostResult 单台主机的执行结果
+// 非 UTF-8 的输出（二进制内容）使用 base64 编码，并设置对应的 *_encoding 字段
+type HostResult struct {
+	Host           string   `json:"host"`
+	Status         string   `json:"status"` // success、failed 或 skipped
+	ExitCode       int      `json:"exit_code"`
+	DurationMs     int64    `json:"duration_ms"`
+	Stdout         string   `json:"stdout,omitempty"`
+	StdoutEncoding string   `json:"stdout_encoding,omitempty"`
+	Stderr         string   `json:"stderr,omitempty"`
+	StderrEncoding string   `json:"stderr_encoding,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	ErrorCategory  string   `json:"error_category,omitempty"` // 认证失败、连接超时等，见 ssh.ErrorCategory
+	Warnings       []string `json:"warnings,omitempty"`
+
+	ResolvedCommand string `json:"resolved_command,omitempty"` // 实际执行的命令（--print-command）
+
+	Index int `json:"index,omitempty"` // 主机在主机列表中的序号（从 1 开始）
+}
+
+// NewPayload 根据执行结果构建 webhook 内容
+func NewPayload(command, target, group string, startedAt time.Time, totalDuration time.Duration, results []*ssh.Result) *Payload {
+	payload := &Payload{
+		Command:    command,
+		Target:     target,
+		Group:      group,
+		StartedAt:  startedAt,
+		DurationMs: totalDuration.Milliseconds(),
+		Hosts:      make([]HostResult, 0, len(results)),
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		hr := HostResult{
+			Host:       result.Host,
+			ExitCode:   result.ExitCode,
+			DurationMs: result.Duration.Milliseconds(),
+			Warnings:   result.Warnings,
+
+			ResolvedCommand: result.ResolvedCommand,
+
+			Index: result.Index,
+		}
+		hr.Stdout, hr.StdoutEncoding = encodeOutput(result.Stdout)
+		hr.Stderr, hr.StderrEncoding = encodeOutput(result.Stderr)
+		if result.Error != nil {
+			hr.Error = result.Error.Error()
+			hr.ErrorCategory = ssh.ErrorCategory(result.Error)
+		}
+
+		switch {
+		case result.Skipped:
+			hr.Status = "skipped"
+			payload.Summary.Skipped++
+		case result.Error == nil && result.ExitCode == 0:
+			hr.Status = "success"
+			payload.Summary.Success++
+		default:
+			hr.Status = "failed"
+			payload.Summary.Failed++
+		}
+		payload.Hosts = append(payload.Hosts, hr)
+	}
+
+	payload.Summary.Total = len(payload.Hosts)
+	payload.Summary.OK = payload.Summary.Failed == 0
+	return payload
+}
+
+// encodeOutput 非 UTF-8 的输出使用 base64 编码
+func encodeOutput(output string) (string, string) {
+	if output != "" && !utf8.ValidString(output) {
+		return base64.StdEncoding.EncodeToString([]byte(output)), "base64"
+	}
+	return output, ""
+}
+
+// ValidateURL 校验 webhook 地址（只支持 http 和 https）
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("无效的 --webhook 地址 %q: %w", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的 --webhook 地址 %q: 只支持 http:// 或 https://", rawURL)
+	}
+	return nil
+}
+
+// ParseHeaders 解析 --webhook-header 参数（格式: "Name: Value"）
+func ParseHeaders(headers []string) (http.Header, error) {
+	parsed := make(http.Header)
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("无效的 --webhook-header %q，格式应为 \"Name: Value\"", header)
+		}
+		parsed.Add(name, strings.TrimSpace(value))
+	}
+	return parsed, nil
+}
+
+// Send 以 POST 方式发送 JSON 内容，响应状态码不是 2xx 时返回错误
+func Send(rawURL string, headers http.Header, payload *Payload, timeout time.Duration) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化 webhook 内容失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 webhook 请求失败: %w", err)
+	}
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送 webhook 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook 返回 %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}